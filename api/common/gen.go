@@ -9,6 +9,7 @@ import (
 )
 
 const TOKEN_DURATION = 10 * 24 * time.Hour
+const OPERATOR_TOKEN_DURATION = 12 * time.Hour
 
 type HeaderParam struct {
 	AppId     string
@@ -0,0 +1,195 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"chaos/api/api/common"
+	"chaos/api/codes"
+	"chaos/api/log"
+	"chaos/api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// adjustmentError maps the workflow errors of service.ReviewAdjustment and friends to a response code.
+func adjustmentError(res *common.Response, err error) {
+	switch {
+	case errors.Is(err, service.ErrAdjustmentNotFound):
+		res.Code = codes.CODE_ERR_OBJ_NOT_FOUND
+	case errors.Is(err, service.ErrSelfApproval), errors.Is(err, service.ErrAlreadyReviewed):
+		res.Code = codes.CODE_ERR_SECURITY
+	case errors.Is(err, service.ErrAdjustmentNotPending):
+		res.Code = codes.CODE_ERR_REPEAT
+	case errors.Is(err, service.ErrAdjustmentInvalid), errors.Is(err, service.ErrAssetNotFound),
+		errors.Is(err, service.ErrInsufficientBalance):
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+	default:
+		log.Error("balance adjustment failed", err)
+		res.Code = codes.CODE_ERR_UNKNOWN
+		res.Msg = "adjustment failed"
+		return
+	}
+	res.Msg = err.Error()
+}
+
+// CreateAdjustment files a manual credit or debit; it is posted once enough other operators approve it.
+func CreateAdjustment(c *gin.Context) {
+	var req AdjustmentReq
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "param error"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	adj, err := service.CreateAdjustment(c.GetUint64("operator_id"), service.AdjustmentRequest{
+		MainID:      req.MainID,
+		AssetID:     req.AssetID,
+		Direction:   req.Direction,
+		Amount:      req.Amount,
+		Reason:      req.Reason,
+		EvidenceURL: req.EvidenceURL,
+	})
+	if err != nil {
+		adjustmentError(&res, err)
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	res.Data = adj
+	c.JSON(http.StatusOK, res)
+}
+
+// ReviewAdjustment approves or rejects a pending adjustment made by another operator.
+func ReviewAdjustment(c *gin.Context) {
+	var req AdjustmentReviewReq
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+
+	if err := c.ShouldBindJSON(&req); err != nil || req.ID == 0 {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "param error"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	adj, err := service.ReviewAdjustment(c.GetUint64("operator_id"), req.ID, req.Approve, req.Comment)
+	if err != nil {
+		adjustmentError(&res, err)
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	res.Data = adj
+	c.JSON(http.StatusOK, res)
+}
+
+// CancelAdjustment withdraws a pending adjustment of the calling operator.
+func CancelAdjustment(c *gin.Context) {
+	var req AdjustmentCancelReq
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+
+	if err := c.ShouldBindJSON(&req); err != nil || req.ID == 0 {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "param error"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	adj, err := service.CancelAdjustment(c.GetUint64("operator_id"), req.ID)
+	if err != nil {
+		adjustmentError(&res, err)
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	res.Data = adj
+	c.JSON(http.StatusOK, res)
+}
+
+// ListAdjustments pages through adjustments, optionally of one status.
+func ListAdjustments(c *gin.Context) {
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+
+	pn, err := strconv.Atoi(c.DefaultQuery("pn", "1"))
+	if err != nil || pn < 1 {
+		pn = 1
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	rows, total, err := service.ListAdjustments(c.Query("status"), pn, limit)
+	if err != nil {
+		res.Code = codes.CODE_ERR_UNKNOWN
+		res.Msg = "query adjustments failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	res.Data = gin.H{
+		"results": rows,
+		"pagination": gin.H{
+			"page":  pn,
+			"limit": limit,
+			"total": total,
+		},
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// AuditLog pages through the operator audit trail, optionally of one target row.
+func AuditLog(c *gin.Context) {
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+
+	var targetID uint64
+	if s := c.Query("target_id"); s != "" {
+		var err error
+		if targetID, err = strconv.ParseUint(s, 10, 64); err != nil {
+			res.Code = codes.CODE_ERR_BAD_PARAMS
+			res.Msg = "invalid target_id"
+			c.JSON(http.StatusOK, res)
+			return
+		}
+	}
+	pn, err := strconv.Atoi(c.DefaultQuery("pn", "1"))
+	if err != nil || pn < 1 {
+		pn = 1
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 500 {
+		limit = 50
+	}
+
+	rows, err := service.ListAudit(c.Query("target_table"), targetID, pn, limit)
+	if err != nil {
+		res.Code = codes.CODE_ERR_UNKNOWN
+		res.Msg = "query audit log failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	res.Data = gin.H{
+		"results": rows,
+		"pagination": gin.H{
+			"page":  pn,
+			"limit": limit,
+		},
+	}
+	c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,129 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"chaos/api/api/common"
+	"chaos/api/codes"
+	"chaos/api/log"
+	"chaos/api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateAirdropCampaign builds the merkle tree of a campaign from a csv or the n_airdrop_claim
+// snapshot and stores it as a draft. The returned root is what the distributor is deployed with.
+func CreateAirdropCampaign(c *gin.Context) {
+	var req AirdropCampaignReq
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "param error"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	var entries []service.AirdropEntry
+	var err error
+	switch req.Source {
+	case service.AirdropSourceCSV:
+		entries, err = service.ParseAirdropCSV(strings.NewReader(req.CSV), req.Decimals)
+	case service.AirdropSourceSnapshot:
+		entries, err = service.AirdropSnapshotEntries(req.SnapDecimals, req.Decimals)
+	default:
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "source must be csv or snapshot"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	if err != nil {
+		airdropError(&res, err)
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	campaign, err := service.CreateAirdropCampaign(c.GetUint64("operator_id"), service.AirdropCampaignRequest{
+		Name:     req.Name,
+		ChainID:  req.ChainID,
+		Decimals: req.Decimals,
+		Source:   req.Source,
+		Entries:  entries,
+	})
+	if err != nil {
+		airdropError(&res, err)
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	res.Data = campaign
+	c.JSON(http.StatusOK, res)
+}
+
+// AttachAirdropContract opens the claims of a campaign on its deployed distributor.
+func AttachAirdropContract(c *gin.Context) {
+	var req AirdropAttachReq
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+
+	if err := c.ShouldBindJSON(&req); err != nil || req.Name == "" {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "param error"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Second)
+	defer cancel()
+	campaign, err := service.AttachAirdropContract(ctx, c.GetUint64("operator_id"), req.Name, req.Contract, req.StartBlock)
+	if err != nil {
+		airdropError(&res, err)
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	res.Data = campaign
+	c.JSON(http.StatusOK, res)
+}
+
+// AirdropCampaigns lists the campaigns, newest first.
+func AirdropCampaigns(c *gin.Context) {
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+
+	campaigns, err := service.ListAirdropCampaigns()
+	if err != nil {
+		log.Error("list airdrop campaigns failed", err)
+		res.Code = codes.CODE_ERR_UNKNOWN
+		res.Msg = "query failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	res.Data = campaigns
+	c.JSON(http.StatusOK, res)
+}
+
+// airdropError maps the errors of the airdrop campaign service to a response code.
+func airdropError(res *common.Response, err error) {
+	switch {
+	case errors.Is(err, service.ErrCampaignInvalid):
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+	case errors.Is(err, service.ErrCampaignNotFound):
+		res.Code = codes.CODE_ERR_OBJ_NOT_FOUND
+	default:
+		log.Error("airdrop campaign failed", err)
+		res.Code = codes.CODE_ERR_UNKNOWN
+		res.Msg = "airdrop campaign failed"
+		return
+	}
+	res.Msg = err.Error()
+}
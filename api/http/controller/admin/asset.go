@@ -0,0 +1,78 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"chaos/api/api/common"
+	"chaos/api/codes"
+	"chaos/api/log"
+	"chaos/api/service"
+	"chaos/api/tools"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DiscoverAsset reads name, symbol and decimals of an ERC20 contract and registers it in t_asset,
+// or with dry_run only shows what the contract reports.
+func DiscoverAsset(c *gin.Context) {
+	var req AssetDiscoverReq
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+
+	if err := c.ShouldBindJSON(&req); err != nil || req.ChainID == 0 || req.Contract == "" {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "param error"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Second)
+	defer cancel()
+	if req.DryRun {
+		md, err := service.DiscoverERC20(ctx, req.ChainID, req.Contract)
+		if err != nil {
+			assetError(&res, err)
+			c.JSON(http.StatusOK, res)
+			return
+		}
+		res.Data = md
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	asset, err := service.RegisterERC20Asset(ctx, c.GetUint64("operator_id"), service.AssetRegistration{
+		ChainID:         req.ChainID,
+		Contract:        req.Contract,
+		LedgerDecimals:  req.LedgerDecimals,
+		DisplayDecimals: req.DisplayDecimals,
+	})
+	if err != nil {
+		assetError(&res, err)
+		res.Data = asset // the registered one when it exists
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	res.Data = asset
+	c.JSON(http.StatusOK, res)
+}
+
+// assetError maps the errors of the asset registry to a response code.
+func assetError(res *common.Response, err error) {
+	switch {
+	case errors.Is(err, service.ErrAssetExists):
+		res.Code = codes.CODE_ERR_REPEAT
+	case errors.Is(err, service.ErrAssetInvalid), errors.Is(err, tools.ErrNotERC20), errors.Is(err, tools.ErrUnknownChain):
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+	default:
+		log.Error("asset discovery failed", err)
+		res.Code = codes.CODE_ERR_UNKNOWN
+		res.Msg = "asset discovery failed"
+		return
+	}
+	res.Msg = err.Error()
+}
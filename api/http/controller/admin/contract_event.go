@@ -0,0 +1,86 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"chaos/api/api/common"
+	"chaos/api/codes"
+	"chaos/api/log"
+	"chaos/api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ContractEvents pages through the recorded TopupLogic owner events; status=00 lists the ones
+// nobody acknowledged yet.
+func ContractEvents(c *gin.Context) {
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+
+	pn, err := strconv.Atoi(c.DefaultQuery("pn", "1"))
+	if err != nil || pn < 1 {
+		pn = 1
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	rows, total, err := service.ListContractEvents(c.Query("status"), pn, limit)
+	if err != nil {
+		res.Code = codes.CODE_ERR_UNKNOWN
+		res.Msg = "query contract events failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	res.Data = gin.H{
+		"results": rows,
+		"pagination": gin.H{
+			"page":  pn,
+			"limit": limit,
+			"total": total,
+		},
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// AckContractEvent records that an operator looked at an owner event.
+func AckContractEvent(c *gin.Context) {
+	var req ContractEventAckReq
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+
+	if err := c.ShouldBindJSON(&req); err != nil || req.ID == 0 {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "param error"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	event, err := service.AckContractEvent(c.GetUint64("operator_id"), req.ID, req.Comment)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrContractEventNotFound):
+			res.Code = codes.CODE_ERR_OBJ_NOT_FOUND
+			res.Msg = err.Error()
+		case errors.Is(err, service.ErrContractEventAcked):
+			res.Code = codes.CODE_ERR_REPEAT
+			res.Msg = err.Error()
+		default:
+			log.Error("ack contract event failed", err)
+			res.Code = codes.CODE_ERR_UNKNOWN
+			res.Msg = "ack contract event failed"
+		}
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	res.Data = event
+	c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,126 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"chaos/api/api/common"
+	"chaos/api/codes"
+	"chaos/api/log"
+	"chaos/api/model"
+	"chaos/api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateFeeSchedule adds a new version of the fee schedule of a game, catalog or the platform default.
+func CreateFeeSchedule(c *gin.Context) {
+	var req FeeScheduleReq
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "param error"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	schedule := model.FeeSchedule{
+		GameID:       req.GameID,
+		Catalog:      req.Catalog,
+		PlatformBps:  req.PlatformBps,
+		DeveloperBps: req.DeveloperBps,
+		PrizePoolBps: req.PrizePoolBps,
+		Remark:       req.Remark,
+	}
+	if req.EffectiveFrom > 0 {
+		schedule.EffectiveFrom = time.Unix(req.EffectiveFrom, 0)
+	}
+	created, err := service.CreateFeeSchedule(c.GetUint64("operator_id"), schedule)
+	if err != nil {
+		if errors.Is(err, service.ErrFeeScheduleInvalid) {
+			res.Code = codes.CODE_ERR_BAD_PARAMS
+			res.Msg = err.Error()
+		} else {
+			log.Error("create fee schedule failed", err)
+			res.Code = codes.CODE_ERR_UNKNOWN
+			res.Msg = "create fee schedule failed"
+		}
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	res.Data = created
+	c.JSON(http.StatusOK, res)
+}
+
+// FeeSchedules returns the schedule history of a game, game_id 0 for the platform default.
+func FeeSchedules(c *gin.Context) {
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+
+	gameID, err := strconv.ParseUint(c.DefaultQuery("game_id", "0"), 10, 64)
+	if err != nil {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "invalid game_id"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	rows, err := service.ListFeeSchedules(gameID)
+	if err != nil {
+		res.Code = codes.CODE_ERR_UNKNOWN
+		res.Msg = "query fee schedules failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	res.Data = gin.H{
+		"results": rows,
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// RecomputeSpends shows how the spends of a session (session_id) or one spend (flow_id) split
+// under the schedules in force at the time, next to what was recorded.
+func RecomputeSpends(c *gin.Context) {
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+
+	sessionID := c.Query("session_id")
+	var flowID uint64
+	if s := c.Query("flow_id"); s != "" {
+		var err error
+		if flowID, err = strconv.ParseUint(s, 10, 64); err != nil {
+			res.Code = codes.CODE_ERR_BAD_PARAMS
+			res.Msg = "invalid flow_id"
+			c.JSON(http.StatusOK, res)
+			return
+		}
+	}
+	if sessionID == "" && flowID == 0 {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "session_id or flow_id required"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	results, err := service.RecomputeSpends(sessionID, flowID)
+	if err != nil {
+		log.Error("recompute spends failed", sessionID, flowID, err)
+		res.Code = codes.CODE_ERR_UNKNOWN
+		res.Msg = "recompute failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	res.Data = gin.H{
+		"results": results,
+	}
+	c.JSON(http.StatusOK, res)
+}
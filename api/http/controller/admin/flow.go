@@ -0,0 +1,60 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"chaos/api/api/common"
+	"chaos/api/codes"
+	"chaos/api/log"
+	"chaos/api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FlowRefund gives a spend or a stuck freeze back to the user, without client or age limits.
+func FlowRefund(c *gin.Context) {
+	var req RefundReq
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+
+	if err := c.ShouldBindJSON(&req); err != nil || req.FlowID == 0 {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "param error"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	operatorID := c.GetUint64("operator_id")
+	refund, err := service.RefundFlow(service.RefundRequest{
+		FlowID: req.FlowID,
+		Remark: fmt.Sprintf("operator %d: %s", operatorID, req.Reason),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrFlowNotFound):
+			res.Code = codes.CODE_ERR_OBJ_NOT_FOUND
+		case errors.Is(err, service.ErrFlowNotRefundable):
+			res.Code = codes.CODE_ERR_BAD_PARAMS
+		default:
+			log.Error("refund flow failed", req.FlowID, err)
+			res.Code = codes.CODE_ERR_UNKNOWN
+		}
+		res.Msg = err.Error()
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	log.Infof("operator %d refunded flow %d as %d", operatorID, req.FlowID, refund.ID)
+	res.Data = gin.H{
+		"operation_id":   refund.ID,
+		"operation_type": "refund",
+		"ref_flow_id":    refund.RefFlowID,
+		"amount":         refund.Amount,
+	}
+	c.JSON(http.StatusOK, res)
+}
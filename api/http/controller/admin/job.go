@@ -0,0 +1,86 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"chaos/api/api/common"
+	"chaos/api/codes"
+	"chaos/api/log"
+	"chaos/api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Jobs pages through the durable queue jobs; queue=topup_tx&status=30 lists the dead-lettered
+// tx checks.
+func Jobs(c *gin.Context) {
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+
+	pn, err := strconv.Atoi(c.DefaultQuery("pn", "1"))
+	if err != nil || pn < 1 {
+		pn = 1
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	rows, total, err := service.ListJobs(c.Query("queue"), c.Query("status"), pn, limit)
+	if err != nil {
+		res.Code = codes.CODE_ERR_UNKNOWN
+		res.Msg = "query jobs failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	res.Data = gin.H{
+		"results": rows,
+		"pagination": gin.H{
+			"page":  pn,
+			"limit": limit,
+			"total": total,
+		},
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// RetryJob requeues a dead-lettered job.
+func RetryJob(c *gin.Context) {
+	var req JobRetryReq
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+
+	if err := c.ShouldBindJSON(&req); err != nil || req.ID == 0 {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "param error"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	job, err := service.RetryJob(c.GetUint64("operator_id"), req.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrJobNotFound):
+			res.Code = codes.CODE_ERR_OBJ_NOT_FOUND
+			res.Msg = err.Error()
+		case errors.Is(err, service.ErrJobNotDead):
+			res.Code = codes.CODE_ERR_BAD_PARAMS
+			res.Msg = err.Error()
+		default:
+			log.Error("retry job failed", err)
+			res.Code = codes.CODE_ERR_UNKNOWN
+			res.Msg = "retry job failed"
+		}
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	res.Data = job
+	c.JSON(http.StatusOK, res)
+}
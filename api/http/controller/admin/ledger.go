@@ -0,0 +1,91 @@
+package admin
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"chaos/api/api/common"
+	"chaos/api/codes"
+	"chaos/api/log"
+	"chaos/api/model"
+	"chaos/api/service"
+	"chaos/api/system"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LedgerCheck rebuilds every balance from the journal and reports drift.
+func LedgerCheck(c *gin.Context) {
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+
+	check, report, err := service.CheckLedger()
+	if err != nil {
+		log.Error("ledger check failed", err)
+		res.Code = codes.CODE_ERR_UNKNOWN
+		res.Msg = "ledger check failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	res.Data = gin.H{
+		"check_id":           check.ID,
+		"checked_accounts":   report.CheckedAccounts,
+		"drifts":             report.Drifts,
+		"unbalanced_entries": report.UnbalancedEntries,
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// LedgerCheckList returns the latest checker runs.
+func LedgerCheckList(c *gin.Context) {
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 || limit > 200 {
+		limit = 20
+	}
+
+	var checks []model.LedgerCheck
+	err = system.GetDb().Model(&model.LedgerCheck{}).
+		Select("id, checked_accounts, drift_count, unbalanced_entries, add_time").
+		Order("id desc").Limit(limit).Find(&checks).Error
+	if err != nil {
+		res.Code = codes.CODE_ERR_UNKNOWN
+		res.Msg = "query ledger check failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	res.Data = gin.H{
+		"results": checks,
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// LedgerOpen journals opening entries for balances created before the journal existed.
+func LedgerOpen(c *gin.Context) {
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+
+	opened, err := service.OpenLedgerBalances()
+	if err != nil {
+		log.Error("open ledger balances failed", err)
+		res.Code = codes.CODE_ERR_UNKNOWN
+		res.Msg = "open ledger balances failed"
+	}
+
+	log.Infof("operator %v opened %d ledger balances", c.GetUint64("operator_id"), opened)
+	res.Data = gin.H{
+		"opened": opened,
+	}
+	c.JSON(http.StatusOK, res)
+}
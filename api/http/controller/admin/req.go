@@ -0,0 +1,89 @@
+package admin
+
+import (
+	"chaos/api/chain"
+
+	"github.com/shopspring/decimal"
+)
+
+type RefundReq struct {
+	FlowID uint64 `json:"flow_id"`
+	Reason string `json:"reason"`
+}
+
+type SnapshotReq struct {
+	Date string `json:"date"` // YYYY-MM-DD
+}
+
+type AdjustmentReq struct {
+	MainID      uint64          `json:"main_id"`
+	AssetID     uint64          `json:"asset_id"`
+	Direction   string          `json:"direction"` // credit or debit
+	Amount      decimal.Decimal `json:"amount"`
+	Reason      string          `json:"reason"`
+	EvidenceURL string          `json:"evidence_url"`
+}
+
+type AdjustmentReviewReq struct {
+	ID      uint64 `json:"id"`
+	Approve bool   `json:"approve"`
+	Comment string `json:"comment"`
+}
+
+type AdjustmentCancelReq struct {
+	ID uint64 `json:"id"`
+}
+
+type FeeScheduleReq struct {
+	GameID        uint64 `json:"game_id"`
+	Catalog       string `json:"catalog"`
+	PlatformBps   int    `json:"platform_bps"`
+	DeveloperBps  int    `json:"developer_bps"`
+	PrizePoolBps  int    `json:"prize_pool_bps"`
+	EffectiveFrom int64  `json:"effective_from"` // unix seconds, 0 for now
+	Remark        string `json:"remark"`
+}
+
+type ContractEventAckReq struct {
+	ID      uint64 `json:"id"`
+	Comment string `json:"comment"`
+}
+
+type JobRetryReq struct {
+	ID uint64 `json:"id"`
+}
+
+type TxRecordReq struct {
+	ChainID uint64 `json:"chain_id"`
+	Op      int    `json:"op"` // flow op the tx settles, 0 recharge .. 4 revoke
+	TxHash  string `json:"tx_hash"`
+}
+
+type TxReplayReq struct {
+	Op      int              `json:"op"`
+	TxHash  string           `json:"tx_hash"`
+	Fixture *chain.TxFixture `json:"fixture"` // as returned by /admin/tx/record
+}
+
+type AirdropCampaignReq struct {
+	Name         string `json:"name"`
+	ChainID      uint64 `json:"chain_id"`
+	Decimals     int32  `json:"decimals"` // of the airdropped token
+	Source       string `json:"source"`   // csv or snapshot
+	CSV          string `json:"csv"`      // wallet,amount rows in whole tokens, for source csv
+	SnapDecimals int32  `json:"snap_decimals"`
+}
+
+type AirdropAttachReq struct {
+	Name       string `json:"name"`
+	Contract   string `json:"contract"`
+	StartBlock uint64 `json:"start_block"` // block the distributor was deployed in
+}
+
+type AssetDiscoverReq struct {
+	ChainID         uint64 `json:"chain_id"`
+	Contract        string `json:"contract"`
+	LedgerDecimals  int32  `json:"ledger_decimals"` // 0 for the default
+	DisplayDecimals int32  `json:"display_decimals"`
+	DryRun          bool   `json:"dry_run"` // only read the token, register nothing
+}
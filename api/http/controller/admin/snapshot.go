@@ -0,0 +1,180 @@
+package admin
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"chaos/api/api/common"
+	"chaos/api/codes"
+	"chaos/api/log"
+	"chaos/api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// optionalAssetID reads the asset_id query parameter, nil when absent.
+func optionalAssetID(c *gin.Context) (*uint64, error) {
+	s := c.Query("asset_id")
+	if s == "" {
+		return nil, nil
+	}
+	id, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
+
+// BalanceSnapshot (re)takes the end-of-day snapshot of a past day.
+func BalanceSnapshot(c *gin.Context) {
+	var req SnapshotReq
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "param error"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	day, err := time.ParseInLocation(time.DateOnly, req.Date, time.Local)
+	if err != nil {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "date must be YYYY-MM-DD"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	count, err := service.TakeBalanceSnapshot(day)
+	if err != nil {
+		log.Error("take balance snapshot failed", req.Date, err)
+		res.Code = codes.CODE_ERR_UNKNOWN
+		res.Msg = err.Error()
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	log.Infof("operator %d took balance snapshot of %s", c.GetUint64("operator_id"), req.Date)
+	res.Data = gin.H{
+		"date":     req.Date,
+		"balances": count,
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// BalanceAt returns the balances of one user, or a page of all users, at a past moment.
+func BalanceAt(c *gin.Context) {
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+
+	at, err := time.ParseInLocation(time.DateTime, c.Query("at"), time.Local)
+	if err != nil {
+		if at, err = time.Parse(time.RFC3339, c.Query("at")); err != nil {
+			res.Code = codes.CODE_ERR_BAD_PARAMS
+			res.Msg = "at must be 'YYYY-MM-DD hh:mm:ss' or RFC3339"
+			c.JSON(http.StatusOK, res)
+			return
+		}
+	}
+	var mainID uint64
+	if s := c.Query("main_id"); s != "" {
+		if mainID, err = strconv.ParseUint(s, 10, 64); err != nil {
+			res.Code = codes.CODE_ERR_BAD_PARAMS
+			res.Msg = "invalid main_id"
+			c.JSON(http.StatusOK, res)
+			return
+		}
+	}
+	assetID, err := optionalAssetID(c)
+	if err != nil {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "invalid asset id"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	pn, err := strconv.Atoi(c.DefaultQuery("pn", "1"))
+	if err != nil || pn < 1 {
+		pn = 1
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+
+	balances, base, err := service.BalancesAt(service.BalanceAtQuery{
+		MainID:  mainID,
+		AssetID: assetID,
+		At:      at,
+		Page:    pn,
+		Limit:   limit,
+	})
+	if err != nil {
+		log.Error("query balances at failed", err)
+		res.Code = codes.CODE_ERR_UNKNOWN
+		res.Msg = "query balances failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	var snapshot string
+	if base != nil {
+		snapshot = base.Format(time.DateOnly)
+	}
+	res.Data = gin.H{
+		"at":       at,
+		"snapshot": snapshot,
+		"results":  balances,
+		"pagination": gin.H{
+			"page":  pn,
+			"limit": limit,
+		},
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// Liabilities returns what the platform owed all users per asset per day.
+func Liabilities(c *gin.Context) {
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+
+	from, err := time.ParseInLocation(time.DateOnly, c.Query("from"), time.Local)
+	if err != nil {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "from must be YYYY-MM-DD"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	to, err := time.ParseInLocation(time.DateOnly, c.DefaultQuery("to", time.Now().Format(time.DateOnly)), time.Local)
+	if err != nil {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "to must be YYYY-MM-DD"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	assetID, err := optionalAssetID(c)
+	if err != nil {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "invalid asset id"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	rows, err := service.ListLiabilities(from, to, assetID)
+	if err != nil {
+		res.Code = codes.CODE_ERR_UNKNOWN
+		res.Msg = "query liabilities failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	res.Data = gin.H{
+		"results": rows,
+	}
+	c.JSON(http.StatusOK, res)
+}
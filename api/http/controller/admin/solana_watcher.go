@@ -0,0 +1,29 @@
+package admin
+
+import (
+	"net/http"
+	"time"
+
+	"chaos/api/api/common"
+	"chaos/api/codes"
+	"chaos/api/config"
+	"chaos/api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SolanaWatcher shows how far the Solana watcher got and the last error it ran into.
+func SolanaWatcher(c *gin.Context) {
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+
+	status, started := service.SolanaWatcherStatus()
+	res.Data = gin.H{
+		"enabled": config.GetConfig().Solana.Watch.Enable,
+		"started": started,
+		"status":  status,
+	}
+	c.JSON(http.StatusOK, res)
+}
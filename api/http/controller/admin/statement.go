@@ -0,0 +1,30 @@
+package admin
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"chaos/api/api/common"
+	"chaos/api/api/http/controller/statement"
+	"chaos/api/codes"
+	"chaos/api/log"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AccountStatement exports the statement of any user for support, without a range limit.
+func AccountStatement(c *gin.Context) {
+	mainID, err := strconv.ParseUint(c.Query("main_id"), 10, 64)
+	if err != nil || mainID == 0 {
+		res := common.Response{}
+		res.Timestamp = time.Now().Unix()
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "main_id required"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	log.Infof("operator %d exports statement of %d", c.GetUint64("operator_id"), mainID)
+	statement.Serve(c, mainID, 0)
+}
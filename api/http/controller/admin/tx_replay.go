@@ -0,0 +1,96 @@
+package admin
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"chaos/api/api/common"
+	"chaos/api/chain"
+	"chaos/api/codes"
+	"chaos/api/log"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/gin-gonic/gin"
+)
+
+// txInfoView is a parse outcome as json.
+func txInfoView(info *chain.TopupTxInfo) gin.H {
+	if info == nil {
+		return nil
+	}
+	amount := ""
+	if info.Amount != nil {
+		amount = info.Amount.String()
+	}
+	return gin.H{
+		"tx_hash":       info.TxHash,
+		"status":        info.Status,
+		"from":          info.From,
+		"to":            info.To,
+		"contract":      info.Contract,
+		"user_from_log": info.UserFromLog,
+		"amount":        amount,
+		"lock_id":       hexutil.Encode(info.LockID[:]),
+		"block_number":  info.BlockNumber,
+		"block_hash":    info.BlockHash,
+		"block_time":    info.BlockTime.Unix(),
+	}
+}
+
+// RecordTx parses a tx on the live chain, as the topup consumer would, and returns the outcome
+// with the rpc responses it read. Saved as a fixture, they let /admin/tx/replay or a test parse
+// the same tx again offline.
+func RecordTx(c *gin.Context) {
+	var req TxRecordReq
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+
+	if err := c.ShouldBindJSON(&req); err != nil || req.ChainID == 0 || req.TxHash == "" {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "param error"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Second)
+	defer cancel()
+	info, fixture, err := chain.RecordTx(ctx, req.ChainID, req.Op, req.TxHash)
+	if err != nil {
+		log.Errorf("record tx %s on chain %d failed: %v", req.TxHash, req.ChainID, err)
+		res.Code = codes.CODE_ERR_UNKNOWN
+		res.Msg = err.Error()
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	res.Data = gin.H{"info": txInfoView(info), "fixture": fixture}
+	c.JSON(http.StatusOK, res)
+}
+
+// ReplayTx parses a tx from a recorded fixture, without any rpc.
+func ReplayTx(c *gin.Context) {
+	var req TxReplayReq
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+
+	if err := c.ShouldBindJSON(&req); err != nil || req.Fixture == nil || req.TxHash == "" {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "param error"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	info, err := chain.ReplayTx(c.Request.Context(), req.Fixture, req.Op, req.TxHash)
+	if err != nil {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = err.Error()
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	res.Data = txInfoView(info)
+	c.JSON(http.StatusOK, res)
+}
@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"chaos/api/api/common"
+	"chaos/api/codes"
+	"chaos/api/log"
+	coresvc "chaos/api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// airdropError maps the errors of the airdrop service to a response code.
+func airdropError(res *common.Response, err error) {
+	switch {
+	case errors.Is(err, coresvc.ErrCampaignNotFound), errors.Is(err, coresvc.ErrNotInAirdrop):
+		res.Code = codes.CODE_ERR_OBJ_NOT_FOUND
+	case errors.Is(err, coresvc.ErrClaimPending), errors.Is(err, coresvc.ErrClaimNotFound):
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+	default:
+		log.Error("airdrop request failed", err)
+		res.Code = codes.CODE_ERR_UNKNOWN
+		res.Msg = "airdrop request failed"
+		return
+	}
+	res.Msg = err.Error()
+}
+
+// AirdropProof returns the amount and merkle proof of each linked wallet of the user in a
+// campaign, or of the wallet given in the query.
+func AirdropProof(c *gin.Context) {
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+
+	mainID, err := strconv.ParseUint(c.GetString("main_id"), 10, 64)
+	if err != nil {
+		res.Code = codes.CODE_ERR_SECURITY
+		res.Msg = "please login first"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	proofs, err := coresvc.AirdropProofs(mainID, c.Param("campaign"), c.Query("wallet"))
+	if err != nil {
+		airdropError(&res, err)
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	res.Data = proofs
+	c.JSON(http.StatusOK, res)
+}
+
+// AirdropClaim reports a claim tx, so the claim shows before the claim follower reaches its block.
+func AirdropClaim(c *gin.Context) {
+	var req AirdropClaimReq
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+
+	mainID, err := strconv.ParseUint(c.GetString("main_id"), 10, 64)
+	if err != nil {
+		res.Code = codes.CODE_ERR_SECURITY
+		res.Msg = "please login first"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.TxHash == "" {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "param error"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	campaign := c.Param("campaign")
+	if err := coresvc.ReportAirdropClaim(campaign, req.TxHash); err != nil {
+		airdropError(&res, err)
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	proofs, err := coresvc.AirdropProofs(mainID, campaign, "")
+	if err != nil && !errors.Is(err, coresvc.ErrNotInAirdrop) {
+		airdropError(&res, err)
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	res.Data = proofs
+	c.JSON(http.StatusOK, res)
+}
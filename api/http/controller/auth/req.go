@@ -34,6 +34,7 @@ type JoinSeasonReq struct {
 type TopupReq struct {
 	TxHash  string          `json:"tx_hash"`
 	ChainID uint64          `json:"chain_id"`
+	AssetID *uint64         `json:"asset_id"` // the native asset, or the token of the chain's topup contract when empty
 	Amount  decimal.Decimal `json:"amount"`
 	Type    string          `json:"type"` // recharge/withdraw/lock/cancel
 	RefID   uint64          `json:"ref_id"`
@@ -42,5 +43,10 @@ type TopupReq struct {
 type WithdrawReq struct {
 	Amount  decimal.Decimal `json:"amount"`
 	ChainID uint64          `json:"chain_id"`
+	AssetID *uint64         `json:"asset_id"` // must be the token of the chain's topup contract, which it defaults to
 	ID      uint64          `json:"id"`
 }
+
+type AirdropClaimReq struct {
+	TxHash string `json:"tx_hash"`
+}
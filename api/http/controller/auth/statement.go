@@ -0,0 +1,30 @@
+package auth
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"chaos/api/api/common"
+	"chaos/api/api/http/controller/statement"
+	"chaos/api/codes"
+
+	"github.com/gin-gonic/gin"
+)
+
+// statementMaxRange bounds a self-service statement; support can export longer ones from the admin api.
+const statementMaxRange = 366 * 24 * time.Hour
+
+// AccountStatement exports the statement of the logged in user as csv, json or printable html.
+func AccountStatement(c *gin.Context) {
+	mainID, err := strconv.ParseUint(c.GetString("main_id"), 10, 64)
+	if err != nil {
+		res := common.Response{}
+		res.Timestamp = time.Now().Unix()
+		res.Code = codes.CODE_ERR_SECURITY
+		res.Msg = "please login first"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	statement.Serve(c, mainID, statementMaxRange)
+}
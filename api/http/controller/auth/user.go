@@ -6,8 +6,10 @@ import (
 	"chaos/api/api/service"
 	"chaos/api/chain"
 	"chaos/api/codes"
+	"chaos/api/config"
 	"chaos/api/log"
 	"chaos/api/model"
+	coresvc "chaos/api/service"
 	"chaos/api/system"
 	"chaos/api/tools"
 	"chaos/api/utils"
@@ -23,12 +25,9 @@ import (
 	"sync"
 	"time"
 
-	"os"
-
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/gin-gonic/gin"
-	"github.com/shopspring/decimal"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
@@ -433,7 +432,21 @@ func QueryBalance(c *gin.Context) {
 		}
 	}
 
-	accountBalance, err := service.QueryAccountBalance(userMain.ID, 0)
+	assetID, err := assetIDParam(c)
+	if err != nil {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "invalid asset id"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	if _, err := coresvc.GetAsset(assetID); err != nil {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "unknown asset"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	accountBalance, err := service.QueryAccountBalance(userMain.ID, assetID)
 	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
 		log.Error("can not get account balance", err)
 	} else if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -441,7 +454,7 @@ func QueryBalance(c *gin.Context) {
 			Available:  0,
 			Frozen:     0,
 			MainID:     userMain.ID,
-			AssetID:    0,
+			AssetID:    assetID,
 			UpdateTime: time.Now(),
 		}
 		err = db.Create(&accountBalance).Error
@@ -450,13 +463,36 @@ func QueryBalance(c *gin.Context) {
 		}
 	}
 
+	balances, err := coresvc.ListAccountBalances(userMain.ID)
+	if err != nil {
+		log.Error("can not list account balances", err)
+	}
+	assetBalances := make([]gin.H, 0, len(balances))
+	for _, b := range balances {
+		asset, err := coresvc.GetAsset(b.AssetID)
+		if err != nil {
+			log.Error("can not load asset", b.AssetID, err)
+			continue
+		}
+		assetBalances = append(assetBalances, gin.H{
+			"asset_id":          b.AssetID,
+			"symbol":            asset.Symbol,
+			"decimals":          asset.Decimals,
+			"available":         b.Available,
+			"frozen":            b.Frozen,
+			"pendingWithdrawal": b.Withdrawal,
+		})
+	}
+
 	res.Data = gin.H{
 		"chain_balance": chainBalance,
+		"asset_id":      assetID,
 		"account_balance": map[string]interface{}{
 			"available":         accountBalance.Available,
 			"frozen":            accountBalance.Frozen,
 			"pendingWithdrawal": accountBalance.Withdrawal,
 		},
+		"balances": assetBalances,
 	}
 
 	c.JSON(http.StatusOK, res)
@@ -484,9 +520,24 @@ func FetchTransactions(c *gin.Context) {
 	typeStr := c.DefaultQuery("type", "all")
 	typeCode := model.AvailFlowType(typeStr)
 
+	assetID, err := assetIDParam(c)
+	if err != nil {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "invalid asset id"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	asset, err := coresvc.GetAsset(assetID)
+	if err != nil {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "unknown asset"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
 	db := system.GetDb()
 	query := db.Model(&model.AccountFlow{}).
-		Where("main_id = ? AND asset_id = ?", mainIdStr, 0)
+		Where("main_id = ? AND asset_id = ?", mainIdStr, assetID)
 
 	if typeCode != -1 {
 		query = query.Where("biz_type = ?", typeCode)
@@ -530,6 +581,8 @@ func FetchTransactions(c *gin.Context) {
 			txType = "spend"
 		case model.FlowRefund:
 			txType = "refund"
+		case model.FlowAdjust:
+			txType = "adjust"
 		default:
 			txType = fmt.Sprintf("%d", flow.BizType)
 		}
@@ -540,6 +593,8 @@ func FetchTransactions(c *gin.Context) {
 			status = "confirmed"
 		} else if flow.Status == 0 {
 			status = "pending"
+		} else if flow.Status == model.FlowStatusReversed {
+			status = "refunded"
 		} else {
 			status = "failed"
 		}
@@ -547,8 +602,8 @@ func FetchTransactions(c *gin.Context) {
 		transactions = append(transactions, gin.H{
 			"id":        flow.ID,
 			"type":      txType,
-			"token":     "N",
-			"amount":    fmt.Sprintf("%.6f", float64(flow.Amount)/1000000), // Assuming 6 decimal precision
+			"token":     asset.Symbol,
+			"amount":    asset.Format(flow.Amount),
 			"timestamp": flow.AddTime.Format("2006-01-02T15:04:05Z"),
 			"status":    status,
 		})
@@ -909,8 +964,24 @@ func BalanceTopupReport(c *gin.Context) {
 		return
 	}
 
+	// solana only takes SPL top-ups; locks and withdrawals live on the evm contracts
+	isSolana := chain.IsSolanaChain(req.ChainID)
+	if isSolana && req.Type != "recharge" {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "unsupported type on solana"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	evmChain, err := tools.LookupChain(req.ChainID)
+	if err != nil && !isSolana {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "unsupported chain"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
 	var hashPattern = regexp.MustCompile(`^0x[0-9a-fA-F]{64}$`)
-	if !hashPattern.MatchString(req.TxHash) {
+	if (isSolana && !chain.IsSolanaSignature(req.TxHash)) || (!isSolana && !hashPattern.MatchString(req.TxHash)) {
 		res.Code = codes.CODE_ERR_BAD_PARAMS
 		res.Msg = "invalid tx hash"
 		c.JSON(http.StatusOK, res)
@@ -920,7 +991,7 @@ func BalanceTopupReport(c *gin.Context) {
 	db := system.GetDb()
 
 	var userMain model.UserMain
-	err := db.Model(&model.UserMain{}).Where("id = ?", mainIdStr).First(&userMain).Error
+	err = db.Model(&model.UserMain{}).Where("id = ?", mainIdStr).First(&userMain).Error
 	if err != nil {
 		log.Error("query user main failed", err)
 	}
@@ -967,6 +1038,7 @@ func BalanceTopupReport(c *gin.Context) {
 		case "cancel":
 			var existAccountBalanceFlow = model.AccountBalanceFlow{
 				MainID:     userMain.ID,
+				AssetID:    existAccountBalanceFlowForLocOrWithdraw.AssetID,
 				TxHash:     req.TxHash,
 				AddTime:    time.Now(),
 				UpdateTime: time.Now(),
@@ -1005,7 +1077,63 @@ func BalanceTopupReport(c *gin.Context) {
 		return
 	}
 
-	amount := req.Amount.Mul(decimal.NewFromInt(1000000)).Round(0).BigInt().Uint64()
+	assetID := assetOrDefault(req.AssetID)
+	if req.Type == "withdraw" {
+		assetID = existAccountBalanceFlowForLocOrWithdraw.AssetID
+	}
+	if isSolana {
+		assetID = config.GetConfig().Solana.AssetID
+	}
+	asset, err := coresvc.GetAsset(assetID)
+	if err != nil {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "unknown asset"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	// native coins are recharged by a plain transfer to the chain's treasury, into the chain's native asset
+	native := !isSolana && req.Type == "recharge" && asset.Type == model.AssetTypeNative
+	if native {
+		if !chain.IsNativeAsset(req.ChainID, assetID) || chain.NativeTreasury(req.ChainID) == "" {
+			res.Code = codes.CODE_ERR_BAD_PARAMS
+			res.Msg = "native deposits of this asset are not accepted on this chain"
+			c.JSON(http.StatusOK, res)
+			return
+		}
+	}
+
+	// a deposit to the TopupLogic contract is credited in the asset of the contract's token alone
+	if !isSolana && req.Type == "recharge" && !native {
+		topupAsset, err := coresvc.TopupAsset(c.Request.Context(), req.ChainID, evmChain.GetTopupContract())
+		if err != nil {
+			log.Error("resolve topup asset failed", err, req.ChainID)
+			res.Code = codes.CODE_ERR_UNKNOWN
+			res.Msg = "resolve topup asset failed"
+			if errors.Is(err, coresvc.ErrAssetNotFound) {
+				res.Code = codes.CODE_ERR_BAD_PARAMS
+				res.Msg = "deposits are not accepted on this chain"
+			}
+			c.JSON(http.StatusOK, res)
+			return
+		}
+		if req.AssetID != nil && *req.AssetID != topupAsset.ID {
+			res.Code = codes.CODE_ERR_BAD_PARAMS
+			res.Msg = "asset is not the token deposited on this chain"
+			c.JSON(http.StatusOK, res)
+			return
+		}
+		assetID, asset = topupAsset.ID, topupAsset
+	}
+
+	parsed, err := asset.ParseAmount(req.Amount)
+	if err != nil {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "invalid amount: " + err.Error()
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	amount := parsed.Uint64()
 	if req.Type == "withdraw" {
 		amount = existAccountBalanceFlowForLocOrWithdraw.RealAmount
 	}
@@ -1019,6 +1147,7 @@ func BalanceTopupReport(c *gin.Context) {
 
 	var existAccountBalanceFlow = model.AccountBalanceFlow{
 		MainID:     userMain.ID,
+		AssetID:    assetID,
 		TxHash:     req.TxHash,
 		AddTime:    time.Now(),
 		UpdateTime: time.Now(),
@@ -1043,7 +1172,12 @@ func BalanceTopupReport(c *gin.Context) {
 		}
 	}()
 	var userAccount model.AccountBalance
-	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("main_id = ? and asset_id = ?", userMain.ID, 0).First(&userAccount).Error
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("main_id = ? and asset_id = ?", userMain.ID, assetID).First(&userAccount).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) && req.Type == "recharge" {
+		// first deposit of this asset
+		userAccount = model.AccountBalance{MainID: userMain.ID, AssetID: assetID, UpdateTime: time.Now()}
+		err = tx.Create(&userAccount).Error
+	}
 	if err != nil {
 		res.Code = codes.CODE_ERR_OBJ_NOT_FOUND
 		res.Msg = "user account not found"
@@ -1063,6 +1197,13 @@ func BalanceTopupReport(c *gin.Context) {
 	}
 
 	if err = tx.Create(&existAccountBalanceFlow).Error; err != nil {
+		if coresvc.IsDuplicateKey(err) {
+			// another user reported this deposit; it is credited to the wallet that made it
+			res.Code = codes.CODE_ERR_REPEAT
+			res.Msg = "tx hash already exists"
+			c.JSON(http.StatusOK, res)
+			return
+		}
 		res.Code = codes.CODE_ERR_UNKNOWN
 		res.Msg = "handle topup transaction failed, please try report it manually"
 		c.JSON(http.StatusOK, res)
@@ -1078,7 +1219,11 @@ func BalanceTopupReport(c *gin.Context) {
 
 	committed = true
 
-	chain.AppendTopupTx(req.ChainID, req.TxHash, userMain.ID, existAccountBalanceFlow.ID, op)
+	if native {
+		chain.AppendNativeTopupTx(req.ChainID, req.TxHash, userMain.ID, existAccountBalanceFlow.ID)
+	} else {
+		chain.AppendTopupTx(req.ChainID, req.TxHash, userMain.ID, existAccountBalanceFlow.ID, op)
+	}
 
 	log.Infof("BalanceTopupReport [success] transaction report: %v", req)
 	c.JSON(http.StatusOK, res)
@@ -1184,13 +1329,46 @@ func BalanceWithdrawRequest(c *gin.Context) {
 
 	// init system parameters
 	chainID := req.ChainID
-	contractAddr := os.Getenv("TOPUP_CONTRACT")
-	privKeyHex := os.Getenv("WITHDRAW_LOCK_PK")
-	var computeAmount = req.Amount.Mul(decimal.NewFromInt(1000000)).Round(0).BigInt().Uint64()
+	evmChain, err := tools.LookupChain(chainID)
+	if err != nil {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "unsupported chain"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	contractAddr := evmChain.GetTopupContract()
+	// the TopupLogic contract only pays out its token, so only a balance in that asset is locked
+	asset, err := coresvc.TopupAsset(c.Request.Context(), chainID, contractAddr)
+	if err != nil {
+		log.Error("resolve topup asset failed", err, chainID)
+		res.Code = codes.CODE_ERR_UNKNOWN
+		res.Msg = "resolve topup asset failed"
+		if errors.Is(err, coresvc.ErrAssetNotFound) {
+			res.Code = codes.CODE_ERR_BAD_PARAMS
+			res.Msg = "withdrawals are not paid on this chain"
+		}
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	if req.AssetID != nil && *req.AssetID != asset.ID {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "asset cannot be withdrawn on chain"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	assetID := asset.ID
+	parsed, err := asset.ParseAmount(req.Amount)
+	if err != nil || parsed == 0 {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "invalid amount"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	var computeAmount = parsed.Uint64()
 
 	db := system.GetDb()
 	var userMain model.UserMain
-	err := db.Model(&model.UserMain{}).Where("id = ?", mainIdStr).First(&userMain).Error
+	err = db.Model(&model.UserMain{}).Where("id = ?", mainIdStr).First(&userMain).Error
 	if err != nil {
 		res.Code = codes.CODE_ERR_UNKNOWN
 		res.Msg = "query user main failed"
@@ -1200,13 +1378,12 @@ func BalanceWithdrawRequest(c *gin.Context) {
 
 	var existLockFlow model.AccountBalanceFlow
 	db.Model(&model.AccountBalanceFlow{}).
-		Where("main_id = ? and op = ? AND status IN ?",
-			userMain.ID, model.BalanceFlowOpFreeze,
+		Where("main_id = ? and asset_id = ? and op = ? AND status IN ?",
+			userMain.ID, assetID, model.BalanceFlowOpFreeze,
 			[]int{model.BalanceFlowStatusPending, model.BalanceFlowStatusPendingWithDraw}).
 		First(&existLockFlow)
 
 	if existLockFlow.ID > 0 {
-		var _ = big.NewInt(int64(existLockFlow.RealAmount))
 		var expiry = uint64(existLockFlow.LockExpiry.Unix())
 		// var nonce = new(big.Int).SetBytes(existLockFlow.LockNonce)
 		var nonce = hex32ToBigInt(existLockFlow.LockNonce)
@@ -1234,7 +1411,7 @@ func BalanceWithdrawRequest(c *gin.Context) {
 			"signature":      existLockFlow.LockSig,
 			"expiry":         expiry,
 			"nonce":          nonce.String(),
-			"amount":         existLockFlow.RealAmount,
+			"amount":         asset.ToChain(model.Amount(existLockFlow.RealAmount)).String(),
 		}
 		c.JSON(http.StatusOK, res)
 		return
@@ -1255,7 +1432,7 @@ func BalanceWithdrawRequest(c *gin.Context) {
 
 	var userAccount model.AccountBalance
 	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
-		Where("main_id = ? and asset_id = ?", userMain.ID, 0).First(&userAccount).Error; err != nil {
+		Where("main_id = ? and asset_id = ?", userMain.ID, assetID).First(&userAccount).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			res.Code = codes.CODE_ERR_OBJ_NOT_FOUND
 			res.Msg = "user account not found"
@@ -1275,25 +1452,13 @@ func BalanceWithdrawRequest(c *gin.Context) {
 		return
 	}
 
-	userAccount.Available -= computeAmount
-	userAccount.Withdrawal += computeAmount
-	userAccount.UpdateTime = time.Now()
-
-	if err := tx.Save(&userAccount).Error; err != nil {
-		log.Error("update user account balance failed", err)
-		res.Code = codes.CODE_ERR_UNKNOWN
-		res.Msg = "update and lock user account balance failed"
-		c.JSON(http.StatusOK, res)
-		return
-	}
-
 	// 生成复合合约签名（EIP712）
 	// 构造必要参数
 
 	var up model.UserProvider
 	db.Model(&model.UserProvider{}).Where("main_id = ? and provider_type = ?", userMain.ID, "wallet").First(&up)
 	userAddr := up.ProviderID
-	if len(contractAddr) == 0 || len(privKeyHex) == 0 {
+	if len(contractAddr) == 0 {
 		res.Code = codes.CODE_ERR_UNKNOWN
 		res.Msg = "sign config missing"
 		c.JSON(http.StatusOK, res)
@@ -1312,7 +1477,8 @@ func BalanceWithdrawRequest(c *gin.Context) {
 
 	lockIdHexStr := hexutil.Encode(crypto.Keccak256([]byte(fmt.Sprintf("%d-%d", userMain.ID, timeNow.UnixNano()))))
 	log.Info(lockIdHexStr)
-	amountBI := new(big.Int).SetUint64(computeAmount)
+	// the contract moves base units of the token, the ledger keeps asset units
+	amountBI := asset.ToChain(parsed)
 
 	// nonce := big.NewInt(timeNow.Unix())
 	nonce := generateInternalNonce()
@@ -1327,17 +1493,22 @@ func BalanceWithdrawRequest(c *gin.Context) {
 	}
 
 	ctx := context.Background()
-	digest, sig, err := chain.BuildAndSignLockAuth(
-		ctx,
-		chainID,
-		contractAddr,
-		privKeyHex,
-		up.ProviderID,
-		lockIdHex,
-		amountBI,
-		expiry,
-		nonce,
-	)
+	digest, sig, err := coresvc.SignLockAuth(ctx, coresvc.LockAuthRequest{
+		MainID:   userMain.ID,
+		ChainID:  chainID,
+		Contract: contractAddr,
+		User:     up.ProviderID,
+		LockID:   lockIdHex,
+		Amount:   amountBI,
+		Expiry:   expiry,
+		Nonce:    nonce,
+	})
+	if errors.Is(err, coresvc.ErrSignerLimit) {
+		res.Code = codes.CODE_ERR_SECURITY
+		res.Msg = "withdrawal limit reached, please try again later"
+		c.JSON(http.StatusOK, res)
+		return
+	}
 	if err != nil {
 		log.Error("build and sign lock auth failed", err)
 		res.Code = codes.CODE_ERR_UNKNOWN
@@ -1349,7 +1520,7 @@ func BalanceWithdrawRequest(c *gin.Context) {
 	expiryTime := time.Unix(int64(expiry), 0)
 	userAccountFlow := model.AccountBalanceFlow{
 		MainID:     userMain.ID,
-		AssetID:    0,
+		AssetID:    assetID,
 		Op:         model.BalanceFlowOpFreeze,
 		Status:     model.BalanceFlowStatusPending,
 		Amount:     computeAmount,
@@ -1361,6 +1532,7 @@ func BalanceWithdrawRequest(c *gin.Context) {
 		LockExpiry: &expiryTime,
 		LockNonce:  bigIntToHex32(nonce),
 		LockAddr:   userAddr,
+		ChainID:    fmt.Sprintf("%d", chainID),
 	}
 
 	if err := tx.Create(&userAccountFlow).Error; err != nil {
@@ -1371,6 +1543,17 @@ func BalanceWithdrawRequest(c *gin.Context) {
 		return
 	}
 
+	if err := coresvc.PostBalanceChange(tx, &userAccount, model.LedgerBizWithdrawLock, computeAmount, coresvc.LedgerRef{
+		Table: model.LedgerRefAccountBalanceFlow,
+		ID:    userAccountFlow.ID,
+	}); err != nil {
+		log.Error("update user account balance failed", err)
+		res.Code = codes.CODE_ERR_UNKNOWN
+		res.Msg = "update and lock user account balance failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
 	if err := tx.Commit().Error; err != nil {
 		res.Code = codes.CODE_ERR_UNKNOWN
 		res.Msg = "commit transaction failed"
@@ -1388,7 +1571,7 @@ func BalanceWithdrawRequest(c *gin.Context) {
 		"signature":      hexutil.Encode(sig),
 		"expiry":         expiry,
 		"nonce":          nonce.String(),
-		"amount":         computeAmount,
+		"amount":         amountBI.String(),
 	}
 
 	c.JSON(http.StatusOK, res)
@@ -1438,6 +1621,8 @@ func BalanceWithdrawCheck(c *gin.Context) {
 		opType = "withdraw"
 	case model.BalanceFlowOpUnfreeze:
 		opType = "unlock"
+	case model.BalanceFlowOpRevoke:
+		opType = "revoke"
 	default:
 		opType = "unknown"
 	}
@@ -1452,6 +1637,16 @@ func BalanceWithdrawCheck(c *gin.Context) {
 		status = "pendingWithDraw"
 	case model.BalanceFlowStatusCanceled:
 		status = "canceled"
+	case model.BalanceFlowStatusLockExpired:
+		status = "lockExpired"
+	}
+
+	asset, err := coresvc.GetAsset(accountBalanceFlow.AssetID)
+	if err != nil {
+		res.Code = codes.CODE_ERR_UNKNOWN
+		res.Msg = "unknown asset"
+		c.JSON(http.StatusOK, res)
+		return
 	}
 
 	res.Data = gin.H{
@@ -1464,7 +1659,7 @@ func BalanceWithdrawCheck(c *gin.Context) {
 		"signature":      accountBalanceFlow.LockSig,
 		"expiry":         accountBalanceFlow.LockExpiry,
 		"nonce":          accountBalanceFlow.LockNonce,
-		"amount":         accountBalanceFlow.RealAmount,
+		"amount":         asset.ToChain(model.Amount(accountBalanceFlow.RealAmount)).String(),
 	}
 
 	c.JSON(http.StatusOK, res)
@@ -1495,3 +1690,20 @@ func hex32ToBigInt(s string) *big.Int {
 	}
 	return new(big.Int).SetBytes(b)
 }
+
+// assetIDParam reads the optional asset_id query parameter, falling back to the platform asset.
+func assetIDParam(c *gin.Context) (uint64, error) {
+	s := c.Query("asset_id")
+	if s == "" {
+		return coresvc.DefaultAssetID(), nil
+	}
+	return strconv.ParseUint(s, 10, 64)
+}
+
+// assetOrDefault resolves an optional asset id from a request body.
+func assetOrDefault(id *uint64) uint64 {
+	if id == nil {
+		return coresvc.DefaultAssetID()
+	}
+	return *id
+}
@@ -5,6 +5,7 @@ import (
 	"chaos/api/codes"
 	"chaos/api/log"
 	"chaos/api/model"
+	"chaos/api/service"
 	"chaos/api/system"
 	"fmt"
 	"math/rand"
@@ -13,7 +14,6 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"github.com/shopspring/decimal"
 )
 
 func Profile(c *gin.Context) {
@@ -274,6 +274,15 @@ func UpdateGame(c *gin.Context) {
 	gameInfo.Avatar = req.Avatar
 	gameInfo.Image = req.Image
 	gameInfo.PlayUrl = req.PlayUrl
+	if req.SessionTimeout != nil {
+		if *req.SessionTimeout != 0 && (*req.SessionTimeout < model.MinSessionTimeout || *req.SessionTimeout > model.MaxSessionTimeout) {
+			res.Code = codes.CODE_ERR_BAD_PARAMS
+			res.Msg = fmt.Sprintf("session timeout must be between %d and %d seconds", model.MinSessionTimeout, model.MaxSessionTimeout)
+			c.JSON(http.StatusOK, res)
+			return
+		}
+		gameInfo.SessionTimeout = *req.SessionTimeout
+	}
 
 	if shouldResetStatus {
 		if gameInfo.Status == model.GameStatusActive {
@@ -565,12 +574,31 @@ func SaveSetting(c *gin.Context) {
 	db.Model(&model.GameSetting{}).Where("game_id = ? and code = ?", req.GameID, req.Code).First(&gameSetting)
 	if gameSetting.ID == 0 {
 		gameSetting.GameID = gameInfo.ID
+		gameSetting.AssetID = service.DefaultAssetID()
 		gameSetting.AddTime = time.Now()
 	}
+	if req.AssetID != nil {
+		gameSetting.AssetID = *req.AssetID
+	}
+	asset, err := service.GetAsset(gameSetting.AssetID)
+	if err != nil {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "unknown asset"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	amountPerPlay, err := asset.ParseAmount(req.AmountPerPlay)
+	if err != nil {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "invalid amount per play: " + err.Error()
+		c.JSON(http.StatusOK, res)
+		return
+	}
 
 	gameSetting.Code = req.Code
 	gameSetting.Catalog = req.Catalog
-	gameSetting.AmountPerPlay = req.AmountPerPlay.Mul(decimal.NewFromInt(1000000)).Round(0).BigInt().Uint64()
+	gameSetting.AmountPerPlay = amountPerPlay.Uint64()
 	db.Save(&gameSetting)
 
 	if gameInfo.Status == model.GameStatusActive {
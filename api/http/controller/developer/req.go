@@ -17,6 +17,8 @@ type UpdateGameReq struct {
 	Image         string `json:"image"`
 	PlayUrl       string `json:"play_url"`
 	AmountPerPlay uint64 `json:"amount_per_play"`
+	// SessionTimeout in seconds, nil keeps the current value and 0 restores the platform default
+	SessionTimeout *int `json:"session_timeout"`
 }
 
 type GameKeyReq struct {
@@ -32,6 +34,7 @@ type GameSettingSaveReq struct {
 	GameID        uint64          `json:"game_id"`
 	Code          string          `json:"code"`
 	Catalog       string          `json:"catalog"`
+	AssetID       *uint64         `json:"asset_id"` // asset the entry fee is charged in, defaults to the platform asset
 	AmountPerPlay decimal.Decimal `json:"amount_per_play"`
 }
 
@@ -0,0 +1,96 @@
+package home
+
+import (
+	"errors"
+	"math/big"
+	"net/http"
+	"time"
+
+	"chaos/api/api/common"
+	"chaos/api/chain"
+	"chaos/api/codes"
+	"chaos/api/log"
+	"chaos/api/service"
+	"chaos/api/tools"
+
+	gethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/gin-gonic/gin"
+)
+
+// LockTicketReq is a withdraw lock ticket as /auth/account/balance/withdraw/request returns it.
+type LockTicketReq struct {
+	ChainID   uint64 `json:"chain_id"`
+	Contract  string `json:"contract"` // must be the chain's TopupLogic contract, which it defaults to
+	User      string `json:"user"`
+	LockID    string `json:"lock_id"`
+	Amount    string `json:"amount"` // base units
+	Expiry    uint64 `json:"expiry"`
+	Nonce     string `json:"nonce"` // decimal, or hex with 0x
+	Signature string `json:"signature"`
+}
+
+// VerifyLockTicket tells who signed a withdraw lock ticket and whether it is the signer of this
+// service, so a wallet or support can check a ticket before anyone pays gas for openLock.
+func VerifyLockTicket(c *gin.Context) {
+	var req LockTicketReq
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+
+	if err := c.ShouldBindJSON(&req); err != nil || req.ChainID == 0 || req.User == "" || req.LockID == "" {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "param error"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	amount, ok1 := new(big.Int).SetString(req.Amount, 10)
+	nonce, ok2 := new(big.Int).SetString(req.Nonce, 0)
+	sig, err := hexutil.Decode(req.Signature)
+	lockID, err2 := hexutil.Decode(req.LockID)
+	if !ok1 || !ok2 || err != nil || len(sig) != 65 || err2 != nil || len(lockID) != 32 || !gethcommon.IsHexAddress(req.User) {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "invalid user, lock id, amount, nonce or signature"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	evmChain, err := tools.LookupChain(req.ChainID)
+	if err != nil {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "unsupported chain"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	if req.Contract == "" {
+		req.Contract = evmChain.GetTopupContract()
+	}
+	if !chain.IsTopupContract(req.ChainID, req.Contract) {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "contract is not the topup contract of the chain"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	check, err := service.CheckLockTicket(service.LockAuthRequest{
+		ChainID:  req.ChainID,
+		Contract: req.Contract,
+		User:     req.User,
+		LockID:   req.LockID,
+		Amount:   amount,
+		Expiry:   req.Expiry,
+		Nonce:    nonce,
+	}, sig)
+	if err != nil {
+		log.Error("check lock ticket failed", err)
+		res.Code = codes.CODE_ERR_UNKNOWN
+		res.Msg = "check lock ticket failed"
+		if errors.Is(err, chain.ErrDomainUnverified) {
+			res.Msg = "lock domain of the chain is not verified yet"
+		}
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	res.Data = check
+	c.JSON(http.StatusOK, res)
+}
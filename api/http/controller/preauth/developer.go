@@ -6,6 +6,7 @@ import (
 	"chaos/api/log"
 	"chaos/api/model"
 	"chaos/api/security"
+	coresvc "chaos/api/service"
 	"chaos/api/system"
 	"chaos/api/utils"
 	"fmt"
@@ -174,3 +175,51 @@ func DeveloperVerify(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, res)
 }
+
+func OperatorLogin(c *gin.Context) {
+	var req LoginRequest
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		res.Code = codes.CODE_ERR_REQFORMAT
+		res.Msg = "invalid request" + err.Error()
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	db := system.GetDb()
+
+	var operator model.SysOperator
+	db.Model(&model.SysOperator{}).
+		Where("email = ?", req.Email).
+		First(&operator)
+
+	if operator.ID == 0 || !coresvc.CheckOperatorPassword(operator, req.Password) || operator.Status != model.OperatorStatusActive {
+		res.Code = codes.CODE_ERR_UNKNOWN
+		res.Msg = "email not found or password incorrect"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	expireTs := time.Now().Add(common.OPERATOR_TOKEN_DURATION).Unix()
+
+	tokenOrig := fmt.Sprintf("op|%d|%d", operator.ID, expireTs)
+	tokenEnc, err := security.Encrypt([]byte(tokenOrig))
+	if err != nil {
+		res.Code = codes.CODE_ERR_SECURITY
+		res.Msg = "token gen error:" + err.Error()
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+	res.Data = gin.H{
+		"operator_id": operator.ID,
+		"name":        operator.Name,
+		"role":        operator.Role,
+		"token":       tokenEnc,
+	}
+	c.JSON(http.StatusOK, res)
+}
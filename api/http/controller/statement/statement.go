@@ -0,0 +1,83 @@
+// Package statement serves account statements for the user and admin apis.
+package statement
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"chaos/api/api/common"
+	"chaos/api/codes"
+	"chaos/api/log"
+	"chaos/api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Serve streams the statement of mainID for the from/to/asset_id/format query of c.
+// maxRange limits the length of the range, 0 means unlimited.
+func Serve(c *gin.Context, mainID uint64, maxRange time.Duration) {
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+
+	from, to, err := service.ParseStatementRange(c.Query("from"), c.Query("to"))
+	if err != nil {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "from and to must be YYYY-MM-DD dates, from <= to"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+	if maxRange > 0 && to.Sub(from) > maxRange {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = fmt.Sprintf("statement range is limited to %d days", int(maxRange.Hours()/24))
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	assetID := service.DefaultAssetID()
+	if s := c.Query("asset_id"); s != "" {
+		if assetID, err = strconv.ParseUint(s, 10, 64); err != nil {
+			res.Code = codes.CODE_ERR_BAD_PARAMS
+			res.Msg = "invalid asset id"
+			c.JSON(http.StatusOK, res)
+			return
+		}
+	}
+	if _, err := service.GetAsset(assetID); err != nil {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "unknown asset"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	format := c.DefaultQuery("format", "json")
+	w, contentType, err := service.NewStatementWriter(format, c.Writer)
+	if err != nil {
+		if errors.Is(err, service.ErrStatementFormat) {
+			res.Code = codes.CODE_ERR_BAD_PARAMS
+			res.Msg = "format must be csv, json or html"
+		} else {
+			log.Error("create statement writer failed", err)
+			res.Code = codes.CODE_ERR_UNKNOWN
+			res.Msg = "statement unavailable"
+		}
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	filename := fmt.Sprintf("statement-%d-%s-%s.%s", mainID, from.Format(time.DateOnly), to.AddDate(0, 0, -1).Format(time.DateOnly), format)
+	disposition := "attachment"
+	if format == "html" {
+		disposition = "inline"
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf("%s; filename=%q", disposition, filename))
+	c.Status(http.StatusOK)
+
+	// headers are gone once the first line is written, so a failure can only be logged
+	if err := service.WriteStatement(service.StatementQuery{MainID: mainID, AssetID: assetID, From: from, To: to}, w); err != nil {
+		log.Error("write statement failed", mainID, err)
+	}
+}
@@ -3,12 +3,14 @@ package http
 import (
 	"github.com/gin-gonic/gin"
 
+	"chaos/api/api/http/controller/admin"
 	"chaos/api/api/http/controller/auth"
 	"chaos/api/api/http/controller/developer"
 	"chaos/api/api/http/controller/home"
 	preauth "chaos/api/api/http/controller/preauth"
 	"chaos/api/api/interceptor"
 	"chaos/api/log"
+	"chaos/api/model"
 )
 
 func Routers(e *gin.RouterGroup) {
@@ -16,6 +18,7 @@ func Routers(e *gin.RouterGroup) {
 	homeGroup := e.Group("/")
 	homeGroup.GET("public/config", home.Public)
 	homeGroup.GET("public/assets", home.Assets)
+	homeGroup.POST("public/lock/verify", home.VerifyLockTicket)
 
 	homeGroup.GET("public/game", home.Game)
 	homeGroup.GET("public/game/:game_id", home.GameDetail)
@@ -36,6 +39,7 @@ func Routers(e *gin.RouterGroup) {
 	preAuthGroup.POST("/developer/login", preauth.DeveloperLogin)
 	preAuthGroup.POST("/developer/register", preauth.DeveloperRegister)
 	preAuthGroup.POST("/developer/verify", preauth.DeveloperVerify)
+	preAuthGroup.POST("/operator/login", preauth.OperatorLogin)
 
 	authGroup := e.Group("/auth", interceptor.TokenInterceptor())
 
@@ -53,10 +57,14 @@ func Routers(e *gin.RouterGroup) {
 	authGroup.POST("/season/join", auth.JoinSeason)
 	authGroup.GET("/season/joined", auth.CheckSeasonJoined)
 
-	authGroup.POST("/account/balance/topup", auth.BalanceTopupReport)
+	authGroup.POST("/account/balance/topup", interceptor.IdempotencyInterceptor(), auth.BalanceTopupReport)
 	authGroup.GET("/account/balance/topup", auth.BalanceTopupList)
-	authGroup.POST("/account/balance/withdraw/request", auth.BalanceWithdrawRequest)
+	authGroup.POST("/account/balance/withdraw/request", interceptor.IdempotencyInterceptor(), auth.BalanceWithdrawRequest)
 	authGroup.GET("/account/balance/withdraw/check", auth.BalanceWithdrawCheck)
+	authGroup.GET("/account/statement", auth.AccountStatement)
+
+	authGroup.GET("/airdrop/:campaign/proof", auth.AirdropProof)
+	authGroup.POST("/airdrop/:campaign/claim", auth.AirdropClaim)
 
 	// Twitter OAuth callback endpoint - requires authentication
 	authGroup.GET("/thirdpart/x/callback", auth.XCallback)
@@ -75,6 +83,38 @@ func Routers(e *gin.RouterGroup) {
 	devAuthGroup.POST("/game/online/audit", developer.SubmitOnlineAudit)
 	devAuthGroup.POST("/game/session/list", developer.GameSessionList)
 
+	adminGroup := e.Group("/admin", interceptor.OperatorTokenInterceptor())
+	// routes that move funds need finance; those acting on contracts, airdrops, assets and jobs need admin
+	finance := interceptor.RequireOperatorRole(model.OperatorRoleFinance, model.OperatorRoleAdmin)
+	adminOnly := interceptor.RequireOperatorRole(model.OperatorRoleAdmin)
+	adminGroup.POST("/ledger/check", admin.LedgerCheck)
+	adminGroup.GET("/ledger/check", admin.LedgerCheckList)
+	adminGroup.POST("/ledger/open", finance, admin.LedgerOpen)
+	adminGroup.POST("/flow/refund", finance, admin.FlowRefund)
+	adminGroup.GET("/account/statement", admin.AccountStatement)
+	adminGroup.POST("/balance/snapshot", admin.BalanceSnapshot)
+	adminGroup.GET("/balance/at", admin.BalanceAt)
+	adminGroup.GET("/liabilities", admin.Liabilities)
+	adminGroup.POST("/adjustment", finance, admin.CreateAdjustment)
+	adminGroup.GET("/adjustment", admin.ListAdjustments)
+	adminGroup.POST("/adjustment/review", finance, admin.ReviewAdjustment)
+	adminGroup.POST("/adjustment/cancel", finance, admin.CancelAdjustment)
+	adminGroup.GET("/audit", admin.AuditLog)
+	adminGroup.POST("/fee/schedule", finance, admin.CreateFeeSchedule)
+	adminGroup.GET("/fee/schedule", admin.FeeSchedules)
+	adminGroup.GET("/fee/recompute", admin.RecomputeSpends)
+	adminGroup.GET("/contract/event", admin.ContractEvents)
+	adminGroup.POST("/contract/event/ack", adminOnly, admin.AckContractEvent)
+	adminGroup.GET("/job", admin.Jobs)
+	adminGroup.POST("/job/retry", adminOnly, admin.RetryJob)
+	adminGroup.GET("/solana/watcher", admin.SolanaWatcher)
+	adminGroup.POST("/tx/record", adminOnly, admin.RecordTx)
+	adminGroup.POST("/tx/replay", admin.ReplayTx)
+	adminGroup.POST("/airdrop/campaign", adminOnly, admin.CreateAirdropCampaign)
+	adminGroup.GET("/airdrop/campaign", admin.AirdropCampaigns)
+	adminGroup.POST("/airdrop/attach", adminOnly, admin.AttachAirdropContract)
+	adminGroup.POST("/asset/discover", adminOnly, admin.DiscoverAsset)
+
 	/***** Intend to use api in future ****/
 	// authGroup.POST("ref_uri", auth.Ref)
 	// authGroup.POST("/ref/stat", auth.RefCount)
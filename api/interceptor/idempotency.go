@@ -0,0 +1,134 @@
+package interceptor
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"chaos/api/api/common"
+	"chaos/api/codes"
+	"chaos/api/log"
+	"chaos/api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	IdempotencyHeader       = "Idempotency-Key"
+	IdempotencyReplayHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLen    = 128
+)
+
+// responseRecorder keeps a copy of what the handler writes.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// idempotencyScope is the caller a key belongs to: the game client and user of an oapi token,
+// or the user of an auth token. It must run after the route's auth middleware.
+func idempotencyScope(c *gin.Context) string {
+	if sub := c.GetString("sub"); sub != "" {
+		return "oapi:" + c.GetString("aud") + ":" + sub
+	}
+	if mainID := c.GetString("main_id"); mainID != "" {
+		return "auth:" + mainID
+	}
+	return ""
+}
+
+// IdempotencyInterceptor makes a money-moving route safe to retry. A request carrying an
+// Idempotency-Key header runs once per caller and key; retries get the stored response, and
+// reusing a key for a different request is rejected. Requests without the header pass through.
+func IdempotencyInterceptor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := strings.TrimSpace(c.GetHeader(IdempotencyHeader))
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLen {
+			makeFaileRes(c, codes.CODE_ERR_BAD_PARAMS, "idempotency key too long")
+			return
+		}
+		scope := idempotencyScope(c)
+		if scope == "" {
+			makeFaileRes(c, codes.CODE_ERR_SECURITY, "please login first")
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			makeFaileRes(c, codes.CODE_ERR_BAD_PARAMS, "read body failed")
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		sum := sha256.Sum256(body)
+		route := c.Request.Method + " " + c.FullPath()
+
+		row, replay, err := service.ClaimIdempotencyKey(scope, key, route, hex.EncodeToString(sum[:]))
+		switch {
+		case errors.Is(err, service.ErrIdempotencyMismatch):
+			makeFaileRes(c, codes.CODE_ERR_REPEAT, "idempotency key already used for another request")
+			return
+		case errors.Is(err, service.ErrIdempotencyInFlight):
+			makeFaileRes(c, codes.CODE_ERR_PROCESSING, "request with this idempotency key is processing")
+			return
+		case err != nil:
+			log.Error("claim idempotency key failed", scope, key, err)
+			makeFaileRes(c, codes.CODE_ERR_UNKNOWN, "idempotency check failed")
+			return
+		}
+		if replay {
+			c.Abort()
+			c.Header(IdempotencyReplayHeader, "true")
+			c.Data(row.ResponseCode, "application/json; charset=utf-8", []byte(row.ResponseBody))
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = rec
+		finished := false
+		defer func() {
+			if !finished {
+				// the handler panicked before answering
+				if err := service.ReleaseIdempotencyKey(row.ID); err != nil {
+					log.Error("release idempotency key failed", row.ID, err)
+				}
+			}
+		}()
+
+		c.Next()
+		finished = true
+
+		// unknown failures roll back their transaction, so the request may run again
+		var res common.Response
+		retryable := rec.Status() >= http.StatusInternalServerError
+		if json.Unmarshal(rec.body.Bytes(), &res) == nil && (res.Code == codes.CODE_ERR_UNKNOWN || res.Code == codes.CODE_ERR_PROCESSING) {
+			retryable = true
+		}
+		if retryable {
+			err = service.ReleaseIdempotencyKey(row.ID)
+		} else {
+			err = service.CompleteIdempotencyKey(row.ID, rec.Status(), rec.body.String())
+		}
+		if err != nil {
+			log.Error("store idempotency key failed", row.ID, err)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package interceptor
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestIdempotencyScope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+	if s := idempotencyScope(c); s != "" {
+		t.Fatalf("anonymous scope = %q", s)
+	}
+	c.Set("main_id", "7")
+	if s := idempotencyScope(c); s != "auth:7" {
+		t.Fatalf("auth scope = %q", s)
+	}
+	c.Set("aud", "client-a")
+	c.Set("sub", "7")
+	if s := idempotencyScope(c); s != "oapi:client-a:7" {
+		t.Fatalf("oapi scope = %q", s)
+	}
+}
+
+func TestIdempotencyWithoutKeyPassesThrough(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	calls := 0
+	r.POST("/x", IdempotencyInterceptor(), func(c *gin.Context) {
+		calls++
+		c.String(http.StatusOK, "ok")
+	})
+
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/x", nil))
+		if w.Body.String() != "ok" {
+			t.Fatalf("unexpected body %q", w.Body.String())
+		}
+	}
+	if calls != 2 {
+		t.Fatalf("handler ran %d times, want 2", calls)
+	}
+}
@@ -0,0 +1,64 @@
+package interceptor
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"chaos/api/codes"
+	"chaos/api/model"
+	"chaos/api/security"
+	"chaos/api/system"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OperatorTokenInterceptor guards the /admin group. The AAUTH token is "op|operator_id|expire_ts".
+func OperatorTokenInterceptor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, err := security.Decrypt(c.Request.Header.Get("AAUTH"))
+		if err != nil {
+			makeFaileRes(c, codes.CODE_ERR_SECURITY, "token check failed")
+			return
+		}
+		tokenArr := strings.Split(token, "|")
+		if len(tokenArr) != 3 || tokenArr[0] != "op" {
+			makeFaileRes(c, codes.CODE_ERR_SECURITY, "token length error")
+			return
+		}
+		expireTs, err := strconv.ParseInt(tokenArr[2], 10, 64)
+		if err != nil {
+			makeFaileRes(c, codes.CODE_ERR_SECURITY, "token format error")
+			return
+		}
+		if time.Now().Unix() > expireTs {
+			makeFaileRes(c, codes.CODE_ERR_SECURITY, "token expired error")
+			return
+		}
+
+		var operator model.SysOperator
+		system.GetDb().Model(&model.SysOperator{}).Where("id = ?", tokenArr[1]).First(&operator)
+		if operator.ID == 0 || operator.Status != model.OperatorStatusActive {
+			makeFaileRes(c, codes.CODE_ERR_SECURITY, "operator disabled")
+			return
+		}
+
+		c.Set("operator_id", operator.ID)
+		c.Set("operator_role", operator.Role)
+
+		c.Next()
+	}
+}
+
+// RequireOperatorRole lets through only operators of one of roles. It runs after
+// OperatorTokenInterceptor, on the admin routes that move funds or act on contracts.
+func RequireOperatorRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !slices.Contains(roles, c.GetString("operator_role")) {
+			makeFaileRes(c, codes.CODE_ERR_SECURITY, "operator role not allowed")
+			return
+		}
+		c.Next()
+	}
+}
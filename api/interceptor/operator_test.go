@@ -0,0 +1,33 @@
+package interceptor
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"chaos/api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequireOperatorRole(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for role, allowed := range map[string]bool{
+		model.OperatorRoleOperator: false,
+		model.OperatorRoleFinance:  true,
+		model.OperatorRoleAdmin:    true,
+		"":                         false,
+	} {
+		r := gin.New()
+		r.POST("/x", func(c *gin.Context) {
+			c.Set("operator_role", role)
+		}, RequireOperatorRole(model.OperatorRoleFinance, model.OperatorRoleAdmin), func(c *gin.Context) {
+			c.String(http.StatusOK, "ok")
+		})
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/x", nil))
+		if got := w.Body.String() == "ok"; got != allowed {
+			t.Fatalf("role %q let through = %v, body %q", role, got, w.Body.String())
+		}
+	}
+}
@@ -5,9 +5,11 @@ import (
 	"chaos/api/codes"
 	"chaos/api/log"
 	"chaos/api/model"
+	"chaos/api/service"
 	"chaos/api/system"
 	"errors"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -19,6 +21,9 @@ import (
 	"gorm.io/gorm/clause"
 )
 
+// clientRefundWindow is how long a game client may refund its own flows; older ones need an operator.
+const clientRefundWindow = 72 * time.Hour
+
 // 判断是否唯一键冲突（MySQL 1062）
 func isDup(err error) bool {
 	var me *mysql.MySQLError
@@ -125,7 +130,7 @@ func MeHandler(c *gin.Context) {
 
 	db.Model(&model.UserProfile{}).Where("main_id = ?", userId).First(&userProfile)
 	var userAccount model.AccountBalance
-	db.Model(&model.AccountBalance{}).Where("main_id = ? and asset_id = ?", userId, 0).First(&userAccount)
+	db.Model(&model.AccountBalance{}).Where("main_id = ? and asset_id = ?", userId, service.DefaultAssetID()).First(&userAccount)
 
 	res.Data = gin.H{
 		"user_no":      userMain.UserNo,
@@ -383,7 +388,7 @@ func GameStartHandler(c *gin.Context) {
 	var userAccount model.AccountBalance
 	if err := tx.
 		Clauses(clause.Locking{Strength: "UPDATE"}).
-		Where("main_id = ? AND asset_id = ?", userMain.ID, 0).
+		Where("main_id = ? AND asset_id = ?", userMain.ID, gameSetting.AssetID).
 		First(&userAccount).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			if gameSetting.AmountPerPlay > 0 {
@@ -398,7 +403,7 @@ func GameStartHandler(c *gin.Context) {
 				// 创建一个余额为0的账户记录
 				userAccount = model.AccountBalance{
 					MainID:    userMain.ID,
-					AssetID:   0,
+					AssetID:   gameSetting.AssetID,
 					Available: 0,
 					Frozen:    0,
 				}
@@ -428,7 +433,7 @@ func GameStartHandler(c *gin.Context) {
 	}
 	accountFlow := model.AccountFlow{
 		MainID:         userMain.ID,
-		AssetID:        uint64(0),
+		AssetID:        gameSetting.AssetID,
 		BizType:        model.FlowFreeze,
 		Amount:         gameSetting.AmountPerPlay,
 		Direction:      model.DirectionNone,
@@ -465,15 +470,25 @@ func GameStartHandler(c *gin.Context) {
 		return
 	}
 
-	userAccount.Available -= gameSetting.AmountPerPlay
-	userAccount.Frozen += gameSetting.AmountPerPlay
-	if err := tx.Save(&userAccount).Error; err != nil {
+	if err := service.PostBalanceChange(tx, &userAccount, model.LedgerBizFreeze, gameSetting.AmountPerPlay, service.LedgerRef{
+		Table: model.LedgerRefAccountFlow,
+		ID:    accountFlow.ID,
+	}); err != nil {
 		tx.Rollback()
 		res.Code = codes.CODE_ERR_UNKNOWN
 		res.Msg = "freeze operation failed (balance update)"
 		c.JSON(http.StatusOK, res)
 		return
 	}
+	// 记录玩法分类，结束时按分类费率拆分
+	if err := tx.Model(&model.GameSession{}).Where("session_id = ?", gameSession.SessionID).
+		Update("catalog", gameSetting.Catalog).Error; err != nil {
+		tx.Rollback()
+		res.Code = codes.CODE_ERR_UNKNOWN
+		res.Msg = "update game session failed"
+		c.JSON(http.StatusOK, res)
+		return
+	}
 	if err := tx.Commit().Error; err != nil {
 		res.Code = codes.CODE_ERR_UNKNOWN
 		res.Msg = "freeze commit failed"
@@ -629,7 +644,7 @@ func GameEndHandler(c *gin.Context) {
 	var userAccount model.AccountBalance
 	if err := tx.
 		Clauses(clause.Locking{Strength: "UPDATE"}).
-		Where("main_id = ? AND asset_id = ?", userMain.ID, 0).
+		Where("main_id = ? AND asset_id = ?", userMain.ID, freezeFlow.AssetID).
 		First(&userAccount).Error; err != nil {
 		tx.Rollback()
 		res.Code = codes.CODE_ERR_OBJ_NOT_FOUND
@@ -648,7 +663,7 @@ func GameEndHandler(c *gin.Context) {
 	// 5.3 写 spend（方向=Out，引用 freeze）
 	spendFlow := model.AccountFlow{
 		MainID:     userMain.ID,
-		AssetID:    uint64(0),
+		AssetID:    freezeFlow.AssetID,
 		BizType:    model.FlowSpend,
 		Amount:     freezeFlow.Amount,
 		Direction:  model.DirectionOut,
@@ -668,9 +683,8 @@ func GameEndHandler(c *gin.Context) {
 		return
 	}
 
-	// 5.4 扣冻结
-	userAccount.Frozen -= freezeFlow.Amount
-	if err := tx.Save(&userAccount).Error; err != nil {
+	// 5.4 扣冻结，按费率拆分到平台、开发者和赛季奖池
+	if _, err := service.PostSpend(tx, &userAccount, &spendFlow, gameSession.Catalog); err != nil {
 		tx.Rollback()
 		res.Code = codes.CODE_ERR_UNKNOWN
 		res.Msg = "update balance failed"
@@ -770,8 +784,19 @@ func FreezeHandler(c *gin.Context) {
 		return
 	}
 
+	assetID := service.DefaultAssetID()
+	if req.AssetID != nil {
+		assetID = *req.AssetID
+	}
+	if _, err := service.GetAsset(assetID); err != nil {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "unknown asset"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
 	var userAccount model.AccountBalance
-	db.Model(&model.AccountBalance{}).Where("main_id = ? and asset_id = ?", userId, 0).First(&userAccount)
+	db.Model(&model.AccountBalance{}).Where("main_id = ? and asset_id = ?", userId, assetID).First(&userAccount)
 
 	if userAccount.Available < req.Amount {
 		res.Code = codes.CODE_ERR_BAD_PARAMS
@@ -780,9 +805,17 @@ func FreezeHandler(c *gin.Context) {
 		return
 	}
 	tx := db.Begin()
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", userAccount.ID).First(&userAccount).Error; err != nil {
+		tx.Rollback()
+		res.Code = codes.CODE_ERR_OBJ_NOT_FOUND
+		res.Msg = "user account not found"
+		c.JSON(http.StatusOK, res)
+		return
+	}
 	accountFlow := model.AccountFlow{
 		MainID:         userMain.ID,
-		AssetID:        uint64(0),
+		AssetID:        assetID,
 		BizType:        model.FlowFreeze,
 		Amount:         req.Amount,
 		Direction:      1,
@@ -801,9 +834,10 @@ func FreezeHandler(c *gin.Context) {
 		c.JSON(http.StatusOK, res)
 		return
 	}
-	userAccount.Available -= req.Amount
-	userAccount.Frozen += req.Amount
-	err = tx.Save(&userAccount).Error
+	err = service.PostBalanceChange(tx, &userAccount, model.LedgerBizFreeze, req.Amount, service.LedgerRef{
+		Table: model.LedgerRefAccountFlow,
+		ID:    accountFlow.ID,
+	})
 	if err != nil {
 		tx.Rollback()
 		res.Code = codes.CODE_ERR_UNKNOWN
@@ -821,6 +855,16 @@ func FreezeHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, res)
 }
 
+// lockPendingFreeze selects freeze freezeID of mainID for update, gorm.ErrRecordNotFound when it
+// is not a pending freeze of that user.
+func lockPendingFreeze(tx *gorm.DB, freezeID, mainID uint64) (model.AccountFlow, error) {
+	var flow model.AccountFlow
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND main_id = ? AND biz_type = ? AND status = ?", freezeID, mainID, model.FlowFreeze, model.FlowStatusPending).
+		First(&flow).Error
+	return flow, err
+}
+
 func UnFreezeHandler(c *gin.Context) {
 	var req SpendOrUnfreezeReq
 
@@ -858,29 +902,35 @@ func UnFreezeHandler(c *gin.Context) {
 		return
 	}
 
-	var userAccount model.AccountBalance
-	db.Model(&model.AccountBalance{}).Where("main_id = ? and asset_id = ?", userId, 0).First(&userAccount)
-
-	var accountFlow model.AccountFlow
-	db.Model(&model.AccountFlow{}).Where("id = ?", req.FreezeID).First(&accountFlow)
-	if accountFlow.ID == 0 {
-		res.Code = codes.CODE_ERR_OBJ_NOT_FOUND
-		res.Msg = "freeze not found"
+	// the freeze is read under lock: a refund or the session sweeper may release it concurrently
+	tx := db.Begin()
+	accountFlow, err := lockPendingFreeze(tx, req.FreezeID, userMain.ID)
+	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			res.Code = codes.CODE_ERR_OBJ_NOT_FOUND
+			res.Msg = "freeze not found or been reversed"
+		} else {
+			res.Code = codes.CODE_ERR_UNKNOWN
+			res.Msg = "load freeze failed"
+		}
 		c.JSON(http.StatusOK, res)
 		return
 	}
-	if accountFlow.Status != model.FlowStatusPending {
+
+	var userAccount model.AccountBalance
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("main_id = ? AND asset_id = ?", userMain.ID, accountFlow.AssetID).First(&userAccount).Error; err != nil {
+		tx.Rollback()
 		res.Code = codes.CODE_ERR_OBJ_NOT_FOUND
-		res.Msg = "freeze been reversed"
+		res.Msg = "user account not found"
 		c.JSON(http.StatusOK, res)
 		return
 	}
 
-	tx := db.Begin()
-
 	accountFlow.Status = model.FlowStatusReversed
 	accountFlow.UpdateTime = time.Now()
-	err := tx.Save(&accountFlow).Error
+	err = tx.Save(&accountFlow).Error
 	if err != nil {
 		tx.Rollback()
 		res.Code = codes.CODE_ERR_UNKNOWN
@@ -891,7 +941,7 @@ func UnFreezeHandler(c *gin.Context) {
 
 	unfreezeAccountFlow := model.AccountFlow{
 		MainID:     userMain.ID,
-		AssetID:    uint64(0),
+		AssetID:    accountFlow.AssetID,
 		BizType:    model.FlowUnfreeze,
 		Amount:     accountFlow.Amount,
 		Direction:  0,
@@ -909,9 +959,10 @@ func UnFreezeHandler(c *gin.Context) {
 		c.JSON(http.StatusOK, res)
 		return
 	}
-	userAccount.Frozen -= accountFlow.Amount
-	userAccount.Available += accountFlow.Amount
-	err = tx.Save(&userAccount).Error
+	err = service.PostBalanceChange(tx, &userAccount, model.LedgerBizUnfreeze, accountFlow.Amount, service.LedgerRef{
+		Table: model.LedgerRefAccountFlow,
+		ID:    unfreezeAccountFlow.ID,
+	})
 	if err != nil {
 		tx.Rollback()
 		res.Code = codes.CODE_ERR_UNKNOWN
@@ -967,29 +1018,35 @@ func SpendHandler(c *gin.Context) {
 		return
 	}
 
-	var userAccount model.AccountBalance
-	db.Model(&model.AccountBalance{}).Where("main_id = ? and asset_id = ?", userId, 0).First(&userAccount)
-
-	var accountFlow model.AccountFlow
-	db.Model(&model.AccountFlow{}).Where("id = ?", req.FreezeID).First(&accountFlow)
-	if accountFlow.ID == 0 {
-		res.Code = codes.CODE_ERR_OBJ_NOT_FOUND
-		res.Msg = "freeze not found"
+	// the freeze is read under lock: a refund or the session sweeper may release it concurrently
+	tx := db.Begin()
+	accountFlow, err := lockPendingFreeze(tx, req.FreezeID, userMain.ID)
+	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			res.Code = codes.CODE_ERR_OBJ_NOT_FOUND
+			res.Msg = "freeze not found or been reversed"
+		} else {
+			res.Code = codes.CODE_ERR_UNKNOWN
+			res.Msg = "load freeze failed"
+		}
 		c.JSON(http.StatusOK, res)
 		return
 	}
-	if accountFlow.Status != model.FlowStatusPending {
+
+	var userAccount model.AccountBalance
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("main_id = ? AND asset_id = ?", userMain.ID, accountFlow.AssetID).First(&userAccount).Error; err != nil {
+		tx.Rollback()
 		res.Code = codes.CODE_ERR_OBJ_NOT_FOUND
-		res.Msg = "freeze been reversed"
+		res.Msg = "user account not found"
 		c.JSON(http.StatusOK, res)
 		return
 	}
 
-	tx := db.Begin()
-
 	accountFlow.Status = model.FlowStatusDone
 	accountFlow.UpdateTime = time.Now()
-	err := tx.Save(&accountFlow).Error
+	err = tx.Save(&accountFlow).Error
 	if err != nil {
 		tx.Rollback()
 		res.Code = codes.CODE_ERR_UNKNOWN
@@ -1000,7 +1057,7 @@ func SpendHandler(c *gin.Context) {
 
 	spendAccountFlow := model.AccountFlow{
 		MainID:     userMain.ID,
-		AssetID:    uint64(0),
+		AssetID:    accountFlow.AssetID,
 		BizType:    model.FlowSpend,
 		Amount:     accountFlow.Amount,
 		Direction:  0,
@@ -1009,6 +1066,7 @@ func SpendHandler(c *gin.Context) {
 		AddTime:    time.Now(),
 		UpdateTime: time.Now(),
 		ClientID:   clientId,
+		GameID:     accountFlow.GameID,
 	}
 	err = tx.Save(&spendAccountFlow).Error
 	if err != nil {
@@ -1018,8 +1076,7 @@ func SpendHandler(c *gin.Context) {
 		c.JSON(http.StatusOK, res)
 		return
 	}
-	userAccount.Frozen -= accountFlow.Amount
-	err = tx.Save(&userAccount).Error
+	_, err = service.PostSpend(tx, &userAccount, &spendAccountFlow, "")
 	if err != nil {
 		tx.Rollback()
 		res.Code = codes.CODE_ERR_UNKNOWN
@@ -1037,3 +1094,68 @@ func SpendHandler(c *gin.Context) {
 
 	c.JSON(http.StatusOK, res)
 }
+
+// RefundHandler lets a game client give back a spend or a stuck freeze of its own, e.g. for a
+// voided match or a crashed game. Only flows created by the calling client for the token's user
+// within clientRefundWindow can be refunded.
+func RefundHandler(c *gin.Context) {
+	var req RefundReq
+
+	res := common.Response{}
+	res.Timestamp = time.Now().Unix()
+	res.Code = codes.CODE_SUCCESS
+	res.Msg = "success"
+	res.Data = nil
+
+	if err := c.ShouldBindJSON(&req); err != nil || req.FlowID == 0 {
+		res.Code = codes.CODE_ERR_BAD_PARAMS
+		res.Msg = "invalid param"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	clientId := c.GetString("aud") //appid
+	userId, err := strconv.ParseUint(c.GetString("sub"), 10, 64)
+	if err != nil || userId == 0 {
+		res.Code = codes.CODE_ERR_OBJ_NOT_FOUND
+		res.Msg = "user not found"
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	refund, err := service.RefundFlow(service.RefundRequest{
+		FlowID:   req.FlowID,
+		ClientID: clientId,
+		MainID:   userId,
+		MaxAge:   clientRefundWindow,
+		Remark:   req.Remark,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrFlowNotFound):
+			res.Code = codes.CODE_ERR_OBJ_NOT_FOUND
+			res.Msg = "flow not found"
+		case errors.Is(err, service.ErrRefundForbidden):
+			res.Code = codes.CODE_ERR_SECURITY
+			res.Msg = "flow belongs to another client"
+		case errors.Is(err, service.ErrRefundExpired):
+			res.Code = codes.CODE_ERR_REQ_EXPIRED
+			res.Msg = "refund window has passed, contact the platform"
+		case errors.Is(err, service.ErrFlowNotRefundable):
+			res.Code = codes.CODE_ERR_BAD_PARAMS
+			res.Msg = "flow can not be refunded"
+		default:
+			log.Error("refund flow failed", req.FlowID, err)
+			res.Code = codes.CODE_ERR_UNKNOWN
+			res.Msg = "refund operation failed"
+		}
+		c.JSON(http.StatusOK, res)
+		return
+	}
+
+	res.Data = gin.H{
+		"operation_id":   refund.ID,
+		"operation_type": "refund",
+	}
+	c.JSON(http.StatusOK, res)
+}
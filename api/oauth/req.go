@@ -3,15 +3,21 @@ package oauth
 import "github.com/shopspring/decimal"
 
 type FreezeReq struct {
-	Amount     uint64 `json:"amount"`
-	ExternalID string `json:"external_id"`
-	Remark     string `json:"remark"`
+	Amount     uint64  `json:"amount"`
+	AssetID    *uint64 `json:"asset_id"` // defaults to the platform asset
+	ExternalID string  `json:"external_id"`
+	Remark     string  `json:"remark"`
 }
 
 type SpendOrUnfreezeReq struct {
 	FreezeID uint64 `json:"freeze_id"`
 }
 
+type RefundReq struct {
+	FlowID uint64 `json:"flow_id"` // a spend or a freeze still pending
+	Remark string `json:"remark"`
+}
+
 type GameStartReq struct {
 	SessionID       string `json:"session_id"`
 	PlaySettingCode string `json:"play_setting_code"`
@@ -38,8 +38,8 @@ func Init() *gin.Engine {
 		r.Use(cors.New(cors.Config{
 			AllowOrigins:     []string{"*"},
 			AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-			AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "APPID", "SIG", "TS", "VER", "REQUESTID", "XAUTH", "DAUTH"},
-			ExposeHeaders:    []string{"Content-Length"},
+			AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "APPID", "SIG", "TS", "VER", "REQUESTID", "XAUTH", "DAUTH", "AAUTH", "Idempotency-Key"},
+			ExposeHeaders:    []string{"Content-Length", "Idempotent-Replayed"},
 			AllowCredentials: true,
 		}))
 	}
@@ -78,15 +78,16 @@ func Init() *gin.Engine {
 	{
 		apiGroup2.GET("/me", oauth.MeHandler)
 		apiGroup2.POST("/game/session/init", oauth.GameSessionInitHandler)
-		apiGroup2.POST("/game/start", oauth.GameStartHandler)
-		apiGroup2.POST("/game/end", oauth.GameEndHandler)
+		apiGroup2.POST("/game/start", interceptor.IdempotencyInterceptor(), oauth.GameStartHandler)
+		apiGroup2.POST("/game/end", interceptor.IdempotencyInterceptor(), oauth.GameEndHandler)
 
 		// 高级接口 - 添加额外中间件
 		advancedGroup := apiGroup2.Group("", oauth.AdvancedAuthMiddleware())
 		{
-			advancedGroup.POST("/trans/freeze", oauth.FreezeHandler)
-			advancedGroup.POST("/trans/unfreeze", oauth.UnFreezeHandler)
-			advancedGroup.POST("/trans/spend", oauth.SpendHandler)
+			advancedGroup.POST("/trans/freeze", interceptor.IdempotencyInterceptor(), oauth.FreezeHandler)
+			advancedGroup.POST("/trans/unfreeze", interceptor.IdempotencyInterceptor(), oauth.UnFreezeHandler)
+			advancedGroup.POST("/trans/spend", interceptor.IdempotencyInterceptor(), oauth.SpendHandler)
+			advancedGroup.POST("/trans/refund", interceptor.IdempotencyInterceptor(), oauth.RefundHandler)
 		}
 		//this is directly money control api, need special advanced auth
 		// apiGroup2.POST("/trans/freeze", oauth.FreezeHandler)
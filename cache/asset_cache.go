@@ -0,0 +1,41 @@
+package mycache
+
+import (
+	"time"
+
+	"chaos/api/model"
+
+	"github.com/dgraph-io/ristretto/v2"
+)
+
+const assetCacheTTL = 5 * time.Minute
+
+var AssetCache *ristretto.Cache[uint64, model.SysAsset]
+
+func init() {
+	cache, err := ristretto.NewCache[uint64, model.SysAsset](&ristretto.Config[uint64, model.SysAsset]{
+		NumCounters: 1000,
+		MaxCost:     1000,
+		BufferItems: 64,
+	})
+	if err != nil {
+		panic(err)
+	}
+	AssetCache = cache
+}
+
+// GetAsset 从缓存读取资产配置，ok 表示命中
+func GetAsset(id uint64) (model.SysAsset, bool) {
+	return AssetCache.Get(id)
+}
+
+// SetAsset 写入资产配置到缓存，TTL 5 分钟
+func SetAsset(asset model.SysAsset) {
+	AssetCache.SetWithTTL(asset.ID, asset, 1, assetCacheTTL)
+	AssetCache.Wait()
+}
+
+// DelAsset 资产配置变更后清除缓存
+func DelAsset(id uint64) {
+	AssetCache.Del(id)
+}
@@ -0,0 +1,4 @@
+package abi
+
+// ERC20BalanceABI is balanceOf alone, to read what a TopupLogic contract holds of its token.
+var ERC20BalanceABI = `[{"inputs":[{"internalType":"address","name":"account","type":"address"}],"name":"balanceOf","outputs":[{"internalType":"uint256","name":"","type":"uint256"}],"stateMutability":"view","type":"function"}]`
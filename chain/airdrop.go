@@ -0,0 +1,193 @@
+package chain
+
+import (
+	topupabi "chaos/api/chain/abi"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// AirdropClaim is one Claimed(account, amount) event emitted by a merkle distributor.
+type AirdropClaim struct {
+	ChainID     uint64
+	Contract    string
+	TxHash      string
+	LogIndex    uint
+	BlockNumber uint64
+	BlockHash   string
+	BlockTime   time.Time
+	Account     string
+	Amount      *big.Int // base units of the airdropped token
+}
+
+var (
+	distributorABIOnce sync.Once
+	distributorABI     abi.ABI
+	distributorABIErr  error
+)
+
+func parsedDistributorABI() (abi.ABI, error) {
+	distributorABIOnce.Do(func() {
+		distributorABI, distributorABIErr = abi.JSON(strings.NewReader(topupabi.MerkleDistributorABI))
+	})
+	return distributorABI, distributorABIErr
+}
+
+// decodeAirdropClaim reads account (topic 1) and amount (data) of a Claimed log.
+func decodeAirdropClaim(evt abi.Event, lg types.Log) (AirdropClaim, error) {
+	if len(lg.Topics) < 2 || lg.Topics[0] != evt.ID {
+		return AirdropClaim{}, errors.New("not a Claimed log")
+	}
+	vals, err := evt.Inputs.NonIndexed().Unpack(lg.Data)
+	if err != nil {
+		return AirdropClaim{}, err
+	}
+	if len(vals) != 1 {
+		return AirdropClaim{}, fmt.Errorf("Claimed log carries %d values", len(vals))
+	}
+	amount, ok := vals[0].(*big.Int)
+	if !ok {
+		return AirdropClaim{}, fmt.Errorf("unexpected amount type %T", vals[0])
+	}
+	return AirdropClaim{
+		Contract:    lg.Address.Hex(),
+		TxHash:      lg.TxHash.Hex(),
+		LogIndex:    lg.Index,
+		BlockNumber: lg.BlockNumber,
+		BlockHash:   lg.BlockHash.Hex(),
+		Account:     common.BytesToAddress(lg.Topics[1].Bytes()).Hex(),
+		Amount:      new(big.Int).Set(amount),
+	}, nil
+}
+
+// FetchAirdropClaims returns the Claimed logs of a distributor in blocks [from, to], in chain order.
+func FetchAirdropClaims(ctx context.Context, chainID uint64, contract string, from, to uint64) ([]AirdropClaim, error) {
+	parsed, err := parsedDistributorABI()
+	if err != nil {
+		return nil, fmt.Errorf("parse abi error: %w", err)
+	}
+	evt := parsed.Events["Claimed"]
+	logs, err := fetchContractLogs(ctx, chainID, contract, from, to, evt.ID)
+	if err != nil {
+		return nil, err
+	}
+	claims := make([]AirdropClaim, 0, len(logs))
+	for _, lg := range logs {
+		cl, err := decodeAirdropClaim(evt, lg.Log)
+		if err != nil {
+			return nil, fmt.Errorf("decode log %s/%d: %w", lg.TxHash.Hex(), lg.Index, err)
+		}
+		cl.ChainID = chainID
+		cl.BlockTime = lg.BlockTime
+		claims = append(claims, cl)
+	}
+	return claims, nil
+}
+
+// AirdropMerkleRoot reads the root a distributor pays out against.
+func AirdropMerkleRoot(ctx context.Context, chainID uint64, contract string) (common.Hash, error) {
+	parsed, err := parsedDistributorABI()
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("parse abi error: %w", err)
+	}
+	client, err := chainClient(chainID)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("get client error: %w", err)
+	}
+	data, err := parsed.Pack("merkleRoot")
+	if err != nil {
+		return common.Hash{}, err
+	}
+	to := common.HexToAddress(contract)
+	out, err := client.CallContract(ctx, ethereum.CallMsg{To: &to, Data: data}, nil)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("call merkleRoot error: %w", err)
+	}
+	vals, err := parsed.Unpack("merkleRoot", out)
+	if err != nil || len(vals) != 1 {
+		return common.Hash{}, errors.New("failed to unpack merkleRoot")
+	}
+	root, ok := vals[0].([32]byte)
+	if !ok {
+		return common.Hash{}, fmt.Errorf("unexpected merkleRoot type %T", vals[0])
+	}
+	return root, nil
+}
+
+// AirdropClaimTx is a reported claim tx: pending until it has enough confirmations, then success
+// with the claims it made on the distributor, or failed.
+type AirdropClaimTx struct {
+	Status string
+	Claims []AirdropClaim
+}
+
+// ParseAirdropClaimTx reads the Claimed logs contract emitted in a reported tx.
+func ParseAirdropClaimTx(chainID uint64, contract, txHash string) (*AirdropClaimTx, error) {
+	client, err := chainClient(chainID)
+	if err != nil {
+		return nil, fmt.Errorf("get client error: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	return parseAirdropClaimTx(ctx, client, chainID, contract, txHash)
+}
+
+func parseAirdropClaimTx(ctx context.Context, client TxBackend, chainID uint64, contract, txHash string) (*AirdropClaimTx, error) {
+	parsed, err := parsedDistributorABI()
+	if err != nil {
+		return nil, fmt.Errorf("parse abi error: %w", err)
+	}
+	evt := parsed.Events["Claimed"]
+
+	result := &AirdropClaimTx{Status: "pending"}
+	receipt, err := client.TransactionReceipt(ctx, common.HexToHash(txHash))
+	if errors.Is(err, ethereum.NotFound) {
+		return result, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get receipt error: %w", err)
+	}
+	// the confirmation rules of the TopupLogic txs hold for claims too
+	info := &TopupTxInfo{Status: "success"}
+	if err := checkConfirmations(ctx, client, chainID, receipt, info); err != nil {
+		return nil, err
+	}
+	if info.Status == "pending" {
+		return result, nil
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		result.Status = "failed"
+		return result, nil
+	}
+
+	var blockTime time.Time
+	if header, err := client.HeaderByNumber(ctx, receipt.BlockNumber); err == nil {
+		blockTime = time.Unix(int64(header.Time), 0)
+	}
+	for _, lg := range receipt.Logs {
+		if !strings.EqualFold(lg.Address.Hex(), contract) || len(lg.Topics) == 0 || lg.Topics[0] != evt.ID {
+			continue
+		}
+		cl, err := decodeAirdropClaim(evt, *lg)
+		if err != nil {
+			return nil, fmt.Errorf("decode log %d: %w", lg.Index, err)
+		}
+		cl.ChainID = chainID
+		cl.BlockTime = blockTime
+		result.Claims = append(result.Claims, cl)
+	}
+	result.Status = "success"
+	if len(result.Claims) == 0 {
+		result.Status = "failed"
+	}
+	return result, nil
+}
@@ -0,0 +1,84 @@
+package chain
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestAirdropClaimTx(t *testing.T) {
+	parsed, err := parsedDistributorABI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _ := crypto.GenerateKey()
+	account := crypto.PubkeyToAddress(key.PublicKey)
+	distributor := common.HexToAddress("0x00000000000000000000000000000000000000e0")
+	other := common.HexToAddress("0x00000000000000000000000000000000000000e1")
+	amount := big.NewInt(2500000)
+	chainID := big.NewInt(31337)
+
+	data, err := parsed.Pack("claim", account, amount, [][32]byte{{1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := types.SignTx(types.NewTx(&types.DynamicFeeTx{
+		ChainID: chainID, Nonce: 1, GasTipCap: big.NewInt(1), GasFeeCap: big.NewInt(2), Gas: 100000, To: &distributor, Data: data,
+	}), types.LatestSignerForChainID(chainID), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := &types.Header{Number: big.NewInt(100), Time: 1700000000, Difficulty: big.NewInt(0), Extra: []byte{}}
+	claimed := parsed.Events["Claimed"]
+	logData, _ := claimed.Inputs.NonIndexed().Pack(amount)
+	claimLog := func(addr common.Address) *types.Log {
+		return &types.Log{
+			Address:     addr,
+			Topics:      []common.Hash{claimed.ID, common.BytesToHash(account.Bytes())},
+			Data:        logData,
+			BlockNumber: 100,
+			TxHash:      tx.Hash(),
+			BlockHash:   header.Hash(),
+		}
+	}
+	receipt := &types.Receipt{
+		Type:        types.DynamicFeeTxType,
+		Status:      types.ReceiptStatusSuccessful,
+		TxHash:      tx.Hash(),
+		BlockHash:   header.Hash(),
+		BlockNumber: header.Number,
+		// the same event of another distributor is not a claim on this one
+		Logs: []*types.Log{claimLog(other), claimLog(distributor)},
+	}
+	f := NewTxFixture(chainID.Uint64())
+	f.NetworkID = chainID.String()
+	f.Head = 100
+	f.Txs[fixtureKey(tx.Hash())] = &FixtureTx{Tx: tx}
+	f.Receipts[fixtureKey(tx.Hash())] = receipt
+	f.Headers["100"] = header
+
+	got, err := parseAirdropClaimTx(context.Background(), NewReplayBackend(f), f.ChainID, distributor.Hex(), tx.Hash().Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status != "success" || len(got.Claims) != 1 {
+		t.Fatalf("parsed %+v", got)
+	}
+	cl := got.Claims[0]
+	if cl.Account != account.Hex() || cl.Amount.Cmp(amount) != 0 || cl.Contract != distributor.Hex() || cl.BlockNumber != 100 || cl.BlockTime.Unix() != 1700000000 {
+		t.Fatalf("claim %+v", cl)
+	}
+
+	if got, err := parseAirdropClaimTx(context.Background(), NewReplayBackend(f), f.ChainID, common.HexToAddress("0xe2").Hex(), tx.Hash().Hex()); err != nil || got.Status != "failed" {
+		t.Fatalf("tx without a claim on the distributor: %v %+v", err, got)
+	}
+
+	delete(f.Receipts, fixtureKey(tx.Hash()))
+	if got, err := parseAirdropClaimTx(context.Background(), NewReplayBackend(f), f.ChainID, distributor.Hex(), tx.Hash().Hex()); err != nil || got.Status != "pending" {
+		t.Fatalf("unmined tx: %v %+v", err, got)
+	}
+}
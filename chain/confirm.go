@@ -0,0 +1,115 @@
+package chain
+
+import (
+	"chaos/api/tools"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Confirmations is how many blocks, the including one counted, must exist before a tx of the
+// chain is final. Chains without a setting keep the old behaviour of trusting the first receipt.
+func Confirmations(chainID uint64) uint64 {
+	if c, err := tools.LookupChain(chainID); err == nil {
+		return c.GetConfirmations()
+	}
+	return 1
+}
+
+// confirmedHead is the newest block with enough confirmations on top of it, 0 when none has.
+func confirmedHead(head, confirmations uint64) uint64 {
+	if confirmations <= 1 {
+		return head
+	}
+	if head+1 < confirmations {
+		return 0
+	}
+	return head + 1 - confirmations
+}
+
+// checkConfirmations records the block of a receipt in info and keeps info pending until the
+// block is deep enough and still the canonical block at its height.
+func checkConfirmations(ctx context.Context, client TxBackend, chainID uint64, receipt *types.Receipt, info *TopupTxInfo) error {
+	if receipt.BlockNumber == nil {
+		info.Status = "pending"
+		return nil
+	}
+	info.BlockHash = receipt.BlockHash.Hex()
+
+	head, err := client.BlockNumber(ctx)
+	if err != nil {
+		return fmt.Errorf("get head error: %w", err)
+	}
+	if receipt.BlockNumber.Uint64() > confirmedHead(head, Confirmations(chainID)) {
+		info.Status = "pending"
+		return nil
+	}
+	header, err := client.HeaderByNumber(ctx, receipt.BlockNumber)
+	if err != nil {
+		return fmt.Errorf("get block %s error: %w", receipt.BlockNumber, err)
+	}
+	if header.Hash() != receipt.BlockHash {
+		// the receipt comes from a block that was reorged away, wait for the canonical one
+		info.Status = "pending"
+	}
+	return nil
+}
+
+// TxInclusion is where a tx sits on the canonical chain now.
+type TxInclusion struct {
+	Found       bool // false when the tx is in no canonical block
+	BlockHash   string
+	BlockNumber uint64
+	Success     bool
+}
+
+// LookupTxInclusion re-reads the receipt of a tx, to find out whether a reorg moved or dropped it.
+func LookupTxInclusion(ctx context.Context, chainID uint64, txHash string) (TxInclusion, error) {
+	// 从链注册表选择健康的 RPC，未知链直接报错
+	client, err := chainClient(chainID)
+	if err != nil {
+		return TxInclusion{}, fmt.Errorf("get client error: %w", err)
+	}
+	receipt, err := client.TransactionReceipt(ctx, common.HexToHash(txHash))
+	if errors.Is(err, ethereum.NotFound) {
+		return TxInclusion{}, nil
+	}
+	if err != nil {
+		return TxInclusion{}, fmt.Errorf("get receipt error: %w", err)
+	}
+	if receipt.BlockNumber == nil {
+		return TxInclusion{}, nil
+	}
+	header, err := client.HeaderByNumber(ctx, receipt.BlockNumber)
+	if err != nil {
+		return TxInclusion{}, fmt.Errorf("get block %s error: %w", receipt.BlockNumber, err)
+	}
+	if header.Hash() != receipt.BlockHash {
+		// a node may still serve the receipt of an orphaned block
+		return TxInclusion{}, nil
+	}
+	return TxInclusion{
+		Found:       true,
+		BlockHash:   receipt.BlockHash.Hex(),
+		BlockNumber: receipt.BlockNumber.Uint64(),
+		Success:     receipt.Status == types.ReceiptStatusSuccessful,
+	}, nil
+}
+
+// ConfirmedHead is the newest block of a chain with enough confirmations on top of it.
+func ConfirmedHead(ctx context.Context, chainID uint64) (uint64, error) {
+	head, err := LatestBlock(ctx, chainID)
+	if err != nil {
+		return 0, err
+	}
+	return confirmedHead(head, Confirmations(chainID)), nil
+}
+
+// EvmChains lists the chains of the registry.
+func EvmChains() []*tools.EvmChain {
+	return tools.Chains()
+}
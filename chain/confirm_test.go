@@ -0,0 +1,20 @@
+package chain
+
+import "testing"
+
+func TestConfirmedHead(t *testing.T) {
+	cases := []struct {
+		head, confirmations, want uint64
+	}{
+		{100, 0, 100},
+		{100, 1, 100},
+		{100, 12, 89},
+		{11, 12, 0},
+		{12, 12, 1},
+	}
+	for _, c := range cases {
+		if got := confirmedHead(c.head, c.confirmations); got != c.want {
+			t.Fatalf("confirmedHead(%d, %d) = %d; want %d", c.head, c.confirmations, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package chain
+
+import (
+	"chaos/api/model"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// TopupLogic events emitted by the contract owner rather than by users
+const (
+	EventLockRevoked       = "LockRevoked"
+	EventSwept             = "Swept"
+	EventSignerUpdated     = "SignerUpdated"
+	EventMaxLockTTLUpdated = "MaxLockTTLUpdated"
+)
+
+// ContractEvents are the owner events the contract event follower watches.
+var ContractEvents = []string{EventLockRevoked, EventSwept, EventSignerUpdated, EventMaxLockTTLUpdated}
+
+// ContractEvent is one owner event of a TopupLogic contract. Only the fields of its kind are set.
+type ContractEvent struct {
+	Name        string
+	ChainID     uint64
+	Contract    string
+	TxHash      string
+	LogIndex    uint
+	BlockNumber uint64
+	BlockHash   string
+	BlockTime   time.Time
+
+	User   string   // LockRevoked: owner of the lock
+	LockID [32]byte // LockRevoked
+	To     string   // Swept: receiver of the funds
+	Amount *big.Int // LockRevoked, Swept: base units of the token
+	Signer string   // SignerUpdated: the new lock signer
+	TTL    uint64   // MaxLockTTLUpdated: seconds
+}
+
+// TopupTxInfo turns a LockRevoked event into the confirmed revocation UpdateAccountBalance settles.
+func (e ContractEvent) TopupTxInfo(mainID, refFlowID uint64) *TopupTxInfo {
+	return &TopupTxInfo{
+		TxHash:      e.TxHash,
+		To:          e.Contract,
+		Contract:    e.Contract,
+		Amount:      e.Amount,
+		BlockNumber: e.BlockNumber,
+		BlockHash:   e.BlockHash,
+		BlockTime:   e.BlockTime,
+		Status:      "success",
+		UserFromLog: e.User,
+		MainID:      mainID,
+		RefFlowID:   refFlowID,
+		Op:          model.BalanceFlowOpRevoke,
+		ChainID:     e.ChainID,
+		LockID:      e.LockID,
+		LogIndex:    e.LogIndex,
+	}
+}
+
+// decodeContractEvent reads an owner event. Logs of other events are rejected.
+func decodeContractEvent(parsed abi.ABI, lg types.Log) (ContractEvent, error) {
+	if len(lg.Topics) == 0 {
+		return ContractEvent{}, errors.New("log has no topics")
+	}
+	evt, err := parsed.EventByID(lg.Topics[0])
+	if err != nil {
+		return ContractEvent{}, err
+	}
+	e := ContractEvent{
+		Name:        evt.Name,
+		Contract:    lg.Address.Hex(),
+		TxHash:      lg.TxHash.Hex(),
+		LogIndex:    lg.Index,
+		BlockNumber: lg.BlockNumber,
+		BlockHash:   lg.BlockHash.Hex(),
+	}
+	vals, err := evt.Inputs.NonIndexed().Unpack(lg.Data)
+	if err != nil {
+		return ContractEvent{}, err
+	}
+
+	switch evt.Name {
+	case EventLockRevoked:
+		// LockRevoked(address indexed user, bytes32 indexed lockId, uint256 amount)
+		if len(lg.Topics) < 3 || len(vals) != 1 {
+			return ContractEvent{}, errors.New("malformed LockRevoked log")
+		}
+		e.User = common.BytesToAddress(lg.Topics[1].Bytes()).Hex()
+		e.LockID = lg.Topics[2]
+		e.Amount, err = bigArg(vals[0])
+	case EventSwept:
+		// Swept(address to, uint256 amount)
+		if len(vals) != 2 {
+			return ContractEvent{}, errors.New("malformed Swept log")
+		}
+		to, ok := vals[0].(common.Address)
+		if !ok {
+			return ContractEvent{}, fmt.Errorf("unexpected to type %T", vals[0])
+		}
+		e.To = to.Hex()
+		e.Amount, err = bigArg(vals[1])
+	case EventSignerUpdated:
+		// SignerUpdated(address signer)
+		if len(vals) != 1 {
+			return ContractEvent{}, errors.New("malformed SignerUpdated log")
+		}
+		signer, ok := vals[0].(common.Address)
+		if !ok {
+			return ContractEvent{}, fmt.Errorf("unexpected signer type %T", vals[0])
+		}
+		e.Signer = signer.Hex()
+	case EventMaxLockTTLUpdated:
+		// MaxLockTTLUpdated(uint64 ttl)
+		if len(vals) != 1 {
+			return ContractEvent{}, errors.New("malformed MaxLockTTLUpdated log")
+		}
+		ttl, ok := vals[0].(uint64)
+		if !ok {
+			return ContractEvent{}, fmt.Errorf("unexpected ttl type %T", vals[0])
+		}
+		e.TTL = ttl
+	default:
+		return ContractEvent{}, fmt.Errorf("%s is not an owner event", evt.Name)
+	}
+	if err != nil {
+		return ContractEvent{}, err
+	}
+	return e, nil
+}
+
+func bigArg(v interface{}) (*big.Int, error) {
+	switch n := v.(type) {
+	case *big.Int:
+		return new(big.Int).Set(n), nil
+	case big.Int:
+		return new(big.Int).Set(&n), nil
+	}
+	return nil, fmt.Errorf("unexpected amount type %T", v)
+}
+
+// FetchContractEvents returns the owner events of contract in blocks [from, to], in chain order.
+func FetchContractEvents(ctx context.Context, chainID uint64, contract string, from, to uint64) ([]ContractEvent, error) {
+	parsed, err := parsedTopupABI()
+	if err != nil {
+		return nil, fmt.Errorf("parse abi error: %w", err)
+	}
+	ids := make([]common.Hash, 0, len(ContractEvents))
+	for _, name := range ContractEvents {
+		evt, ok := parsed.Events[name]
+		if !ok {
+			return nil, fmt.Errorf("%s event missing from abi", name)
+		}
+		ids = append(ids, evt.ID)
+	}
+
+	logs, err := fetchContractLogs(ctx, chainID, contract, from, to, ids...)
+	if err != nil {
+		return nil, err
+	}
+	events := make([]ContractEvent, 0, len(logs))
+	for _, lg := range logs {
+		e, err := decodeContractEvent(parsed, lg.Log)
+		if err != nil {
+			return nil, fmt.Errorf("decode log %s/%d: %w", lg.TxHash.Hex(), lg.Index, err)
+		}
+		e.ChainID = chainID
+		e.BlockTime = lg.BlockTime
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+// ParseRevokeLockTx 解析 TopupLogic 的 revokeLock 交易，从 LockRevoked 事件读取用户、lockId 与金额
+func ParseRevokeLockTx(chainID uint64, txHash string) (*TopupTxInfo, error) {
+	return parseLive(chainID, txHash, parseRevokeLockTx)
+}
+
+func parseRevokeLockTx(ctx context.Context, client TxBackend, chainID uint64, txHash string) (*TopupTxInfo, error) {
+	if txHash == "" {
+		return nil, errors.New("empty tx hash")
+	}
+	parsed, err := parsedTopupABI()
+	if err != nil {
+		return nil, fmt.Errorf("parse abi error: %w", err)
+	}
+
+	h := common.HexToHash(txHash)
+	info := &TopupTxInfo{TxHash: txHash, Status: "pending", Op: model.BalanceFlowOpRevoke, ChainID: chainID}
+	_, isPending, err := client.TransactionByHash(ctx, h)
+	if err != nil {
+		return nil, fmt.Errorf("get tx error: %w", err)
+	}
+	if isPending {
+		return info, nil
+	}
+	receipt, err := client.TransactionReceipt(ctx, h)
+	if err != nil {
+		// 可能未上链
+		return info, nil
+	}
+	if receipt.Status == 1 {
+		info.Status = "success"
+	} else {
+		info.Status = "failed"
+	}
+	if err := checkConfirmations(ctx, client, chainID, receipt, info); err != nil {
+		return nil, err
+	}
+	if info.Status != "success" {
+		return info, nil
+	}
+
+	for _, lg := range receipt.Logs {
+		if len(lg.Topics) == 0 || lg.Topics[0] != parsed.Events[EventLockRevoked].ID {
+			continue
+		}
+		e, err := decodeContractEvent(parsed, *lg)
+		if err != nil {
+			return nil, fmt.Errorf("decode log %s/%d: %w", txHash, lg.Index, err)
+		}
+		e.ChainID = chainID
+		e.BlockHash = info.BlockHash
+		if header, err := client.HeaderByNumber(ctx, receipt.BlockNumber); err == nil {
+			e.BlockTime = time.Unix(int64(header.Time), 0)
+		}
+		return e.TopupTxInfo(0, 0), nil
+	}
+	return nil, fmt.Errorf("tx %s has no LockRevoked log", txHash)
+}
@@ -0,0 +1,65 @@
+package chain
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestDecodeContractEvent(t *testing.T) {
+	parsed, err := parsedTopupABI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := common.HexToAddress("0x00000000000000000000000000000000000000a1")
+	to := common.HexToAddress("0x00000000000000000000000000000000000000b2")
+	lockID := common.HexToHash("0x1234")
+	amount := big.NewInt(2500)
+
+	build := func(name string, topics []common.Hash, args ...interface{}) types.Log {
+		evt := parsed.Events[name]
+		data, err := evt.Inputs.NonIndexed().Pack(args...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return types.Log{Topics: append([]common.Hash{evt.ID}, topics...), Data: data, Index: 1}
+	}
+
+	e, err := decodeContractEvent(parsed, build(EventLockRevoked, []common.Hash{common.BytesToHash(user.Bytes()), lockID}, amount))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Name != EventLockRevoked || e.User != user.Hex() || e.LockID != lockID || e.Amount.Cmp(amount) != 0 {
+		t.Fatalf("unexpected LockRevoked %+v", e)
+	}
+
+	e, err = decodeContractEvent(parsed, build(EventSwept, nil, to, amount))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.To != to.Hex() || e.Amount.Cmp(amount) != 0 {
+		t.Fatalf("unexpected Swept %+v", e)
+	}
+
+	e, err = decodeContractEvent(parsed, build(EventSignerUpdated, nil, user))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Signer != user.Hex() {
+		t.Fatalf("unexpected SignerUpdated %+v", e)
+	}
+
+	e, err = decodeContractEvent(parsed, build(EventMaxLockTTLUpdated, nil, uint64(3600)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.TTL != 3600 {
+		t.Fatalf("unexpected MaxLockTTLUpdated %+v", e)
+	}
+
+	if _, err := decodeContractEvent(parsed, build("Deposited", []common.Hash{common.BytesToHash(user.Bytes())}, amount)); err == nil {
+		t.Fatal("decoded a Deposited log as an owner event")
+	}
+}
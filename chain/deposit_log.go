@@ -0,0 +1,190 @@
+package chain
+
+import (
+	topupabi "chaos/api/chain/abi"
+	"chaos/api/model"
+	"chaos/api/tools"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// DepositLog is one Deposited(user, amount) event emitted by a TopupLogic contract.
+type DepositLog struct {
+	ChainID     uint64
+	Contract    string
+	TxHash      string
+	LogIndex    uint
+	BlockNumber uint64
+	BlockHash   string
+	BlockTime   time.Time
+	User        string
+	Amount      *big.Int // base units of the deposited token
+}
+
+// TopupTxInfo turns the log into the confirmed recharge UpdateAccountBalance settles.
+func (d DepositLog) TopupTxInfo(mainID uint64) *TopupTxInfo {
+	return &TopupTxInfo{
+		TxHash:      d.TxHash,
+		From:        d.User,
+		To:          d.Contract,
+		Contract:    d.Contract,
+		Amount:      d.Amount,
+		BlockNumber: d.BlockNumber,
+		BlockHash:   d.BlockHash,
+		BlockTime:   d.BlockTime,
+		Status:      "success",
+		UserFromLog: d.User,
+		MainID:      mainID,
+		Op:          model.BalanceFlowOpRecharge,
+		ChainID:     d.ChainID,
+		LogIndex:    d.LogIndex,
+	}
+}
+
+var (
+	topupABIOnce sync.Once
+	topupABI     abi.ABI
+	topupABIErr  error
+)
+
+func parsedTopupABI() (abi.ABI, error) {
+	topupABIOnce.Do(func() {
+		topupABI, topupABIErr = abi.JSON(strings.NewReader(topupabi.TopupLogicABI))
+	})
+	return topupABI, topupABIErr
+}
+
+// decodeDepositLog reads user (topic 1) and amount (data) of a Deposited log.
+func decodeDepositLog(evt abi.Event, lg types.Log) (DepositLog, error) {
+	if len(lg.Topics) < 2 || lg.Topics[0] != evt.ID {
+		return DepositLog{}, errors.New("not a Deposited log")
+	}
+	vals, err := evt.Inputs.NonIndexed().Unpack(lg.Data)
+	if err != nil {
+		return DepositLog{}, err
+	}
+	if len(vals) != 1 {
+		return DepositLog{}, fmt.Errorf("Deposited log carries %d values", len(vals))
+	}
+	amount, ok := vals[0].(*big.Int)
+	if !ok {
+		return DepositLog{}, fmt.Errorf("unexpected amount type %T", vals[0])
+	}
+	return DepositLog{
+		Contract:    lg.Address.Hex(),
+		TxHash:      lg.TxHash.Hex(),
+		LogIndex:    lg.Index,
+		BlockNumber: lg.BlockNumber,
+		BlockHash:   lg.BlockHash.Hex(),
+		User:        common.BytesToAddress(lg.Topics[1].Bytes()).Hex(),
+		Amount:      new(big.Int).Set(amount),
+	}, nil
+}
+
+// LatestBlock returns the current head of an EVM chain.
+func LatestBlock(ctx context.Context, chainID uint64) (uint64, error) {
+	// 从链注册表选择健康的 RPC，未知链直接报错
+	client, err := chainClient(chainID)
+	if err != nil {
+		return 0, fmt.Errorf("get client error: %w", err)
+	}
+	return client.BlockNumber(ctx)
+}
+
+// FetchDepositLogs returns the Deposited logs of contract in blocks [from, to], in chain order.
+// Removed logs (of a reorged block) are left out.
+func FetchDepositLogs(ctx context.Context, chainID uint64, contract string, from, to uint64) ([]DepositLog, error) {
+	parsed, err := parsedTopupABI()
+	if err != nil {
+		return nil, fmt.Errorf("parse abi error: %w", err)
+	}
+	evt, ok := parsed.Events["Deposited"]
+	if !ok {
+		return nil, errors.New("Deposited event missing from abi")
+	}
+	logs, err := fetchContractLogs(ctx, chainID, contract, from, to, evt.ID)
+	if err != nil {
+		return nil, err
+	}
+	deposits := make([]DepositLog, 0, len(logs))
+	for _, lg := range logs {
+		d, err := decodeDepositLog(evt, lg.Log)
+		if err != nil {
+			return nil, fmt.Errorf("decode log %s/%d: %w", lg.TxHash.Hex(), lg.Index, err)
+		}
+		d.ChainID = chainID
+		d.BlockTime = lg.BlockTime
+		deposits = append(deposits, d)
+	}
+	return deposits, nil
+}
+
+// contractLog is a log together with the time of its block.
+type contractLog struct {
+	types.Log
+	BlockTime time.Time
+}
+
+// fetchContractLogs returns the logs of contract in blocks [from, to] whose topic 0 is one of
+// eventIDs, in chain order. Removed logs (of a reorged block) are left out, and the range fails
+// when a block is no longer the canonical one at its height.
+func fetchContractLogs(ctx context.Context, chainID uint64, contract string, from, to uint64, eventIDs ...common.Hash) ([]contractLog, error) {
+	// 从链注册表选择健康的 RPC，未知链直接报错
+	client, err := chainClient(chainID)
+	if err != nil {
+		return nil, fmt.Errorf("get client error: %w", err)
+	}
+
+	logs, err := client.FilterLogs(ctx, ethereum.FilterQuery{
+		FromBlock: new(big.Int).SetUint64(from),
+		ToBlock:   new(big.Int).SetUint64(to),
+		Addresses: []common.Address{common.HexToAddress(contract)},
+		Topics:    [][]common.Hash{eventIDs},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("filter logs %d-%d error: %w", from, to, err)
+	}
+
+	blockTimes := map[uint64]time.Time{}
+	result := make([]contractLog, 0, len(logs))
+	for _, lg := range logs {
+		if lg.Removed {
+			continue
+		}
+		bt, ok := blockTimes[lg.BlockNumber]
+		if !ok {
+			header, err := client.HeaderByNumber(ctx, new(big.Int).SetUint64(lg.BlockNumber))
+			if err != nil {
+				return nil, fmt.Errorf("get block %d error: %w", lg.BlockNumber, err)
+			}
+			if header.Hash() != lg.BlockHash {
+				return nil, fmt.Errorf("block %d was reorged since the logs were read", lg.BlockNumber)
+			}
+			bt = time.Unix(int64(header.Time), 0)
+			blockTimes[lg.BlockNumber] = bt
+		}
+		result = append(result, contractLog{Log: lg, BlockTime: bt})
+	}
+	return result, nil
+}
+
+// DepositChains lists the registered chains that have a TopupLogic contract to index.
+func DepositChains() []*tools.EvmChain {
+	var chains []*tools.EvmChain
+	for _, c := range tools.Chains() {
+		if c.GetTopupContract() != "" {
+			chains = append(chains, c)
+		}
+	}
+	return chains
+}
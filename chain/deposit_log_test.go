@@ -0,0 +1,40 @@
+package chain
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestDecodeDepositLog(t *testing.T) {
+	parsed, err := parsedTopupABI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	evt := parsed.Events["Deposited"]
+	user := common.HexToAddress("0x00000000000000000000000000000000000000a1")
+	amount, _ := new(big.Int).SetString("1500000000000000000", 10)
+
+	lg := types.Log{
+		Address:     common.HexToAddress("0x00000000000000000000000000000000000000c0"),
+		Topics:      []common.Hash{evt.ID, common.BytesToHash(user.Bytes())},
+		Data:        common.LeftPadBytes(amount.Bytes(), 32),
+		BlockNumber: 42,
+		TxHash:      common.HexToHash("0x01"),
+		Index:       3,
+	}
+	d, err := decodeDepositLog(evt, lg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.User != user.Hex() || d.Amount.Cmp(amount) != 0 || d.LogIndex != 3 || d.BlockNumber != 42 {
+		t.Fatalf("unexpected deposit %+v", d)
+	}
+
+	lg.Topics[0] = parsed.Events["LockRevoked"].ID
+	if _, err := decodeDepositLog(evt, lg); err == nil {
+		t.Fatal("decoded a log of another event")
+	}
+}
@@ -0,0 +1,147 @@
+package chain
+
+import (
+	"chaos/api/tools"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+var (
+	ErrDomainMismatch   = errors.New("eip712 domain mismatch")
+	ErrDomainUnverified = errors.New("eip712 domain not verified")
+	ErrNotTopupContract = errors.New("not the topup contract of the chain")
+)
+
+var eip712DomainTypeHash = crypto.Keccak256Hash([]byte("EIP712Domain(string name,string version,uint256 chainId,address verifyingContract)"))
+
+// eip712DomainFields is the eip712Domain() fields bitmap of name, version, chainId and verifyingContract.
+const eip712DomainFields = 0x0f
+
+// LockAuthDomainSeparator is the EIP-712 domain separator of a TopupLogic contract.
+func LockAuthDomainSeparator(name, version string, chainID uint64, contract common.Address) common.Hash {
+	enc := make([]byte, 0, 32*5)
+	enc = append(enc, eip712DomainTypeHash.Bytes()...)
+	enc = append(enc, crypto.Keccak256([]byte(name))...)
+	enc = append(enc, crypto.Keccak256([]byte(version))...)
+	enc = append(enc, toUint256(new(big.Int).SetUint64(chainID))...)
+	enc = append(enc, leftPadBytes32(contract.Bytes())...)
+	return crypto.Keccak256Hash(enc)
+}
+
+type domainKey struct {
+	chainID  uint64
+	contract common.Address
+}
+
+// lockAuthDomains caches the verified domain separators by chain and contract.
+var lockAuthDomains sync.Map
+
+// IsTopupContract tells whether contract is the TopupLogic contract of the EVM chain chainID.
+func IsTopupContract(chainID uint64, contract string) bool {
+	c, err := tools.LookupChain(chainID)
+	return err == nil && contract != "" && strings.EqualFold(contract, c.GetTopupContract())
+}
+
+// lockAuthDomain returns the domain separator of the chain's TopupLogic contract, verifying it
+// against the contract the first time. Later withdrawals of the chain need no rpc for it.
+func lockAuthDomain(ctx context.Context, chainID uint64, contract common.Address) (common.Hash, error) {
+	if v, ok := lockAuthDomains.Load(domainKey{chainID, contract}); ok {
+		return v.(common.Hash), nil
+	}
+	if !IsTopupContract(chainID, contract.Hex()) {
+		return common.Hash{}, fmt.Errorf("%w: %s on chain %d", ErrNotTopupContract, contract.Hex(), chainID)
+	}
+	return VerifyLockAuthDomain(ctx, chainID, contract.Hex())
+}
+
+// verifiedLockAuthDomain returns the domain separator of contract only once it has been verified.
+func verifiedLockAuthDomain(chainID uint64, contract common.Address) (common.Hash, error) {
+	if v, ok := lockAuthDomains.Load(domainKey{chainID, contract}); ok {
+		return v.(common.Hash), nil
+	}
+	return common.Hash{}, fmt.Errorf("%w: %s on chain %d", ErrDomainUnverified, contract.Hex(), chainID)
+}
+
+// VerifyLockAuthDomain computes the domain separator of contract on chainID from the chain's
+// eip712Name and eip712Version, or from the contract's eip712Domain() when they are not set,
+// checks it against domainSeparator() and caches it. A mismatch is not cached: signing with a
+// domain the contract does not use would hand out tickets that can never be opened.
+func VerifyLockAuthDomain(ctx context.Context, chainID uint64, contractAddr string) (common.Hash, error) {
+	c, err := tools.LookupChain(chainID)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	client, err := chainClient(chainID)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("get client error: %w", err)
+	}
+	contract := common.HexToAddress(contractAddr)
+
+	name, version := c.Eip712Name, c.Eip712Version
+	if name == "" || version == "" {
+		if name, version, err = fetchDomainNameVersion(ctx, client, contract); err != nil {
+			return common.Hash{}, err
+		}
+	}
+	local := LockAuthDomainSeparator(name, version, chainID, contract)
+
+	onchain, err := fetchDomainSeparator(ctx, client, contract)
+	if err != nil {
+		return common.Hash{}, fmt.Errorf("get domainSeparator error: %w", err)
+	}
+	if local != onchain {
+		return common.Hash{}, fmt.Errorf("%w on chain %d contract %s: %q/%q gives %s, the contract uses %s",
+			ErrDomainMismatch, chainID, contract.Hex(), name, version, local.Hex(), onchain.Hex())
+	}
+	lockAuthDomains.Store(domainKey{chainID, contract}, local)
+	return local, nil
+}
+
+// VerifyLockAuthDomains verifies and caches the domain of every chain with a TopupLogic contract.
+func VerifyLockAuthDomains(ctx context.Context) error {
+	var errs error
+	for _, c := range DepositChains() {
+		if _, err := VerifyLockAuthDomain(ctx, c.ChainID, c.GetTopupContract()); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("chain %d: %w", c.ChainID, err))
+		}
+	}
+	return errs
+}
+
+// fetchDomainNameVersion reads name and version from the contract's ERC-5267 eip712Domain().
+func fetchDomainNameVersion(ctx context.Context, client *rpcClient, contract common.Address) (string, string, error) {
+	parsed, err := parsedTopupABI()
+	if err != nil {
+		return "", "", err
+	}
+	data, err := parsed.Pack("eip712Domain")
+	if err != nil {
+		return "", "", err
+	}
+	out, err := client.CallContract(ctx, ethereum.CallMsg{To: &contract, Data: data}, nil)
+	if err != nil {
+		return "", "", fmt.Errorf("call eip712Domain error: %w", err)
+	}
+	vals, err := parsed.Unpack("eip712Domain", out)
+	if err != nil || len(vals) < 3 {
+		return "", "", errors.New("failed to unpack eip712Domain")
+	}
+	fields, ok1 := vals[0].([1]byte)
+	name, ok2 := vals[1].(string)
+	version, ok3 := vals[2].(string)
+	if !ok1 || !ok2 || !ok3 {
+		return "", "", errors.New("unexpected eip712Domain result")
+	}
+	if fields[0] != eip712DomainFields {
+		return "", "", fmt.Errorf("eip712Domain fields %#x, only name, version, chainId and verifyingContract are supported", fields[0])
+	}
+	return name, version, nil
+}
@@ -0,0 +1,96 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// the local domain and digest must be what any EIP-712 wallet computes
+func TestLockAuthDigest(t *testing.T) {
+	contract := common.HexToAddress("0x00000000000000000000000000000000000000c0")
+	user := common.HexToAddress("0x00000000000000000000000000000000000000a1")
+	lockID := common.HexToHash("0x0102030405060708091011121314151617181920212223242526272829303132")
+	amount, _ := new(big.Int).SetString("1234500000000000000000", 10)
+	nonce := big.NewInt(42)
+
+	typed := apitypes.TypedData{
+		Types: apitypes.Types{
+			"EIP712Domain": {
+				{Name: "name", Type: "string"},
+				{Name: "version", Type: "string"},
+				{Name: "chainId", Type: "uint256"},
+				{Name: "verifyingContract", Type: "address"},
+			},
+			"LockAuth": {
+				{Name: "user", Type: "address"},
+				{Name: "lockId", Type: "bytes32"},
+				{Name: "amount", Type: "uint256"},
+				{Name: "expiry", Type: "uint64"},
+				{Name: "nonce", Type: "uint256"},
+			},
+		},
+		PrimaryType: "LockAuth",
+		Domain: apitypes.TypedDataDomain{
+			Name:              "TopupLogic",
+			Version:           "1",
+			ChainId:           math.NewHexOrDecimal256(97),
+			VerifyingContract: contract.Hex(),
+		},
+		Message: apitypes.TypedDataMessage{
+			"user":   user.Hex(),
+			"lockId": hexutil.Encode(lockID[:]),
+			"amount": amount.String(),
+			"expiry": "1700000000",
+			"nonce":  nonce.String(),
+		},
+	}
+	wantDomain, err := typed.HashStruct("EIP712Domain", typed.Domain.Map())
+	if err != nil {
+		t.Fatal(err)
+	}
+	domain := LockAuthDomainSeparator("TopupLogic", "1", 97, contract)
+	if domain != common.BytesToHash(wantDomain) {
+		t.Fatalf("domain %s, want %s", domain.Hex(), hexutil.Encode(wantDomain))
+	}
+
+	want, _, err := apitypes.TypedDataAndHash(typed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := LockAuthDigest(domain, user, lockID, amount, 1700000000, nonce); got != common.BytesToHash(want) {
+		t.Fatalf("digest %s, want %s", got.Hex(), hexutil.Encode(want))
+	}
+
+	if LockAuthDomainSeparator("TopupLogic", "1", 56, contract) == domain {
+		t.Fatal("domain does not depend on the chain")
+	}
+}
+
+// a contract that is not a chain's TopupLogic contract is never verified, nor checked unverified
+func TestLockAuthDomainContract(t *testing.T) {
+	foreign := common.HexToAddress("0x00000000000000000000000000000000000000f0")
+	lockID := common.HexToHash("0x01").Hex()
+	if _, err := BuildLockAuthDigest(context.Background(), 56, foreign.Hex(), foreign.Hex(), lockID, big.NewInt(1), 1, big.NewInt(1)); !errors.Is(err, ErrNotTopupContract) {
+		t.Fatalf("foreign contract: %v", err)
+	}
+	if _, err := VerifiedLockAuthDigest(56, foreign.Hex(), foreign.Hex(), lockID, big.NewInt(1), 1, big.NewInt(1)); !errors.Is(err, ErrDomainUnverified) {
+		t.Fatalf("unverified contract: %v", err)
+	}
+
+	verified := common.HexToAddress("0x00000000000000000000000000000000000000c0")
+	domain := LockAuthDomainSeparator("TopupLogic", "1", 31337, verified)
+	lockAuthDomains.Store(domainKey{31337, verified}, domain)
+	defer lockAuthDomains.Delete(domainKey{31337, verified})
+	user := common.HexToAddress("0x00000000000000000000000000000000000000a1")
+	got, err := VerifiedLockAuthDigest(31337, verified.Hex(), user.Hex(), lockID, big.NewInt(1), 1, big.NewInt(1))
+	if err != nil || got != LockAuthDigest(domain, user, common.HexToHash(lockID), big.NewInt(1), 1, big.NewInt(1)) {
+		t.Fatalf("verified contract: %s, %v", got.Hex(), err)
+	}
+}
@@ -3,17 +3,13 @@ package chain
 import (
 	"context"
 	"errors"
+	"fmt"
 	"math/big"
-	"strings"
-
-	topupabi "chaos/api/chain/abi"
 
 	"github.com/ethereum/go-ethereum"
-	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/crypto"
-	"github.com/ethereum/go-ethereum/ethclient"
 )
 
 // EIP712: LockAuth(address user,bytes32 lockId,uint256 amount,uint64 expiry,uint256 nonce)
@@ -67,14 +63,8 @@ func buildLockAuthStructHash(user common.Address, lockId common.Hash, amount *bi
 }
 
 // fetchDomainSeparator calls domainSeparator() from the verifying contract
-func fetchDomainSeparator(ctx context.Context, rpcURL string, contract common.Address) (common.Hash, error) {
-	client, err := ethclient.DialContext(ctx, rpcURL)
-	if err != nil {
-		return common.Hash{}, err
-	}
-	defer client.Close()
-
-	topupAbi, err := abi.JSON(strings.NewReader(topupabi.TopupLogicABI))
+func fetchDomainSeparator(ctx context.Context, client *rpcClient, contract common.Address) (common.Hash, error) {
+	topupAbi, err := parsedTopupABI()
 	if err != nil {
 		return common.Hash{}, err
 	}
@@ -109,20 +99,33 @@ func fetchDomainSeparator(ctx context.Context, rpcURL string, contract common.Ad
 	}
 }
 
-// BuildAndSignLockAuth computes the EIP-712 digest and signs it with the provided private key
-// chainID is used only to select RPC (via pickRPCByChainID). The contract's domainSeparator is fetched on-chain to avoid domain mismatch.
-func BuildAndSignLockAuth(ctx context.Context, chainID uint64, contractAddr string, privKeyHex string,
-	// mainID uint64,
+// BuildLockAuthDigest computes the EIP-712 digest of a LockAuth.
+// The domain separator is computed locally and checked against the contract once per chain, see VerifyLockAuthDomain.
+func BuildLockAuthDigest(ctx context.Context, chainID uint64, contractAddr string,
 	userAddr string,
-	lockIdHex string, amount *big.Int, expiry uint64, nonce *big.Int) (digest common.Hash, sig []byte, err error) {
+	lockIdHex string, amount *big.Int, expiry uint64, nonce *big.Int) (digest common.Hash, err error) {
 
-	if contractAddr == "" || userAddr == "" || lockIdHex == "" || privKeyHex == "" {
-		return common.Hash{}, nil, errors.New("missing required params")
-	}
+	return buildLockAuthDigest(contractAddr, userAddr, lockIdHex, amount, expiry, nonce, func(contract common.Address) (common.Hash, error) {
+		return lockAuthDomain(ctx, chainID, contract)
+	})
+}
 
-	rpcURL, err := pickRPCByChainID(chainID)
-	if err != nil {
-		return common.Hash{}, nil, err
+// VerifiedLockAuthDigest is BuildLockAuthDigest for a contract whose domain was already verified,
+// at startup or for an earlier signature. It makes no rpc call, so anyone may ask for it.
+func VerifiedLockAuthDigest(chainID uint64, contractAddr string,
+	userAddr string,
+	lockIdHex string, amount *big.Int, expiry uint64, nonce *big.Int) (digest common.Hash, err error) {
+
+	return buildLockAuthDigest(contractAddr, userAddr, lockIdHex, amount, expiry, nonce, func(contract common.Address) (common.Hash, error) {
+		return verifiedLockAuthDomain(chainID, contract)
+	})
+}
+
+func buildLockAuthDigest(contractAddr string, userAddr string, lockIdHex string, amount *big.Int, expiry uint64, nonce *big.Int,
+	domain func(contract common.Address) (common.Hash, error)) (common.Hash, error) {
+
+	if contractAddr == "" || userAddr == "" || lockIdHex == "" {
+		return common.Hash{}, errors.New("missing required params")
 	}
 
 	contract := common.HexToAddress(contractAddr)
@@ -131,14 +134,23 @@ func BuildAndSignLockAuth(ctx context.Context, chainID uint64, contractAddr stri
 	// parse lockId (bytes32)
 	lockIdBytes, err := hexutil.Decode(lockIdHex)
 	if err != nil {
-		return common.Hash{}, nil, err
+		return common.Hash{}, err
 	}
 	if len(lockIdBytes) != 32 {
-		return common.Hash{}, nil, errors.New("lockId must be 32 bytes")
+		return common.Hash{}, errors.New("lockId must be 32 bytes")
 	}
 	var lockId common.Hash
 	copy(lockId[:], lockIdBytes)
 
+	domainSep, err := domain(contract)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return LockAuthDigest(domainSep, user, lockId, amount, expiry, nonce), nil
+}
+
+// LockAuthDigest is keccak256(0x1901 || domainSeparator || structHash) of a LockAuth.
+func LockAuthDigest(domainSep common.Hash, user common.Address, lockId common.Hash, amount *big.Int, expiry uint64, nonce *big.Int) common.Hash {
 	if amount == nil {
 		amount = big.NewInt(0)
 	}
@@ -149,36 +161,53 @@ func BuildAndSignLockAuth(ctx context.Context, chainID uint64, contractAddr stri
 	// struct hash
 	structHash := buildLockAuthStructHash(user, lockId, amount, expiry, nonce)
 
-	// domain separator from chain
-	domainSep, err := fetchDomainSeparator(ctx, rpcURL, contract)
+	// EIP-191 prefix 0x1901
+	prefix := []byte{0x19, 0x01}
+	return crypto.Keccak256Hash(append(append(prefix, domainSep.Bytes()...), structHash.Bytes()...))
+}
+
+// SignLockAuthDigest signs a LockAuth digest with signer and checks the signature recovers to it.
+func SignLockAuthDigest(ctx context.Context, signer Signer, digest common.Hash) ([]byte, error) {
+	signature, err := signer.SignDigest(ctx, digest)
 	if err != nil {
-		return common.Hash{}, nil, err
+		return nil, err
+	}
+	if len(signature) != 65 {
+		return nil, errors.New("invalid signature length")
 	}
 
-	// EIP-191 prefix 0x1901
-	prefix := []byte{0x19, 0x01}
-	digestBytes := crypto.Keccak256(append(append(prefix, domainSep.Bytes()...), structHash.Bytes()...))
-	copy(digest[:], digestBytes)
+	// ensure v is 27/28
+	if signature[64] < 27 {
+		signature[64] += 27
+	}
 
-	// sign
-	privKey, err := crypto.HexToECDSA(strings.TrimPrefix(privKeyHex, "0x"))
+	recovered, err := RecoverLockAuthSigner(digest, signature)
 	if err != nil {
-		return common.Hash{}, nil, err
+		return nil, err
 	}
+	if recovered != signer.Address() {
+		return nil, fmt.Errorf("signature recovers to %s, not signer %s", recovered.Hex(), signer.Address().Hex())
+	}
+	return signature, nil
+}
+
+// BuildAndSignLockAuth computes the EIP-712 digest and signs it with signer
+func BuildAndSignLockAuth(ctx context.Context, chainID uint64, contractAddr string, signer Signer,
+	userAddr string,
+	lockIdHex string, amount *big.Int, expiry uint64, nonce *big.Int) (digest common.Hash, sig []byte, err error) {
 
-	signature, err := crypto.Sign(digest[:], privKey)
+	if signer == nil {
+		return common.Hash{}, nil, errors.New("missing signer")
+	}
+	digest, err = BuildLockAuthDigest(ctx, chainID, contractAddr, userAddr, lockIdHex, amount, expiry, nonce)
 	if err != nil {
 		return common.Hash{}, nil, err
 	}
-
-	// ensure v is 27/28
-	if len(signature) == 65 {
-		if signature[64] < 27 {
-			signature[64] += 27
-		}
+	sig, err = SignLockAuthDigest(ctx, signer, digest)
+	if err != nil {
+		return common.Hash{}, nil, err
 	}
-
-	return digest, signature, nil
+	return digest, sig, nil
 }
 
 // RecoverLockAuthSigner recovers signer address from digest and signature
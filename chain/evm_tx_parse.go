@@ -2,7 +2,6 @@ package chain
 
 import (
 	topupabi "chaos/api/chain/abi"
-	"chaos/api/config"
 	"chaos/api/tools"
 	"context"
 	"errors"
@@ -32,6 +31,9 @@ type TopupTxInfo struct {
 	Op          int
 	ChainID     uint64
 	LockID      [32]byte
+	LogIndex    uint   // index within the block of the event log the tx was settled by
+	BlockHash   string // block the tx was confirmed in, re-checked later for reorgs
+	Native      bool   // a native coin transfer to the treasury, not a contract deposit
 }
 
 type QueuePassObject struct {
@@ -40,60 +42,21 @@ type QueuePassObject struct {
 	MainID    uint64
 	RefFlowID uint64
 	Op        int
-}
-
-// pickRPCByChainID 根据 EVM chainID 选择一个可用的 RPC（取对应配置的第一个）
-func pickRPCByChainID(chainID uint64) (string, error) {
-	// 常见链 ID 到配置名的简单映射（需要与配置文件中的 chain.name 对应）
-	idToName := map[uint64]string{
-		1:     "ETH",
-		56:    "BSC",
-		97:    "BSC_TESTNET",
-		137:   "POLYGON",
-		10:    "OPTIMISM",
-		42161: "ARBITRUM",
-	}
-	if name, ok := idToName[chainID]; ok {
-		if cfg := config.GetRpcConfig(name); cfg != nil {
-			rpcs := cfg.GetRpc()
-			if len(rpcs) > 0 {
-				return rpcs[0], nil
-			}
-		}
-	}
-	// 回退：优先 BSC
-	if cfg := config.GetRpcConfig("BSC"); cfg != nil {
-		rpcs := cfg.GetRpc()
-		if len(rpcs) > 0 {
-			return rpcs[0], nil
-		}
-	}
-	return "", fmt.Errorf("no rpc found for chainID %d", chainID)
+	Native    bool `json:",omitempty"` // recharge by a native coin transfer
 }
 
 // ParseTopupTx 解析 TopupLogic 的 deposit 交易，返回关键信息
-// - 根据传入的 chainID 选择对应 RPC；若未匹配到则回退 BSC 配置
+// - 根据传入的 chainID 从链注册表选择健康的 RPC；未注册的链直接报错
 // - 优先从事件日志解码金额与用户；若无日志则从 input 解码
 func ParseTopupTx(chainID uint64, txHash string) (*TopupTxInfo, error) {
+	return parseLive(chainID, txHash, parseTopupTx)
+}
+
+func parseTopupTx(ctx context.Context, client TxBackend, chainID uint64, txHash string) (*TopupTxInfo, error) {
 	if txHash == "" {
 		return nil, errors.New("empty tx hash")
 	}
 
-	// 根据 chainID 选择 RPC
-	rpcURL, err := pickRPCByChainID(chainID)
-	if err != nil {
-		return nil, err
-	}
-
-	// 连接客户端（复用全局连接池）
-	client, err := tools.GetGlobalClient().GetClient(rpcURL)
-	if err != nil {
-		return nil, fmt.Errorf("get client error: %w", err)
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
-	defer cancel()
-
 	h := common.HexToHash(txHash)
 	tx, isPending, err := client.TransactionByHash(ctx, h)
 	if err != nil {
@@ -157,14 +120,25 @@ func ParseTopupTx(chainID uint64, txHash string) (*TopupTxInfo, error) {
 			info.BlockTime = time.Unix(int64(blk.Time()), 0)
 		}
 	}
+	// 未达到确认数、或收据所在区块已被重组时保持 pending，稍后重试
+	if err := checkConfirmations(ctx, client, chainID, receipt, info); err != nil {
+		return nil, err
+	}
 
-	// 优先从 Deposited 事件读取
+	// 优先从 Deposited 事件读取；链已登记时只认其 TopupLogic 合约发出的事件
+	var topupContract string
+	if c, e := tools.LookupChain(chainID); e == nil {
+		topupContract = c.GetTopupContract()
+	}
 	if evt, ok := parsedABI.Events["Deposited"]; ok {
 		topic0 := evt.ID
 		for _, lg := range receipt.Logs {
 			if len(lg.Topics) == 0 || lg.Topics[0] != topic0 {
 				continue
 			}
+			if topupContract != "" && !strings.EqualFold(lg.Address.Hex(), topupContract) {
+				continue
+			}
 			// 索引: user (topic[1])，非索引: amount (data)
 			if len(lg.Topics) > 1 {
 				info.UserFromLog = common.HexToAddress(lg.Topics[1].Hex()).Hex()
@@ -180,6 +154,7 @@ func ParseTopupTx(chainID uint64, txHash string) (*TopupTxInfo, error) {
 				}
 			}
 			info.Contract = lg.Address.Hex()
+			info.LogIndex = lg.Index
 			break
 		}
 	}
@@ -188,25 +163,14 @@ func ParseTopupTx(chainID uint64, txHash string) (*TopupTxInfo, error) {
 }
 
 func ParseOpenLockTx(chainID uint64, txHash string) (*TopupTxInfo, error) {
+	return parseLive(chainID, txHash, parseOpenLockTx)
+}
+
+func parseOpenLockTx(ctx context.Context, client TxBackend, chainID uint64, txHash string) (*TopupTxInfo, error) {
 	if txHash == "" {
 		return nil, errors.New("empty tx hash")
 	}
 
-	// 根据 chainID 选择 RPC
-	rpcURL, err := pickRPCByChainID(chainID)
-	if err != nil {
-		return nil, err
-	}
-
-	// 连接客户端（复用全局连接池）
-	client, err := tools.GetGlobalClient().GetClient(rpcURL)
-	if err != nil {
-		return nil, fmt.Errorf("get client error: %w", err)
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
-	defer cancel()
-
 	h := common.HexToHash(txHash)
 	tx, isPending, err := client.TransactionByHash(ctx, h)
 	if err != nil {
@@ -270,6 +234,10 @@ func ParseOpenLockTx(chainID uint64, txHash string) (*TopupTxInfo, error) {
 			info.BlockTime = time.Unix(int64(blk.Time()), 0)
 		}
 	}
+	// 未达到确认数、或收据所在区块已被重组时保持 pending，稍后重试
+	if err := checkConfirmations(ctx, client, chainID, receipt, info); err != nil {
+		return nil, err
+	}
 
 	// 优先从 Deposited 事件读取
 	if evt, ok := parsedABI.Events["LockOpened"]; ok {
@@ -301,25 +269,14 @@ func ParseOpenLockTx(chainID uint64, txHash string) (*TopupTxInfo, error) {
 }
 
 func ParseClaimLockedTx(chainID uint64, txHash string) (*TopupTxInfo, error) {
+	return parseLive(chainID, txHash, parseClaimLockedTx)
+}
+
+func parseClaimLockedTx(ctx context.Context, client TxBackend, chainID uint64, txHash string) (*TopupTxInfo, error) {
 	if txHash == "" {
 		return nil, errors.New("empty tx hash")
 	}
 
-	// 根据 chainID 选择 RPC
-	rpcURL, err := pickRPCByChainID(chainID)
-	if err != nil {
-		return nil, err
-	}
-
-	// 连接客户端（复用全局连接池）
-	client, err := tools.GetGlobalClient().GetClient(rpcURL)
-	if err != nil {
-		return nil, fmt.Errorf("get client error: %w", err)
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
-	defer cancel()
-
 	h := common.HexToHash(txHash)
 	tx, isPending, err := client.TransactionByHash(ctx, h)
 	if err != nil {
@@ -383,6 +340,10 @@ func ParseClaimLockedTx(chainID uint64, txHash string) (*TopupTxInfo, error) {
 			info.BlockTime = time.Unix(int64(blk.Time()), 0)
 		}
 	}
+	// 未达到确认数、或收据所在区块已被重组时保持 pending，稍后重试
+	if err := checkConfirmations(ctx, client, chainID, receipt, info); err != nil {
+		return nil, err
+	}
 
 	// 优先从 LockClaimed 事件读取
 	if evt, ok := parsedABI.Events["LockClaimed"]; ok {
@@ -449,25 +410,14 @@ func ParseClaimLockedTx(chainID uint64, txHash string) (*TopupTxInfo, error) {
 }
 
 func ParseCancelLockedTx(chainID uint64, txHash string) (*TopupTxInfo, error) {
+	return parseLive(chainID, txHash, parseCancelLockedTx)
+}
+
+func parseCancelLockedTx(ctx context.Context, client TxBackend, chainID uint64, txHash string) (*TopupTxInfo, error) {
 	if txHash == "" {
 		return nil, errors.New("empty tx hash")
 	}
 
-	// 根据 chainID 选择 RPC
-	rpcURL, err := pickRPCByChainID(chainID)
-	if err != nil {
-		return nil, err
-	}
-
-	// 连接客户端（复用全局连接池）
-	client, err := tools.GetGlobalClient().GetClient(rpcURL)
-	if err != nil {
-		return nil, fmt.Errorf("get client error: %w", err)
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
-	defer cancel()
-
 	h := common.HexToHash(txHash)
 	tx, isPending, err := client.TransactionByHash(ctx, h)
 	if err != nil {
@@ -529,6 +479,10 @@ func ParseCancelLockedTx(chainID uint64, txHash string) (*TopupTxInfo, error) {
 			info.BlockTime = time.Unix(int64(blk.Time()), 0)
 		}
 	}
+	// 未达到确认数、或收据所在区块已被重组时保持 pending，稍后重试
+	if err := checkConfirmations(ctx, client, chainID, receipt, info); err != nil {
+		return nil, err
+	}
 
 	// 优先从 LockCanceled 事件读取
 	// 期望事件签名：LockCanceled(address indexed user, bytes32 indexed lockId, uint256 amount)
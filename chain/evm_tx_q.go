@@ -5,15 +5,25 @@ import (
 	"chaos/api/model"
 	"chaos/api/system"
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"sync"
-	"time"
 )
 
-var topupTxQueue = system.NewRichQueue[QueuePassObject]()
+// TopupTxQueue is the name of the durable queue of tx checks.
+const TopupTxQueue = "topup_tx"
+
+var errTxPending = errors.New("tx not confirmed yet")
+
+var topupTxQueue = system.NewJobQueue(TopupTxQueue)
 var startOnce sync.Once
 
+// AppendTopupTx queues the check of a reported tx. One check per chain, tx and op is live at a
+// time; queueing it again once it is done runs it again.
 func AppendTopupTx(chainID uint64, txHash string, mainID uint64, refFlowID uint64, op int) {
-	topupTxQueue.Enqueue(QueuePassObject{
+	key := fmt.Sprintf("%d-%s-%d", chainID, queueHash(txHash), op)
+	appendTopupJob(key, QueuePassObject{
 		ChainID:   chainID,
 		TxHash:    txHash,
 		MainID:    mainID,
@@ -22,76 +32,85 @@ func AppendTopupTx(chainID uint64, txHash string, mainID uint64, refFlowID uint6
 	})
 }
 
-var topupHashChannel = make(chan *TopupTxInfo, 500)
+// AppendNativeTopupTx queues the check of a reported native coin deposit.
+func AppendNativeTopupTx(chainID uint64, txHash string, mainID uint64, refFlowID uint64) {
+	key := fmt.Sprintf("%d-%s-native", chainID, queueHash(txHash))
+	appendTopupJob(key, QueuePassObject{
+		ChainID:   chainID,
+		TxHash:    txHash,
+		MainID:    mainID,
+		RefFlowID: refFlowID,
+		Op:        model.BalanceFlowOpRecharge,
+		Native:    true,
+	})
+}
+
+func queueHash(txHash string) string {
+	if strings.HasPrefix(txHash, "0x") {
+		// evm hashes are hex and may come in any case; solana signatures are case sensitive
+		return strings.ToLower(txHash)
+	}
+	return txHash
+}
+
+func appendTopupJob(key string, obj QueuePassObject) {
+	if err := topupTxQueue.Enqueue(key, obj); err != nil {
+		log.Errorf("queue topup tx %s failed: %v", key, err)
+	}
+}
 
-func StartTopupConsumer(ctx context.Context) {
+// StartTopupConsumer checks queued txs on chain and hands the confirmed outcome to settle. A
+// tx that is still pending, or whose check or settlement fails, is retried with backoff.
+func StartTopupConsumer(ctx context.Context, settle func(*TopupTxInfo) error) {
 	startOnce.Do(func() {
 		go func() {
 			defer func() {
 				log.Info("Topup consumer goroutine shutting down...")
 			}()
-			topupTxQueue.ConsumerWithContext(ctx, 1, func(chainHash QueuePassObject, lock *sync.WaitGroup) {
-				// parts := strings.SplitN(chainHash, "-", 2)
-				// if len(parts) != 2 {
-				// 	log.Errorf("bad key: %s", chainHash)
-				// 	return
-				// }
-				// cid, err := strconv.ParseUint(parts[0], 10, 64)
-				// if err != nil || len(parts[1]) != 66 || !strings.HasPrefix(parts[1], "0x") {
-				// 	return
-				// }
+			topupTxQueue.Consume(ctx, 1, func(ctx context.Context, job model.Job) error {
+				var chainHash QueuePassObject
+				if err := job.Decode(&chainHash); err != nil {
+					log.Errorf("bad topup job %d payload: %v", job.ID, err)
+					return nil
+				}
 
 				var topupInfo *TopupTxInfo
 				var err error
 				var op = chainHash.Op
-				switch op {
-				case model.BalanceFlowOpRecharge:
+				switch {
+				case op == model.BalanceFlowOpRecharge && chainHash.Native:
+					topupInfo, err = ParseNativeTopupTx(chainHash.ChainID, chainHash.TxHash)
+				case op == model.BalanceFlowOpRecharge && IsSolanaChain(chainHash.ChainID):
+					topupInfo, err = ParseSolanaTopupTx(chainHash.ChainID, chainHash.TxHash)
+				case op == model.BalanceFlowOpRecharge:
 					topupInfo, err = ParseTopupTx(chainHash.ChainID, chainHash.TxHash)
-				case model.BalanceFlowOpFreeze:
+				case op == model.BalanceFlowOpFreeze:
 					topupInfo, err = ParseOpenLockTx(chainHash.ChainID, chainHash.TxHash)
-				case model.BalanceFlowOpWithdraw:
+				case op == model.BalanceFlowOpWithdraw:
 					topupInfo, err = ParseClaimLockedTx(chainHash.ChainID, chainHash.TxHash)
-				case model.BalanceFlowOpUnfreeze:
+				case op == model.BalanceFlowOpUnfreeze:
 					topupInfo, err = ParseCancelLockedTx(chainHash.ChainID, chainHash.TxHash)
-					// case model.BalanceFlowOpWithdraw:
-					// 	topupInfo, err = ParseTopupTx(chainHash.ChainID, chainHash.TxHash)
-					// case model.BalanceFlowOpUnfreeze:
-					// 	topupInfo, err = ParseTopupTx(chainHash.ChainID, chainHash.TxHash)
+				case op == model.BalanceFlowOpRevoke:
+					topupInfo, err = ParseRevokeLockTx(chainHash.ChainID, chainHash.TxHash)
 				}
 
-				// topupInfo, err := ParseTopupTx(chainHash.ChainID, chainHash.TxHash)
 				if err != nil {
-					log.Error("parse topup tx failed: ", err, topupInfo)
-					topupTxQueue.EnqueueWithDelay(chainHash, 10*time.Second)
-					return
+					return fmt.Errorf("parse topup tx failed: %w", err)
 				}
 				if topupInfo == nil {
 					log.Error("unsupported tx object parse, so ignore it: ", chainHash.TxHash)
-					return
+					return nil
 				}
 				if topupInfo.Status == "pending" {
-					log.Info("topup tx pending, will retry after delay", chainHash.TxHash)
-					topupTxQueue.EnqueueWithDelay(chainHash, 5*time.Second)
-					return
+					return errTxPending
 				}
 				topupInfo.MainID = chainHash.MainID
 				topupInfo.RefFlowID = chainHash.RefFlowID
 				topupInfo.Op = chainHash.Op
 				topupInfo.ChainID = chainHash.ChainID
-				publishUpdate(topupInfo)
+				log.Info("prepare for db update", topupInfo)
+				return settle(topupInfo)
 			})
 		}()
 	})
 }
-
-func UpdateTopupTx() <-chan *TopupTxInfo {
-	return topupHashChannel
-}
-
-func publishUpdate(info *TopupTxInfo) {
-	select {
-	case topupHashChannel <- info:
-	default:
-		log.Warnf("topup updates channel full; please handle tx in time manually: %s", info.TxHash)
-	}
-}
@@ -0,0 +1,154 @@
+package chain
+
+import (
+	"chaos/api/tools"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+const defaultLockScanRange = 500
+
+// LockState is what a TopupLogic contract knows of a withdraw lock at its newest confirmed block.
+type LockState struct {
+	Opened      bool     // the lock was opened at some point: a LockOpened log exists for it
+	Amount      *big.Int // locks(user, lockId).amount, zero once canceled or revoked
+	Expiry      uint64
+	Claimed     bool
+	Active      bool // isLockActive(user, lockId)
+	BlockNumber uint64
+	BlockTime   time.Time
+}
+
+// ReadLockState reads lock lockIdHex of user on the contract of chainID. since bounds the search
+// for its LockOpened log: a lock cannot be opened before its authorization was signed.
+func ReadLockState(ctx context.Context, chainID uint64, contract, user, lockIdHex string, since time.Time) (*LockState, error) {
+	c, err := tools.LookupChain(chainID)
+	if err != nil {
+		return nil, err
+	}
+	client, err := chainClient(chainID)
+	if err != nil {
+		return nil, fmt.Errorf("get client error: %w", err)
+	}
+	parsed, err := parsedTopupABI()
+	if err != nil {
+		return nil, fmt.Errorf("parse abi error: %w", err)
+	}
+	lockIdBytes, err := hexutil.Decode(lockIdHex)
+	if err != nil || len(lockIdBytes) != 32 {
+		return nil, fmt.Errorf("invalid lock id %q", lockIdHex)
+	}
+	lockID := common.BytesToHash(lockIdBytes)
+	userAddr := common.HexToAddress(user)
+	contractAddr := common.HexToAddress(contract)
+
+	head, err := ConfirmedHead(ctx, chainID)
+	if err != nil {
+		return nil, err
+	}
+	headBlock := new(big.Int).SetUint64(head)
+	header, err := client.HeaderByNumber(ctx, headBlock)
+	if err != nil {
+		return nil, fmt.Errorf("get block %d error: %w", head, err)
+	}
+	state := &LockState{BlockNumber: head, BlockTime: time.Unix(int64(header.Time), 0)}
+
+	call := func(method string, args ...interface{}) ([]interface{}, error) {
+		data, err := parsed.Pack(method, args...)
+		if err != nil {
+			return nil, err
+		}
+		out, err := client.CallContract(ctx, ethereum.CallMsg{To: &contractAddr, Data: data}, headBlock)
+		if err != nil {
+			return nil, fmt.Errorf("call %s error: %w", method, err)
+		}
+		return parsed.Unpack(method, out)
+	}
+
+	vals, err := call("locks", userAddr, lockID)
+	if err != nil {
+		return nil, err
+	}
+	if len(vals) != 3 {
+		return nil, errors.New("unexpected locks() result")
+	}
+	if state.Amount, err = bigArg(vals[0]); err != nil {
+		return nil, err
+	}
+	expiry, ok1 := vals[1].(uint64)
+	claimed, ok2 := vals[2].(bool)
+	if !ok1 || !ok2 {
+		return nil, errors.New("unexpected locks() result")
+	}
+	state.Expiry, state.Claimed = expiry, claimed
+
+	vals, err = call("isLockActive", userAddr, lockID)
+	if err != nil {
+		return nil, err
+	}
+	active, ok := vals[0].(bool)
+	if len(vals) != 1 || !ok {
+		return nil, errors.New("unexpected isLockActive() result")
+	}
+	state.Active = active
+
+	if state.Amount.Sign() > 0 || state.Claimed {
+		state.Opened = true
+		return state, nil
+	}
+
+	// an empty lock was either never opened or opened and then canceled or revoked: its logs tell
+	from, err := blockAtTime(ctx, client, since, head)
+	if err != nil {
+		return nil, err
+	}
+	step := uint64(c.RangeRound)
+	if step == 0 {
+		step = defaultLockScanRange
+	}
+	evt := parsed.Events["LockOpened"]
+	for lo := from; lo <= head; lo += step {
+		hi := min(lo+step-1, head)
+		logs, err := client.FilterLogs(ctx, ethereum.FilterQuery{
+			FromBlock: new(big.Int).SetUint64(lo),
+			ToBlock:   new(big.Int).SetUint64(hi),
+			Addresses: []common.Address{contractAddr},
+			Topics:    [][]common.Hash{{evt.ID}, {common.BytesToHash(userAddr.Bytes())}, {lockID}},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("filter logs %d-%d error: %w", lo, hi, err)
+		}
+		for _, lg := range logs {
+			if !lg.Removed {
+				state.Opened = true
+				return state, nil
+			}
+		}
+	}
+	return state, nil
+}
+
+// blockAtTime is the first block at or after t, searched in [0, head].
+func blockAtTime(ctx context.Context, client *rpcClient, t time.Time, head uint64) (uint64, error) {
+	lo, hi := uint64(0), head
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		header, err := client.HeaderByNumber(ctx, new(big.Int).SetUint64(mid))
+		if err != nil {
+			return 0, fmt.Errorf("get block %d error: %w", mid, err)
+		}
+		if time.Unix(int64(header.Time), 0).Before(t) {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo, nil
+}
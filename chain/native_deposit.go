@@ -0,0 +1,142 @@
+package chain
+
+import (
+	"chaos/api/model"
+	"chaos/api/tools"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// nativeCoinDecimals is the on-chain scale of the native coin of every EVM chain: wei.
+const nativeCoinDecimals = 18
+
+// nativeRejected holds the chains whose native asset failed CheckNativeAsset, with the reason.
+var nativeRejected sync.Map
+
+// NativeTreasury is where native coin deposits of chainID go, "" when the chain takes none.
+func NativeTreasury(chainID uint64) string {
+	c, err := tools.LookupChain(chainID)
+	if err != nil || !common.IsHexAddress(c.NativeTreasury) {
+		return ""
+	}
+	if _, rejected := nativeRejected.Load(chainID); rejected {
+		return ""
+	}
+	return c.NativeTreasury
+}
+
+// CheckNativeAsset verifies that asset can book the native coin of chainID: a native asset whose
+// chain scale is wei and whose ledger scale fits the ledger. Until a later check passes, a chain
+// whose asset failed takes no native deposits.
+func CheckNativeAsset(chainID uint64, asset model.SysAsset) error {
+	var err error
+	switch {
+	case asset.Type != model.AssetTypeNative:
+		err = fmt.Errorf("asset %d of chain %d has type %q, not %q", asset.ID, chainID, asset.Type, model.AssetTypeNative)
+	case asset.ChainDecimals != nativeCoinDecimals:
+		err = fmt.Errorf("asset %d of chain %d has chain_decimals %d, native coins have %d", asset.ID, chainID, asset.ChainDecimals, nativeCoinDecimals)
+	case asset.Decimals < 0 || asset.Decimals > model.MaxLedgerDecimals:
+		err = fmt.Errorf("asset %d of chain %d has decimals %d, the ledger takes 0-%d", asset.ID, chainID, asset.Decimals, model.MaxLedgerDecimals)
+	}
+	if err != nil {
+		nativeRejected.Store(chainID, err)
+		return err
+	}
+	nativeRejected.Delete(chainID)
+	return nil
+}
+
+// IsNativeAsset reports whether assetID is the native coin deposited on chainID, whose recharges
+// are checked with ParseNativeTopupTx rather than as deposits to the TopupLogic contract.
+func IsNativeAsset(chainID, assetID uint64) bool {
+	c, err := tools.LookupChain(chainID)
+	return err == nil && c.NativeAssetID != 0 && c.NativeAssetID == assetID
+}
+
+// ParseNativeTopupTx verifies a native coin deposit: a plain transfer of value to the chain's
+// treasury. The amount is the value in wei; From is the sender, who must be the user's wallet.
+func ParseNativeTopupTx(chainID uint64, txHash string) (*TopupTxInfo, error) {
+	treasury := NativeTreasury(chainID)
+	if treasury == "" {
+		return nil, fmt.Errorf("chain %d takes no native deposits", chainID)
+	}
+	return parseLive(chainID, txHash, nativeTopupParser(treasury))
+}
+
+func nativeTopupParser(treasury string) txParser {
+	return func(ctx context.Context, client TxBackend, chainID uint64, txHash string) (*TopupTxInfo, error) {
+		return parseNativeTopupTx(ctx, client, chainID, txHash, treasury)
+	}
+}
+
+func parseNativeTopupTx(ctx context.Context, client TxBackend, chainID uint64, txHash, treasury string) (*TopupTxInfo, error) {
+	if txHash == "" {
+		return nil, errors.New("empty tx hash")
+	}
+
+	h := common.HexToHash(txHash)
+	tx, isPending, err := client.TransactionByHash(ctx, h)
+	if err != nil {
+		return nil, fmt.Errorf("get tx error: %w", err)
+	}
+	info := &TopupTxInfo{
+		TxHash:  txHash,
+		To:      treasury,
+		Status:  "pending",
+		Op:      model.BalanceFlowOpRecharge,
+		ChainID: chainID,
+		Native:  true,
+	}
+	nid, err := client.NetworkID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get network id error: %w", err)
+	}
+	sender, err := types.Sender(types.LatestSignerForChainID(nid), tx)
+	if err != nil {
+		return nil, fmt.Errorf("recover sender error: %w", err)
+	}
+	info.From = sender.Hex()
+	info.UserFromLog = info.From
+	if isPending {
+		return info, nil
+	}
+
+	receipt, err := client.TransactionReceipt(ctx, h)
+	if err != nil {
+		// 可能未上链
+		return info, nil
+	}
+	if receipt.BlockNumber != nil {
+		info.BlockNumber = receipt.BlockNumber.Uint64()
+		if blk, e := client.BlockByNumber(ctx, receipt.BlockNumber); e == nil {
+			info.BlockTime = time.Unix(int64(blk.Time()), 0)
+		}
+	}
+	info.Status = nativeDepositStatus(tx, receipt, treasury)
+	if info.Status == "success" {
+		info.Amount = tx.Value()
+	}
+	if err := checkConfirmations(ctx, client, chainID, receipt, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// nativeDepositStatus is success when the mined tx moved value to the treasury itself. Coins
+// forwarded by a contract are internal transfers the tx does not show, so they are not credited.
+func nativeDepositStatus(tx *types.Transaction, receipt *types.Receipt, treasury string) string {
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return "failed"
+	}
+	if tx.To() == nil || !strings.EqualFold(tx.To().Hex(), treasury) || tx.Value().Sign() <= 0 {
+		return "failed"
+	}
+	return "success"
+}
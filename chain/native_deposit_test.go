@@ -0,0 +1,108 @@
+package chain
+
+import (
+	"chaos/api/model"
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// nativeFixture is a confirmed plain transfer of value from a fresh wallet to `to` on chain 31337.
+func nativeFixture(t *testing.T, to common.Address, value *big.Int, status uint64) (*TxFixture, string, common.Address) {
+	t.Helper()
+	key, _ := crypto.GenerateKey()
+	user := crypto.PubkeyToAddress(key.PublicKey)
+	chainID := big.NewInt(31337)
+
+	tx, err := types.SignTx(types.NewTx(&types.DynamicFeeTx{
+		ChainID: chainID, Nonce: 1, GasTipCap: big.NewInt(1), GasFeeCap: big.NewInt(2), Gas: 21000, To: &to, Value: value,
+	}), types.LatestSignerForChainID(chainID), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header := &types.Header{Number: big.NewInt(100), Time: 1700000000, Difficulty: big.NewInt(0), Extra: []byte{}}
+	receipt := &types.Receipt{
+		Type:        types.DynamicFeeTxType,
+		Status:      status,
+		TxHash:      tx.Hash(),
+		BlockHash:   header.Hash(),
+		BlockNumber: header.Number,
+		Logs:        []*types.Log{},
+	}
+	receipt.Bloom = types.CreateBloom(receipt)
+
+	f := NewTxFixture(chainID.Uint64())
+	f.NetworkID = chainID.String()
+	f.Head = 100
+	f.Txs[fixtureKey(tx.Hash())] = &FixtureTx{Tx: tx}
+	f.Receipts[fixtureKey(tx.Hash())] = receipt
+	f.Headers["100"] = header
+	return f, tx.Hash().Hex(), user
+}
+
+func TestNativeTopupTx(t *testing.T) {
+	treasury := common.HexToAddress("0x00000000000000000000000000000000000000d0")
+	parse := nativeTopupParser(treasury.Hex())
+	oneEth, _ := new(big.Int).SetString("1000000000000000001", 10)
+
+	f, hash, user := nativeFixture(t, treasury, oneEth, types.ReceiptStatusSuccessful)
+	info, err := parse(context.Background(), NewReplayBackend(f), f.ChainID, hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Status != "success" || !info.Native || info.Amount.Cmp(oneEth) != 0 || info.From != user.Hex() ||
+		info.UserFromLog != user.Hex() || info.BlockNumber != 100 || info.BlockTime.Unix() != 1700000000 {
+		t.Fatalf("parsed %+v", info)
+	}
+
+	for name, c := range map[string]struct {
+		to     common.Address
+		value  *big.Int
+		status uint64
+	}{
+		"other recipient": {common.HexToAddress("0x00000000000000000000000000000000000000d1"), oneEth, types.ReceiptStatusSuccessful},
+		"zero value":      {treasury, big.NewInt(0), types.ReceiptStatusSuccessful},
+		"reverted":        {treasury, oneEth, types.ReceiptStatusFailed},
+	} {
+		f, hash, _ := nativeFixture(t, c.to, c.value, c.status)
+		info, err := parse(context.Background(), NewReplayBackend(f), f.ChainID, hash)
+		if err != nil || info.Status != "failed" || info.Amount != nil {
+			t.Fatalf("%s: %v %+v", name, err, info)
+		}
+	}
+
+	// not mined yet
+	delete(f.Receipts, fixtureKey(common.HexToHash(hash)))
+	if info, err := parse(context.Background(), NewReplayBackend(f), f.ChainID, hash); err != nil || info.Status != "pending" {
+		t.Fatalf("missing receipt: %v %+v", err, info)
+	}
+}
+
+func TestCheckNativeAsset(t *testing.T) {
+	const chainID = 31337
+	good := model.SysAsset{ID: 12, Type: model.AssetTypeNative, Decimals: 9, ChainDecimals: 18}
+	for _, a := range []model.SysAsset{
+		{ID: 12, Type: model.AssetTypeNative, Decimals: 18},                    // t_asset defaults
+		{ID: 12, Type: model.AssetTypeNative, Decimals: 12, ChainDecimals: 18}, // overflows the ledger
+		{ID: 12, Type: model.AssetTypeNative, Decimals: 9, ChainDecimals: 8},
+		{ID: 12, Type: model.AssetTypeERC20, Decimals: 9, ChainDecimals: 18},
+	} {
+		if err := CheckNativeAsset(chainID, a); err == nil {
+			t.Errorf("accepted %+v", a)
+		}
+		if _, rejected := nativeRejected.Load(uint64(chainID)); !rejected {
+			t.Errorf("chain still takes native deposits into %+v", a)
+		}
+	}
+	if err := CheckNativeAsset(chainID, good); err != nil {
+		t.Fatal(err)
+	}
+	if _, rejected := nativeRejected.Load(uint64(chainID)); rejected {
+		t.Fatal("a valid asset did not enable native deposits again")
+	}
+}
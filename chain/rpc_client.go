@@ -0,0 +1,90 @@
+package chain
+
+import (
+	"chaos/api/tools"
+	"context"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// rpcClient is a client of one rpc of a registered chain that reports how long each call through
+// it took and how it ended, so rpcs are picked by the latency of real calls, and one failing
+// eth_getLogs or eth_call is taken out of rotation even while it still answers eth_blockNumber.
+type rpcClient struct {
+	*ethclient.Client
+	chainID uint64
+}
+
+// chainClient returns a client of a healthy rpc of chainID.
+func chainClient(chainID uint64) (*rpcClient, error) {
+	client, err := tools.GetGlobalClient().GetChainClient(chainID)
+	if err != nil {
+		return nil, err
+	}
+	return &rpcClient{Client: client, chainID: chainID}, nil
+}
+
+func (c *rpcClient) report(start time.Time, err error) {
+	tools.GetGlobalClient().ReportChainCall(c.chainID, c.Client, time.Since(start), err)
+}
+
+func (c *rpcClient) BlockNumber(ctx context.Context) (uint64, error) {
+	start := time.Now()
+	n, err := c.Client.BlockNumber(ctx)
+	c.report(start, err)
+	return n, err
+}
+
+func (c *rpcClient) NetworkID(ctx context.Context) (*big.Int, error) {
+	start := time.Now()
+	id, err := c.Client.NetworkID(ctx)
+	c.report(start, err)
+	return id, err
+}
+
+func (c *rpcClient) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	start := time.Now()
+	header, err := c.Client.HeaderByNumber(ctx, number)
+	c.report(start, err)
+	return header, err
+}
+
+func (c *rpcClient) BlockByNumber(ctx context.Context, number *big.Int) (*types.Block, error) {
+	start := time.Now()
+	block, err := c.Client.BlockByNumber(ctx, number)
+	c.report(start, err)
+	return block, err
+}
+
+func (c *rpcClient) TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error) {
+	start := time.Now()
+	tx, isPending, err := c.Client.TransactionByHash(ctx, hash)
+	c.report(start, err)
+	return tx, isPending, err
+}
+
+func (c *rpcClient) TransactionReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, error) {
+	start := time.Now()
+	receipt, err := c.Client.TransactionReceipt(ctx, hash)
+	c.report(start, err)
+	return receipt, err
+}
+
+func (c *rpcClient) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	start := time.Now()
+	logs, err := c.Client.FilterLogs(ctx, q)
+	c.report(start, err)
+	return logs, err
+}
+
+func (c *rpcClient) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	start := time.Now()
+	out, err := c.Client.CallContract(ctx, msg, blockNumber)
+	c.report(start, err)
+	return out, err
+}
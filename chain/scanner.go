@@ -22,7 +22,7 @@ type TailScannerConfig struct {
 	StatePath  string   // 必填：进度文件，例如 "state.json"
 	CSVPath    string   // 必填：结果 CSV，例如 "trades_tail.csv"
 
-	FlushEvery   time.Duration // 可选：CSV flush 周期，默认 2s
+	FlushEvery   time.Duration // 已不使用：每个有结果的 slot 都会 flush
 	SaveEveryN   uint64        // 可选：每处理 N 个 slot 也保存一次进度，默认 100
 	HeadIdleWait time.Duration // 可选：追到最新时的等待时间，默认 1s
 }
@@ -32,156 +32,33 @@ type tailState struct {
 }
 
 /*************** 运行入口（可用 goroutine 启动） ***************/
+// RunTailScanner 监控一组固定钱包在一个 mint 上的买卖，结果追加到 CSV，进度写入 JSON 文件。
+// 它是 SolanaWatcher 的一种固定配置。
 func RunTailScanner(ctx context.Context, cfg TailScannerConfig) error {
 	if cfg.RPCURL == "" || len(cfg.Wallets) == 0 || cfg.TargetMint == "" ||
 		cfg.StatePath == "" || cfg.CSVPath == "" {
 		return fmt.Errorf("invalid config")
 	}
-	if cfg.FlushEvery == 0 {
-		cfg.FlushEvery = 2 * time.Second
-	}
-	if cfg.SaveEveryN == 0 {
-		cfg.SaveEveryN = 100
-	}
-	if cfg.HeadIdleWait == 0 {
-		cfg.HeadIdleWait = 1 * time.Second
-	}
-
-	// 钱包集合
-	wSet := make(map[string]struct{}, len(cfg.Wallets))
-	for _, w := range cfg.Wallets {
-		wSet[w] = struct{}{}
-	}
-
-	// 进度
-	st, _ := loadState(cfg.StatePath)
-	if st.LastProcessed < cfg.StartSlot {
-		st.LastProcessed = cfg.StartSlot
-	}
-	fmt.Printf("[scanner] resume from slot %d\n", st.LastProcessed)
-
-	// CSV append
-	writer, file, err := openCSVAppend(cfg.CSVPath)
+	sink, err := NewCSVSink(cfg.CSVPath)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
-
-	flushTicker := time.NewTicker(cfg.FlushEvery)
-	defer flushTicker.Stop()
-
-	// 主循环（可长期运行）
-	for {
-		select {
-		case <-ctx.Done():
-			writer.Flush()
-			_ = writer.Error()
-			_ = saveState(cfg.StatePath, st)
-			return ctx.Err()
-		default:
-		}
-
-		// 最新 head
-		head, err := getSlot(cfg.RPCURL)
-		if err != nil {
-			fmt.Println("[scanner] getSlot err:", err)
-			time.Sleep(2 * time.Second)
-			continue
-		}
-		if st.LastProcessed >= head {
-			time.Sleep(cfg.HeadIdleWait)
-			continue
-		}
-
-		// 逐 slot 扫描
-		begin := st.LastProcessed + 1
-		for slot := begin; slot <= head; slot++ {
-			select {
-			case <-ctx.Done():
-				writer.Flush()
-				_ = writer.Error()
-				_ = saveState(cfg.StatePath, st)
-				return ctx.Err()
-			default:
-			}
-
-			br, err := getBlock(cfg.RPCURL, slot)
-			if err != nil {
-				fmt.Printf("[scanner] getBlock(%d) err: %v\n", slot, err)
-				st.LastProcessed = slot
-				if slot%cfg.SaveEveryN == 0 {
-					_ = saveState(cfg.StatePath, st)
-				}
-				continue
-			}
-			if br == nil || len(br.Transactions) == 0 {
-				// skipped / 空块
-				st.LastProcessed = slot
-				if slot%cfg.SaveEveryN == 0 {
-					_ = saveState(cfg.StatePath, st)
-				}
-				continue
-			}
+	defer sink.Close()
 
-			var ts string
-			if br.BlockTime != nil {
-				ts = time.Unix(*br.BlockTime, 0).UTC().Format(time.RFC3339)
-			}
-
-			for _, tx := range br.Transactions {
-				if tx.Meta == nil {
-					continue
-				}
-				feeSOL := fmt.Sprintf("%.9f", float64(tx.Meta.Fee)/1e9)
-				sig := ""
-				if len(tx.Transaction.Signatures) > 0 {
-					sig = tx.Transaction.Signatures[0]
-				}
-
-				// 先找这笔交易中，跟我们目标 mint 且 owner 在钱包集合的账户
-				owners := ownersHit(tx.Meta, cfg.TargetMint, wSet)
-				if len(owners) == 0 {
-					continue
-				}
-				for owner := range owners {
-					delta, dec, ok := calcDeltaFor(owner, cfg.TargetMint, tx.Meta)
-					if !ok || delta.Sign() == 0 {
-						continue
-					}
-					dir := "BUY"
-					if delta.Sign() < 0 {
-						dir = "SELL"
-					}
-					deltaAbs := new(big.Rat).Abs(delta)
-					// 用代币 decimals 打印，避免精度损失
-					deltaStr := deltaAbs.FloatString(dec)
-					okStr := "true"
-					if tx.Meta.Err != nil {
-						okStr = "false"
-					}
-
-					_ = writer.Write([]string{
-						fmt.Sprint(slot), ts, sig, owner, dir, deltaStr, cfg.TargetMint, feeSOL, okStr,
-					})
-				}
-			}
-
-			// 定期 flush + 保存进度
-			st.LastProcessed = slot
-			select {
-			case <-flushTicker.C:
-				writer.Flush()
-				_ = writer.Error()
-				_ = saveState(cfg.StatePath, st)
-			default:
-			}
-		}
-
-		// 扫到最新再落一次
-		writer.Flush()
-		_ = writer.Error()
-		_ = saveState(cfg.StatePath, st)
+	w, err := NewSolanaWatcher(SolanaWatcherConfig{
+		RPCURLs:      []string{cfg.RPCURL},
+		Mints:        []string{cfg.TargetMint},
+		Wallets:      StaticWallets(cfg.Wallets),
+		Sink:         sink,
+		Progress:     FileProgress(cfg.StatePath),
+		StartSlot:    cfg.StartSlot,
+		SaveEveryN:   cfg.SaveEveryN,
+		HeadIdleWait: cfg.HeadIdleWait,
+	})
+	if err != nil {
+		return err
 	}
+	return w.Run(ctx)
 }
 
 /*************** RPC 与数据类型 ***************/
@@ -195,6 +72,22 @@ type rpcErr struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
 }
+
+// Solana rpc 错误码：slot 被跳过，或节点上已没有该区块
+const (
+	rpcSlotSkipped       = -32007
+	rpcBlockNotAvailable = -32009
+)
+
+type solanaRpcError struct {
+	Method string
+	rpcErr
+}
+
+func (e *solanaRpcError) Error() string {
+	return fmt.Sprintf("rpc %s error %d: %s", e.Method, e.Code, e.Message)
+}
+
 type rpcResp[T any] struct {
 	Jsonrpc string  `json:"jsonrpc"`
 	ID      int     `json:"id"`
@@ -214,7 +107,7 @@ func rpcCall[T any](rpcURL, method string, params []interface{}, out *T) error {
 		return err
 	}
 	if wrap.Error != nil {
-		return fmt.Errorf("rpc %s error %d: %s", method, wrap.Error.Code, wrap.Error.Message)
+		return &solanaRpcError{Method: method, rpcErr: *wrap.Error}
 	}
 	*out = wrap.Result
 	return nil
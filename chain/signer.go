@@ -0,0 +1,138 @@
+package chain
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Signer signs LockAuth digests for withdrawals. The key may live in this process or behind a
+// signing service; callers only see its address.
+type Signer interface {
+	Address() common.Address
+	// SignDigest returns the 65 byte [R || S || V] signature of digest.
+	SignDigest(ctx context.Context, digest common.Hash) ([]byte, error)
+}
+
+// KeySigner signs with a private key held in memory.
+type KeySigner struct {
+	key     *ecdsa.PrivateKey
+	address common.Address
+}
+
+// NewKeySigner holds a hex private key in memory. Meant for tests and local development.
+func NewKeySigner(privKeyHex string) (*KeySigner, error) {
+	key, err := crypto.HexToECDSA(strings.TrimPrefix(privKeyHex, "0x"))
+	if err != nil {
+		return nil, err
+	}
+	return &KeySigner{key: key, address: crypto.PubkeyToAddress(key.PublicKey)}, nil
+}
+
+// UnlockKeystore decrypts an encrypted JSON keystore file with passphrase.
+func UnlockKeystore(path, passphrase string) (*KeySigner, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	k, err := keystore.DecryptKey(b, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("unlock keystore %s: %w", path, err)
+	}
+	return &KeySigner{key: k.PrivateKey, address: k.Address}, nil
+}
+
+func (s *KeySigner) Address() common.Address {
+	return s.address
+}
+
+func (s *KeySigner) SignDigest(_ context.Context, digest common.Hash) ([]byte, error) {
+	return crypto.Sign(digest[:], s.key)
+}
+
+// RemoteSigner asks a signing service to sign. The service receives
+// POST {url}/sign {"address": "0x..", "digest": "0x.."} and answers {"signature": "0x.."}.
+type RemoteSigner struct {
+	url     string
+	token   string
+	address common.Address
+	client  *http.Client
+}
+
+func NewRemoteSigner(url, token string, address common.Address) *RemoteSigner {
+	return &RemoteSigner{
+		url:     strings.TrimSuffix(url, "/"),
+		token:   token,
+		address: address,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// RemoteSignRequest is the body sent to a remote signer.
+type RemoteSignRequest struct {
+	Address string `json:"address"`
+	Digest  string `json:"digest"`
+}
+
+// RemoteSignResponse is the body a remote signer answers with.
+type RemoteSignResponse struct {
+	Signature string `json:"signature"`
+	Error     string `json:"error,omitempty"`
+}
+
+func (s *RemoteSigner) Address() common.Address {
+	return s.address
+}
+
+func (s *RemoteSigner) SignDigest(ctx context.Context, digest common.Hash) ([]byte, error) {
+	body, err := json.Marshal(RemoteSignRequest{Address: s.address.Hex(), Digest: digest.Hex()})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url+"/sign", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("remote signer: %w", err)
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+	if err != nil {
+		return nil, err
+	}
+	var out RemoteSignResponse
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, fmt.Errorf("remote signer status %d: %s", resp.StatusCode, b)
+	}
+	if resp.StatusCode != http.StatusOK || out.Error != "" {
+		return nil, fmt.Errorf("remote signer status %d: %s", resp.StatusCode, out.Error)
+	}
+	sig, err := hexutil.Decode(out.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("remote signer signature: %w", err)
+	}
+	if len(sig) != 65 {
+		return nil, errors.New("remote signer returned an invalid signature length")
+	}
+	return sig, nil
+}
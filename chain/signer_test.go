@@ -0,0 +1,96 @@
+package chain
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
+)
+
+const testSignerKey = "4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318"
+
+// stubRemoteSigner serves the remote signer protocol with a key held by the test.
+func stubRemoteSigner(t *testing.T, key *KeySigner, token string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/sign" || r.Header.Get("Authorization") != "Bearer "+token {
+			w.WriteHeader(http.StatusUnauthorized)
+			_ = json.NewEncoder(w).Encode(RemoteSignResponse{Error: "unauthorized"})
+			return
+		}
+		var req RemoteSignRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+			return
+		}
+		sig, err := key.SignDigest(r.Context(), common.HexToHash(req.Digest))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(RemoteSignResponse{Signature: hexutil.Encode(sig)})
+	}))
+}
+
+func TestSigners(t *testing.T) {
+	ctx := context.Background()
+	digest := crypto.Keccak256Hash([]byte("lock auth"))
+
+	mem, err := NewKeySigner("0x" + testSignerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// keystore, encrypted with light scrypt to keep the test fast
+	k := &keystore.Key{Id: uuid.New(), PrivateKey: mem.key, Address: mem.Address()}
+	enc, err := keystore.EncryptKey(k, "secret", keystore.LightScryptN, keystore.LightScryptP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "signer.json")
+	if err := os.WriteFile(path, enc, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := UnlockKeystore(path, "wrong"); err == nil {
+		t.Fatal("keystore unlocked with a wrong passphrase")
+	}
+	ks, err := UnlockKeystore(path, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := stubRemoteSigner(t, mem, "token")
+	defer srv.Close()
+	remote := NewRemoteSigner(srv.URL, "token", mem.Address())
+
+	for name, s := range map[string]Signer{"memory": mem, "keystore": ks, "remote": remote} {
+		if s.Address() != mem.Address() {
+			t.Errorf("%s: address %s, want %s", name, s.Address().Hex(), mem.Address().Hex())
+		}
+		sig, err := SignLockAuthDigest(ctx, s, digest)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if sig[64] != 27 && sig[64] != 28 {
+			t.Errorf("%s: v = %d", name, sig[64])
+		}
+	}
+
+	if _, err := NewRemoteSigner(srv.URL, "bad", mem.Address()).SignDigest(ctx, digest); err == nil {
+		t.Error("remote signer accepted a bad token")
+	}
+	// a service signing with another key than the one configured is refused
+	other := NewRemoteSigner(srv.URL, "token", common.HexToAddress("0x00000000000000000000000000000000000000a1"))
+	if _, err := SignLockAuthDigest(ctx, other, digest); err == nil {
+		t.Error("signature of another key accepted")
+	}
+}
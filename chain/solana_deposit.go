@@ -0,0 +1,135 @@
+package chain
+
+import (
+	"chaos/api/config"
+	"chaos/api/model"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/mr-tron/base58"
+)
+
+// IsSolanaChain tells whether chainID is the id Solana top-ups are recorded under.
+func IsSolanaChain(chainID uint64) bool {
+	cfg := config.GetConfig().Solana
+	return cfg.Enable && cfg.ChainID != 0 && chainID == cfg.ChainID
+}
+
+// IsSolanaSignature tells whether s looks like a base58 transaction signature.
+func IsSolanaSignature(s string) bool {
+	b, err := base58.Decode(s)
+	return err == nil && len(b) == 64
+}
+
+// IsSolanaAddress tells whether s looks like a base58 account address.
+func IsSolanaAddress(s string) bool {
+	b, err := base58.Decode(s)
+	return err == nil && len(b) == 32
+}
+
+// SolanaRpcs are the rpc endpoints of the SOLANA chain entry.
+func SolanaRpcs() []string {
+	c := config.GetRpcConfig("SOLANA")
+	if c == nil {
+		return nil
+	}
+	return c.GetRpc()
+}
+
+// ParseSolanaTopupTx verifies an SPL top-up: a finalized tx moving the configured mint into the
+// treasury. The amount is what the treasury received; From is the wallet that paid the most.
+func ParseSolanaTopupTx(chainID uint64, signature string) (*TopupTxInfo, error) {
+	cfg := config.GetConfig().Solana
+	if cfg.Mint == "" || cfg.Treasury == "" {
+		return nil, errors.New("solana top-up is not configured")
+	}
+	if !IsSolanaSignature(signature) {
+		return nil, fmt.Errorf("invalid solana signature %q", signature)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	info := &TopupTxInfo{
+		TxHash:   signature,
+		To:       cfg.Treasury,
+		Contract: cfg.Mint,
+		Status:   "pending",
+		Op:       model.BalanceFlowOpRecharge,
+		ChainID:  chainID,
+	}
+	lastErr := errors.New("no solana rpc configured")
+	for _, rpc := range SolanaRpcs() {
+		var st signatureStatuses
+		err := rpcCall(rpc, "getSignatureStatuses", []interface{}{[]string{signature}, map[string]any{"searchTransactionHistory": true}}, &st)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		// finalized slots cannot be rolled back, so they need no reorg check afterwards
+		if len(st.Value) == 0 || st.Value[0].ConfirmationStatus == nil || *st.Value[0].ConfirmationStatus != "finalized" {
+			return info, nil
+		}
+		analysis, err := AnalyzeTxMintDelta(ctx, rpc, signature, cfg.Mint)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return solanaDepositInfo(info, analysis, cfg.Treasury)
+	}
+	return nil, fmt.Errorf("solana rpc: %w", lastErr)
+}
+
+// solanaDepositInfo reads the treasury credit and the paying wallet out of a mint analysis.
+func solanaDepositInfo(info *TopupTxInfo, a *TxMintAnalysis, treasury string) (*TopupTxInfo, error) {
+	info.BlockNumber = a.Slot
+	if a.BlockTime != nil {
+		info.BlockTime = *a.BlockTime
+	}
+	if !a.Success {
+		info.Status = "failed"
+		return info, nil
+	}
+
+	var received *big.Int
+	var payer *big.Int
+	for _, d := range a.Deltas {
+		units, err := mintDeltaUnits(d)
+		if err != nil {
+			return nil, err
+		}
+		if d.Owner == treasury {
+			received = units
+			continue
+		}
+		if units.Sign() < 0 && (payer == nil || units.Cmp(payer) < 0) {
+			payer = units
+			info.From = d.Owner
+		}
+	}
+	if received == nil || received.Sign() <= 0 {
+		// the tx did not pay the treasury: nothing to credit
+		info.Status = "failed"
+		return info, nil
+	}
+	info.Amount = received
+	info.UserFromLog = info.From
+	info.Status = "success"
+	return info, nil
+}
+
+// mintDeltaUnits turns a formatted delta back into base units of the mint.
+func mintDeltaUnits(d TxMintDelta) (*big.Int, error) {
+	r, ok := new(big.Rat).SetString(d.Delta)
+	if !ok {
+		return nil, fmt.Errorf("invalid delta %q of %s", d.Delta, d.Owner)
+	}
+	r.Mul(r, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(d.Decimals)), nil)))
+	if !r.IsInt() {
+		return nil, fmt.Errorf("delta %q of %s has more than %d decimals", d.Delta, d.Owner, d.Decimals)
+	}
+	return new(big.Int).Set(r.Num()), nil
+}
@@ -0,0 +1,51 @@
+package chain
+
+import (
+	"testing"
+)
+
+func TestSolanaDepositInfo(t *testing.T) {
+	treasury := "Treasury1111111111111111111111111111111111"
+	payer := "Payer11111111111111111111111111111111111111"
+	a := &TxMintAnalysis{
+		Signature: "sig",
+		Success:   true,
+		Slot:      42,
+		Deltas: []TxMintDelta{
+			{Owner: payer, Delta: "-12.500000", Decimals: 6},
+			{Owner: "Router1111111111111111111111111111111111111", Delta: "-0.000001", Decimals: 6},
+			{Owner: treasury, Delta: "12.500001", Decimals: 6},
+		},
+	}
+	info, err := solanaDepositInfo(&TopupTxInfo{}, a, treasury)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Status != "success" || info.Amount.String() != "12500001" || info.From != payer || info.BlockNumber != 42 {
+		t.Fatalf("got status %s amount %s from %s slot %d", info.Status, info.Amount, info.From, info.BlockNumber)
+	}
+
+	// nothing reached the treasury
+	a.Deltas = a.Deltas[:2]
+	if info, _ := solanaDepositInfo(&TopupTxInfo{}, a, treasury); info.Status != "failed" {
+		t.Fatalf("tx not paying the treasury is %s", info.Status)
+	}
+
+	a.Success = false
+	if info, _ := solanaDepositInfo(&TopupTxInfo{}, a, treasury); info.Status != "failed" {
+		t.Fatalf("failed tx is %s", info.Status)
+	}
+
+	if _, err := mintDeltaUnits(TxMintDelta{Owner: payer, Delta: "1.0000001", Decimals: 6}); err == nil {
+		t.Fatal("delta finer than the mint decimals accepted")
+	}
+}
+
+func TestIsSolanaSignature(t *testing.T) {
+	if !IsSolanaSignature("32ueEjtQ6B5ih3YvNshPPAVwrHMy1n4wGhjCFqZwC26k3tNV3wRtY1smfzKYJTtCwAL6fRTqcGCTEPTM7Vfq5dGw") {
+		t.Fatal("valid signature rejected")
+	}
+	if IsSolanaSignature("0x9f3a0c2b7e4d1f6a8b5c3e2d1f0a9b8c7d6e5f4a3b2c1d0e9f8a7b6c5d4e3f2a") {
+		t.Fatal("evm hash accepted")
+	}
+}
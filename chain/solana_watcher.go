@@ -0,0 +1,379 @@
+package chain
+
+import (
+	"chaos/api/log"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+)
+
+// SolanaTrade is a change of the balance a watched wallet holds in a watched mint.
+type SolanaTrade struct {
+	Slot       uint64     `json:"slot"`
+	BlockTime  *time.Time `json:"block_time,omitempty"`
+	Signature  string     `json:"signature"`
+	Owner      string     `json:"owner"`
+	Mint       string     `json:"mint"`
+	Side       string     `json:"side"`   // BUY when the balance grew, SELL when it shrank
+	Amount     string     `json:"amount"` // absolute change, decimal in token units
+	Decimals   int        `json:"decimals"`
+	FeeLamport uint64     `json:"fee_lamport"`
+	Success    bool       `json:"success"`
+}
+
+// TradeSink receives the trades of each scanned slot. An error makes the watcher scan the slot
+// again, so sinks must tolerate a slot written twice.
+type TradeSink interface {
+	WriteTrades(ctx context.Context, trades []SolanaTrade) error
+}
+
+// SlotProgress keeps the last fully processed slot.
+type SlotProgress interface {
+	LoadSlot() (uint64, error)
+	SaveSlot(slot uint64) error
+}
+
+// WalletSource returns the wallets to watch. It is called again every WalletRefresh, so the set
+// may change while the watcher runs.
+type WalletSource func(ctx context.Context) ([]string, error)
+
+// StaticWallets watches a fixed set of wallets.
+func StaticWallets(wallets []string) WalletSource {
+	return func(context.Context) ([]string, error) {
+		return wallets, nil
+	}
+}
+
+type SolanaWatcherConfig struct {
+	RPCURLs  []string // tried in turn when one fails
+	Mints    []string
+	Wallets  WalletSource
+	Sink     TradeSink
+	Progress SlotProgress
+
+	StartSlot     uint64        // first slot is StartSlot+1 when there is no progress; the head when 0
+	SaveEveryN    uint64        // save the progress every N slots, default 100
+	HeadIdleWait  time.Duration // wait once caught up with the head, default 1s
+	RetryWait     time.Duration // wait after an rpc or sink error, default 2s
+	WalletRefresh time.Duration // default 1m
+}
+
+// SolanaWatcherStatus is a snapshot of a running watcher.
+type SolanaWatcherStatus struct {
+	Running       bool       `json:"running"`
+	Mints         []string   `json:"mints"`
+	Wallets       int        `json:"wallets"`
+	LastSlot      uint64     `json:"last_slot"`
+	HeadSlot      uint64     `json:"head_slot"`
+	Trades        uint64     `json:"trades"`
+	LastError     string     `json:"last_error,omitempty"`
+	LastErrorTime *time.Time `json:"last_error_time,omitempty"`
+	StartedAt     *time.Time `json:"started_at,omitempty"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// SolanaWatcher follows finalized Solana blocks and reports the balance changes of a set of
+// wallets in a set of mints to a sink.
+type SolanaWatcher struct {
+	cfg SolanaWatcherConfig
+	rpc int
+
+	mu     sync.Mutex
+	status SolanaWatcherStatus
+}
+
+func NewSolanaWatcher(cfg SolanaWatcherConfig) (*SolanaWatcher, error) {
+	if len(cfg.RPCURLs) == 0 || len(cfg.Mints) == 0 || cfg.Wallets == nil || cfg.Sink == nil || cfg.Progress == nil {
+		return nil, fmt.Errorf("invalid config")
+	}
+	if cfg.SaveEveryN == 0 {
+		cfg.SaveEveryN = 100
+	}
+	if cfg.HeadIdleWait == 0 {
+		cfg.HeadIdleWait = time.Second
+	}
+	if cfg.RetryWait == 0 {
+		cfg.RetryWait = 2 * time.Second
+	}
+	if cfg.WalletRefresh == 0 {
+		cfg.WalletRefresh = time.Minute
+	}
+	return &SolanaWatcher{cfg: cfg, status: SolanaWatcherStatus{Mints: cfg.Mints}}, nil
+}
+
+// Status returns a snapshot of the watcher's progress.
+func (w *SolanaWatcher) Status() SolanaWatcherStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.status
+}
+
+func (w *SolanaWatcher) update(f func(s *SolanaWatcherStatus)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	f(&w.status)
+	w.status.UpdatedAt = time.Now()
+}
+
+// fail records err and moves on to the next rpc.
+func (w *SolanaWatcher) fail(err error) {
+	now := time.Now()
+	w.update(func(s *SolanaWatcherStatus) {
+		s.LastError = err.Error()
+		s.LastErrorTime = &now
+	})
+	w.rpc = (w.rpc + 1) % len(w.cfg.RPCURLs)
+	log.Errorf("[solana-watcher] %v", err)
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+// Run scans until ctx is done. Progress is saved on the way and when it returns.
+func (w *SolanaWatcher) Run(ctx context.Context) error {
+	last, err := w.cfg.Progress.LoadSlot()
+	if err != nil {
+		return fmt.Errorf("load progress: %w", err)
+	}
+	if last < w.cfg.StartSlot {
+		last = w.cfg.StartSlot
+	}
+	started := time.Now()
+	w.update(func(s *SolanaWatcherStatus) {
+		s.Running = true
+		s.StartedAt = &started
+		s.LastSlot = last
+	})
+	defer w.update(func(s *SolanaWatcherStatus) { s.Running = false })
+
+	var wallets map[string]struct{}
+	var walletsAt time.Time
+	saved := last
+	save := func() {
+		if last == saved {
+			return
+		}
+		if err := w.cfg.Progress.SaveSlot(last); err != nil {
+			w.fail(fmt.Errorf("save progress: %w", err))
+			return
+		}
+		saved = last
+	}
+	defer save()
+
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if wallets == nil || time.Since(walletsAt) >= w.cfg.WalletRefresh {
+			list, err := w.cfg.Wallets(ctx)
+			if err != nil && wallets == nil {
+				w.fail(fmt.Errorf("load wallets: %w", err))
+				sleepCtx(ctx, w.cfg.RetryWait)
+				continue
+			}
+			if err != nil {
+				// keep watching the previous set
+				w.fail(fmt.Errorf("refresh wallets: %w", err))
+			} else {
+				wallets = make(map[string]struct{}, len(list))
+				for _, a := range list {
+					wallets[a] = struct{}{}
+				}
+				w.update(func(s *SolanaWatcherStatus) { s.Wallets = len(wallets) })
+			}
+			walletsAt = time.Now()
+		}
+
+		rpc := w.cfg.RPCURLs[w.rpc]
+		head, err := getSlot(rpc)
+		if err != nil {
+			w.fail(fmt.Errorf("getSlot: %w", err))
+			sleepCtx(ctx, w.cfg.RetryWait)
+			continue
+		}
+		w.update(func(s *SolanaWatcherStatus) { s.HeadSlot = head })
+		if last == 0 {
+			last = head
+			save()
+		}
+		if last >= head {
+			sleepCtx(ctx, w.cfg.HeadIdleWait)
+			continue
+		}
+
+		for slot := last + 1; slot <= head; slot++ {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			trades, err := w.scanSlot(rpc, slot, wallets)
+			if err == nil && len(trades) > 0 {
+				err = w.cfg.Sink.WriteTrades(ctx, trades)
+			}
+			if err != nil {
+				// the same slot is scanned again: nothing is skipped
+				w.fail(fmt.Errorf("slot %d: %w", slot, err))
+				sleepCtx(ctx, w.cfg.RetryWait)
+				break
+			}
+			last = slot
+			w.update(func(s *SolanaWatcherStatus) {
+				s.LastSlot = slot
+				s.Trades += uint64(len(trades))
+			})
+			if slot%w.cfg.SaveEveryN == 0 {
+				save()
+			}
+		}
+		save()
+	}
+}
+
+// scanSlot returns the trades of the watched wallets in one block. A skipped slot has none.
+func (w *SolanaWatcher) scanSlot(rpc string, slot uint64, wallets map[string]struct{}) ([]SolanaTrade, error) {
+	br, err := getBlock(rpc, slot)
+	var rerr *solanaRpcError
+	if errors.As(err, &rerr) && (rerr.Code == rpcSlotSkipped || rerr.Code == rpcBlockNotAvailable) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if br == nil {
+		return nil, nil
+	}
+	var bt *time.Time
+	if br.BlockTime != nil {
+		t := time.Unix(*br.BlockTime, 0).UTC()
+		bt = &t
+	}
+
+	var trades []SolanaTrade
+	for _, tx := range br.Transactions {
+		if tx.Meta == nil {
+			continue
+		}
+		sig := ""
+		if len(tx.Transaction.Signatures) > 0 {
+			sig = tx.Transaction.Signatures[0]
+		}
+		for _, mint := range w.cfg.Mints {
+			for owner := range ownersHit(tx.Meta, mint, wallets) {
+				delta, dec, ok := calcDeltaFor(owner, mint, tx.Meta)
+				if !ok || delta.Sign() == 0 {
+					continue
+				}
+				side := "BUY"
+				if delta.Sign() < 0 {
+					side = "SELL"
+				}
+				trades = append(trades, SolanaTrade{
+					Slot:       slot,
+					BlockTime:  bt,
+					Signature:  sig,
+					Owner:      owner,
+					Mint:       mint,
+					Side:       side,
+					Amount:     new(big.Rat).Abs(delta).FloatString(dec),
+					Decimals:   dec,
+					FeeLamport: tx.Meta.Fee,
+					Success:    tx.Meta.Err == nil,
+				})
+			}
+		}
+	}
+	return trades, nil
+}
+
+// ChannelSink hands trades to an in-process consumer, waiting while the channel is full.
+type ChannelSink chan<- SolanaTrade
+
+func (s ChannelSink) WriteTrades(ctx context.Context, trades []SolanaTrade) error {
+	for _, t := range trades {
+		select {
+		case s <- t:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// CSVSink appends trades to a CSV file.
+type CSVSink struct {
+	mu sync.Mutex
+	w  *csv.Writer
+	f  *os.File
+}
+
+func NewCSVSink(path string) (*CSVSink, error) {
+	w, f, err := openCSVAppend(path)
+	if err != nil {
+		return nil, err
+	}
+	return &CSVSink{w: w, f: f}, nil
+}
+
+func (s *CSVSink) WriteTrades(_ context.Context, trades []SolanaTrade) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range trades {
+		ts := ""
+		if t.BlockTime != nil {
+			ts = t.BlockTime.Format(time.RFC3339)
+		}
+		_ = s.w.Write([]string{
+			fmt.Sprint(t.Slot), ts, t.Signature, t.Owner, t.Side, t.Amount, t.Mint,
+			fmt.Sprintf("%.9f", float64(t.FeeLamport)/1e9), fmt.Sprint(t.Success),
+		})
+	}
+	s.w.Flush()
+	return s.w.Error()
+}
+
+func (s *CSVSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.w.Flush()
+	return s.f.Close()
+}
+
+// MultiSink writes to every sink in turn.
+type MultiSink []TradeSink
+
+func (m MultiSink) WriteTrades(ctx context.Context, trades []SolanaTrade) error {
+	for _, s := range m {
+		if err := s.WriteTrades(ctx, trades); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FileProgress keeps the progress in a JSON file.
+type FileProgress string
+
+func (p FileProgress) LoadSlot() (uint64, error) {
+	st, err := loadState(string(p))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	return st.LastProcessed, err
+}
+
+func (p FileProgress) SaveSlot(slot uint64) error {
+	return saveState(string(p), tailState{LastProcessed: slot})
+}
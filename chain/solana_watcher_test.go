@@ -0,0 +1,118 @@
+package chain
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type memProgress struct {
+	slot   uint64
+	onSave func(uint64)
+}
+
+func (p *memProgress) LoadSlot() (uint64, error) { return p.slot, nil }
+
+func (p *memProgress) SaveSlot(slot uint64) error {
+	p.slot = slot
+	p.onSave(slot)
+	return nil
+}
+
+func TestSolanaWatcher(t *testing.T) {
+	const wallet, other = "Wallet1111111111111111111111111111111111111", "Other11111111111111111111111111111111111111"
+	const mintA, mintB = "MintA111111111111111111111111111111111111111", "MintB111111111111111111111111111111111111111"
+	bal := func(owner, mint, amount string) map[string]any {
+		return map[string]any{"owner": owner, "mint": mint, "uiTokenAmount": map[string]any{"amount": amount, "decimals": 6}}
+	}
+	blocks := map[uint64]any{
+		// wallet buys 5 A; the other wallet is not watched
+		102: map[string]any{"blockTime": 1700000000, "transactions": []any{map[string]any{
+			"transaction": map[string]any{"signatures": []string{"sig1"}},
+			"meta": map[string]any{
+				"fee":               5000,
+				"preTokenBalances":  []any{bal(other, mintA, "9000000")},
+				"postTokenBalances": []any{bal(wallet, mintA, "5000000"), bal(other, mintA, "4000000")},
+			},
+		}}},
+		// wallet sells 1.5 B
+		103: map[string]any{"blockTime": 1700000001, "transactions": []any{map[string]any{
+			"transaction": map[string]any{"signatures": []string{"sig2"}},
+			"meta": map[string]any{
+				"fee":               5000,
+				"preTokenBalances":  []any{bal(wallet, mintB, "2000000")},
+				"postTokenBalances": []any{bal(wallet, mintB, "500000")},
+			},
+		}}},
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req rpcReq
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+			return
+		}
+		resp := map[string]any{"jsonrpc": "2.0", "id": req.ID}
+		switch req.Method {
+		case "getSlot":
+			resp["result"] = 103
+		case "getBlock":
+			slot := uint64(req.Params[0].(float64))
+			if b, ok := blocks[slot]; ok {
+				resp["result"] = b
+			} else {
+				resp["error"] = map[string]any{"code": rpcSlotSkipped, "message": "slot was skipped"}
+			}
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	trades := make(chan SolanaTrade, 10)
+	progress := &memProgress{slot: 100, onSave: func(slot uint64) {
+		if slot == 103 {
+			cancel()
+		}
+	}}
+	w, err := NewSolanaWatcher(SolanaWatcherConfig{
+		RPCURLs:  []string{srv.URL},
+		Mints:    []string{mintA, mintB},
+		Wallets:  StaticWallets([]string{wallet}),
+		Sink:     ChannelSink(trades),
+		Progress: progress,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Run(ctx); err != context.Canceled {
+		t.Fatalf("run: %v", err)
+	}
+	close(trades)
+
+	var got []SolanaTrade
+	for tr := range trades {
+		got = append(got, tr)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d trades: %+v", len(got), got)
+	}
+	if got[0].Signature != "sig1" || got[0].Mint != mintA || got[0].Side != "BUY" || got[0].Amount != "5.000000" || got[0].Slot != 102 {
+		t.Fatalf("first trade %+v", got[0])
+	}
+	if got[1].Signature != "sig2" || got[1].Mint != mintB || got[1].Side != "SELL" || got[1].Amount != "1.500000" {
+		t.Fatalf("second trade %+v", got[1])
+	}
+
+	st := w.Status()
+	if st.Running || st.LastSlot != 103 || st.HeadSlot != 103 || st.Trades != 2 || st.Wallets != 1 || st.LastError != "" {
+		t.Fatalf("status %+v", st)
+	}
+	if progress.slot != 103 {
+		t.Fatalf("progress saved at %d", progress.slot)
+	}
+}
@@ -0,0 +1,348 @@
+package chain
+
+import (
+	topupabi "chaos/api/chain/abi"
+	"chaos/api/tools"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+var ErrTopupAdminInvalid = errors.New("invalid topup admin call")
+
+// TopupAdminBackend is what TopupAdmin needs of a node. An ethclient.Client is one, and so is the
+// client of go-ethereum's simulated backend.
+type TopupAdminBackend interface {
+	CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error)
+	EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error)
+	SuggestGasTipCap(ctx context.Context) (*big.Int, error)
+	PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	SendTransaction(ctx context.Context, tx *types.Transaction) error
+	ChainID(ctx context.Context) (*big.Int, error)
+}
+
+// TopupAdmin reads the state of a TopupLogic contract and builds its owner-only calls.
+type TopupAdmin struct {
+	backend  TopupAdminBackend
+	contract common.Address
+	topup    abi.ABI
+	erc20    abi.ABI
+}
+
+// TopupState is what a TopupLogic contract reports of itself at the newest block.
+type TopupState struct {
+	Contract        string   `json:"contract"`
+	Token           string   `json:"token"`
+	Signer          string   `json:"signer"`
+	MaxLockTTL      uint64   `json:"max_lock_ttl"`
+	TotalLocked     *big.Int `json:"total_locked"`
+	TokenBalance    *big.Int `json:"token_balance"` // token.balanceOf(contract)
+	DomainSeparator string   `json:"domain_separator"`
+}
+
+// Sweepable is the token balance that no open lock holds, the most sweep may move.
+func (s *TopupState) Sweepable() *big.Int {
+	free := new(big.Int).Sub(s.TokenBalance, s.TotalLocked)
+	if free.Sign() < 0 {
+		return new(big.Int)
+	}
+	return free
+}
+
+// TopupCall is an unsigned call to a TopupLogic contract. It is what a multisig needs to propose
+// the transaction: to, value and data, with the decoded method and args for its signers to check.
+type TopupCall struct {
+	ChainID uint64            `json:"chain_id"`
+	To      string            `json:"to"`
+	Value   string            `json:"value"`
+	Data    hexutil.Bytes     `json:"data"`
+	Method  string            `json:"method"`
+	Args    map[string]string `json:"args"`
+}
+
+// NewTopupAdmin administers contract through backend.
+func NewTopupAdmin(backend TopupAdminBackend, contract string) (*TopupAdmin, error) {
+	if !common.IsHexAddress(contract) {
+		return nil, fmt.Errorf("%w: bad contract address %q", ErrTopupAdminInvalid, contract)
+	}
+	topup, err := parsedTopupABI()
+	if err != nil {
+		return nil, fmt.Errorf("parse abi error: %w", err)
+	}
+	erc20, err := abi.JSON(strings.NewReader(topupabi.ERC20BalanceABI))
+	if err != nil {
+		return nil, fmt.Errorf("parse abi error: %w", err)
+	}
+	return &TopupAdmin{backend: backend, contract: common.HexToAddress(contract), topup: topup, erc20: erc20}, nil
+}
+
+// NewChainTopupAdmin administers the TopupLogic contract of a registered chain, or contract on it
+// when not empty.
+func NewChainTopupAdmin(chainID uint64, contract string) (*TopupAdmin, error) {
+	c, err := tools.LookupChain(chainID)
+	if err != nil {
+		return nil, err
+	}
+	if contract == "" {
+		contract = c.GetTopupContract()
+	}
+	client, err := chainClient(chainID)
+	if err != nil {
+		return nil, fmt.Errorf("get client error: %w", err)
+	}
+	return NewTopupAdmin(client, contract)
+}
+
+// topupTokens caches the token of each TopupLogic contract, by chain and contract. A contract
+// takes its token once, at initialize.
+var topupTokens sync.Map
+
+type topupKey struct {
+	chainID  uint64
+	contract common.Address
+}
+
+// TopupToken returns the ERC20 the TopupLogic contract on chainID takes deposits in and pays out.
+func TopupToken(ctx context.Context, chainID uint64, contract string) (common.Address, error) {
+	if !common.IsHexAddress(contract) {
+		return common.Address{}, fmt.Errorf("%w: bad contract address %q", ErrTopupAdminInvalid, contract)
+	}
+	key := topupKey{chainID, common.HexToAddress(contract)}
+	if v, ok := topupTokens.Load(key); ok {
+		return v.(common.Address), nil
+	}
+	admin, err := NewChainTopupAdmin(chainID, contract)
+	if err != nil {
+		return common.Address{}, err
+	}
+	v, err := admin.call(ctx, admin.topup, admin.contract, "token")
+	if err != nil {
+		return common.Address{}, err
+	}
+	token, ok := v.(common.Address)
+	if !ok || token == (common.Address{}) {
+		return common.Address{}, fmt.Errorf("topup contract %s on chain %d has no token", contract, chainID)
+	}
+	topupTokens.Store(key, token)
+	return token, nil
+}
+
+func (a *TopupAdmin) Contract() common.Address {
+	return a.contract
+}
+
+func (a *TopupAdmin) call(ctx context.Context, parsed abi.ABI, to common.Address, method string, args ...interface{}) (interface{}, error) {
+	data, err := parsed.Pack(method, args...)
+	if err != nil {
+		return nil, err
+	}
+	out, err := a.backend.CallContract(ctx, ethereum.CallMsg{To: &to, Data: data}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("call %s error: %w", method, err)
+	}
+	vals, err := parsed.Unpack(method, out)
+	if err != nil || len(vals) == 0 {
+		return nil, fmt.Errorf("failed to unpack %s", method)
+	}
+	return vals[0], nil
+}
+
+// State reads the contract's token, signer, lock ttl, locked total and token balance.
+func (a *TopupAdmin) State(ctx context.Context) (*TopupState, error) {
+	state := &TopupState{Contract: a.contract.Hex()}
+	v, err := a.call(ctx, a.topup, a.contract, "token")
+	if err != nil {
+		return nil, err
+	}
+	token, ok := v.(common.Address)
+	if !ok {
+		return nil, errors.New("unexpected token result")
+	}
+	state.Token = token.Hex()
+
+	if v, err = a.call(ctx, a.topup, a.contract, "signer"); err != nil {
+		return nil, err
+	}
+	signer, ok := v.(common.Address)
+	if !ok {
+		return nil, errors.New("unexpected signer result")
+	}
+	state.Signer = signer.Hex()
+
+	if v, err = a.call(ctx, a.topup, a.contract, "maxLockTTL"); err != nil {
+		return nil, err
+	}
+	if state.MaxLockTTL, ok = v.(uint64); !ok {
+		return nil, errors.New("unexpected maxLockTTL result")
+	}
+
+	if v, err = a.call(ctx, a.topup, a.contract, "totalLocked"); err != nil {
+		return nil, err
+	}
+	if state.TotalLocked, ok = v.(*big.Int); !ok {
+		return nil, errors.New("unexpected totalLocked result")
+	}
+
+	if v, err = a.call(ctx, a.topup, a.contract, "domainSeparator"); err != nil {
+		return nil, err
+	}
+	domain, ok := v.([32]byte)
+	if !ok {
+		return nil, errors.New("unexpected domainSeparator result")
+	}
+	state.DomainSeparator = common.Hash(domain).Hex()
+
+	if v, err = a.call(ctx, a.erc20, token, "balanceOf", a.contract); err != nil {
+		return nil, err
+	}
+	if state.TokenBalance, ok = v.(*big.Int); !ok {
+		return nil, errors.New("unexpected balanceOf result")
+	}
+	return state, nil
+}
+
+func (a *TopupAdmin) build(ctx context.Context, method string, args map[string]string, values ...interface{}) (*TopupCall, error) {
+	data, err := a.topup.Pack(method, values...)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTopupAdminInvalid, err)
+	}
+	id, err := a.backend.ChainID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get chain id error: %w", err)
+	}
+	return &TopupCall{ChainID: id.Uint64(), To: a.contract.Hex(), Value: "0", Data: data, Method: method, Args: args}, nil
+}
+
+// SetSigner builds setSigner(signer). The contract trusts only the new signer's LockAuth tickets
+// from then on, so the service must be signing with it before the call is sent.
+func (a *TopupAdmin) SetSigner(ctx context.Context, signer string) (*TopupCall, error) {
+	if !common.IsHexAddress(signer) || common.HexToAddress(signer) == (common.Address{}) {
+		return nil, fmt.Errorf("%w: bad signer address %q", ErrTopupAdminInvalid, signer)
+	}
+	addr := common.HexToAddress(signer)
+	return a.build(ctx, "setSigner", map[string]string{"_signer": addr.Hex()}, addr)
+}
+
+// SetMaxLockTTL builds setMaxLockTTL(ttl), ttl in seconds.
+func (a *TopupAdmin) SetMaxLockTTL(ctx context.Context, ttl uint64) (*TopupCall, error) {
+	if ttl == 0 {
+		return nil, fmt.Errorf("%w: ttl must be positive", ErrTopupAdminInvalid)
+	}
+	return a.build(ctx, "setMaxLockTTL", map[string]string{"_ttl": fmt.Sprint(ttl)}, ttl)
+}
+
+// Sweep builds sweep(to, amount), amount in base units of the token. It refuses to move more than
+// the contract holds beyond its open locks: those tokens are owed to users.
+func (a *TopupAdmin) Sweep(ctx context.Context, to string, amount *big.Int) (*TopupCall, error) {
+	if !common.IsHexAddress(to) || common.HexToAddress(to) == (common.Address{}) {
+		return nil, fmt.Errorf("%w: bad recipient address %q", ErrTopupAdminInvalid, to)
+	}
+	if amount == nil || amount.Sign() <= 0 {
+		return nil, fmt.Errorf("%w: amount must be positive", ErrTopupAdminInvalid)
+	}
+	state, err := a.State(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if free := state.Sweepable(); amount.Cmp(free) > 0 {
+		return nil, fmt.Errorf("%w: %s above the %s not held by open locks", ErrTopupAdminInvalid, amount, free)
+	}
+	addr := common.HexToAddress(to)
+	return a.build(ctx, "sweep", map[string]string{"to": addr.Hex(), "amount": amount.String()}, addr, amount)
+}
+
+// RevokeLock builds revokeLock(user, lockId), which returns the tokens of an open lock to the pool.
+func (a *TopupAdmin) RevokeLock(ctx context.Context, user, lockIdHex string) (*TopupCall, error) {
+	if !common.IsHexAddress(user) {
+		return nil, fmt.Errorf("%w: bad user address %q", ErrTopupAdminInvalid, user)
+	}
+	lockIdBytes, err := hexutil.Decode(lockIdHex)
+	if err != nil || len(lockIdBytes) != 32 {
+		return nil, fmt.Errorf("%w: bad lock id %q", ErrTopupAdminInvalid, lockIdHex)
+	}
+	addr, lockID := common.HexToAddress(user), common.BytesToHash(lockIdBytes)
+	return a.build(ctx, "revokeLock", map[string]string{"user": addr.Hex(), "lockId": lockID.Hex()}, addr, lockID)
+}
+
+// Simulate runs call as from at the newest block, so a call the contract would revert, e.g. from
+// an address that is not its owner, fails before anyone signs it.
+func (a *TopupAdmin) Simulate(ctx context.Context, call *TopupCall, from common.Address) error {
+	to := common.HexToAddress(call.To)
+	if _, err := a.backend.CallContract(ctx, ethereum.CallMsg{From: from, To: &to, Data: call.Data}, nil); err != nil {
+		return fmt.Errorf("%s reverts: %w", call.Method, err)
+	}
+	return nil
+}
+
+// Send signs call with signer as an EIP-1559 transaction and broadcasts it. It does not wait for
+// the transaction to be mined.
+func (a *TopupAdmin) Send(ctx context.Context, call *TopupCall, signer Signer) (*types.Transaction, error) {
+	from := signer.Address()
+	to := common.HexToAddress(call.To)
+	msg := ethereum.CallMsg{From: from, To: &to, Data: call.Data}
+	gas, err := a.backend.EstimateGas(ctx, msg)
+	if err != nil {
+		return nil, fmt.Errorf("estimate %s gas error: %w", call.Method, err)
+	}
+	nonce, err := a.backend.PendingNonceAt(ctx, from)
+	if err != nil {
+		return nil, fmt.Errorf("get nonce error: %w", err)
+	}
+	tip, err := a.backend.SuggestGasTipCap(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get gas tip error: %w", err)
+	}
+	head, err := a.backend.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("get head error: %w", err)
+	}
+	feeCap := new(big.Int).Set(tip)
+	if head.BaseFee != nil {
+		// room for the base fee to double before the tx is mined
+		feeCap.Add(feeCap, new(big.Int).Mul(head.BaseFee, big.NewInt(2)))
+	}
+	chainID := new(big.Int).SetUint64(call.ChainID)
+
+	tx := types.NewTx(&types.DynamicFeeTx{
+		ChainID:   chainID,
+		Nonce:     nonce,
+		GasTipCap: tip,
+		GasFeeCap: feeCap,
+		Gas:       gas * 6 / 5,
+		To:        &to,
+		Data:      call.Data,
+	})
+	txSigner := types.LatestSignerForChainID(chainID)
+	sig, err := signer.SignDigest(ctx, txSigner.Hash(tx))
+	if err != nil {
+		return nil, err
+	}
+	if len(sig) != 65 {
+		return nil, errors.New("invalid signature length")
+	}
+	// typed transactions take v as 0/1
+	if sig[64] >= 27 {
+		sig[64] -= 27
+	}
+	signed, err := tx.WithSignature(txSigner, sig)
+	if err != nil {
+		return nil, err
+	}
+	if sender, err := types.Sender(txSigner, signed); err != nil || sender != from {
+		return nil, fmt.Errorf("signature does not recover to signer %s", from.Hex())
+	}
+	if err := a.backend.SendTransaction(ctx, signed); err != nil {
+		return nil, fmt.Errorf("send %s error: %w", call.Method, err)
+	}
+	return signed, nil
+}
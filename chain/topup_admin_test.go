@@ -0,0 +1,320 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient/simulated"
+)
+
+// evmAsm assembles EVM code, with jump labels resolved once the code is complete.
+type evmAsm struct {
+	code   []byte
+	labels map[string]int
+	jumps  map[int]string // offset of a PUSH2 operand -> label it jumps to
+}
+
+func newEvmAsm() *evmAsm {
+	return &evmAsm{labels: map[string]int{}, jumps: map[int]string{}}
+}
+
+func (a *evmAsm) op(ops ...vm.OpCode) *evmAsm {
+	for _, o := range ops {
+		a.code = append(a.code, byte(o))
+	}
+	return a
+}
+
+func (a *evmAsm) push(b ...byte) *evmAsm {
+	a.code = append(a.code, byte(vm.PUSH1)+byte(len(b)-1))
+	a.code = append(a.code, b...)
+	return a
+}
+
+// jumpi jumps to label when the top of the stack is not zero.
+func (a *evmAsm) jumpi(label string) *evmAsm {
+	a.code = append(a.code, byte(vm.PUSH2))
+	a.jumps[len(a.code)] = label
+	a.code = append(a.code, 0, 0)
+	return a.op(vm.JUMPI)
+}
+
+func (a *evmAsm) label(name string) *evmAsm {
+	a.labels[name] = len(a.code)
+	return a.op(vm.JUMPDEST)
+}
+
+func (a *evmAsm) revert() *evmAsm {
+	return a.push(0).op(vm.DUP1, vm.REVERT)
+}
+
+// returnTop returns the word on top of the stack.
+func (a *evmAsm) returnTop() *evmAsm {
+	return a.push(0).op(vm.MSTORE).push(32).push(0).op(vm.RETURN)
+}
+
+// dispatch jumps to the label of the selector of the call, and reverts on any other.
+func (a *evmAsm) dispatch(t *testing.T, selectors map[string][]byte) *evmAsm {
+	a.push(0).op(vm.CALLDATALOAD).push(0xe0).op(vm.SHR)
+	for label, sel := range selectors {
+		if len(sel) != 4 {
+			t.Fatalf("selector of %s: %x", label, sel)
+		}
+		a.op(vm.DUP1).push(sel...).op(vm.EQ).jumpi(label)
+	}
+	return a.revert()
+}
+
+func (a *evmAsm) bytes(t *testing.T) []byte {
+	for at, label := range a.jumps {
+		to, ok := a.labels[label]
+		if !ok {
+			t.Fatalf("no label %s", label)
+		}
+		a.code[at], a.code[at+1] = byte(to>>8), byte(to)
+	}
+	return a.code
+}
+
+// TopupLogic storage of the stand-in: the lock of (user, lockId) is at keccak256(user, lockId).
+const (
+	slotToken = iota
+	slotSigner
+	slotMaxLockTTL
+	slotTotalLocked
+	slotOwner
+)
+
+func selector(sig string) []byte {
+	return crypto.Keccak256([]byte(sig))[:4]
+}
+
+// topupLogicCode is runtime code that keeps the TopupLogic state the admin reads and changes it as
+// the owner-only calls do: setSigner, setMaxLockTTL, sweep of the token to an address and
+// revokeLock of an open lock. The compiled contract is not part of this repository.
+func topupLogicCode(t *testing.T) []byte {
+	a := newEvmAsm().dispatch(t, map[string][]byte{
+		"token":         selector("token()"),
+		"signer":        selector("signer()"),
+		"maxLockTTL":    selector("maxLockTTL()"),
+		"totalLocked":   selector("totalLocked()"),
+		"domain":        selector("domainSeparator()"),
+		"setSigner":     selector("setSigner(address)"),
+		"setMaxLockTTL": selector("setMaxLockTTL(uint64)"),
+		"sweep":         selector("sweep(address,uint256)"),
+		"revokeLock":    selector("revokeLock(address,bytes32)"),
+	})
+	for label, slot := range map[string]byte{"token": slotToken, "signer": slotSigner, "maxLockTTL": slotMaxLockTTL, "totalLocked": slotTotalLocked} {
+		a.label(label).push(slot).op(vm.SLOAD).returnTop()
+	}
+	a.label("domain").push(crypto.Keccak256([]byte("domain"))...).returnTop()
+
+	onlyOwner := func(label string) {
+		a.label(label).push(slotOwner).op(vm.SLOAD, vm.CALLER, vm.EQ, vm.ISZERO).jumpi("fail")
+	}
+	onlyOwner("setSigner")
+	a.push(4).op(vm.CALLDATALOAD).push(slotSigner).op(vm.SSTORE, vm.STOP)
+	onlyOwner("setMaxLockTTL")
+	a.push(4).op(vm.CALLDATALOAD).push(slotMaxLockTTL).op(vm.SSTORE, vm.STOP)
+
+	// sweep: token.transfer(to, amount), which must return true
+	onlyOwner("sweep")
+	a.push(append(selector("transfer(address,uint256)"), make([]byte, 28)...)...).push(0).op(vm.MSTORE)
+	a.push(4).op(vm.CALLDATALOAD).push(4).op(vm.MSTORE)
+	a.push(36).op(vm.CALLDATALOAD).push(36).op(vm.MSTORE)
+	a.push(32).push(0).push(68).push(0).push(0).push(slotToken).op(vm.SLOAD, vm.GAS, vm.CALL, vm.ISZERO).jumpi("fail")
+	a.push(0).op(vm.MLOAD, vm.ISZERO).jumpi("fail").op(vm.STOP)
+
+	// revokeLock: totalLocked -= lock, lock = 0; reverts when the lock is not open
+	onlyOwner("revokeLock")
+	a.push(4).op(vm.CALLDATALOAD).push(0).op(vm.MSTORE)
+	a.push(36).op(vm.CALLDATALOAD).push(32).op(vm.MSTORE)
+	a.push(64).push(0).op(vm.KECCAK256, vm.DUP1, vm.SLOAD, vm.DUP1, vm.ISZERO).jumpi("fail")
+	a.push(slotTotalLocked).op(vm.SLOAD, vm.SUB).push(slotTotalLocked).op(vm.SSTORE)
+	a.push(0).op(vm.SWAP1, vm.SSTORE, vm.STOP)
+
+	a.label("fail").revert()
+	return a.bytes(t)
+}
+
+// tokenCode is runtime code of a token keeping the balance of an address at the slot of the
+// address, with balanceOf and transfer.
+func tokenCode(t *testing.T) []byte {
+	a := newEvmAsm().dispatch(t, map[string][]byte{
+		"balanceOf": selector("balanceOf(address)"),
+		"transfer":  selector("transfer(address,uint256)"),
+	})
+	a.label("balanceOf").push(4).op(vm.CALLDATALOAD, vm.SLOAD).returnTop()
+	a.label("transfer").push(36).op(vm.CALLDATALOAD, vm.CALLER, vm.SLOAD, vm.DUP2, vm.DUP2, vm.LT).jumpi("fail")
+	a.op(vm.SUB, vm.CALLER, vm.SSTORE)
+	a.push(36).op(vm.CALLDATALOAD).push(4).op(vm.CALLDATALOAD, vm.SLOAD, vm.ADD).push(4).op(vm.CALLDATALOAD, vm.SSTORE)
+	a.push(1).returnTop()
+	a.label("fail").revert()
+	return a.bytes(t)
+}
+
+func word(v interface{}) common.Hash {
+	switch v := v.(type) {
+	case common.Address:
+		return common.BytesToHash(v.Bytes())
+	case int64:
+		return common.BigToHash(big.NewInt(v))
+	}
+	panic("word of unexpected type")
+}
+
+func TestTopupAdmin(t *testing.T) {
+	ctx := context.Background()
+	newSigner := func() *KeySigner {
+		key, _ := crypto.GenerateKey()
+		s, _ := NewKeySigner(hexutil.Encode(crypto.FromECDSA(key)))
+		return s
+	}
+	owner, stranger := newSigner(), newSigner()
+
+	// the contract holds 100 tokens, 40 of them in the open lock of user
+	contract := common.HexToAddress("0x00000000000000000000000000000000000000c0")
+	token := common.HexToAddress("0x000000000000000000000000000000000000002a")
+	oldSigner := common.HexToAddress("0x0000000000000000000000000000000000005160")
+	user := common.HexToAddress("0x0000000000000000000000000000000000000b0b")
+	lockID := common.HexToHash("0x01")
+	lockSlot := crypto.Keccak256Hash(word(user).Bytes(), lockID.Bytes())
+	funds := new(big.Int).Lsh(big.NewInt(1), 100)
+	backend := simulated.NewBackend(types.GenesisAlloc{
+		owner.Address():    {Balance: funds},
+		stranger.Address(): {Balance: funds},
+		contract: {Code: topupLogicCode(t), Storage: map[common.Hash]common.Hash{
+			word(int64(slotToken)):       word(token),
+			word(int64(slotSigner)):      word(oldSigner),
+			word(int64(slotMaxLockTTL)):  word(int64(42)),
+			word(int64(slotTotalLocked)): word(int64(40)),
+			word(int64(slotOwner)):       word(owner.Address()),
+			lockSlot:                     word(int64(40)),
+		}},
+		token: {Code: tokenCode(t), Storage: map[common.Hash]common.Hash{word(contract): word(int64(100))}},
+	})
+	defer backend.Close()
+	client := backend.Client()
+
+	admin, err := NewTopupAdmin(client, contract.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := func() *TopupState {
+		t.Helper()
+		s, err := admin.State(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	send := func(call *TopupCall) {
+		t.Helper()
+		if err := admin.Simulate(ctx, call, owner.Address()); err != nil {
+			t.Fatal(err)
+		}
+		tx, err := admin.Send(ctx, call, owner)
+		if err != nil {
+			t.Fatal(err)
+		}
+		backend.Commit()
+		receipt, err := client.TransactionReceipt(ctx, tx.Hash())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if receipt.Status != types.ReceiptStatusSuccessful || hexutil.Encode(tx.Data()) != hexutil.Encode(call.Data) {
+			t.Fatalf("%s: receipt status %d, data %x", call.Method, receipt.Status, tx.Data())
+		}
+		if sender, _ := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx); sender != owner.Address() {
+			t.Fatalf("%s sent by %s", call.Method, sender.Hex())
+		}
+	}
+
+	if s := state(); s.Token != token.Hex() || s.Signer != oldSigner.Hex() || s.MaxLockTTL != 42 ||
+		s.TotalLocked.Int64() != 40 || s.TokenBalance.Int64() != 100 || s.Sweepable().Int64() != 60 {
+		t.Fatalf("state %+v", s)
+	}
+
+	// unsigned payloads carry the abi encoded call
+	call, err := admin.SetMaxLockTTL(ctx, 3600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := admin.topup.Pack("setMaxLockTTL", uint64(3600))
+	if call.To != contract.Hex() || call.Value != "0" || hexutil.Encode(call.Data) != hexutil.Encode(want) ||
+		call.ChainID != 1337 || call.Args["_ttl"] != "3600" {
+		t.Fatalf("call %+v", call)
+	}
+	send(call)
+	if s := state(); s.MaxLockTTL != 3600 {
+		t.Fatalf("max lock ttl %d after setMaxLockTTL", s.MaxLockTTL)
+	}
+
+	call, err = admin.SetSigner(ctx, owner.Address().Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	send(call)
+	if s := state(); s.Signer != owner.Address().Hex() {
+		t.Fatalf("signer %s after setSigner", s.Signer)
+	}
+
+	// sweep moves only what no open lock holds
+	if _, err := admin.Sweep(ctx, owner.Address().Hex(), big.NewInt(61)); !errors.Is(err, ErrTopupAdminInvalid) {
+		t.Fatalf("sweep above the unlocked balance: %v", err)
+	}
+	call, err = admin.Sweep(ctx, owner.Address().Hex(), big.NewInt(60))
+	if err != nil {
+		t.Fatal(err)
+	}
+	send(call)
+	if s := state(); s.TokenBalance.Int64() != 40 || s.TotalLocked.Int64() != 40 || s.Sweepable().Sign() != 0 {
+		t.Fatalf("state %+v after sweep", s)
+	}
+	if v, err := admin.call(ctx, admin.erc20, token, "balanceOf", owner.Address()); err != nil || v.(*big.Int).Int64() != 60 {
+		t.Fatalf("owner holds %v after sweep: %v", v, err)
+	}
+
+	// revokeLock returns the lock to the pool, after which it can be swept
+	call, err = admin.RevokeLock(ctx, user.Hex(), lockID.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	send(call)
+	if s := state(); s.TotalLocked.Sign() != 0 || s.Sweepable().Int64() != 40 {
+		t.Fatalf("state %+v after revokeLock", s)
+	}
+	if err := admin.Simulate(ctx, call, owner.Address()); err == nil {
+		t.Fatal("simulated revoking a lock no longer open")
+	}
+
+	if _, err := admin.SetSigner(ctx, common.Address{}.Hex()); !errors.Is(err, ErrTopupAdminInvalid) {
+		t.Fatalf("zero signer: %v", err)
+	}
+	if _, err := admin.RevokeLock(ctx, owner.Address().Hex(), "0x01"); !errors.Is(err, ErrTopupAdminInvalid) {
+		t.Fatalf("short lock id: %v", err)
+	}
+
+	// a call the contract reverts, here from an address that is not its owner, fails before it is
+	// signed or sent
+	call, err = admin.SetSigner(ctx, stranger.Address().Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := admin.Simulate(ctx, call, stranger.Address()); err == nil {
+		t.Fatal("simulated a call from a stranger")
+	}
+	if _, err := admin.Send(ctx, call, stranger); err == nil {
+		t.Fatal("sent a call from a stranger")
+	}
+	if s := state(); s.Signer != owner.Address().Hex() {
+		t.Fatalf("signer %s after a stranger's setSigner", s.Signer)
+	}
+}
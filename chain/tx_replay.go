@@ -0,0 +1,279 @@
+package chain
+
+import (
+	"chaos/api/model"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// TxBackend is what the tx parsers read from a chain. *ethclient.Client is the live one; a
+// ReplayBackend serves the responses a RecordingBackend saved, so a parse can run offline.
+type TxBackend interface {
+	TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error)
+	TransactionReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, error)
+	BlockByNumber(ctx context.Context, number *big.Int) (*types.Block, error)
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	BlockNumber(ctx context.Context) (uint64, error)
+	NetworkID(ctx context.Context) (*big.Int, error)
+}
+
+type txParser func(ctx context.Context, client TxBackend, chainID uint64, txHash string) (*TopupTxInfo, error)
+
+// parseLive runs parse against a healthy rpc of the chain.
+func parseLive(chainID uint64, txHash string, parse txParser) (*TopupTxInfo, error) {
+	// 从链注册表选择健康的 RPC，未知链直接报错
+	client, err := chainClient(chainID)
+	if err != nil {
+		return nil, fmt.Errorf("get client error: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	return parse(ctx, client, chainID, txHash)
+}
+
+func evmTxParser(op int) (txParser, error) {
+	switch op {
+	case model.BalanceFlowOpRecharge:
+		return parseTopupTx, nil
+	case model.BalanceFlowOpFreeze:
+		return parseOpenLockTx, nil
+	case model.BalanceFlowOpWithdraw:
+		return parseClaimLockedTx, nil
+	case model.BalanceFlowOpUnfreeze:
+		return parseCancelLockedTx, nil
+	case model.BalanceFlowOpRevoke:
+		return parseRevokeLockTx, nil
+	}
+	return nil, fmt.Errorf("no evm tx parser for op %d", op)
+}
+
+// ParseTxWith parses the TopupLogic tx of a flow op with client, e.g. a ReplayBackend.
+func ParseTxWith(ctx context.Context, client TxBackend, chainID uint64, op int, txHash string) (*TopupTxInfo, error) {
+	parse, err := evmTxParser(op)
+	if err != nil {
+		return nil, err
+	}
+	return parse(ctx, client, chainID, txHash)
+}
+
+// TxFixture holds the rpc responses a parse read, enough to run it again offline. Blocks are
+// kept as headers: the parsers only read their time.
+type TxFixture struct {
+	ChainID   uint64                    `json:"chain_id"`
+	NetworkID string                    `json:"network_id,omitempty"`
+	Head      uint64                    `json:"head"`
+	Txs       map[string]*FixtureTx     `json:"txs"`
+	Receipts  map[string]*types.Receipt `json:"receipts"`
+	Headers   map[string]*types.Header  `json:"headers"` // by decimal block number
+	Recorded  time.Time                 `json:"recorded"`
+}
+
+type FixtureTx struct {
+	Tx      *types.Transaction `json:"tx"`
+	Pending bool               `json:"pending"`
+}
+
+func NewTxFixture(chainID uint64) *TxFixture {
+	return &TxFixture{
+		ChainID:  chainID,
+		Txs:      map[string]*FixtureTx{},
+		Receipts: map[string]*types.Receipt{},
+		Headers:  map[string]*types.Header{},
+		Recorded: time.Now().UTC(),
+	}
+}
+
+func fixtureKey(hash common.Hash) string {
+	return strings.ToLower(hash.Hex())
+}
+
+// LoadTxFixture reads a fixture saved by SaveTxFixture.
+func LoadTxFixture(path string) (*TxFixture, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f TxFixture
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("decode fixture %s: %w", path, err)
+	}
+	return &f, nil
+}
+
+// SaveTxFixture writes f to path as indented json.
+func SaveTxFixture(path string, f *TxFixture) error {
+	b, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0o644)
+}
+
+// RecordingBackend passes calls through to a live backend and keeps the successful responses.
+type RecordingBackend struct {
+	live TxBackend
+
+	mu sync.Mutex
+	f  *TxFixture
+}
+
+func NewRecordingBackend(live TxBackend, chainID uint64) *RecordingBackend {
+	return &RecordingBackend{live: live, f: NewTxFixture(chainID)}
+}
+
+// Fixture returns what was recorded so far.
+func (r *RecordingBackend) Fixture() *TxFixture {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.f
+}
+
+func (r *RecordingBackend) TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error) {
+	tx, pending, err := r.live.TransactionByHash(ctx, hash)
+	if err == nil {
+		r.mu.Lock()
+		r.f.Txs[fixtureKey(hash)] = &FixtureTx{Tx: tx, Pending: pending}
+		r.mu.Unlock()
+	}
+	return tx, pending, err
+}
+
+func (r *RecordingBackend) TransactionReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, error) {
+	receipt, err := r.live.TransactionReceipt(ctx, hash)
+	if err == nil {
+		r.mu.Lock()
+		r.f.Receipts[fixtureKey(hash)] = receipt
+		r.mu.Unlock()
+	}
+	return receipt, err
+}
+
+func (r *RecordingBackend) BlockByNumber(ctx context.Context, number *big.Int) (*types.Block, error) {
+	blk, err := r.live.BlockByNumber(ctx, number)
+	if err == nil {
+		r.mu.Lock()
+		r.f.Headers[blk.Number().String()] = blk.Header()
+		r.mu.Unlock()
+	}
+	return blk, err
+}
+
+func (r *RecordingBackend) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	header, err := r.live.HeaderByNumber(ctx, number)
+	if err == nil {
+		r.mu.Lock()
+		r.f.Headers[header.Number.String()] = header
+		r.mu.Unlock()
+	}
+	return header, err
+}
+
+func (r *RecordingBackend) BlockNumber(ctx context.Context) (uint64, error) {
+	head, err := r.live.BlockNumber(ctx)
+	if err == nil {
+		r.mu.Lock()
+		r.f.Head = head
+		r.mu.Unlock()
+	}
+	return head, err
+}
+
+func (r *RecordingBackend) NetworkID(ctx context.Context) (*big.Int, error) {
+	id, err := r.live.NetworkID(ctx)
+	if err == nil {
+		r.mu.Lock()
+		r.f.NetworkID = id.String()
+		r.mu.Unlock()
+	}
+	return id, err
+}
+
+// ReplayBackend serves a fixture. What the fixture lacks is not found, as on a node that does
+// not know it.
+type ReplayBackend struct {
+	f *TxFixture
+}
+
+func NewReplayBackend(f *TxFixture) *ReplayBackend {
+	return &ReplayBackend{f: f}
+}
+
+func (r *ReplayBackend) TransactionByHash(_ context.Context, hash common.Hash) (*types.Transaction, bool, error) {
+	t, ok := r.f.Txs[fixtureKey(hash)]
+	if !ok || t.Tx == nil {
+		return nil, false, ethereum.NotFound
+	}
+	return t.Tx, t.Pending, nil
+}
+
+func (r *ReplayBackend) TransactionReceipt(_ context.Context, hash common.Hash) (*types.Receipt, error) {
+	receipt, ok := r.f.Receipts[fixtureKey(hash)]
+	if !ok || receipt == nil {
+		return nil, ethereum.NotFound
+	}
+	return receipt, nil
+}
+
+func (r *ReplayBackend) BlockByNumber(ctx context.Context, number *big.Int) (*types.Block, error) {
+	header, err := r.HeaderByNumber(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	return types.NewBlockWithHeader(header), nil
+}
+
+func (r *ReplayBackend) HeaderByNumber(_ context.Context, number *big.Int) (*types.Header, error) {
+	if number == nil {
+		number = new(big.Int).SetUint64(r.f.Head)
+	}
+	header, ok := r.f.Headers[number.String()]
+	if !ok || header == nil {
+		return nil, ethereum.NotFound
+	}
+	return header, nil
+}
+
+func (r *ReplayBackend) BlockNumber(context.Context) (uint64, error) {
+	return r.f.Head, nil
+}
+
+func (r *ReplayBackend) NetworkID(context.Context) (*big.Int, error) {
+	id, ok := new(big.Int).SetString(r.f.NetworkID, 10)
+	if !ok {
+		return nil, errors.New("fixture has no network id")
+	}
+	return id, nil
+}
+
+// RecordTx parses the tx of op on the live chain and returns the outcome with the responses it
+// read, ready to be saved as a fixture.
+func RecordTx(ctx context.Context, chainID uint64, op int, txHash string) (*TopupTxInfo, *TxFixture, error) {
+	parse, err := evmTxParser(op)
+	if err != nil {
+		return nil, nil, err
+	}
+	client, err := chainClient(chainID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get client error: %w", err)
+	}
+	rec := NewRecordingBackend(client, chainID)
+	info, err := parse(ctx, rec, chainID, txHash)
+	return info, rec.Fixture(), err
+}
+
+// ReplayTx parses the tx of op from a fixture, without touching any rpc.
+func ReplayTx(ctx context.Context, f *TxFixture, op int, txHash string) (*TopupTxInfo, error) {
+	return ParseTxWith(ctx, NewReplayBackend(f), f.ChainID, op, txHash)
+}
@@ -0,0 +1,117 @@
+package chain
+
+import (
+	"chaos/api/model"
+	"context"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// depositFixture is a confirmed deposit(amount) of a fresh wallet on chain 31337.
+func depositFixture(t *testing.T, amount *big.Int) (*TxFixture, string, common.Address) {
+	t.Helper()
+	parsed, err := parsedTopupABI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _ := crypto.GenerateKey()
+	user := crypto.PubkeyToAddress(key.PublicKey)
+	contract := common.HexToAddress("0x00000000000000000000000000000000000000c0")
+	chainID := big.NewInt(31337)
+
+	data, err := parsed.Pack("deposit", amount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := types.SignTx(types.NewTx(&types.DynamicFeeTx{
+		ChainID: chainID, Nonce: 1, GasTipCap: big.NewInt(1), GasFeeCap: big.NewInt(2), Gas: 100000, To: &contract, Data: data,
+	}), types.LatestSignerForChainID(chainID), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header := &types.Header{Number: big.NewInt(100), Time: 1700000000, Difficulty: big.NewInt(0), Extra: []byte{}}
+	logData, _ := parsed.Events["Deposited"].Inputs.NonIndexed().Pack(amount)
+	receipt := &types.Receipt{
+		Type:        types.DynamicFeeTxType,
+		Status:      types.ReceiptStatusSuccessful,
+		TxHash:      tx.Hash(),
+		BlockHash:   header.Hash(),
+		BlockNumber: header.Number,
+		Logs: []*types.Log{{
+			Address:     contract,
+			Topics:      []common.Hash{parsed.Events["Deposited"].ID, common.BytesToHash(user.Bytes())},
+			Data:        logData,
+			BlockNumber: 100,
+			TxHash:      tx.Hash(),
+			BlockHash:   header.Hash(),
+		}},
+	}
+	receipt.Bloom = types.CreateBloom(receipt)
+
+	f := NewTxFixture(chainID.Uint64())
+	f.NetworkID = chainID.String()
+	f.Head = 100
+	f.Txs[fixtureKey(tx.Hash())] = &FixtureTx{Tx: tx}
+	f.Receipts[fixtureKey(tx.Hash())] = receipt
+	f.Headers["100"] = header
+	return f, tx.Hash().Hex(), user
+}
+
+func TestReplayTx(t *testing.T) {
+	amount := big.NewInt(1234500)
+	f, hash, user := depositFixture(t, amount)
+
+	// the fixture survives a trip through a file, block hash included
+	path := filepath.Join(t.TempDir(), "deposit.json")
+	if err := SaveTxFixture(path, f); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadTxFixture(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := ReplayTx(context.Background(), loaded, model.BalanceFlowOpRecharge, hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Status != "success" || info.Amount.Cmp(amount) != 0 || info.From != user.Hex() || info.UserFromLog != user.Hex() ||
+		info.BlockNumber != 100 || info.BlockTime.Unix() != 1700000000 || info.BlockHash != f.Headers["100"].Hash().Hex() {
+		t.Fatalf("replayed %+v", info)
+	}
+
+	// a node serving a different block at that height: the receipt is orphaned
+	loaded.Headers["100"].Time++
+	if info, err := ReplayTx(context.Background(), loaded, model.BalanceFlowOpRecharge, hash); err != nil || info.Status != "pending" {
+		t.Fatalf("orphaned receipt: %v %+v", err, info)
+	}
+
+	// no receipt recorded: not mined yet
+	delete(loaded.Receipts, fixtureKey(common.HexToHash(hash)))
+	if info, err := ReplayTx(context.Background(), loaded, model.BalanceFlowOpRecharge, hash); err != nil || info.Status != "pending" {
+		t.Fatalf("missing receipt: %v %+v", err, info)
+	}
+
+	if _, err := ReplayTx(context.Background(), loaded, model.BalanceFlowOpRecharge, "0x01"); err == nil {
+		t.Fatal("unknown tx replayed")
+	}
+}
+
+func TestRecordingBackend(t *testing.T) {
+	f, hash, _ := depositFixture(t, big.NewInt(7))
+	rec := NewRecordingBackend(NewReplayBackend(f), f.ChainID)
+	if _, err := ParseTxWith(context.Background(), rec, f.ChainID, model.BalanceFlowOpRecharge, hash); err != nil {
+		t.Fatal(err)
+	}
+	got := rec.Fixture()
+	key := fixtureKey(common.HexToHash(hash))
+	if got.NetworkID != f.NetworkID || got.Head != f.Head || got.Txs[key] == nil || got.Receipts[key] == nil || got.Headers["100"] == nil {
+		t.Fatalf("recorded %+v", got)
+	}
+}
@@ -0,0 +1,63 @@
+// cmd: DALINK_GO_CONFIG_PATH=./config/dev.yml go run ./cmd/asset -chain 56 -ca 0x... [-ledger-decimals 6] [-dry-run]
+//
+// Registers an ERC20 token in t_asset from what its contract reports, like POST /admin/asset/discover.
+// With -refresh it instead reads the metadata of every stale erc20 asset again.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"chaos/api/service"
+)
+
+func main() {
+	chainID := flag.Uint64("chain", 0, "EVM chain id of the token")
+	contract := flag.String("ca", "", "token contract address")
+	ledgerDecimals := flag.Int("ledger-decimals", 0, "ledger scale, 0 for the default")
+	displayDecimals := flag.Int("display-decimals", 0, "decimals amounts are shown with, 0 for all")
+	dryRun := flag.Bool("dry-run", false, "only print what the contract reports")
+	refresh := flag.Bool("refresh", false, "refresh the metadata of stale erc20 assets and exit")
+	flag.Parse()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	if *refresh {
+		service.RefreshAssetMetadata(ctx)
+		return
+	}
+	if *chainID == 0 || *contract == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var out interface{}
+	var err error
+	if *dryRun {
+		out, err = service.DiscoverERC20(ctx, *chainID, *contract)
+	} else {
+		// operator 0 in the audit trail: registered from the command line
+		out, err = service.RegisterERC20Asset(ctx, 0, service.AssetRegistration{
+			ChainID:         *chainID,
+			Contract:        *contract,
+			LedgerDecimals:  int32(*ledgerDecimals),
+			DisplayDecimals: int32(*displayDecimals),
+		})
+	}
+	if err != nil && !errors.Is(err, service.ErrAssetExists) {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	b, _ := json.MarshalIndent(out, "", "  ")
+	fmt.Println(string(b))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,36 @@
+// cmd: echo "$NEW_PASSWORD" | DALINK_GO_CONFIG_PATH=./config/dev.yml go run ./cmd/operator -email ops@example.com [-role finance]
+//
+// Sets the password of an operator, read from the first line of stdin, stored as a bcrypt hash.
+// Operators still holding a plaintext password cannot log in until it is set here.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"chaos/api/service"
+)
+
+func main() {
+	email := flag.String("email", "", "email of the operator")
+	role := flag.String("role", "", "also set the role: operator, finance or admin")
+	flag.Parse()
+	if *email == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		fmt.Fprintln(os.Stderr, "read password from stdin:", err)
+		os.Exit(1)
+	}
+	if err := service.SetOperatorPassword(*email, strings.TrimRight(line, "\r\n"), *role); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Println("password set for", *email)
+}
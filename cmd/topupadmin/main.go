@@ -0,0 +1,118 @@
+// cmd: DALINK_GO_CONFIG_PATH=./config/dev.yml go run ./cmd/topupadmin -chain 56 [-contract 0x...] [-from 0x...] [-send] <command> [args]
+//
+// Reads and administers a TopupLogic contract, the chain's own unless -contract is given:
+//
+//	state                      token, signer, lock ttl, locked total and sweepable balance
+//	set-signer <address>       setSigner
+//	set-ttl <seconds>          setMaxLockTTL
+//	sweep <to> <amount>        sweep, amount in base units of the token
+//	revoke <user> <lockId>     revokeLock
+//
+// Calls are printed unsigned, as the to/value/data a multisig proposes; -from first simulates them
+// from the multisig. With -send they are signed with the contractAdmin key and broadcast instead.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math/big"
+	"os"
+	"strconv"
+	"time"
+
+	"chaos/api/chain"
+	"chaos/api/service"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func main() {
+	chainID := flag.Uint64("chain", 0, "EVM chain id of the contract")
+	contract := flag.String("contract", "", "TopupLogic contract, the chain's topup contract when empty")
+	from := flag.String("from", "", "simulate the call from this address, e.g. the owning multisig")
+	send := flag.Bool("send", false, "sign with the contractAdmin key and broadcast")
+	flag.Parse()
+	args := flag.Args()
+	if *chainID == 0 || len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	admin, err := chain.NewChainTopupAdmin(*chainID, *contract)
+	if err != nil {
+		fail(err)
+	}
+
+	var call *chain.TopupCall
+	switch cmd, params := args[0], args[1:]; {
+	case cmd == "state" && len(params) == 0:
+		state, err := admin.State(ctx)
+		if err != nil {
+			fail(err)
+		}
+		printJSON(struct {
+			*chain.TopupState
+			Sweepable *big.Int `json:"sweepable"`
+		}{state, state.Sweepable()})
+		return
+	case cmd == "set-signer" && len(params) == 1:
+		call, err = admin.SetSigner(ctx, params[0])
+	case cmd == "set-ttl" && len(params) == 1:
+		ttl, perr := strconv.ParseUint(params[0], 10, 64)
+		if perr != nil {
+			fail(fmt.Errorf("bad ttl %q", params[0]))
+		}
+		call, err = admin.SetMaxLockTTL(ctx, ttl)
+	case cmd == "sweep" && len(params) == 2:
+		amount, ok := new(big.Int).SetString(params[1], 10)
+		if !ok {
+			fail(fmt.Errorf("bad amount %q", params[1]))
+		}
+		call, err = admin.Sweep(ctx, params[0], amount)
+	case cmd == "revoke" && len(params) == 2:
+		call, err = admin.RevokeLock(ctx, params[0], params[1])
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fail(err)
+	}
+
+	if !*send {
+		if *from != "" {
+			if !common.IsHexAddress(*from) {
+				fail(fmt.Errorf("bad from address %q", *from))
+			}
+			if err := admin.Simulate(ctx, call, common.HexToAddress(*from)); err != nil {
+				fail(err)
+			}
+		}
+		printJSON(call)
+		return
+	}
+	signer, err := service.ContractAdminSigner()
+	if err != nil {
+		fail(err)
+	}
+	tx, err := admin.Send(ctx, call, signer)
+	if err != nil {
+		fail(err)
+	}
+	printJSON(map[string]interface{}{"call": call, "from": signer.Address().Hex(), "tx_hash": tx.Hash().Hex(), "nonce": tx.Nonce()})
+}
+
+func printJSON(v interface{}) {
+	b, _ := json.MarshalIndent(v, "", "  ")
+	fmt.Println(string(b))
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
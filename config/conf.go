@@ -29,6 +29,72 @@ type IndexerRootConfig struct {
 
 type ContractConfig struct {
 	NAddress string `yaml:"nAddress"`
+	NAssetID uint64 `yaml:"nAssetId"` // t_asset id used when a request does not name an asset
+	// NChainDecimals is the contract scale of N while it is not registered in t_asset
+	NChainDecimals int32 `yaml:"nChainDecimals"`
+}
+
+type SessionConfig struct {
+	Timeout       int `yaml:"timeout"`       // seconds before an unfinished game session is released
+	SweepInterval int `yaml:"sweepInterval"` // seconds between two abandoned session sweeps
+}
+
+type DepositConfig struct {
+	Enable   bool `yaml:"enable"`
+	Interval int  `yaml:"interval"` // seconds between two deposit log polls
+}
+
+// SolanaConfig accepts top-ups of one SPL mint sent to a treasury wallet. The rpcs are the ones
+// of the SOLANA chain entry.
+type SolanaConfig struct {
+	Enable   bool   `yaml:"enable"`
+	ChainID  uint64 `yaml:"chainId"`  // id solana flows are recorded under, 501 as in the OKX chain index
+	Mint     string `yaml:"mint"`     // SPL mint accepted for top-ups
+	Treasury string `yaml:"treasury"` // wallet owning the token account deposits are sent to
+	AssetID  uint64 `yaml:"assetId"`  // t_asset the mint credits, its decimals must match the mint
+
+	Watch SolanaWatchConfig `yaml:"watch"`
+}
+
+// SolanaWatchConfig runs the Solana watcher over the wallets users linked. It works whether or
+// not top-ups are enabled.
+type SolanaWatchConfig struct {
+	Enable        bool     `yaml:"enable"`
+	Mints         []string `yaml:"mints"`
+	StartSlot     uint64   `yaml:"startSlot"`     // first run only; 0 starts at the head
+	Sinks         []string `yaml:"sinks"`         // db, csv; db when empty
+	CSVPath       string   `yaml:"csvPath"`       // file the csv sink appends to
+	WalletRefresh int      `yaml:"walletRefresh"` // seconds between two reloads of the linked wallets
+}
+
+type AlertConfig struct {
+	Webhook string `yaml:"webhook"` // url alerts are POSTed to as json; alerts are only logged when empty
+}
+
+// SignerConfig is one key that signs withdraw locks. Limits are in base units of the token and
+// empty means no limit.
+type SignerConfig struct {
+	Name            string   `yaml:"name"`
+	Backend         string   `yaml:"backend"`         // keystore, remote or memory
+	ChainIDs        []uint64 `yaml:"chainIds"`        // chains it signs for; empty signs for all
+	Keystore        string   `yaml:"keystore"`        // keystore: path of the encrypted json key
+	PasswordEnv     string   `yaml:"passwordEnv"`     // keystore: env var holding the passphrase
+	KeyEnv          string   `yaml:"keyEnv"`          // memory: env var holding the hex key, tests and dev only
+	URL             string   `yaml:"url"`             // remote: base url of the signing service
+	TokenEnv        string   `yaml:"tokenEnv"`        // remote: env var holding the bearer token
+	Address         string   `yaml:"address"`         // remote: address the service signs as
+	MaxPerSignature string   `yaml:"maxPerSignature"` // largest single lock
+	MaxPerDay       string   `yaml:"maxPerDay"`       // locks signed per chain and calendar day
+}
+
+type AssetConfig struct {
+	MetaRefresh int `yaml:"metaRefresh"` // hours before the token metadata of an erc20 asset is read again, 24 when 0
+}
+
+type AdjustmentConfig struct {
+	// DualApprovalAbove is, by asset id, the amount in whole tokens above which an adjustment of the
+	// asset needs two approvers. An asset missing here always needs two.
+	DualApprovalAbove map[uint64]string `yaml:"dualApprovalAbove"`
 }
 
 type Config struct {
@@ -41,6 +107,15 @@ type Config struct {
 	ProxyEnable bool              `yaml:"proxyEnable"`
 	IndexerRoot IndexerRootConfig `yaml:"indexerRoot"`
 	Contract    ContractConfig    `yaml:"contract"`
+	Session     SessionConfig     `yaml:"session"`
+	Adjustment  AdjustmentConfig  `yaml:"adjustment"`
+	Deposit     DepositConfig     `yaml:"deposit"`
+	Alert       AlertConfig       `yaml:"alert"`
+	Signer      []SignerConfig    `yaml:"signer"`
+	Solana      SolanaConfig      `yaml:"solana"`
+	Asset       AssetConfig       `yaml:"asset"`
+	// ContractAdmin is the key owning the TopupLogic contracts, left empty when a multisig owns them
+	ContractAdmin SignerConfig `yaml:"contractAdmin"`
 }
 
 // DatabaseConfig holds the database connection parameters.
@@ -63,8 +138,20 @@ type ChainConfig struct {
 	SlotParallel int      `yaml:"slotParallel"`
 	TxDetal      int      `yaml:"txDetal"`
 	RangeRound   int      `yaml:"rangeRound"`
-	Rpcs         []RpcMapper
-	RpcMap       map[string]int
+	// EVM chains only, they make up the chain registry of tools.LookupChain
+	ChainID          uint64 `yaml:"chainId"`
+	NativeSymbol     string `yaml:"nativeSymbol"`
+	TopupContract    string `yaml:"topupContract"`
+	TokenContract    string `yaml:"tokenContract"`    // the N token, contract.nAddress when empty
+	DepositFromBlock uint64 `yaml:"depositFromBlock"` // first block to index when there is no cursor yet
+	Confirmations    uint64 `yaml:"confirmations"`    // blocks, the including one counted, before a tx is credited
+	ReorgWindow      uint64 `yaml:"reorgWindow"`      // how many blocks back credited txs are re-checked for reorgs
+	Eip712Name       string `yaml:"eip712Name"`       // LockAuth domain of the TopupLogic contract, read from
+	Eip712Version    string `yaml:"eip712Version"`    // its eip712Domain() when either is empty
+	NativeTreasury   string `yaml:"nativeTreasury"`   // address native coin deposits are sent to, none accepted when empty
+	NativeAssetID    uint64 `yaml:"nativeAssetId"`    // t_asset of the native coin, type native
+	Rpcs             []RpcMapper
+	RpcMap           map[string]int
 }
 
 // LogConfig holds the logging directory and file name.
@@ -118,6 +205,14 @@ func GetRpcConfig(code string) *ChainConfig {
 	return nil
 }
 
+// GetTopupContract is the TopupLogic address on a chain, falling back to the TOPUP_CONTRACT env.
+func (t ChainConfig) GetTopupContract() string {
+	if t.TopupContract != "" {
+		return t.TopupContract
+	}
+	return os.Getenv("TOPUP_CONTRACT")
+}
+
 func (t ChainConfig) GetRpc() []string {
 	r := make([]string, 0)
 	for _, v := range t.Rpcs {
@@ -137,6 +232,20 @@ func (t *ChainConfig) GetSlotParallel() int {
 	return 1
 }
 
+func (t *ChainConfig) GetConfirmations() uint64 {
+	if t.Confirmations > 0 {
+		return t.Confirmations
+	}
+	return 1
+}
+
+func (t *ChainConfig) GetReorgWindow() uint64 {
+	if t.ReorgWindow > 0 {
+		return t.ReorgWindow
+	}
+	return 256
+}
+
 func (t *ChainConfig) GetTxDelay() int {
 	if t.TxDetal > 0 {
 		return t.TxDetal
@@ -31,49 +31,223 @@ func main() {
 	// 创建等待组，用于等待所有goroutine完成
 	var wg sync.WaitGroup
 
-	// 启动链上消费者
-	chain.StartTopupConsumer(ctx)
+	// unlock the withdrawal signers now rather than on the first withdrawal
+	if err := service.LoadSigners(); err != nil {
+		log.Error("load withdrawal signers failed", err)
+	}
+
+	// compute the LockAuth domains once and check them against the contracts
+	service.CheckLockAuthDomains(ctx)
+
+	// native deposits are only taken into an asset that books wei at a scale the ledger holds
+	service.CheckNativeAssets()
+
+	// 启动链上消费者：队列持久化在 n_job，确认后的结果直接入账
+	chain.StartTopupConsumer(ctx, service.SettleTopupTx)
 
-	// 启动数据库更新处理goroutine
+	// start to check database pending transactions
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
+		ticker := time.NewTicker(24 * time.Hour) // 每10秒检查一次
+		defer ticker.Stop()
+
 		for {
 			select {
 			case <-ctx.Done():
-				log.Info("Database update goroutine shutting down...")
+				log.Info("Database check goroutine shutting down...")
 				return
-			case info := <-chain.UpdateTopupTx():
-				if info == nil {
-					continue
-				}
-				log.Info("prepare for db update", info)
-				err := service.UpdateAccountBalance(info)
-				if err != nil {
-					log.Error("update account balance and token flow status failed", err)
+			case <-ticker.C:
+				runScheduled(ctx, "check_tx", checkTx)
+			}
+		}
+	}()
+
+	// rebuild balances from the ledger journal and report drift
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(24 * time.Hour)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				log.Info("Ledger check goroutine shutting down...")
+				return
+			case <-ticker.C:
+				runScheduled(ctx, "ledger_check", func() {
+					if _, _, err := service.CheckLedger(); err != nil {
+						log.Error("ledger check failed", err)
+					}
+				})
+			}
+		}
+	}()
+
+	// release the freezes of game sessions that were started but never ended
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		interval := time.Duration(config.GetConfig().Session.SweepInterval) * time.Second
+		if interval <= 0 {
+			interval = time.Minute
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				log.Info("Session sweep goroutine shutting down...")
+				return
+			case <-ticker.C:
+				runScheduled(ctx, "session_sweep", func() {
+					if _, err := service.SweepAbandonedSessions(); err != nil {
+						log.Error("session sweep failed", err)
+					}
+				})
+			}
+		}
+	}()
+
+	// snapshot every balance shortly after midnight
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			next := service.NextSnapshotRun(time.Now())
+			timer := time.NewTimer(time.Until(next))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				log.Info("Balance snapshot goroutine shutting down...")
+				return
+			case <-timer.C:
+				runScheduled(ctx, "balance_snapshot", func() {
+					if _, err := service.TakeBalanceSnapshot(next.AddDate(0, 0, -1)); err != nil {
+						log.Error("balance snapshot failed", err)
+					}
+				})
+			}
+		}
+	}()
+
+	// forget idempotency keys past their ttl
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				log.Info("Idempotency purge goroutine shutting down...")
+				return
+			case <-ticker.C:
+				runScheduled(ctx, "idempotency_purge", func() {
+					if _, err := service.PurgeIdempotencyKeys(); err != nil {
+						log.Error("purge idempotency keys failed", err)
+					}
+				})
+			}
+		}
+	}()
+
+	// follow the TopupLogic logs: credit deposits whether or not the client reported them,
+	// settle revoked locks and alert on sweeps and setting changes; follow the airdrop claims too
+	if config.GetConfig().Deposit.Enable {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			interval := time.Duration(config.GetConfig().Deposit.Interval) * time.Second
+			if interval <= 0 {
+				interval = 15 * time.Second
+			}
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ctx.Done():
+					log.Info("Deposit indexer goroutine shutting down...")
+					return
+				case <-ticker.C:
+					runScheduled(ctx, "chain_index", func() {
+						service.IndexDeposits(ctx)
+						service.IndexContractEvents(ctx)
+						service.IndexAirdropClaims(ctx)
+					})
 				}
 			}
+		}()
+	}
+
+	// take back recharges whose tx a reorg dropped or moved
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				log.Info("Reorg check goroutine shutting down...")
+				return
+			case <-ticker.C:
+				runScheduled(ctx, "reorg_check", func() { service.CheckReorgs(ctx) })
+			}
 		}
 	}()
 
-	// start to check database pending transactions
+	// give back the withdraw locks that expired without being opened on chain
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		ticker := time.NewTicker(24 * time.Hour) // 每10秒检查一次
+		ticker := time.NewTicker(5 * time.Minute)
 		defer ticker.Stop()
 
 		for {
 			select {
 			case <-ctx.Done():
-				log.Info("Database check goroutine shutting down...")
+				log.Info("Lock watcher goroutine shutting down...")
 				return
 			case <-ticker.C:
-				checkTx()
+				runScheduled(ctx, "lock_watch", func() { service.WatchExpiredLocks(ctx) })
 			}
 		}
 	}()
 
+	// read the token metadata of the erc20 assets again once it is older than asset.metaRefresh
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				log.Info("Asset metadata goroutine shutting down...")
+				return
+			case <-ticker.C:
+				runScheduled(ctx, "asset_metadata", func() { service.RefreshAssetMetadata(ctx) })
+			}
+		}
+	}()
+
+	// follow the Solana wallets users linked; restarted on failure, status at /admin/solana/watcher
+	if config.GetConfig().Solana.Watch.Enable {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			service.RunSolanaWatcher(ctx)
+			log.Info("Solana watcher goroutine shutting down...")
+		}()
+	}
+
 	// 启动HTTP服务器
 	server := router.Init()
 
@@ -146,6 +320,13 @@ func main() {
 	log.Info("Server shutdown complete")
 }
 
+// runScheduled runs a scheduled job on one API instance at a time; the others skip the tick.
+func runScheduled(ctx context.Context, name string, fn func()) {
+	if _, err := system.RunExclusive(ctx, name, fn); err != nil {
+		log.Error("scheduled job "+name+" skipped", err)
+	}
+}
+
 func checkTx() {
 	db := system.GetDb()
 
@@ -178,11 +359,17 @@ func checkTx() {
 
 			switch op {
 			case model.BalanceFlowOpRecharge:
-				chain.AppendTopupTx(chainID, txHash, cflow.MainID, cflow.ID, op)
+				if chain.IsNativeAsset(chainID, cflow.AssetID) {
+					chain.AppendNativeTopupTx(chainID, txHash, cflow.MainID, cflow.ID)
+				} else {
+					chain.AppendTopupTx(chainID, txHash, cflow.MainID, cflow.ID, op)
+				}
 			case model.BalanceFlowOpWithdraw:
 				chain.AppendTopupTx(chainID, txHash, cflow.MainID, cflow.RefFlowID, op)
 			case model.BalanceFlowOpUnfreeze:
 				chain.AppendTopupTx(chainID, txHash, cflow.MainID, cflow.RefFlowID, op)
+			case model.BalanceFlowOpRevoke:
+				chain.AppendTopupTx(chainID, txHash, cflow.MainID, cflow.ID, op)
 			case model.BalanceFlowOpFreeze:
 				chain.AppendTopupTx(chainID, txHash, cflow.MainID, cflow.ID, op)
 			default:
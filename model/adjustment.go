@@ -0,0 +1,72 @@
+package model
+
+import "time"
+
+const (
+	AdjustmentCredit = "credit"
+	AdjustmentDebit  = "debit"
+)
+
+const (
+	AdjustmentStatusPending  = "00"
+	AdjustmentStatusPosted   = "10"
+	AdjustmentStatusRejected = "20"
+	AdjustmentStatusCanceled = "30"
+)
+
+const (
+	AdjustmentDecisionApprove = "approve"
+	AdjustmentDecisionReject  = "reject"
+)
+
+// BalanceAdjustment is an operator request to credit or debit a user's available balance.
+// It only posts to the ledger once RequiredApprovals operators other than the maker approved it.
+type BalanceAdjustment struct {
+	ID                uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	MainID            uint64    `gorm:"column:main_id;type:int(11);not null" json:"main_id"`
+	AssetID           uint64    `gorm:"column:asset_id;type:int(11);not null" json:"asset_id"`
+	Direction         string    `gorm:"column:direction;type:varchar(16);not null" json:"direction"`
+	Amount            uint64    `gorm:"column:amount;type:bigint unsigned;not null" json:"amount"`
+	Reason            string    `gorm:"column:reason;type:varchar(512);not null" json:"reason"`
+	EvidenceURL       string    `gorm:"column:evidence_url;type:varchar(512);not null" json:"evidence_url"`
+	Status            string    `gorm:"column:status;type:varchar(8);not null" json:"status"`
+	RequiredApprovals int       `gorm:"column:required_approvals;type:int(11);not null" json:"required_approvals"`
+	Approvals         int       `gorm:"column:approvals;type:int(11);not null" json:"approvals"`
+	MakerID           uint64    `gorm:"column:maker_id;type:int(11);not null" json:"maker_id"`
+	FlowID            uint64    `gorm:"column:flow_id;type:int(11);not null" json:"flow_id"` // n_account_flow row once posted
+	AddTime           time.Time `gorm:"column:add_time;type:datetime;not null" json:"add_time"`
+	UpdateTime        time.Time `gorm:"column:update_time;type:datetime;not null" json:"update_time"`
+}
+
+func (BalanceAdjustment) TableName() string {
+	return TB_BALANCE_ADJUSTMENT
+}
+
+// AdjustmentReview is one checker decision on a BalanceAdjustment.
+type AdjustmentReview struct {
+	ID           uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	AdjustmentID uint64    `gorm:"column:adjustment_id;type:int(11);not null" json:"adjustment_id"`
+	OperatorID   uint64    `gorm:"column:operator_id;type:int(11);not null" json:"operator_id"`
+	Decision     string    `gorm:"column:decision;type:varchar(16);not null" json:"decision"`
+	Comment      string    `gorm:"column:comment;type:varchar(512);not null" json:"comment"`
+	AddTime      time.Time `gorm:"column:add_time;type:datetime;not null" json:"add_time"`
+}
+
+func (AdjustmentReview) TableName() string {
+	return TB_ADJUSTMENT_REVIEW
+}
+
+// AdminAudit is the append-only trail of operator actions.
+type AdminAudit struct {
+	ID          uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	OperatorID  uint64    `gorm:"column:operator_id;type:int(11);not null" json:"operator_id"`
+	Action      string    `gorm:"column:action;type:varchar(64);not null" json:"action"`
+	TargetTable string    `gorm:"column:target_table;type:varchar(64);not null" json:"target_table"`
+	TargetID    uint64    `gorm:"column:target_id;type:int(11);not null" json:"target_id"`
+	Detail      string    `gorm:"column:detail;type:text;not null" json:"detail"`
+	AddTime     time.Time `gorm:"column:add_time;type:datetime;not null" json:"add_time"`
+}
+
+func (AdminAudit) TableName() string {
+	return TB_ADMIN_AUDIT
+}
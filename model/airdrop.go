@@ -0,0 +1,53 @@
+package model
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	AirdropCampaignStatusDraft = "00" // tree built, no distributor attached yet
+	AirdropCampaignStatusLive  = "10" // distributor holds the root, claims are open
+)
+
+// AirdropCampaign is a merkle airdrop: the root of a tree over (wallet, amount) leaves, and the
+// distributor contract on ChainID that pays out against it.
+type AirdropCampaign struct {
+	ID         uint64          `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name       string          `gorm:"column:name;type:varchar(64);not null" json:"name"`
+	ChainID    uint64          `gorm:"column:chain_id;type:int(11);not null" json:"chain_id"`
+	Contract   string          `gorm:"column:contract;type:varchar(64);not null" json:"contract"`
+	StartBlock uint64          `gorm:"column:start_block;type:bigint unsigned;not null" json:"start_block"` // first block to look for claims in
+	Decimals   int32           `gorm:"column:decimals;type:int(11);not null" json:"decimals"`               // of the airdropped token
+	MerkleRoot string          `gorm:"column:merkle_root;type:varchar(80);not null" json:"merkle_root"`
+	Leaves     int             `gorm:"column:leaves;type:int(11);not null" json:"leaves"`
+	Total      decimal.Decimal `gorm:"column:total;type:decimal(65,0);not null" json:"total"` // base units
+	Source     string          `gorm:"column:source;type:varchar(32);not null" json:"source"` // csv or snapshot
+	Status     string          `gorm:"column:status;type:varchar(8);not null" json:"status"`
+	OperatorID uint64          `gorm:"column:operator_id;type:int(11);not null" json:"operator_id"`
+	AddTime    time.Time       `gorm:"column:add_time;type:datetime;not null" json:"add_time"`
+	UpdateTime time.Time       `gorm:"column:update_time;type:datetime;not null" json:"update_time"`
+}
+
+func (AirdropCampaign) TableName() string {
+	return TB_AIRDROP_CAMPAIGN
+}
+
+// AirdropLeaf is what one wallet may claim in a campaign, with the proof of it against the root.
+type AirdropLeaf struct {
+	ID         uint64          `gorm:"primaryKey;autoIncrement" json:"id"`
+	CampaignID uint64          `gorm:"column:campaign_id;type:int(11);not null" json:"campaign_id"`
+	Idx        int             `gorm:"column:idx;type:int(11);not null" json:"idx"`
+	Wallet     string          `gorm:"column:wallet;type:varchar(64);not null" json:"wallet"`
+	Amount     decimal.Decimal `gorm:"column:amount;type:decimal(65,0);not null" json:"amount"` // base units
+	Leaf       string          `gorm:"column:leaf;type:varchar(80);not null" json:"leaf"`
+	Proof      string          `gorm:"column:proof;type:text;not null" json:"proof"` // json array of hex hashes
+	ClaimTx    string          `gorm:"column:claim_tx;type:varchar(255);not null" json:"claim_tx"`
+	ClaimBlock uint64          `gorm:"column:claim_block;type:bigint unsigned;not null" json:"claim_block"`
+	ClaimTime  *time.Time      `gorm:"column:claim_time;type:datetime" json:"claim_time"`
+}
+
+func (AirdropLeaf) TableName() string {
+	return TB_AIRDROP_LEAF
+}
@@ -0,0 +1,108 @@
+package model
+
+import (
+	"errors"
+	"math"
+	"math/big"
+
+	"github.com/shopspring/decimal"
+)
+
+var (
+	ErrAmountNegative  = errors.New("amount is negative")
+	ErrAmountOverflow  = errors.New("amount overflows the ledger")
+	ErrAmountPrecision = errors.New("amount has more decimals than the asset")
+)
+
+// MaxLedgerDecimals keeps at least ~18 billion whole tokens representable in a uint64 ledger column.
+const MaxLedgerDecimals = 9
+
+var maxUint64 = new(big.Int).SetUint64(math.MaxUint64)
+
+// Amount is a quantity of one asset in ledger units: the integer the balance, flow and journal
+// columns store. An asset moves between three scales:
+//
+//	on-chain base units (ChainDecimals) <-> ledger units (Decimals) <-> display (DisplayDecimals)
+//
+// Conversions into the ledger fail instead of wrapping around or silently dropping value.
+type Amount uint64
+
+// Uint64 returns the ledger units for the uint64 columns.
+func (a Amount) Uint64() uint64 {
+	return uint64(a)
+}
+
+func fitLedger(units *big.Int) (Amount, error) {
+	if units.Sign() < 0 {
+		return 0, ErrAmountNegative
+	}
+	if units.Cmp(maxUint64) > 0 {
+		return 0, ErrAmountOverflow
+	}
+	return Amount(units.Uint64()), nil
+}
+
+func pow10(n int32) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// chainDecimals falls back to the ledger scale for assets registered before chain_decimals existed.
+func (a SysAsset) chainDecimals() int32 {
+	if a.ChainDecimals > 0 {
+		return a.ChainDecimals
+	}
+	return a.Decimals
+}
+
+func (a SysAsset) displayDecimals() int32 {
+	if a.DisplayDecimals > 0 && a.DisplayDecimals < a.Decimals {
+		return a.DisplayDecimals
+	}
+	return a.Decimals
+}
+
+// ParseAmount converts a user or operator supplied amount in whole tokens into ledger units.
+// Amounts finer than one ledger unit are rejected, not rounded.
+func (a SysAsset) ParseAmount(amount decimal.Decimal) (Amount, error) {
+	shifted := amount.Shift(a.Decimals)
+	if !shifted.Equal(shifted.Truncate(0)) {
+		return 0, ErrAmountPrecision
+	}
+	return fitLedger(shifted.BigInt())
+}
+
+// FromChain converts on-chain base units into ledger units. Dust below one ledger unit can not
+// be represented and is returned separately, in base units, so the caller can account for it.
+func (a SysAsset) FromChain(base *big.Int) (Amount, *big.Int, error) {
+	if base == nil {
+		return 0, new(big.Int), nil
+	}
+	diff := a.chainDecimals() - a.Decimals
+	if diff <= 0 {
+		amount, err := fitLedger(new(big.Int).Mul(base, pow10(-diff)))
+		return amount, new(big.Int), err
+	}
+	units, dust := new(big.Int).QuoRem(base, pow10(diff), new(big.Int))
+	amount, err := fitLedger(units)
+	return amount, dust, err
+}
+
+// ToChain converts ledger units into the base units a contract call or signature carries.
+func (a SysAsset) ToChain(amount Amount) *big.Int {
+	base := new(big.Int).SetUint64(uint64(amount))
+	diff := a.chainDecimals() - a.Decimals
+	if diff >= 0 {
+		return base.Mul(base, pow10(diff))
+	}
+	return base.Quo(base, pow10(-diff))
+}
+
+// FromUnits converts ledger units back to whole tokens, exactly.
+func (a SysAsset) FromUnits(units uint64) decimal.Decimal {
+	return decimal.NewFromUint64(units).Shift(-a.Decimals)
+}
+
+// Format renders ledger units for display, cut down to the display decimals of the asset.
+func (a SysAsset) Format(units uint64) string {
+	return a.FromUnits(units).Truncate(a.displayDecimals()).StringFixed(a.displayDecimals())
+}
@@ -0,0 +1,69 @@
+package model
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestParseAmount(t *testing.T) {
+	n := SysAsset{Symbol: "N", Decimals: 6}
+	if got, err := n.ParseAmount(decimal.RequireFromString("1.5")); err != nil || got != 1500000 {
+		t.Fatalf("ParseAmount(1.5) = %d, %v; want 1500000", got, err)
+	}
+	if got := n.FromUnits(1500000); !got.Equal(decimal.RequireFromString("1.5")) {
+		t.Fatalf("FromUnits(1500000) = %s, want 1.5", got)
+	}
+	if _, err := n.ParseAmount(decimal.RequireFromString("0.0000015")); !errors.Is(err, ErrAmountPrecision) {
+		t.Fatalf("sub-unit amount: got %v, want ErrAmountPrecision", err)
+	}
+	if _, err := n.ParseAmount(decimal.RequireFromString("-1")); !errors.Is(err, ErrAmountNegative) {
+		t.Fatalf("negative amount: got %v, want ErrAmountNegative", err)
+	}
+	if _, err := n.ParseAmount(decimal.RequireFromString("18446744073709.551616")); !errors.Is(err, ErrAmountOverflow) {
+		t.Fatalf("2^64 units: got %v, want ErrAmountOverflow", err)
+	}
+	if got, err := n.ParseAmount(decimal.RequireFromString("18446744073709.551615")); err != nil || got != Amount(1<<64-1) {
+		t.Fatalf("max units: got %d, %v", got, err)
+	}
+}
+
+func TestChainAmount(t *testing.T) {
+	usdt := SysAsset{Symbol: "USDT", Decimals: 6, ChainDecimals: 18}
+	wei, _ := new(big.Int).SetString("2500000000000000123", 10)
+	got, dust, err := usdt.FromChain(wei)
+	if err != nil || got != 2500000 || dust.Int64() != 123 {
+		t.Fatalf("FromChain(%s) = %d, dust %s, %v", wei, got, dust, err)
+	}
+	if back := usdt.ToChain(got); back.String() != "2500000000000000000" {
+		t.Fatalf("ToChain(%d) = %s", got, back)
+	}
+
+	// registered before chain_decimals: both scales are the same
+	n := SysAsset{Symbol: "N", Decimals: 6}
+	if got, _, err := n.FromChain(big.NewInt(42)); err != nil || got != 42 || n.ToChain(42).Int64() != 42 {
+		t.Fatalf("FromChain without chain decimals = %d, %v", got, err)
+	}
+
+	huge, _ := new(big.Int).SetString("100000000000000000000000000000000000000", 10)
+	if _, _, err := usdt.FromChain(huge); !errors.Is(err, ErrAmountOverflow) {
+		t.Fatalf("huge deposit: got %v, want ErrAmountOverflow", err)
+	}
+
+	small := SysAsset{Decimals: 6, ChainDecimals: 2}
+	if got, _, _ := small.FromChain(big.NewInt(150)); got != 1500000 {
+		t.Fatalf("scaling up from 2 chain decimals = %d", got)
+	}
+}
+
+func TestFormatAmount(t *testing.T) {
+	a := SysAsset{Decimals: 6, DisplayDecimals: 2}
+	if got := a.Format(1239999); got != "1.23" {
+		t.Fatalf("Format = %s, want 1.23", got)
+	}
+	if got := (SysAsset{Decimals: 6}).Format(1500000); got != "1.500000" {
+		t.Fatalf("Format without display decimals = %s", got)
+	}
+}
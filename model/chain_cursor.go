@@ -0,0 +1,62 @@
+package model
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// cursor names of the chain followers
+const (
+	CursorTopupDeposit = "topup_deposit"
+	CursorTopupEvents  = "topup_events"  // owner events, see ContractEvent
+	CursorSolanaTrades = "solana_trades" // slots of the Solana watcher, see SolanaTrade
+	CursorAirdropClaim = "airdrop_claim" // Claimed logs of a campaign's distributor, suffixed with its id
+)
+
+// ChainCursor is how far a log follower got on one chain: every block up to and including
+// BlockNumber has been processed.
+type ChainCursor struct {
+	ID          uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	ChainID     uint64    `gorm:"column:chain_id;type:int(11);not null" json:"chain_id"`
+	Name        string    `gorm:"column:name;type:varchar(64);not null" json:"name"`
+	Contract    string    `gorm:"column:contract;type:varchar(64);not null" json:"contract"`
+	BlockNumber uint64    `gorm:"column:block_number;type:bigint unsigned;not null" json:"block_number"`
+	UpdateTime  time.Time `gorm:"column:update_time;type:datetime;not null" json:"update_time"`
+}
+
+func (ChainCursor) TableName() string {
+	return TB_CHAIN_CURSOR
+}
+
+const (
+	UnmatchedDepositStatusOpen     = "00"
+	UnmatchedDepositStatusResolved = "10"
+)
+
+// why a deposit could not be credited
+const (
+	UnmatchedDepositReasonNoWallet = "no_wallet" // the depositor is not the wallet of any user
+	UnmatchedDepositReasonNoAsset  = "no_asset"  // the token of the contract is not registered on the chain
+)
+
+// UnmatchedDeposit is a Deposited log that could not be credited: its depositor is not the wallet
+// of any user, or its token is no registered asset. The funds are in custody; an operator resolves
+// it, e.g. with a balance adjustment once the owner or asset is known.
+type UnmatchedDeposit struct {
+	ID          uint64          `gorm:"primaryKey;autoIncrement" json:"id"`
+	ChainID     uint64          `gorm:"column:chain_id;type:int(11);not null" json:"chain_id"`
+	Contract    string          `gorm:"column:contract;type:varchar(64);not null" json:"contract"`
+	TxHash      string          `gorm:"column:tx_hash;type:varchar(255);not null" json:"tx_hash"`
+	LogIndex    uint            `gorm:"column:log_index;type:int(11);not null" json:"log_index"`
+	BlockNumber uint64          `gorm:"column:block_number;type:bigint unsigned;not null" json:"block_number"`
+	Depositor   string          `gorm:"column:depositor;type:varchar(64);not null" json:"depositor"`
+	Amount      decimal.Decimal `gorm:"column:amount;type:decimal(65,0);not null" json:"amount"` // base units
+	Status      string          `gorm:"column:status;type:varchar(8);not null" json:"status"`
+	Reason      string          `gorm:"column:reason;type:varchar(16);not null" json:"reason"`
+	AddTime     time.Time       `gorm:"column:add_time;type:datetime;not null" json:"add_time"`
+}
+
+func (UnmatchedDeposit) TableName() string {
+	return TB_UNMATCHED_DEPOSIT
+}
@@ -24,4 +24,26 @@ const (
 	TB_SEASON_USER          = "season_user"
 	TB_SEASON_SESSION_BOARD = "season_session_board"
 	TB_SEASON_GAME          = "season_game"
+
+	TB_SYS_OPERATOR = "sys_operator"
+
+	TB_LEDGER_ENTRY       = "n_ledger_entry"
+	TB_LEDGER_POSTING     = "n_ledger_posting"
+	TB_LEDGER_CHECK       = "n_ledger_check"
+	TB_IDEMPOTENCY_KEY    = "n_idempotency_key"
+	TB_BALANCE_SNAPSHOT   = "n_balance_snapshot"
+	TB_LIABILITY_SNAPSHOT = "n_liability_snapshot"
+	TB_BALANCE_ADJUSTMENT = "n_balance_adjustment"
+	TB_ADJUSTMENT_REVIEW  = "n_adjustment_review"
+	TB_ADMIN_AUDIT        = "n_admin_audit"
+	TB_FEE_SCHEDULE       = "n_fee_schedule"
+	TB_SPEND_SPLIT        = "n_spend_split"
+	TB_CHAIN_CURSOR       = "n_chain_cursor"
+	TB_UNMATCHED_DEPOSIT  = "n_unmatched_deposit"
+	TB_CONTRACT_EVENT     = "n_contract_event"
+	TB_JOB                = "n_job"
+	TB_SIGNER_AUDIT       = "n_signer_audit"
+	TB_SOLANA_TRADE       = "n_solana_trade"
+	TB_AIRDROP_CAMPAIGN   = "n_airdrop_campaign"
+	TB_AIRDROP_LEAF       = "n_airdrop_leaf"
 )
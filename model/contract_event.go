@@ -0,0 +1,38 @@
+package model
+
+import "time"
+
+const (
+	ContractEventStatusOpen  = "00"
+	ContractEventStatusAcked = "10"
+)
+
+const (
+	ContractEventSeverityInfo     = "info"
+	ContractEventSeverityWarning  = "warning"
+	ContractEventSeverityCritical = "critical"
+)
+
+// ContractEvent is the operations record of an owner event of the TopupLogic contract: a lock
+// revocation, a sweep of custody funds, or a signer or lock TTL change. It stays open until an
+// operator acknowledges it.
+type ContractEvent struct {
+	ID          uint64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	ChainID     uint64     `gorm:"column:chain_id;type:int(11);not null" json:"chain_id"`
+	Contract    string     `gorm:"column:contract;type:varchar(64);not null" json:"contract"`
+	TxHash      string     `gorm:"column:tx_hash;type:varchar(255);not null" json:"tx_hash"`
+	LogIndex    uint       `gorm:"column:log_index;type:int(11);not null" json:"log_index"`
+	BlockNumber uint64     `gorm:"column:block_number;type:bigint unsigned;not null" json:"block_number"`
+	BlockTime   time.Time  `gorm:"column:block_time;type:datetime;not null" json:"block_time"`
+	Event       string     `gorm:"column:event;type:varchar(64);not null" json:"event"`
+	Severity    string     `gorm:"column:severity;type:varchar(16);not null" json:"severity"`
+	Detail      string     `gorm:"column:detail;type:text;not null" json:"detail"`
+	Status      string     `gorm:"column:status;type:varchar(8);not null" json:"status"`
+	AckOperator uint64     `gorm:"column:ack_operator;type:int(11);not null" json:"ack_operator"`
+	AckTime     *time.Time `gorm:"column:ack_time;type:datetime" json:"ack_time"`
+	AddTime     time.Time  `gorm:"column:add_time;type:datetime;not null" json:"add_time"`
+}
+
+func (ContractEvent) TableName() string {
+	return TB_CONTRACT_EVENT
+}
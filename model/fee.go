@@ -0,0 +1,49 @@
+package model
+
+import "time"
+
+// FeeBpsTotal is 100% in basis points; the three shares of a FeeSchedule add up to it.
+const FeeBpsTotal = 10000
+
+// FeeSchedule splits every spend of a game between the platform fee, the developer revenue share
+// and the season prize pool. Rows are never updated: a change inserts a new row with a later
+// EffectiveFrom, so the schedule of any past spend can be looked up again.
+// GameID 0 is the platform default, an empty Catalog applies to every catalog of the game.
+type FeeSchedule struct {
+	ID            uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	GameID        uint64    `gorm:"column:game_id;type:int(11);not null" json:"game_id"`
+	Catalog       string    `gorm:"column:catalog;type:varchar(64);not null" json:"catalog"`
+	PlatformBps   int       `gorm:"column:platform_bps;type:int(11);not null" json:"platform_bps"`
+	DeveloperBps  int       `gorm:"column:developer_bps;type:int(11);not null" json:"developer_bps"`
+	PrizePoolBps  int       `gorm:"column:prize_pool_bps;type:int(11);not null" json:"prize_pool_bps"`
+	EffectiveFrom time.Time `gorm:"column:effective_from;type:datetime;not null" json:"effective_from"`
+	OperatorID    uint64    `gorm:"column:operator_id;type:int(11);not null" json:"operator_id"`
+	Remark        string    `gorm:"column:remark;type:varchar(255);not null" json:"remark"`
+	AddTime       time.Time `gorm:"column:add_time;type:datetime;not null" json:"add_time"`
+}
+
+func (FeeSchedule) TableName() string {
+	return TB_FEE_SCHEDULE
+}
+
+// SpendSplit records how one spend flow was divided and under which schedule.
+type SpendSplit struct {
+	ID          uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	FlowID      uint64    `gorm:"column:flow_id;type:int(11);not null" json:"flow_id"`
+	MainID      uint64    `gorm:"column:main_id;type:int(11);not null" json:"main_id"`
+	AssetID     uint64    `gorm:"column:asset_id;type:int(11);not null" json:"asset_id"`
+	GameID      uint64    `gorm:"column:game_id;type:int(11);not null" json:"game_id"`
+	Catalog     string    `gorm:"column:catalog;type:varchar(64);not null" json:"catalog"`
+	ScheduleID  uint64    `gorm:"column:schedule_id;type:int(11);not null" json:"schedule_id"`
+	DeveloperID uint64    `gorm:"column:developer_id;type:int(11);not null" json:"developer_id"`
+	SeasonID    uint64    `gorm:"column:season_id;type:int(11);not null" json:"season_id"`
+	Amount      uint64    `gorm:"column:amount;type:bigint unsigned;not null" json:"amount"`
+	PlatformFee uint64    `gorm:"column:platform_fee;type:bigint unsigned;not null" json:"platform_fee"`
+	Developer   uint64    `gorm:"column:developer;type:bigint unsigned;not null" json:"developer"`
+	PrizePool   uint64    `gorm:"column:prize_pool;type:bigint unsigned;not null" json:"prize_pool"`
+	AddTime     time.Time `gorm:"column:add_time;type:datetime;not null" json:"add_time"`
+}
+
+func (SpendSplit) TableName() string {
+	return TB_SPEND_SPLIT
+}
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"fmt"
 	"time"
 )
 
@@ -11,6 +12,7 @@ const (
 	FlowSpend    = 3
 	FlowWithdraw = 4
 	FlowRefund   = 5
+	FlowAdjust   = 6 // operator adjustment, see BalanceAdjustment
 )
 
 const (
@@ -31,12 +33,14 @@ const (
 	BalanceFlowOpFreeze   = 1
 	BalanceFlowOpWithdraw = 2
 	BalanceFlowOpUnfreeze = 3
+	BalanceFlowOpRevoke   = 4 // the contract owner revoked a withdraw lock
 
 	BalanceFlowStatusPending         = 0
 	BalanceFlowStatusPendingWithDraw = 4
 	BalanceFlowStatusSuccess         = 1
 	BalanceFlowStatusFailed          = 2
 	BalanceFlowStatusCanceled        = 3
+	BalanceFlowStatusLockExpired     = 5 // a withdraw lock opened on chain that expired unclaimed
 )
 
 func AvailFlowType(typeStr string) int {
@@ -53,10 +57,80 @@ func AvailFlowType(typeStr string) int {
 		return FlowSpend
 	case "refund":
 		return FlowRefund
+	case "adjust":
+		return FlowAdjust
 	}
 	return -1
 }
 
+// FlowTypeName is the display name of an AccountFlow biz type.
+func FlowTypeName(bizType int) string {
+	switch bizType {
+	case FlowRecharge:
+		return "deposit"
+	case FlowFreeze:
+		return "freeze"
+	case FlowUnfreeze:
+		return "unfreeze"
+	case FlowSpend:
+		return "spend"
+	case FlowWithdraw:
+		return "withdraw"
+	case FlowRefund:
+		return "refund"
+	case FlowAdjust:
+		return "adjust"
+	}
+	return fmt.Sprintf("%d", bizType)
+}
+
+// FlowStatusName is the display name of an AccountFlow status.
+func FlowStatusName(status int) string {
+	switch status {
+	case FlowStatusPending:
+		return "pending"
+	case FlowStatusDone:
+		return "confirmed"
+	case FlowStatusReversed:
+		return "refunded"
+	}
+	return "failed"
+}
+
+// BalanceFlowOpName is the display name of an AccountBalanceFlow op.
+func BalanceFlowOpName(op int) string {
+	switch op {
+	case BalanceFlowOpRecharge:
+		return "deposit"
+	case BalanceFlowOpFreeze:
+		return "withdraw_lock"
+	case BalanceFlowOpWithdraw:
+		return "withdraw"
+	case BalanceFlowOpUnfreeze:
+		return "withdraw_cancel"
+	case BalanceFlowOpRevoke:
+		return "withdraw_revoke"
+	}
+	return fmt.Sprintf("%d", op)
+}
+
+// BalanceFlowStatusName is the display name of an AccountBalanceFlow status.
+func BalanceFlowStatusName(status int) string {
+	switch status {
+	case BalanceFlowStatusPending:
+		return "pending"
+	case BalanceFlowStatusPendingWithDraw:
+		return "pending_withdraw"
+	case BalanceFlowStatusSuccess:
+		return "confirmed"
+	case BalanceFlowStatusCanceled:
+		return "canceled"
+	case BalanceFlowStatusLockExpired:
+		return "lock_expired"
+	}
+	return "failed"
+}
+
 type AccountFlow struct {
 	ID             uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
 	MainID         uint64    `gorm:"column:main_id;type:int(11);not null" json:"main_id"`
@@ -108,6 +182,7 @@ type AccountBalanceFlow struct {
 	BlockHeight    int        `gorm:"column:block_height;type:int(11);not null" json:"block_height"`
 	LogIndex       int        `gorm:"column:log_index;type:int(11);not null" json:"log_index"`
 	BlockTimestamp time.Time  `gorm:"column:block_timestamp;type:datetime;not null" json:"block_timestamp"`
+	BlockHash      string     `gorm:"column:block_hash;type:varchar(80);not null" json:"block_hash"`
 	AddTime        time.Time  `gorm:"column:add_time;type:datetime;not null" json:"add_time"`
 	UpdateTime     time.Time  `gorm:"column:update_time;type:datetime;not null" json:"update_time"`
 	RefFlowID      uint64     `gorm:"column:ref_flow_id;type:int(11);not null" json:"ref_flow_id"`
@@ -29,6 +29,12 @@ const (
 	GameStatusInactive           = "20"
 )
 
+// bounds of GameInfo.SessionTimeout in seconds
+const (
+	MinSessionTimeout = 60
+	MaxSessionTimeout = 7 * 24 * 3600
+)
+
 type GameDeveloper struct {
 	ID          uint64    `gorm:"column:id;primary_key;auto_increment"`
 	DevName     string    `gorm:"column:dev_name" json:"dev_name"`
@@ -55,6 +61,9 @@ type GameInfo struct {
 	AddTime     time.Time `gorm:"column:add_time" json:"add_time"`
 	PlayUrl     string    `gorm:"column:play_url" json:"play_url"`
 	Status      string    `gorm:"column:status" json:"status"`
+	// SessionTimeout is how many seconds a started session may stay open before its freeze
+	// is released; 0 uses session.timeout from the config.
+	SessionTimeout int `gorm:"column:session_timeout" json:"session_timeout"`
 }
 
 func (GameInfo) TableName() string {
@@ -80,6 +89,7 @@ type GameSetting struct {
 	Code          string    `gorm:"column:code" json:"code"`
 	Catalog       string    `gorm:"column:catalog" json:"catalog"`
 	AmountPerPlay uint64    `gorm:"column:amount_per_play" json:"amount_per_play"`
+	AssetID       uint64    `gorm:"column:asset_id" json:"asset_id"`
 	AddTime       time.Time `gorm:"column:add_time" json:"add_time"`
 	UpdateTime    time.Time `gorm:"column:update_time" json:"update_time"`
 }
@@ -99,6 +109,7 @@ type GameSession struct {
 	UserReportScore decimal.Decimal `gorm:"column:user_report_score" json:"user_report_score"`
 	SpendAmountN    uint64          `gorm:"column:spend_amount_n" json:"spend_amount_n"`
 	Testing         int             `gorm:"column:testing" json:"testing"`
+	Catalog         string          `gorm:"column:catalog" json:"catalog"` // catalog of the play setting the session started with
 }
 
 func (GameSession) TableName() string {
@@ -0,0 +1,27 @@
+package model
+
+import "time"
+
+const (
+	IdempotencyStatusProcessing = 0
+	IdempotencyStatusDone       = 1
+)
+
+// IdempotencyKey remembers the outcome of a money-moving request sent with an Idempotency-Key
+// header, so a retry gets the original response instead of moving money twice.
+type IdempotencyKey struct {
+	ID           uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	Scope        string    `gorm:"column:scope;type:varchar(128);not null" json:"scope"` // caller the key belongs to
+	IdemKey      string    `gorm:"column:idem_key;type:varchar(128);not null" json:"idem_key"`
+	Route        string    `gorm:"column:route;type:varchar(255);not null" json:"route"`
+	RequestHash  string    `gorm:"column:request_hash;type:char(64);not null" json:"request_hash"`
+	Status       int       `gorm:"column:status;type:int(11);not null" json:"status"`
+	ResponseCode int       `gorm:"column:response_code;type:int(11);not null" json:"response_code"`
+	ResponseBody string    `gorm:"column:response_body;type:mediumtext;not null" json:"-"`
+	AddTime      time.Time `gorm:"column:add_time;type:datetime;not null" json:"add_time"`
+	UpdateTime   time.Time `gorm:"column:update_time;type:datetime;not null" json:"update_time"`
+}
+
+func (IdempotencyKey) TableName() string {
+	return TB_IDEMPOTENCY_KEY
+}
@@ -0,0 +1,40 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	JobStatusPending = "00" // waiting for run_at
+	JobStatusRunning = "10" // claimed by a worker until locked_until
+	JobStatusDone    = "20"
+	JobStatusDead    = "30" // out of attempts, waits for an operator
+)
+
+// Job is one unit of background work of a durable queue. A worker claims it for a visibility
+// timeout; when the worker dies the claim runs out and another worker picks it up again.
+type Job struct {
+	ID          uint64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	Queue       string     `gorm:"column:queue;type:varchar(64);not null" json:"queue"`
+	DedupKey    string     `gorm:"column:dedup_key;type:varchar(191);not null" json:"dedup_key"`
+	Payload     string     `gorm:"column:payload;type:text;not null" json:"payload"`
+	Status      string     `gorm:"column:status;type:varchar(8);not null" json:"status"`
+	Attempts    int        `gorm:"column:attempts;type:int(11);not null" json:"attempts"`
+	MaxAttempts int        `gorm:"column:max_attempts;type:int(11);not null" json:"max_attempts"`
+	RunAt       time.Time  `gorm:"column:run_at;type:datetime;not null" json:"run_at"`
+	LockedBy    string     `gorm:"column:locked_by;type:varchar(128);not null" json:"locked_by"`
+	LockedUntil *time.Time `gorm:"column:locked_until;type:datetime" json:"locked_until"`
+	LastError   string     `gorm:"column:last_error;type:text;not null" json:"last_error"`
+	AddTime     time.Time  `gorm:"column:add_time;type:datetime;not null" json:"add_time"`
+	UpdateTime  time.Time  `gorm:"column:update_time;type:datetime;not null" json:"update_time"`
+}
+
+func (Job) TableName() string {
+	return TB_JOB
+}
+
+// Decode reads the payload the job was enqueued with.
+func (j Job) Decode(v interface{}) error {
+	return json.Unmarshal([]byte(j.Payload), v)
+}
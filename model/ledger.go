@@ -0,0 +1,119 @@
+package model
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// ledger accounts. user_* accounts mirror the buckets of n_account_balance and are
+// liabilities (credit increases them); platform_* accounts hold the other side of every move.
+const (
+	LedgerAccountAvailable  = "user_available"
+	LedgerAccountFrozen     = "user_frozen"
+	LedgerAccountWithdrawal = "user_withdrawal"
+
+	LedgerAccountCustody = "platform_custody" // on-chain funds held by the topup contract
+	LedgerAccountRevenue = "platform_revenue" // spent entry fees
+	LedgerAccountOpening = "platform_opening" // balances that existed before the journal
+	LedgerAccountAdjust  = "platform_adjust"  // approved operator adjustments
+
+	LedgerAccountDeveloper = "platform_developer"  // revenue share owed to game developers
+	LedgerAccountPrizePool = "platform_prize_pool" // season prize pool contributions
+)
+
+// ledger business types, one per kind of balance movement
+const (
+	LedgerBizRecharge        = "recharge"
+	LedgerBizRechargeReorg   = "recharge_reorg" // a recharge whose tx a reorg dropped or moved
+	LedgerBizFreeze          = "freeze"
+	LedgerBizUnfreeze        = "unfreeze"
+	LedgerBizSpend           = "spend" // the platform fee part of a spend
+	LedgerBizSpendDeveloper  = "spend_developer"
+	LedgerBizSpendPrizePool  = "spend_prize_pool"
+	LedgerBizWithdrawLock    = "withdraw_lock"
+	LedgerBizWithdrawCancel  = "withdraw_cancel"
+	LedgerBizWithdrawClaim   = "withdraw_claim"
+	LedgerBizWithdrawRevoke  = "withdraw_revoke" // a lock revoked by the contract owner
+	LedgerBizWithdrawExpire  = "withdraw_expire" // a lock that expired without being opened
+	LedgerBizRefund          = "refund"          // a spend given back to the user
+	LedgerBizRefundFreeze    = "refund_freeze"   // a stuck freeze released by a refund
+	LedgerBizRefundDeveloper = "refund_developer"
+	LedgerBizRefundPrizePool = "refund_prize_pool"
+	LedgerBizAdjustCredit    = "adjust_credit"
+	LedgerBizAdjustDebit     = "adjust_debit"
+	LedgerBizOpening         = "opening"
+)
+
+// tables a ledger entry can point back to
+const (
+	LedgerRefAccountFlow        = "n_account_flow"
+	LedgerRefAccountBalanceFlow = "n_account_balance_flow"
+	LedgerRefAccountBalance     = "n_account_balance"
+)
+
+func IsUserLedgerAccount(account string) bool {
+	switch account {
+	case LedgerAccountAvailable, LedgerAccountFrozen, LedgerAccountWithdrawal:
+		return true
+	}
+	return false
+}
+
+// LedgerEntry is one journal transaction; its postings always sum to zero (debits == credits).
+type LedgerEntry struct {
+	ID       uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	BizType  string    `gorm:"column:biz_type;type:varchar(64);not null" json:"biz_type"`
+	MainID   uint64    `gorm:"column:main_id;type:int(11);not null" json:"main_id"`
+	AssetID  uint64    `gorm:"column:asset_id;type:int(11);not null" json:"asset_id"`
+	Amount   uint64    `gorm:"column:amount;type:bigint unsigned;not null" json:"amount"`
+	RefTable string    `gorm:"column:ref_table;type:varchar(64);not null" json:"ref_table"`
+	RefID    uint64    `gorm:"column:ref_id;type:int(11);not null" json:"ref_id"`
+	Remark   string    `gorm:"column:remark;type:varchar(255);not null" json:"remark"`
+	AddTime  time.Time `gorm:"column:add_time;type:datetime;not null" json:"add_time"`
+}
+
+func (LedgerEntry) TableName() string {
+	return TB_LEDGER_ENTRY
+}
+
+// LedgerPosting is a single debit or credit leg of a LedgerEntry.
+// Platform accounts are posted with main_id = 0.
+type LedgerPosting struct {
+	ID      uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	EntryID uint64    `gorm:"column:entry_id;type:int(11);not null" json:"entry_id"`
+	MainID  uint64    `gorm:"column:main_id;type:int(11);not null" json:"main_id"`
+	AssetID uint64    `gorm:"column:asset_id;type:int(11);not null" json:"asset_id"`
+	Account string    `gorm:"column:account;type:varchar(64);not null" json:"account"`
+	Debit   uint64    `gorm:"column:debit;type:bigint unsigned;not null" json:"debit"`
+	Credit  uint64    `gorm:"column:credit;type:bigint unsigned;not null" json:"credit"`
+	AddTime time.Time `gorm:"column:add_time;type:datetime;not null" json:"add_time"`
+}
+
+func (LedgerPosting) TableName() string {
+	return TB_LEDGER_POSTING
+}
+
+// LedgerCheck records one run of the balance invariant checker.
+type LedgerCheck struct {
+	ID                uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	CheckedAccounts   int       `gorm:"column:checked_accounts;type:int(11);not null" json:"checked_accounts"`
+	DriftCount        int       `gorm:"column:drift_count;type:int(11);not null" json:"drift_count"`
+	UnbalancedEntries int       `gorm:"column:unbalanced_entries;type:int(11);not null" json:"unbalanced_entries"`
+	Detail            string    `gorm:"column:detail;type:longtext;not null" json:"detail"`
+	AddTime           time.Time `gorm:"column:add_time;type:datetime;not null" json:"add_time"`
+}
+
+func (LedgerCheck) TableName() string {
+	return TB_LEDGER_CHECK
+}
+
+// LedgerDrift is a user balance bucket whose stored value differs from the journal.
+type LedgerDrift struct {
+	MainID   uint64          `json:"main_id"`
+	AssetID  uint64          `json:"asset_id"`
+	Account  string          `json:"account"`
+	Stored   decimal.Decimal `json:"stored"`
+	Journal  decimal.Decimal `json:"journal"`
+	Variance decimal.Decimal `json:"variance"`
+}
@@ -0,0 +1,34 @@
+package model
+
+import "time"
+
+const (
+	SignerAuditStatusReserved = "00" // counted against the limits while the signer works
+	SignerAuditStatusSigned   = "10"
+	SignerAuditStatusRefused  = "20" // over a spending limit, never sent to the signer
+	SignerAuditStatusFailed   = "30"
+)
+
+// SignerAudit records every LockAuth digest handed to a withdrawal signer, signed or not.
+// Reserved and signed rows count against the signer's spending limits.
+type SignerAudit struct {
+	ID         uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	Signer     string    `gorm:"column:signer;type:varchar(64);not null" json:"signer"`
+	Address    string    `gorm:"column:address;type:varchar(64);not null" json:"address"`
+	ChainID    uint64    `gorm:"column:chain_id;type:int(11);not null" json:"chain_id"`
+	Contract   string    `gorm:"column:contract;type:varchar(64);not null" json:"contract"`
+	Digest     string    `gorm:"column:digest;type:varchar(80);not null" json:"digest"`
+	MainID     uint64    `gorm:"column:main_id;type:int(11);not null" json:"main_id"`
+	UserAddr   string    `gorm:"column:user_addr;type:varchar(64);not null" json:"user_addr"`
+	LockID     string    `gorm:"column:lock_id;type:varchar(80);not null" json:"lock_id"`
+	Amount     string    `gorm:"column:amount;type:decimal(65,0);not null" json:"amount"` // base units of the token
+	Status     string    `gorm:"column:status;type:varchar(8);not null" json:"status"`
+	Reason     string    `gorm:"column:reason;type:varchar(255);not null" json:"reason"`
+	Signature  string    `gorm:"column:signature;type:varchar(140);not null" json:"signature"`
+	AddTime    time.Time `gorm:"column:add_time;type:datetime;not null" json:"add_time"`
+	UpdateTime time.Time `gorm:"column:update_time;type:datetime;not null" json:"update_time"`
+}
+
+func (SignerAudit) TableName() string {
+	return TB_SIGNER_AUDIT
+}
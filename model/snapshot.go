@@ -0,0 +1,41 @@
+package model
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// BalanceSnapshot is a user balance as of the end of SnapDate (local midnight of the next day).
+type BalanceSnapshot struct {
+	ID         uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	SnapDate   time.Time `gorm:"column:snap_date;type:date;not null" json:"snap_date"`
+	MainID     uint64    `gorm:"column:main_id;type:int(11);not null" json:"main_id"`
+	AssetID    uint64    `gorm:"column:asset_id;type:int(11);not null" json:"asset_id"`
+	Available  uint64    `gorm:"column:available;type:bigint unsigned;not null" json:"available"`
+	Frozen     uint64    `gorm:"column:frozen;type:bigint unsigned;not null" json:"frozen"`
+	Withdrawal uint64    `gorm:"column:withdrawal;type:bigint unsigned;not null" json:"withdrawal"`
+	AddTime    time.Time `gorm:"column:add_time;type:datetime;not null" json:"add_time"`
+}
+
+func (BalanceSnapshot) TableName() string {
+	return TB_BALANCE_SNAPSHOT
+}
+
+// LiabilitySnapshot is what the platform owed all users in one asset at the end of SnapDate.
+// A day's rows are written together with its BalanceSnapshot rows.
+type LiabilitySnapshot struct {
+	ID         uint64          `gorm:"primaryKey;autoIncrement" json:"id"`
+	SnapDate   time.Time       `gorm:"column:snap_date;type:date;not null" json:"snap_date"`
+	AssetID    uint64          `gorm:"column:asset_id;type:int(11);not null" json:"asset_id"`
+	Accounts   int             `gorm:"column:accounts;type:int(11);not null" json:"accounts"`
+	Available  decimal.Decimal `gorm:"column:available;type:decimal(65,0);not null" json:"available"`
+	Frozen     decimal.Decimal `gorm:"column:frozen;type:decimal(65,0);not null" json:"frozen"`
+	Withdrawal decimal.Decimal `gorm:"column:withdrawal;type:decimal(65,0);not null" json:"withdrawal"`
+	Total      decimal.Decimal `gorm:"column:total;type:decimal(65,0);not null" json:"total"`
+	AddTime    time.Time       `gorm:"column:add_time;type:datetime;not null" json:"add_time"`
+}
+
+func (LiabilitySnapshot) TableName() string {
+	return TB_LIABILITY_SNAPSHOT
+}
@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// SolanaTrade is a change of the balance a linked Solana wallet holds in a watched mint, as seen
+// by the Solana watcher. A tx touching several watched wallets or mints gives a row for each.
+type SolanaTrade struct {
+	ID         uint64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	Slot       uint64     `gorm:"column:slot;type:bigint unsigned;not null" json:"slot"`
+	BlockTime  *time.Time `gorm:"column:block_time;type:datetime" json:"block_time"`
+	Signature  string     `gorm:"column:signature;type:varchar(100);not null" json:"signature"`
+	Owner      string     `gorm:"column:owner;type:varchar(64);not null" json:"owner"`
+	Mint       string     `gorm:"column:mint;type:varchar(64);not null" json:"mint"`
+	Side       string     `gorm:"column:side;type:varchar(8);not null" json:"side"`         // BUY or SELL
+	Amount     string     `gorm:"column:amount;type:decimal(65,18);not null" json:"amount"` // token units
+	FeeLamport uint64     `gorm:"column:fee_lamport;type:bigint unsigned;not null" json:"fee_lamport"`
+	Success    bool       `gorm:"column:success;not null" json:"success"`
+	AddTime    time.Time  `gorm:"column:add_time;type:datetime;not null" json:"add_time"`
+}
+
+func (SolanaTrade) TableName() string {
+	return TB_SOLANA_TRADE
+}
@@ -1,17 +1,61 @@
 package model
 
-import "time"
+import (
+	"time"
+)
+
+// asset types
+const (
+	AssetTypeERC20  = "erc20"
+	AssetTypeNative = "native" // the chain's own coin, moved by plain transfers
+)
 
 type SysAsset struct {
-	ID      uint64    `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
-	Chain   string    `gorm:"column:chain" json:"chain"`
-	Ca      string    `gorm:"column:ca" json:"ca"`
-	Name    string    `gorm:"column:name" json:"name"`
-	Symbol  string    `gorm:"column:symbol" json:"symbol"`
-	Type    string    `gorm:"column:type" json:"type"`
-	AddTime time.Time `gorm:"column:add_time" json:"add_time"`
+	ID     uint64 `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	Chain  string `gorm:"column:chain" json:"chain"`
+	Ca     string `gorm:"column:ca" json:"ca"`
+	Name   string `gorm:"column:name" json:"name"`
+	Symbol string `gorm:"column:symbol" json:"symbol"`
+	Type   string `gorm:"column:type" json:"type"`
+	// Decimals is the ledger scale: balances of the asset are stored in units of 10^-Decimals
+	Decimals int32 `gorm:"column:decimals" json:"decimals"`
+	// ChainDecimals is the scale of the token contract, 0 when it equals Decimals
+	ChainDecimals int32 `gorm:"column:chain_decimals" json:"chain_decimals"`
+	// DisplayDecimals is how many decimals amounts are shown with, 0 for all of Decimals
+	DisplayDecimals int32     `gorm:"column:display_decimals" json:"display_decimals"`
+	AddTime         time.Time `gorm:"column:add_time" json:"add_time"`
+	// MetaTime is when name, symbol and decimals were last read from the token contract, nil if never
+	MetaTime *time.Time `gorm:"column:meta_time" json:"meta_time"`
 }
 
 func (SysAsset) TableName() string {
 	return TB_SYS_ASSET
 }
+
+// SysOperator is a platform operator allowed to use the /admin api group.
+type SysOperator struct {
+	ID       uint64    `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	Name     string    `gorm:"column:name" json:"name"`
+	Email    string    `gorm:"column:email" json:"email"`
+	Password string    `gorm:"column:password" json:"-"`
+	Role     string    `gorm:"column:role" json:"role"`
+	Status   string    `gorm:"column:status" json:"status"`
+	AddTime  time.Time `gorm:"column:add_time" json:"add_time"`
+}
+
+func (SysOperator) TableName() string {
+	return TB_SYS_OPERATOR
+}
+
+const (
+	OperatorStatusActive   = "00"
+	OperatorStatusDisabled = "20"
+)
+
+// Operator roles. Operators read; finance also moves funds; admin also runs the contract,
+// airdrop, asset and job actions.
+const (
+	OperatorRoleOperator = "operator"
+	OperatorRoleFinance  = "finance"
+	OperatorRoleAdmin    = "admin"
+)
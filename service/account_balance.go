@@ -13,6 +13,40 @@ import (
 	"gorm.io/gorm/clause"
 )
 
+// ErrFlowSettled is returned for a tx whose flow was already settled by an earlier check.
+var ErrFlowSettled = errors.New("account balance flow not pending, already processed")
+
+// SettleTopupTx applies the confirmed outcome of a queued tx check. A flow settled meanwhile,
+// by the deposit indexer or an earlier check, is not an error.
+func SettleTopupTx(top *chain.TopupTxInfo) error {
+	if top.Op == model.BalanceFlowOpRecharge && top.Status == "success" {
+		// only the wallet that deposited may claim a recharge: the Deposited user of a contract
+		// deposit, the payer of a plain transfer, which names no depositor of ours
+		depositor := top.From
+		if !top.Native && !chain.IsSolanaChain(top.ChainID) {
+			depositor = top.UserFromLog
+			if !chain.IsTopupContract(top.ChainID, top.Contract) {
+				log.Warnf("tx %s has no Deposited log of the topup contract of chain %d", top.TxHash, top.ChainID)
+				top.Status = "failed"
+			}
+		}
+		owner, err := walletOwner(depositor)
+		if err != nil {
+			return err
+		}
+		if top.Status == "success" && (depositor == "" || owner != top.MainID) {
+			log.Warnf("tx %s was deposited by %s, not a wallet of user %d", top.TxHash, depositor, top.MainID)
+			top.Status = "failed"
+		}
+	}
+	err := UpdateAccountBalance(top)
+	if errors.Is(err, ErrFlowSettled) {
+		log.Infof("tx %s already settled", top.TxHash)
+		return nil
+	}
+	return err
+}
+
 func UpdateAccountBalance(top *chain.TopupTxInfo) error {
 	var mainId = top.MainID
 	var txHash = top.TxHash
@@ -42,7 +76,7 @@ func UpdateAccountBalance(top *chain.TopupTxInfo) error {
 
 	var withdrawBalanceFlow model.AccountBalanceFlow
 	var refLockBalanceFlow model.AccountBalanceFlow
-	if op == model.BalanceFlowOpWithdraw || op == model.BalanceFlowOpUnfreeze {
+	if op == model.BalanceFlowOpWithdraw || op == model.BalanceFlowOpUnfreeze || op == model.BalanceFlowOpRevoke {
 		tx.Where("id = ?", refFlowID).First(&withdrawBalanceFlow)
 		if withdrawBalanceFlow.ID == 0 {
 			return errors.New("withdraw flow id not found")
@@ -53,10 +87,13 @@ func UpdateAccountBalance(top *chain.TopupTxInfo) error {
 		}
 	}
 
+	target := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("main_id = ? and tx_hash = ?", mainId, txHash)
+	if op == model.BalanceFlowOpRecharge && refFlowID != 0 {
+		// a recharge names its own flow: a tx may carry more than one deposit
+		target = target.Where("id = ?", refFlowID)
+	}
 	var targetBalanceFlow model.AccountBalanceFlow
-	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
-		Where("main_id = ? and tx_hash = ?", mainId, txHash).
-		First(&targetBalanceFlow).Error; err != nil {
+	if err := target.First(&targetBalanceFlow).Error; err != nil {
 		// defer tx.Rollback()
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return errors.New("account balance flow not found")
@@ -65,7 +102,7 @@ func UpdateAccountBalance(top *chain.TopupTxInfo) error {
 	}
 	if targetBalanceFlow.Status != model.BalanceFlowStatusPending {
 		// tx.Rollback()
-		return errors.New("account balance flow not pending, already processed")
+		return ErrFlowSettled
 	}
 
 	if status == "success" {
@@ -75,7 +112,7 @@ func UpdateAccountBalance(top *chain.TopupTxInfo) error {
 			targetBalanceFlow.Status = model.BalanceFlowStatusSuccess
 		}
 		if op == model.BalanceFlowOpWithdraw {
-			if refLockBalanceFlow.Status == model.BalanceFlowStatusPendingWithDraw {
+			if refLockBalanceFlow.Status == model.BalanceFlowStatusPendingWithDraw || refLockBalanceFlow.Status == model.BalanceFlowStatusLockExpired {
 				refLockBalanceFlow.Status = model.BalanceFlowStatusSuccess
 				refLockBalanceFlow.UpdateTime = time.Now()
 				if err := tx.Save(&refLockBalanceFlow).Error; err != nil {
@@ -83,8 +120,10 @@ func UpdateAccountBalance(top *chain.TopupTxInfo) error {
 					return err
 				}
 			}
-		} else if op == model.BalanceFlowOpUnfreeze {
-			if refLockBalanceFlow.Status == model.BalanceFlowStatusPendingWithDraw {
+		} else if op == model.BalanceFlowOpUnfreeze || op == model.BalanceFlowOpRevoke {
+			// the lock may still wait for its own report, or have expired, when it is canceled or revoked on chain
+			if refLockBalanceFlow.Status == model.BalanceFlowStatusPendingWithDraw || refLockBalanceFlow.Status == model.BalanceFlowStatusPending ||
+				refLockBalanceFlow.Status == model.BalanceFlowStatusLockExpired {
 				refLockBalanceFlow.Status = model.BalanceFlowStatusCanceled
 				refLockBalanceFlow.UpdateTime = time.Now()
 				if err := tx.Save(&refLockBalanceFlow).Error; err != nil {
@@ -96,13 +135,30 @@ func UpdateAccountBalance(top *chain.TopupTxInfo) error {
 	} else {
 		targetBalanceFlow.Status = model.BalanceFlowStatusFailed
 	}
+	// the chain reports base units of the token; convert before anything reaches the ledger
+	asset, err := GetAsset(targetBalanceFlow.AssetID)
+	if err != nil {
+		return err
+	}
+	realAmount, dust, err := asset.FromChain(amount)
+	if err != nil {
+		return fmt.Errorf("tx %s amount %s: %w", txHash, amount, err)
+	}
+	if dust.Sign() > 0 {
+		log.Infof("tx %s leaves %s base units of %s below one ledger unit in custody", txHash, dust, asset.Symbol)
+	}
+	if status == "success" && (op == model.BalanceFlowOpWithdraw || op == model.BalanceFlowOpUnfreeze || op == model.BalanceFlowOpRevoke) && realAmount.Uint64() != refLockBalanceFlow.RealAmount {
+		return fmt.Errorf("tx %s amount %d does not match lock %d of %d", txHash, realAmount, refLockBalanceFlow.ID, refLockBalanceFlow.RealAmount)
+	}
+
 	targetBalanceFlow.UpdateTime = time.Now()
 	targetBalanceFlow.BlockHeight = int(blockNumber)
 	targetBalanceFlow.BlockTimestamp = blockTime
-	targetBalanceFlow.LogIndex = 0
+	targetBalanceFlow.BlockHash = top.BlockHash
+	targetBalanceFlow.LogIndex = int(top.LogIndex)
 	targetBalanceFlow.FromAddr = userFromLog
 	targetBalanceFlow.ToAddr = top.To
-	targetBalanceFlow.RealAmount = amount.Uint64()
+	targetBalanceFlow.RealAmount = realAmount.Uint64()
 	targetBalanceFlow.ChainID = fmt.Sprintf("%d", top.ChainID)
 
 	if err := tx.Save(&targetBalanceFlow).Error; err != nil {
@@ -120,13 +176,13 @@ func UpdateAccountBalance(top *chain.TopupTxInfo) error {
 
 	var accountBalance model.AccountBalance
 	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
-		Where("main_id = ?", mainId).
+		Where("main_id = ? AND asset_id = ?", mainId, targetBalanceFlow.AssetID).
 		First(&accountBalance).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			// create account balance
 			accountBalance = model.AccountBalance{
 				MainID:     mainId,
-				AssetID:    0,
+				AssetID:    targetBalanceFlow.AssetID,
 				Available:  0,
 				Frozen:     0,
 				UpdateTime: time.Now(),
@@ -142,30 +198,30 @@ func UpdateAccountBalance(top *chain.TopupTxInfo) error {
 		}
 	}
 
+	var bizType string
 	switch op {
 	case model.BalanceFlowOpRecharge:
-		accountBalance.Available = accountBalance.Available + amount.Uint64()
+		bizType = model.LedgerBizRecharge
 	case model.BalanceFlowOpWithdraw:
-		var amountUint64 = amount.Uint64()
-		if accountBalance.Withdrawal < amountUint64 {
-			return errors.New("withdrawal amount is greater than available balance")
-		}
-		accountBalance.Withdrawal = accountBalance.Withdrawal - amountUint64
+		bizType = model.LedgerBizWithdrawClaim
 	case model.BalanceFlowOpUnfreeze:
-		var amountUint64 = amount.Uint64()
-		if accountBalance.Withdrawal < amountUint64 {
-			return errors.New("withdrawal amount is greater than available balance")
-		}
-		accountBalance.Withdrawal = accountBalance.Withdrawal - amountUint64
-		accountBalance.Available = accountBalance.Available + amountUint64
+		bizType = model.LedgerBizWithdrawCancel
+	case model.BalanceFlowOpRevoke:
+		bizType = model.LedgerBizWithdrawRevoke
 	}
 
-	accountBalance.UpdateTime = time.Now()
-
-	if err := tx.Save(&accountBalance).Error; err != nil {
-		// defer tx.Rollback()
-		log.Error("update account balance failed", err)
-		return err
+	if len(bizType) > 0 {
+		err := PostBalanceChange(tx, &accountBalance, bizType, realAmount.Uint64(), LedgerRef{
+			Table: model.LedgerRefAccountBalanceFlow,
+			ID:    targetBalanceFlow.ID,
+		})
+		if errors.Is(err, ErrInsufficientBalance) {
+			return errors.New("withdrawal amount is greater than available balance")
+		}
+		if err != nil {
+			log.Error("update account balance failed", err)
+			return err
+		}
 	}
 
 	if err := tx.Commit().Error; err != nil {
@@ -0,0 +1,285 @@
+package service
+
+import (
+	"chaos/api/config"
+	"chaos/api/model"
+	"chaos/api/system"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrAdjustmentNotFound   = errors.New("adjustment not found")
+	ErrAdjustmentNotPending = errors.New("adjustment is not pending")
+	ErrAdjustmentInvalid    = errors.New("invalid adjustment")
+	ErrSelfApproval         = errors.New("the maker of an adjustment can not review it")
+	ErrAlreadyReviewed      = errors.New("operator already reviewed this adjustment")
+)
+
+// audit actions of the adjustment workflow
+const (
+	AuditAdjustmentCreate  = "adjustment.create"
+	AuditAdjustmentApprove = "adjustment.approve"
+	AuditAdjustmentReject  = "adjustment.reject"
+	AuditAdjustmentCancel  = "adjustment.cancel"
+	AuditAdjustmentPost    = "adjustment.post"
+)
+
+type AdjustmentRequest struct {
+	MainID      uint64
+	AssetID     uint64
+	Direction   string
+	Amount      decimal.Decimal // in whole tokens
+	Reason      string
+	EvidenceURL string
+}
+
+// requiredApprovals is 1 up to the threshold of the asset, adjustment.dualApprovalAbove, and 2
+// above it or for an asset that has no valid threshold.
+func requiredApprovals(thresholds map[uint64]string, assetID uint64, amount decimal.Decimal) int {
+	raw, ok := thresholds[assetID]
+	if !ok {
+		return 2
+	}
+	threshold, err := decimal.NewFromString(raw)
+	if err != nil || amount.GreaterThan(threshold) {
+		return 2
+	}
+	return 1
+}
+
+// CreateAdjustment records a pending adjustment made by makerID. Nothing is posted until it is approved.
+func CreateAdjustment(makerID uint64, req AdjustmentRequest) (*model.BalanceAdjustment, error) {
+	if req.Direction != model.AdjustmentCredit && req.Direction != model.AdjustmentDebit {
+		return nil, fmt.Errorf("%w: direction must be credit or debit", ErrAdjustmentInvalid)
+	}
+	if strings.TrimSpace(req.Reason) == "" {
+		return nil, fmt.Errorf("%w: reason required", ErrAdjustmentInvalid)
+	}
+	asset, err := GetAsset(req.AssetID)
+	if err != nil {
+		return nil, err
+	}
+	units, err := asset.ParseAmount(req.Amount)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrAdjustmentInvalid, err)
+	}
+	if units == 0 {
+		return nil, fmt.Errorf("%w: amount must be positive", ErrAdjustmentInvalid)
+	}
+
+	db := system.GetDb()
+	var user model.UserMain
+	if err := db.Model(&model.UserMain{}).Where("id = ?", req.MainID).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: user %d not found", ErrAdjustmentInvalid, req.MainID)
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	adj := model.BalanceAdjustment{
+		MainID:            req.MainID,
+		AssetID:           req.AssetID,
+		Direction:         req.Direction,
+		Amount:            units.Uint64(),
+		Reason:            req.Reason,
+		EvidenceURL:       req.EvidenceURL,
+		Status:            model.AdjustmentStatusPending,
+		RequiredApprovals: requiredApprovals(config.GetConfig().Adjustment.DualApprovalAbove, asset.ID, req.Amount),
+		MakerID:           makerID,
+		AddTime:           now,
+		UpdateTime:        now,
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&adj).Error; err != nil {
+			return err
+		}
+		return RecordAudit(tx, makerID, AuditAdjustmentCreate, model.TB_BALANCE_ADJUSTMENT, adj.ID, adj)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &adj, nil
+}
+
+// ReviewAdjustment records the decision of a checker. A rejection closes the adjustment; the
+// approval that reaches RequiredApprovals posts it to the ledger in the same transaction.
+func ReviewAdjustment(operatorID, adjustmentID uint64, approve bool, comment string) (*model.BalanceAdjustment, error) {
+	var adj model.BalanceAdjustment
+	err := system.GetDb().Transaction(func(tx *gorm.DB) error {
+		if err := lockPendingAdjustment(tx, adjustmentID, &adj); err != nil {
+			return err
+		}
+		if adj.MakerID == operatorID {
+			return ErrSelfApproval
+		}
+		var reviewed int64
+		if err := tx.Model(&model.AdjustmentReview{}).
+			Where("adjustment_id = ? AND operator_id = ?", adj.ID, operatorID).
+			Count(&reviewed).Error; err != nil {
+			return err
+		}
+		if reviewed > 0 {
+			return ErrAlreadyReviewed
+		}
+
+		now := time.Now()
+		review := model.AdjustmentReview{
+			AdjustmentID: adj.ID,
+			OperatorID:   operatorID,
+			Decision:     model.AdjustmentDecisionApprove,
+			Comment:      comment,
+			AddTime:      now,
+		}
+		action := AuditAdjustmentApprove
+		if approve {
+			adj.Approvals++
+		} else {
+			review.Decision = model.AdjustmentDecisionReject
+			action = AuditAdjustmentReject
+			adj.Status = model.AdjustmentStatusRejected
+		}
+		if err := tx.Create(&review).Error; err != nil {
+			return err
+		}
+		if err := RecordAudit(tx, operatorID, action, model.TB_BALANCE_ADJUSTMENT, adj.ID, review); err != nil {
+			return err
+		}
+
+		if adj.Status == model.AdjustmentStatusPending && adj.Approvals >= adj.RequiredApprovals {
+			if err := postAdjustment(tx, &adj); err != nil {
+				return err
+			}
+			if err := RecordAudit(tx, operatorID, AuditAdjustmentPost, model.TB_BALANCE_ADJUSTMENT, adj.ID, adj); err != nil {
+				return err
+			}
+		}
+		adj.UpdateTime = now
+		return tx.Save(&adj).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &adj, nil
+}
+
+// CancelAdjustment withdraws a pending adjustment; only its maker may do so.
+func CancelAdjustment(operatorID, adjustmentID uint64) (*model.BalanceAdjustment, error) {
+	var adj model.BalanceAdjustment
+	err := system.GetDb().Transaction(func(tx *gorm.DB) error {
+		if err := lockPendingAdjustment(tx, adjustmentID, &adj); err != nil {
+			return err
+		}
+		if adj.MakerID != operatorID {
+			return fmt.Errorf("%w: only the maker can cancel", ErrAdjustmentInvalid)
+		}
+		adj.Status = model.AdjustmentStatusCanceled
+		adj.UpdateTime = time.Now()
+		if err := tx.Save(&adj).Error; err != nil {
+			return err
+		}
+		return RecordAudit(tx, operatorID, AuditAdjustmentCancel, model.TB_BALANCE_ADJUSTMENT, adj.ID, nil)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &adj, nil
+}
+
+func lockPendingAdjustment(tx *gorm.DB, id uint64, adj *model.BalanceAdjustment) error {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(adj).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrAdjustmentNotFound
+		}
+		return err
+	}
+	if adj.Status != model.AdjustmentStatusPending {
+		return ErrAdjustmentNotPending
+	}
+	return nil
+}
+
+// postAdjustment writes the FlowAdjust row and moves the balance. A debit larger than the
+// available balance fails with ErrInsufficientBalance and leaves the adjustment pending.
+func postAdjustment(tx *gorm.DB, adj *model.BalanceAdjustment) error {
+	var bal model.AccountBalance
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("main_id = ? AND asset_id = ?", adj.MainID, adj.AssetID).
+		First(&bal).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		bal = model.AccountBalance{MainID: adj.MainID, AssetID: adj.AssetID, UpdateTime: time.Now()}
+		err = tx.Create(&bal).Error
+	}
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	flow := model.AccountFlow{
+		MainID:         adj.MainID,
+		AssetID:        adj.AssetID,
+		BizType:        model.FlowAdjust,
+		Amount:         adj.Amount,
+		Direction:      model.DirectionIn,
+		ExternalID:     fmt.Sprintf("adjustment-%d", adj.ID),
+		ExternalRemark: adj.Reason,
+		Status:         model.FlowStatusDone,
+		AddTime:        now,
+		UpdateTime:     now,
+	}
+	bizType := model.LedgerBizAdjustCredit
+	if adj.Direction == model.AdjustmentDebit {
+		flow.Direction = model.DirectionOut
+		bizType = model.LedgerBizAdjustDebit
+	}
+	if err := tx.Create(&flow).Error; err != nil {
+		return err
+	}
+	if err := PostBalanceChange(tx, &bal, bizType, adj.Amount, LedgerRef{
+		Table:  model.LedgerRefAccountFlow,
+		ID:     flow.ID,
+		Remark: fmt.Sprintf("adjustment %d", adj.ID),
+	}); err != nil {
+		return err
+	}
+
+	adj.Status = model.AdjustmentStatusPosted
+	adj.FlowID = flow.ID
+	return nil
+}
+
+// ListAdjustments pages through adjustments, newest first, optionally filtered by status.
+func ListAdjustments(status string, page, limit int) ([]model.BalanceAdjustment, int64, error) {
+	query := system.GetDb().Model(&model.BalanceAdjustment{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var rows []model.BalanceAdjustment
+	err := query.Order("id desc").Offset((page - 1) * limit).Limit(limit).Find(&rows).Error
+	return rows, total, err
+}
+
+// ListAudit pages through the admin audit trail, optionally for one target row.
+func ListAudit(targetTable string, targetID uint64, page, limit int) ([]model.AdminAudit, error) {
+	query := system.GetDb().Model(&model.AdminAudit{})
+	if targetTable != "" {
+		query = query.Where("target_table = ?", targetTable)
+	}
+	if targetID != 0 {
+		query = query.Where("target_id = ?", targetID)
+	}
+	var rows []model.AdminAudit
+	err := query.Order("id desc").Offset((page - 1) * limit).Limit(limit).Find(&rows).Error
+	return rows, err
+}
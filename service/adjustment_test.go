@@ -0,0 +1,28 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestRequiredApprovals(t *testing.T) {
+	thresholds := map[uint64]string{1: "1000", 2: "0.5", 3: "lots"}
+
+	for _, tc := range []struct {
+		assetID uint64
+		amount  string
+		want    int
+	}{
+		{1, "1000", 1},
+		{1, "1000.000001", 2},
+		{2, "0.5", 1},
+		{2, "0.6", 2},
+		{3, "1", 2},        // a threshold that does not parse
+		{4, "0.000001", 2}, // no threshold
+	} {
+		if got := requiredApprovals(thresholds, tc.assetID, decimal.RequireFromString(tc.amount)); got != tc.want {
+			t.Errorf("asset %d amount %s needs %d approvals, want %d", tc.assetID, tc.amount, got, tc.want)
+		}
+	}
+}
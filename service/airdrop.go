@@ -0,0 +1,514 @@
+package service
+
+import (
+	"bytes"
+	"chaos/api/chain"
+	"chaos/api/log"
+	"chaos/api/model"
+	"chaos/api/system"
+	"chaos/api/tools"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCampaignNotFound = errors.New("airdrop campaign not found")
+	ErrCampaignInvalid  = errors.New("invalid airdrop campaign")
+	ErrNotInAirdrop     = errors.New("no wallet of the user is in the airdrop")
+	ErrClaimPending     = errors.New("claim tx not confirmed yet")
+	ErrClaimNotFound    = errors.New("tx made no claim on the distributor")
+)
+
+// audit actions of the airdrop campaigns
+const (
+	AuditAirdropCreate = "airdrop.create"
+	AuditAirdropAttach = "airdrop.attach"
+)
+
+// sources of airdrop campaigns
+const (
+	AirdropSourceCSV      = "csv"
+	AirdropSourceSnapshot = "snapshot"
+)
+
+const airdropLeafBatch = 500
+
+// AirdropEntry is one wallet of a campaign and what it may claim, in base units of the token.
+type AirdropEntry struct {
+	Wallet string
+	Amount *big.Int
+}
+
+type AirdropCampaignRequest struct {
+	Name     string
+	ChainID  uint64
+	Decimals int32 // of the airdropped token
+	Source   string
+	Entries  []AirdropEntry
+}
+
+// airdropBaseUnits turns a whole-token amount into base units of a token with decimals, refusing
+// amounts the token can not represent.
+func airdropBaseUnits(amount decimal.Decimal, decimals int32) (*big.Int, error) {
+	units := amount.Shift(decimals)
+	if !units.IsInteger() {
+		return nil, fmt.Errorf("%s has more than %d decimals", amount, decimals)
+	}
+	if units.Sign() <= 0 {
+		return nil, fmt.Errorf("%s is not positive", amount)
+	}
+	return units.BigInt(), nil
+}
+
+// ParseAirdropCSV reads wallet,amount rows, amounts in whole tokens. A first row whose wallet is
+// not an address is taken for a header. A wallet listed twice is an error, not a sum.
+func ParseAirdropCSV(r io.Reader, decimals int32) ([]AirdropEntry, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	var entries []AirdropEntry
+	seen := map[common.Address]int{}
+	for line := 1; ; line++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrCampaignInvalid, line, err)
+		}
+		if len(rec) < 2 {
+			return nil, fmt.Errorf("%w: line %d: want wallet,amount", ErrCampaignInvalid, line)
+		}
+		wallet := strings.TrimSpace(rec[0])
+		if !common.IsHexAddress(wallet) {
+			if line == 1 {
+				continue
+			}
+			return nil, fmt.Errorf("%w: line %d: %q is not an address", ErrCampaignInvalid, line, wallet)
+		}
+		amount, err := decimal.NewFromString(strings.TrimSpace(rec[1]))
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: bad amount %q", ErrCampaignInvalid, line, rec[1])
+		}
+		units, err := airdropBaseUnits(amount, decimals)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrCampaignInvalid, line, err)
+		}
+		addr := common.HexToAddress(wallet)
+		if first, ok := seen[addr]; ok {
+			return nil, fmt.Errorf("%w: line %d: %s already listed on line %d", ErrCampaignInvalid, line, addr.Hex(), first)
+		}
+		seen[addr] = line
+		entries = append(entries, AirdropEntry{Wallet: addr.Hex(), Amount: units})
+	}
+	return entries, nil
+}
+
+// AirdropSnapshotEntries takes the entries from the n_airdrop_claim snapshot: the snapshot amounts
+// of every Solana wallet linked to the same BSC wallet, summed. Snapshot amounts are base units
+// with snapDecimals; rows without a valid BSC wallet are skipped.
+func AirdropSnapshotEntries(snapDecimals, decimals int32) ([]AirdropEntry, error) {
+	var rows []struct {
+		BscWallet string
+		Amount    decimal.Decimal
+	}
+	err := system.GetDb().Model(&model.NAirdropClaim{}).
+		Select("bsc_wallet, SUM(sol_snap_amount) AS amount").
+		Where("bsc_wallet <> '' AND sol_snap_amount > 0").
+		Group("bsc_wallet").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	byWallet := map[common.Address]*big.Int{}
+	skipped := 0
+	for _, r := range rows {
+		if !common.IsHexAddress(r.BscWallet) {
+			skipped++
+			continue
+		}
+		units, err := airdropBaseUnits(r.Amount.Shift(-snapDecimals), decimals)
+		if err != nil {
+			return nil, fmt.Errorf("%w: wallet %s: %v", ErrCampaignInvalid, r.BscWallet, err)
+		}
+		// the same wallet may be stored in different cases
+		addr := common.HexToAddress(r.BscWallet)
+		if sum, ok := byWallet[addr]; ok {
+			sum.Add(sum, units)
+		} else {
+			byWallet[addr] = units
+		}
+	}
+	if skipped > 0 {
+		log.Warnf("[Airdrop] %d snapshot rows have no valid bsc wallet and are left out", skipped)
+	}
+	entries := make([]AirdropEntry, 0, len(byWallet))
+	for addr, amount := range byWallet {
+		entries = append(entries, AirdropEntry{Wallet: addr.Hex(), Amount: amount})
+	}
+	return entries, nil
+}
+
+func keccakHash() hash.Hash {
+	return crypto.NewKeccakState()
+}
+
+// BuildAirdropTree builds the tree of a campaign over its entries ordered by wallet, so the same
+// entries always give the same root. Leaves are keccak256(abi.encode(wallet, amount)) and pairs are
+// hashed sorted, as the distributor verifies them. The leaves come back without campaign id.
+func BuildAirdropTree(entries []AirdropEntry) (common.Hash, []model.AirdropLeaf, error) {
+	if len(entries) == 0 {
+		return common.Hash{}, nil, fmt.Errorf("%w: no entries", ErrCampaignInvalid)
+	}
+	sorted := append([]AirdropEntry(nil), entries...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(common.HexToAddress(sorted[i].Wallet).Bytes(), common.HexToAddress(sorted[j].Wallet).Bytes()) < 0
+	})
+
+	contents := make([]tools.TreeContent, len(sorted))
+	for i, e := range sorted {
+		if i > 0 && common.HexToAddress(sorted[i-1].Wallet) == common.HexToAddress(e.Wallet) {
+			return common.Hash{}, nil, fmt.Errorf("%w: %s listed twice", ErrCampaignInvalid, e.Wallet)
+		}
+		if e.Amount == nil || e.Amount.Sign() <= 0 {
+			return common.Hash{}, nil, fmt.Errorf("%w: %s gets no amount", ErrCampaignInvalid, e.Wallet)
+		}
+		contents[i] = tools.DefaultCont{Data: tools.EncodePack(common.HexToAddress(e.Wallet).Hex(), e.Amount)}
+	}
+	tree, err := tools.NewTreeWithHashStrategySorted(contents, keccakHash, true)
+	if err != nil {
+		return common.Hash{}, nil, err
+	}
+
+	leaves := make([]model.AirdropLeaf, len(sorted))
+	for i, e := range sorted {
+		proof, err := json.Marshal(tree.LeafPathHex(i))
+		if err != nil {
+			return common.Hash{}, nil, err
+		}
+		leaves[i] = model.AirdropLeaf{
+			Idx:    i,
+			Wallet: common.HexToAddress(e.Wallet).Hex(),
+			Amount: decimal.NewFromBigInt(e.Amount, 0),
+			Leaf:   hexutil.Encode(tree.Leafs[i].Hash),
+			Proof:  string(proof),
+		}
+	}
+	return common.BytesToHash(tree.MerkleRoot()), leaves, nil
+}
+
+// CreateAirdropCampaign builds the tree of a campaign and stores its root and leaves as a draft.
+// It goes live once AttachAirdropContract finds the root in a deployed distributor.
+func CreateAirdropCampaign(operatorID uint64, req AirdropCampaignRequest) (*model.AirdropCampaign, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > 64 || strings.ContainsAny(name, "/?# ") {
+		return nil, fmt.Errorf("%w: name must be 1-64 characters without spaces, / ? or #", ErrCampaignInvalid)
+	}
+	if _, err := tools.LookupChain(req.ChainID); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCampaignInvalid, err)
+	}
+	if req.Decimals < 0 || req.Decimals > 36 {
+		return nil, fmt.Errorf("%w: decimals out of range", ErrCampaignInvalid)
+	}
+	root, leaves, err := BuildAirdropTree(req.Entries)
+	if err != nil {
+		return nil, err
+	}
+	total := decimal.Zero
+	for _, l := range leaves {
+		total = total.Add(l.Amount)
+	}
+
+	now := time.Now()
+	campaign := model.AirdropCampaign{
+		Name:       name,
+		ChainID:    req.ChainID,
+		Decimals:   req.Decimals,
+		MerkleRoot: root.Hex(),
+		Leaves:     len(leaves),
+		Total:      total,
+		Source:     req.Source,
+		Status:     model.AirdropCampaignStatusDraft,
+		OperatorID: operatorID,
+		AddTime:    now,
+		UpdateTime: now,
+	}
+	err = system.GetDb().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&campaign).Error; err != nil {
+			if IsDuplicateKey(err) {
+				return fmt.Errorf("%w: campaign %s exists", ErrCampaignInvalid, name)
+			}
+			return err
+		}
+		for i := range leaves {
+			leaves[i].CampaignID = campaign.ID
+		}
+		if err := tx.CreateInBatches(leaves, airdropLeafBatch).Error; err != nil {
+			return err
+		}
+		return RecordAudit(tx, operatorID, AuditAirdropCreate, model.TB_AIRDROP_CAMPAIGN, campaign.ID, map[string]interface{}{
+			"name": name, "chain_id": req.ChainID, "root": campaign.MerkleRoot, "leaves": campaign.Leaves, "total": total, "source": req.Source,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &campaign, nil
+}
+
+// AttachAirdropContract opens the claims of a campaign on the distributor at contract, after
+// checking that it pays out against the campaign's root. Claims are looked for from startBlock on.
+func AttachAirdropContract(ctx context.Context, operatorID uint64, name, contract string, startBlock uint64) (*model.AirdropCampaign, error) {
+	if !common.IsHexAddress(contract) {
+		return nil, fmt.Errorf("%w: bad contract address", ErrCampaignInvalid)
+	}
+	campaign, err := GetAirdropCampaign(name)
+	if err != nil {
+		return nil, err
+	}
+	root, err := chain.AirdropMerkleRoot(ctx, campaign.ChainID, contract)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(root.Hex(), campaign.MerkleRoot) {
+		return nil, fmt.Errorf("%w: distributor root %s is not the campaign root %s", ErrCampaignInvalid, root.Hex(), campaign.MerkleRoot)
+	}
+
+	err = system.GetDb().Transaction(func(tx *gorm.DB) error {
+		campaign.Contract = common.HexToAddress(contract).Hex()
+		campaign.StartBlock = startBlock
+		campaign.Status = model.AirdropCampaignStatusLive
+		campaign.UpdateTime = time.Now()
+		if err := tx.Save(campaign).Error; err != nil {
+			return err
+		}
+		return RecordAudit(tx, operatorID, AuditAirdropAttach, model.TB_AIRDROP_CAMPAIGN, campaign.ID, map[string]interface{}{
+			"contract": campaign.Contract, "start_block": startBlock,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return campaign, nil
+}
+
+func GetAirdropCampaign(name string) (*model.AirdropCampaign, error) {
+	var campaign model.AirdropCampaign
+	err := system.GetDb().Model(&model.AirdropCampaign{}).Where("name = ?", name).First(&campaign).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrCampaignNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &campaign, nil
+}
+
+func ListAirdropCampaigns() ([]model.AirdropCampaign, error) {
+	var campaigns []model.AirdropCampaign
+	err := system.GetDb().Model(&model.AirdropCampaign{}).Order("id DESC").Find(&campaigns).Error
+	return campaigns, err
+}
+
+// AirdropProofView is what a wallet needs to claim: the arguments of claim(account, amount, proof).
+type AirdropProofView struct {
+	Campaign   string     `json:"campaign"`
+	ChainID    uint64     `json:"chain_id"`
+	Contract   string     `json:"contract"`
+	MerkleRoot string     `json:"merkle_root"`
+	Wallet     string     `json:"wallet"`
+	Amount     string     `json:"amount"`         // base units
+	AmountText string     `json:"amount_display"` // whole tokens
+	Index      int        `json:"index"`
+	Leaf       string     `json:"leaf"`
+	Proof      []string   `json:"proof"`
+	Claimed    bool       `json:"claimed"`
+	ClaimTx    string     `json:"claim_tx,omitempty"`
+	ClaimTime  *time.Time `json:"claim_time,omitempty"`
+}
+
+// AirdropProofs returns the proofs of the user's linked EVM wallets in a live campaign, only of
+// wallet when it is given.
+func AirdropProofs(mainID uint64, name, wallet string) ([]AirdropProofView, error) {
+	campaign, err := GetAirdropCampaign(name)
+	if err != nil {
+		return nil, err
+	}
+	if campaign.Status != model.AirdropCampaignStatusLive {
+		return nil, ErrCampaignNotFound
+	}
+
+	db := system.GetDb()
+	var wallets []string
+	if err := db.Model(&model.UserProvider{}).
+		Where("main_id = ? AND provider_type = ? AND provider_id LIKE ?", mainID, "wallet", "0x%").
+		Pluck("provider_id", &wallets).Error; err != nil {
+		return nil, err
+	}
+	if wallet != "" {
+		var mine []string
+		for _, w := range wallets {
+			if strings.EqualFold(w, wallet) {
+				mine = append(mine, w)
+			}
+		}
+		wallets = mine
+	}
+	if len(wallets) == 0 {
+		return nil, ErrNotInAirdrop
+	}
+
+	var leaves []model.AirdropLeaf
+	if err := db.Model(&model.AirdropLeaf{}).
+		Where("campaign_id = ? AND wallet IN ?", campaign.ID, wallets).
+		Order("idx").
+		Find(&leaves).Error; err != nil {
+		return nil, err
+	}
+	if len(leaves) == 0 {
+		return nil, ErrNotInAirdrop
+	}
+	views := make([]AirdropProofView, 0, len(leaves))
+	for _, l := range leaves {
+		var proof []string
+		if err := json.Unmarshal([]byte(l.Proof), &proof); err != nil {
+			return nil, fmt.Errorf("leaf %d: bad proof: %w", l.ID, err)
+		}
+		views = append(views, AirdropProofView{
+			Campaign:   campaign.Name,
+			ChainID:    campaign.ChainID,
+			Contract:   campaign.Contract,
+			MerkleRoot: campaign.MerkleRoot,
+			Wallet:     l.Wallet,
+			Amount:     l.Amount.String(),
+			AmountText: l.Amount.Shift(-campaign.Decimals).String(),
+			Index:      l.Idx,
+			Leaf:       l.Leaf,
+			Proof:      proof,
+			Claimed:    l.ClaimTx != "",
+			ClaimTx:    l.ClaimTx,
+			ClaimTime:  l.ClaimTime,
+		})
+	}
+	return views, nil
+}
+
+// ReportAirdropClaim records the claims a reported tx made in a campaign, without waiting for the
+// claim follower to get to its block.
+func ReportAirdropClaim(name, txHash string) error {
+	campaign, err := GetAirdropCampaign(name)
+	if err != nil {
+		return err
+	}
+	if campaign.Status != model.AirdropCampaignStatusLive {
+		return ErrCampaignNotFound
+	}
+	tx, err := chain.ParseAirdropClaimTx(campaign.ChainID, campaign.Contract, txHash)
+	if err != nil {
+		return err
+	}
+	switch tx.Status {
+	case "pending":
+		return ErrClaimPending
+	case "failed":
+		return ErrClaimNotFound
+	}
+	for _, cl := range tx.Claims {
+		if err := settleAirdropClaim(campaign, cl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IndexAirdropClaims follows the Claimed logs of every live campaign once, each from its own cursor.
+func IndexAirdropClaims(ctx context.Context) {
+	var campaigns []model.AirdropCampaign
+	if err := system.GetDb().Model(&model.AirdropCampaign{}).
+		Where("status = ? AND contract <> ''", model.AirdropCampaignStatusLive).
+		Find(&campaigns).Error; err != nil {
+		log.Errorf("[AirdropClaims] load campaigns: %v", err)
+		return
+	}
+	for i := range campaigns {
+		if err := indexCampaignClaims(ctx, &campaigns[i]); err != nil {
+			log.Errorf("[AirdropClaims] campaign %s: %v", campaigns[i].Name, err)
+		}
+	}
+}
+
+func indexCampaignClaims(ctx context.Context, campaign *model.AirdropCampaign) error {
+	c, err := tools.LookupChain(campaign.ChainID)
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s_%d", model.CursorAirdropClaim, campaign.ID)
+	return followLogs(ctx, c, name, campaign.Contract, campaign.StartBlock, func(from, to uint64) error {
+		claims, err := chain.FetchAirdropClaims(ctx, campaign.ChainID, campaign.Contract, from, to)
+		if err != nil {
+			return err
+		}
+		for _, cl := range claims {
+			if err := settleAirdropClaim(campaign, cl); err != nil {
+				return fmt.Errorf("settle %s/%d: %w", cl.TxHash, cl.LogIndex, err)
+			}
+		}
+		return nil
+	})
+}
+
+// settleAirdropClaim marks the leaf of the claiming wallet claimed. A claim seen twice, by the
+// follower and a report, changes nothing the second time. A claim the tree does not back means
+// the distributor pays out against something else, which is alerted on.
+func settleAirdropClaim(campaign *model.AirdropCampaign, cl chain.AirdropClaim) error {
+	db := system.GetDb()
+	var leaf model.AirdropLeaf
+	err := db.Model(&model.AirdropLeaf{}).
+		Where("campaign_id = ? AND wallet = ?", campaign.ID, cl.Account).
+		First(&leaf).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		Alert(model.ContractEventSeverityCritical, "airdrop claimed by a wallet outside the campaign", map[string]interface{}{
+			"campaign": campaign.Name, "wallet": cl.Account, "amount": cl.Amount.String(), "tx_hash": cl.TxHash,
+		})
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if leaf.ClaimTx != "" {
+		return nil
+	}
+	if leaf.Amount.Cmp(decimal.NewFromBigInt(cl.Amount, 0)) != 0 {
+		Alert(model.ContractEventSeverityCritical, "airdrop claim amount differs from the campaign", map[string]interface{}{
+			"campaign": campaign.Name, "wallet": cl.Account, "claimed": cl.Amount.String(), "leaf": leaf.Amount.String(), "tx_hash": cl.TxHash,
+		})
+	}
+	claimTime := cl.BlockTime
+	if claimTime.IsZero() {
+		claimTime = time.Now()
+	}
+	return db.Model(&model.AirdropLeaf{}).
+		Where("id = ? AND claim_tx = ''", leaf.ID).
+		Updates(map[string]interface{}{
+			"claim_tx":    cl.TxHash,
+			"claim_block": cl.BlockNumber,
+			"claim_time":  claimTime,
+		}).Error
+}
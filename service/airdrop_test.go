@@ -0,0 +1,99 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// verifyProof is OpenZeppelin's MerkleProof.verify: pairs hashed sorted.
+func verifyProof(root common.Hash, leaf []byte, proof []string) bool {
+	h := leaf
+	for _, p := range proof {
+		sib := hexutil.MustDecode(p)
+		if bytes.Compare(h, sib) < 0 {
+			h = crypto.Keccak256(h, sib)
+		} else {
+			h = crypto.Keccak256(sib, h)
+		}
+	}
+	return common.BytesToHash(h) == root
+}
+
+func TestBuildAirdropTree(t *testing.T) {
+	for _, n := range []int{1, 2, 5, 8} {
+		var entries []AirdropEntry
+		for i := 0; i < n; i++ {
+			entries = append(entries, AirdropEntry{
+				Wallet: common.BigToAddress(big.NewInt(int64(1000 - i))).Hex(),
+				Amount: big.NewInt(int64(i+1) * 1e6),
+			})
+		}
+		root, leaves, err := BuildAirdropTree(entries)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(leaves) != n {
+			t.Fatalf("%d entries gave %d leaves", n, len(leaves))
+		}
+		for _, l := range leaves {
+			// the leaf the distributor computes: keccak256(abi.encode(account, amount))
+			leaf := crypto.Keccak256(common.LeftPadBytes(common.HexToAddress(l.Wallet).Bytes(), 32), common.LeftPadBytes(l.Amount.BigInt().Bytes(), 32))
+			if hexutil.Encode(leaf) != l.Leaf {
+				t.Fatalf("leaf of %s is %s, want %s", l.Wallet, l.Leaf, hexutil.Encode(leaf))
+			}
+			var proof []string
+			if err := json.Unmarshal([]byte(l.Proof), &proof); err != nil {
+				t.Fatal(err)
+			}
+			if !verifyProof(root, leaf, proof) {
+				t.Fatalf("%d leaves: proof of %s does not verify", n, l.Wallet)
+			}
+		}
+
+		// the order of the input does not change the root
+		reversed := make([]AirdropEntry, n)
+		for i := range entries {
+			reversed[n-1-i] = entries[i]
+		}
+		if again, _, err := BuildAirdropTree(reversed); err != nil || again != root {
+			t.Fatalf("reordered entries give root %s, want %s (%v)", again, root, err)
+		}
+	}
+
+	dup := []AirdropEntry{
+		{Wallet: "0x00000000000000000000000000000000000000aa", Amount: big.NewInt(1)},
+		{Wallet: "0x00000000000000000000000000000000000000AA", Amount: big.NewInt(2)},
+	}
+	if _, _, err := BuildAirdropTree(dup); err == nil {
+		t.Fatal("a wallet listed twice was accepted")
+	}
+}
+
+func TestParseAirdropCSV(t *testing.T) {
+	entries, err := ParseAirdropCSV(strings.NewReader("wallet,amount\n0x00000000000000000000000000000000000000aa, 1.5\n0x00000000000000000000000000000000000000bb,2\n"), 18)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Amount.String() != "1500000000000000000" || entries[1].Wallet != common.HexToAddress("0xbb").Hex() {
+		t.Fatalf("parsed %+v", entries)
+	}
+
+	for name, csv := range map[string]string{
+		"duplicate":      "0x00000000000000000000000000000000000000aa,1\n0x00000000000000000000000000000000000000AA,1\n",
+		"too precise":    "0x00000000000000000000000000000000000000aa,0.0000001\n",
+		"zero":           "0x00000000000000000000000000000000000000aa,0\n",
+		"bad wallet":     "0x00000000000000000000000000000000000000aa,1\nnot-a-wallet,1\n",
+		"missing amount": "0x00000000000000000000000000000000000000aa\n",
+	} {
+		if _, err := ParseAirdropCSV(strings.NewReader(csv), 6); err == nil {
+			t.Fatalf("%s: accepted", name)
+		}
+	}
+}
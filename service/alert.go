@@ -0,0 +1,44 @@
+package service
+
+import (
+	"bytes"
+	"chaos/api/config"
+	"chaos/api/log"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+var alertClient = &http.Client{Timeout: 10 * time.Second}
+
+// Alert reports something operations must look at. It is always logged, and POSTed as json to
+// the configured webhook in the background so a slow receiver never holds up the caller.
+func Alert(severity, title string, detail interface{}) {
+	log.Errorf("[Alert][%s] %s: %+v", severity, title, detail)
+
+	webhook := config.GetConfig().Alert.Webhook
+	if webhook == "" {
+		return
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"severity": severity,
+		"title":    title,
+		"detail":   detail,
+		"time":     time.Now().Unix(),
+	})
+	if err != nil {
+		log.Error("marshal alert failed", err)
+		return
+	}
+	go func() {
+		resp, err := alertClient.Post(webhook, "application/json", bytes.NewReader(body))
+		if err != nil {
+			log.Error("post alert failed", err)
+			return
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			log.Errorf("post alert: webhook answered %d", resp.StatusCode)
+		}
+	}()
+}
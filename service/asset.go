@@ -0,0 +1,91 @@
+package service
+
+import (
+	mycache "chaos/api/cache"
+	"chaos/api/chain"
+	"chaos/api/config"
+	"chaos/api/model"
+	"chaos/api/system"
+	"chaos/api/tools"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var ErrAssetNotFound = errors.New("asset not found")
+
+// legacyNDecimals is the scale every balance row used before t_asset carried decimals.
+const legacyNDecimals = 6
+
+// DefaultAssetID is the asset a request is charged in when it does not name one.
+func DefaultAssetID() uint64 {
+	return config.GetConfig().Contract.NAssetID
+}
+
+// GetAsset loads an asset from the t_asset registry. Asset id 0 is the legacy N balance
+// that predates the registry; it resolves to the configured N token when no row exists.
+func GetAsset(id uint64) (model.SysAsset, error) {
+	if asset, ok := mycache.GetAsset(id); ok {
+		return asset, nil
+	}
+
+	var asset model.SysAsset
+	err := system.GetDb().Model(&model.SysAsset{}).Where("id = ?", id).First(&asset).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if id != 0 {
+			return model.SysAsset{}, fmt.Errorf("%w: %d", ErrAssetNotFound, id)
+		}
+		asset = model.SysAsset{
+			ID:            0,
+			Chain:         "BSC",
+			Ca:            config.GetConfig().Contract.NAddress,
+			Name:          "N",
+			Symbol:        "N",
+			Type:          model.AssetTypeERC20,
+			Decimals:      legacyNDecimals,
+			ChainDecimals: config.GetConfig().Contract.NChainDecimals,
+		}
+	} else if err != nil {
+		return model.SysAsset{}, err
+	}
+
+	mycache.SetAsset(asset)
+	return asset, nil
+}
+
+// TopupAsset returns the asset of the token the TopupLogic contract on chainID takes deposits in
+// and pays out. Only that asset is credited for the contract's deposits and locked for its
+// withdrawals; ErrAssetNotFound when the token is not registered on the chain.
+func TopupAsset(ctx context.Context, chainID uint64, contract string) (model.SysAsset, error) {
+	c, err := tools.LookupChain(chainID)
+	if err != nil {
+		return model.SysAsset{}, err
+	}
+	token, err := chain.TopupToken(ctx, chainID, contract)
+	if err != nil {
+		return model.SysAsset{}, err
+	}
+	found, err := findAsset(system.GetDb(), c.Name, token.Hex())
+	if err != nil {
+		return model.SysAsset{}, err
+	}
+	if found != nil {
+		mycache.SetAsset(*found)
+		return *found, nil
+	}
+	// the N balance may still be the legacy asset, with no t_asset row
+	if def, err := GetAsset(DefaultAssetID()); err == nil && def.Chain == c.Name && strings.EqualFold(def.Ca, token.Hex()) {
+		return def, nil
+	}
+	return model.SysAsset{}, fmt.Errorf("%w: token %s of chain %d", ErrAssetNotFound, token.Hex(), chainID)
+}
+
+// ListAccountBalances returns every asset balance of a user.
+func ListAccountBalances(mainID uint64) ([]model.AccountBalance, error) {
+	var balances []model.AccountBalance
+	err := system.GetDb().Model(&model.AccountBalance{}).Where("main_id = ?", mainID).Order("asset_id").Find(&balances).Error
+	return balances, err
+}
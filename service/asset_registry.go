@@ -0,0 +1,209 @@
+package service
+
+import (
+	mycache "chaos/api/cache"
+	"chaos/api/chain"
+	"chaos/api/config"
+	"chaos/api/log"
+	"chaos/api/model"
+	"chaos/api/system"
+	"chaos/api/tools"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrAssetExists  = errors.New("asset already registered")
+	ErrAssetInvalid = errors.New("invalid asset")
+)
+
+const AuditAssetRegister = "asset.register"
+
+// defaultLedgerDecimals is the ledger scale of a discovered token whose contract has more decimals.
+const defaultLedgerDecimals = 6
+
+type AssetRegistration struct {
+	ChainID         uint64
+	Contract        string
+	LedgerDecimals  int32 // ledger scale, defaultLedgerDecimals or the token's own when fewer if 0
+	DisplayDecimals int32
+}
+
+// ledgerDecimals picks the ledger scale of a token with chainDecimals: the requested one, never
+// finer than the token nor than a uint64 ledger can hold.
+func ledgerDecimals(requested int32, chainDecimals uint8) (int32, error) {
+	if requested < 0 || requested > model.MaxLedgerDecimals {
+		return 0, fmt.Errorf("%w: ledger decimals must be 0-%d", ErrAssetInvalid, model.MaxLedgerDecimals)
+	}
+	if requested == 0 {
+		requested = defaultLedgerDecimals
+	}
+	return min(requested, int32(chainDecimals)), nil
+}
+
+// DiscoverERC20 reads the metadata of the token at contract on a registered chain, failing with
+// tools.ErrNotERC20 when the contract does not answer like one.
+func DiscoverERC20(ctx context.Context, chainID uint64, contract string) (tools.ERC20Metadata, error) {
+	if !common.IsHexAddress(contract) {
+		return tools.ERC20Metadata{}, fmt.Errorf("%w: bad contract address", ErrAssetInvalid)
+	}
+	token, err := tools.NewChainERC20Token(chainID, contract)
+	if err != nil {
+		return tools.ERC20Metadata{}, err
+	}
+	return token.Metadata(ctx)
+}
+
+// findAsset returns the asset of contract on the chain named chainName, nil when there is none.
+func findAsset(db *gorm.DB, chainName, contract string) (*model.SysAsset, error) {
+	var assets []model.SysAsset
+	if err := db.Model(&model.SysAsset{}).Where("chain = ? AND LOWER(ca) = ?", chainName, strings.ToLower(contract)).
+		Limit(1).Find(&assets).Error; err != nil {
+		return nil, err
+	}
+	if len(assets) == 0 {
+		return nil, nil
+	}
+	return &assets[0], nil
+}
+
+// RegisterERC20Asset adds the token at req.Contract to t_asset with the name, symbol and decimals
+// its contract reports. A token already registered on the chain is returned with ErrAssetExists.
+func RegisterERC20Asset(ctx context.Context, operatorID uint64, req AssetRegistration) (*model.SysAsset, error) {
+	c, err := tools.LookupChain(req.ChainID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrAssetInvalid, err)
+	}
+	md, err := DiscoverERC20(ctx, req.ChainID, req.Contract)
+	if err != nil {
+		return nil, err
+	}
+	decimals, err := ledgerDecimals(req.LedgerDecimals, md.Decimals)
+	if err != nil {
+		return nil, err
+	}
+	if req.DisplayDecimals < 0 || req.DisplayDecimals > decimals {
+		return nil, fmt.Errorf("%w: display decimals must be 0-%d", ErrAssetInvalid, decimals)
+	}
+
+	now := time.Now()
+	asset := model.SysAsset{
+		Chain:           c.Name,
+		Ca:              md.Address,
+		Name:            md.Name,
+		Symbol:          md.Symbol,
+		Type:            model.AssetTypeERC20,
+		Decimals:        decimals,
+		ChainDecimals:   int32(md.Decimals),
+		DisplayDecimals: req.DisplayDecimals,
+		AddTime:         now,
+		MetaTime:        &now,
+	}
+	var existing *model.SysAsset
+	err = system.GetDb().Transaction(func(tx *gorm.DB) error {
+		if existing, err = findAsset(tx, c.Name, md.Address); err != nil || existing != nil {
+			return err
+		}
+		if err := tx.Create(&asset).Error; err != nil {
+			return err
+		}
+		return RecordAudit(tx, operatorID, AuditAssetRegister, model.TB_SYS_ASSET, asset.ID, map[string]interface{}{
+			"chain_id": req.ChainID, "contract": md.Address, "symbol": md.Symbol, "decimals": decimals, "chain_decimals": md.Decimals,
+		})
+	})
+	if IsDuplicateKey(err) {
+		// registered by a concurrent discovery since the check
+		if existing, err = findAsset(system.GetDb(), c.Name, md.Address); err != nil || existing == nil {
+			return nil, fmt.Errorf("%w: %s on %s", ErrAssetExists, md.Address, c.Name)
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return existing, fmt.Errorf("%w: %s on %s is asset %d", ErrAssetExists, existing.Symbol, c.Name, existing.ID)
+	}
+	log.Infof("[AssetRegistry] registered %s (%s) on %s as asset %d", asset.Symbol, asset.Ca, c.Name, asset.ID)
+	return &asset, nil
+}
+
+// RefreshAssetMetadata reads name and symbol of the erc20 assets whose metadata is older than
+// asset.metaRefresh again. Decimals are never changed on a live asset: the ledger is scaled by
+// them, so a token reporting other decimals is alerted on for an operator to look at.
+func RefreshAssetMetadata(ctx context.Context) {
+	hours := config.GetConfig().Asset.MetaRefresh
+	if hours <= 0 {
+		hours = 24
+	}
+	stale := time.Now().Add(-time.Duration(hours) * time.Hour)
+
+	db := system.GetDb()
+	var assets []model.SysAsset
+	if err := db.Model(&model.SysAsset{}).
+		Where("type = ? AND ca <> '' AND (meta_time IS NULL OR meta_time < ?)", model.AssetTypeERC20, stale).
+		Find(&assets).Error; err != nil {
+		log.Errorf("[AssetRegistry] load stale assets: %v", err)
+		return
+	}
+	for _, a := range assets {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := refreshAsset(ctx, a); err != nil {
+			log.Errorf("[AssetRegistry] refresh asset %d (%s): %v", a.ID, a.Symbol, err)
+		}
+	}
+}
+
+func refreshAsset(ctx context.Context, a model.SysAsset) error {
+	c, err := tools.LookupChainByName(a.Chain)
+	if err != nil {
+		return err
+	}
+	md, err := DiscoverERC20(ctx, c.ChainID, a.Ca)
+	if err != nil {
+		return err
+	}
+	if int32(md.Decimals) != a.ChainDecimals && a.ChainDecimals != 0 {
+		Alert(model.ContractEventSeverityCritical, "token reports other decimals than its asset", map[string]interface{}{
+			"asset_id": a.ID, "contract": a.Ca, "asset_chain_decimals": a.ChainDecimals, "token_decimals": md.Decimals,
+		})
+	}
+	if md.Name != a.Name || md.Symbol != a.Symbol {
+		log.Infof("[AssetRegistry] asset %d metadata changed: %s/%s -> %s/%s", a.ID, a.Name, a.Symbol, md.Name, md.Symbol)
+	}
+	now := time.Now()
+	if err := system.GetDb().Model(&model.SysAsset{}).Where("id = ?", a.ID).Updates(map[string]interface{}{
+		"name":      md.Name,
+		"symbol":    md.Symbol,
+		"meta_time": now,
+	}).Error; err != nil {
+		return err
+	}
+	a.Name, a.Symbol, a.MetaTime = md.Name, md.Symbol, &now
+	mycache.SetAsset(a)
+	return nil
+}
+
+// CheckNativeAssets verifies the native asset of every chain that takes native deposits. A chain
+// whose asset cannot book wei in the ledger stops taking them, with a critical alert.
+func CheckNativeAssets() {
+	for _, c := range tools.Chains() {
+		if c.NativeAssetID == 0 || chain.NativeTreasury(c.ChainID) == "" {
+			continue
+		}
+		asset, err := GetAsset(c.NativeAssetID)
+		if err != nil {
+			log.Errorf("[AssetRegistry] load native asset %d of chain %d: %v", c.NativeAssetID, c.ChainID, err)
+			continue
+		}
+		if err := chain.CheckNativeAsset(c.ChainID, asset); err != nil {
+			Alert(model.ContractEventSeverityCritical, "native deposits disabled: invalid native asset", err.Error())
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package service
+
+import "testing"
+
+func TestLedgerDecimals(t *testing.T) {
+	for _, c := range []struct {
+		requested int32
+		chain     uint8
+		want      int32
+	}{
+		{0, 18, defaultLedgerDecimals},
+		{0, 2, 2}, // never finer than the token
+		{9, 18, 9},
+		{8, 6, 6},
+	} {
+		if got, err := ledgerDecimals(c.requested, c.chain); err != nil || got != c.want {
+			t.Fatalf("ledgerDecimals(%d, %d) = %d, %v; want %d", c.requested, c.chain, got, err, c.want)
+		}
+	}
+	if _, err := ledgerDecimals(10, 18); err == nil {
+		t.Fatal("a ledger scale a uint64 can not hold was accepted")
+	}
+}
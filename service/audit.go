@@ -0,0 +1,26 @@
+package service
+
+import (
+	"chaos/api/model"
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RecordAudit appends an operator action to n_admin_audit within tx, so the trail commits or
+// rolls back together with the action itself.
+func RecordAudit(tx *gorm.DB, operatorID uint64, action, targetTable string, targetID uint64, detail interface{}) error {
+	b, err := json.Marshal(detail)
+	if err != nil {
+		return err
+	}
+	return tx.Create(&model.AdminAudit{
+		OperatorID:  operatorID,
+		Action:      action,
+		TargetTable: targetTable,
+		TargetID:    targetID,
+		Detail:      string(b),
+		AddTime:     time.Now(),
+	}).Error
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"chaos/api/chain"
+	"chaos/api/log"
+	"chaos/api/model"
+	"chaos/api/system"
+	"chaos/api/tools"
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const defaultFollowRange = 500
+
+// followContract walks the TopupLogic contract of a chain from the cursor named name up to the
+// newest block with enough confirmations, handing each block range to handle and saving the cursor after it. An error stops the walk
+// before the cursor passes the failing range, so the next poll retries it.
+func followContract(ctx context.Context, c *tools.EvmChain, name string, handle func(from, to uint64) error) error {
+	return followLogs(ctx, c, name, c.GetTopupContract(), c.DepositFromBlock, handle)
+}
+
+// followLogs is followContract for any contract of the chain, starting at fromBlock.
+func followLogs(ctx context.Context, c *tools.EvmChain, name, contract string, fromBlock uint64, handle func(from, to uint64) error) error {
+	head, err := chain.ConfirmedHead(ctx, c.ChainID)
+	if err != nil {
+		return err
+	}
+	cursor, err := loadChainCursor(c.ChainID, name, contract, fromBlock, head)
+	if err != nil {
+		return err
+	}
+
+	step := uint64(c.RangeRound)
+	if step == 0 {
+		step = defaultFollowRange
+	}
+	for cursor.BlockNumber < head {
+		if ctx.Err() != nil {
+			return nil
+		}
+		from := cursor.BlockNumber + 1
+		to := min(from+step-1, head)
+		if err := handle(from, to); err != nil {
+			return err
+		}
+		cursor.BlockNumber = to
+		cursor.UpdateTime = time.Now()
+		if err := system.GetDb().Save(cursor).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadChainCursor returns the cursor of a follower, creating it on first use. A follower starts
+// at fromBlock, or at the head when none is configured. Pointing it at another contract restarts it.
+func loadChainCursor(chainID uint64, name, contract string, fromBlock, head uint64) (*model.ChainCursor, error) {
+	start := head
+	if fromBlock > 0 {
+		start = fromBlock - 1
+	}
+	db := system.GetDb()
+	var cursor model.ChainCursor
+	err := db.Model(&model.ChainCursor{}).Where("chain_id = ? AND name = ?", chainID, name).First(&cursor).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		cursor = model.ChainCursor{ChainID: chainID, Name: name, Contract: contract, BlockNumber: start, UpdateTime: time.Now()}
+		return &cursor, db.Create(&cursor).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(cursor.Contract, contract) {
+		log.Warnf("[ChainFollower] %s on chain %d: contract changed from %s to %s, restarting at block %d", name, chainID, cursor.Contract, contract, start+1)
+		cursor.Contract = contract
+		cursor.BlockNumber = start
+	}
+	return &cursor, nil
+}
@@ -0,0 +1,222 @@
+package service
+
+import (
+	"chaos/api/chain"
+	"chaos/api/log"
+	"chaos/api/model"
+	"chaos/api/system"
+	"chaos/api/tools"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrContractEventNotFound = errors.New("contract event not found")
+	ErrContractEventAcked    = errors.New("contract event already acknowledged")
+)
+
+const AuditContractEventAck = "contract_event.ack"
+
+// IndexContractEvents polls the owner events of every configured TopupLogic contract once.
+// Revoked locks are settled, every event is recorded and the ones operations must know about alert.
+func IndexContractEvents(ctx context.Context) {
+	for _, c := range chain.DepositChains() {
+		if err := indexChainContractEvents(ctx, c); err != nil {
+			log.Errorf("[ContractEvents] chain %s (%d): %v", c.Name, c.ChainID, err)
+		}
+	}
+}
+
+func indexChainContractEvents(ctx context.Context, c *tools.EvmChain) error {
+	return followContract(ctx, c, model.CursorTopupEvents, func(from, to uint64) error {
+		events, err := chain.FetchContractEvents(ctx, c.ChainID, c.GetTopupContract(), from, to)
+		if err != nil {
+			return err
+		}
+		for _, e := range events {
+			if err := HandleContractEvent(e); err != nil {
+				return fmt.Errorf("%s %s/%d: %w", e.Name, e.TxHash, e.LogIndex, err)
+			}
+		}
+		return nil
+	})
+}
+
+// HandleContractEvent applies one owner event and records it. Handling an event twice is a no-op.
+func HandleContractEvent(e chain.ContractEvent) error {
+	severity := model.ContractEventSeverityInfo
+	var detail map[string]interface{}
+
+	switch e.Name {
+	case chain.EventLockRevoked:
+		detail = map[string]interface{}{"user": e.User, "lock_id": hexutil.Encode(e.LockID[:]), "amount": e.Amount.String()}
+		flow, err := settleRevokedLock(e)
+		if err != nil {
+			return err
+		}
+		if flow == nil {
+			// the funds went back to the user on chain but no ledger lock moved with them
+			severity = model.ContractEventSeverityCritical
+			detail["note"] = "no open withdraw lock matches the revoked lock"
+		} else {
+			detail["flow_id"] = flow.ID
+			detail["main_id"] = flow.MainID
+		}
+	case chain.EventSwept:
+		// funds left the contract: custody no longer backs the liabilities by that much
+		severity = model.ContractEventSeverityCritical
+		detail = map[string]interface{}{"to": e.To, "amount": e.Amount.String()}
+	case chain.EventSignerUpdated:
+		severity = model.ContractEventSeverityCritical
+		detail = map[string]interface{}{"signer": e.Signer}
+		if ours, err := LockSignerAddress(e.ChainID); err == nil {
+			detail["expected_signer"] = ours
+			if strings.EqualFold(ours, e.Signer) {
+				severity = model.ContractEventSeverityWarning
+			}
+		}
+	case chain.EventMaxLockTTLUpdated:
+		severity = model.ContractEventSeverityWarning
+		detail = map[string]interface{}{"ttl": e.TTL}
+	default:
+		return fmt.Errorf("unsupported contract event %s", e.Name)
+	}
+
+	b, err := json.Marshal(detail)
+	if err != nil {
+		return err
+	}
+	record := model.ContractEvent{
+		ChainID:     e.ChainID,
+		Contract:    e.Contract,
+		TxHash:      e.TxHash,
+		LogIndex:    e.LogIndex,
+		BlockNumber: e.BlockNumber,
+		BlockTime:   e.BlockTime,
+		Event:       e.Name,
+		Severity:    severity,
+		Detail:      string(b),
+		Status:      model.ContractEventStatusOpen,
+		AddTime:     time.Now(),
+	}
+	if err := system.GetDb().Create(&record).Error; err != nil {
+		if IsDuplicateKey(err) {
+			return nil
+		}
+		return err
+	}
+	if severity != model.ContractEventSeverityInfo {
+		Alert(severity, fmt.Sprintf("TopupLogic %s on chain %d", e.Name, e.ChainID), record)
+	}
+	return nil
+}
+
+// settleRevokedLock gives the amount of a revoked lock back to Available and cancels the lock,
+// through a revoke flow settled like a user cancel. It returns that flow, or nil when no lock of
+// ours matches.
+func settleRevokedLock(e chain.ContractEvent) (*model.AccountBalanceFlow, error) {
+	db := system.GetDb()
+	var lock model.AccountBalanceFlow
+	err := db.Model(&model.AccountBalanceFlow{}).
+		Where("op = ? AND lock_id = ?", model.BalanceFlowOpFreeze, hexutil.Encode(e.LockID[:])).
+		First(&lock).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var flow model.AccountBalanceFlow
+	err = db.Model(&model.AccountBalanceFlow{}).
+		Where("tx_hash = ? AND op = ? AND ref_flow_id = ?", e.TxHash, model.BalanceFlowOpRevoke, lock.ID).
+		First(&flow).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if flow.ID > 0 && flow.Status != model.BalanceFlowStatusPending {
+		return &flow, nil
+	}
+	if lock.Status != model.BalanceFlowStatusPending && lock.Status != model.BalanceFlowStatusPendingWithDraw &&
+		lock.Status != model.BalanceFlowStatusLockExpired {
+		log.Warnf("[ContractEvents] revoked lock %d is already %s", lock.ID, model.BalanceFlowStatusName(lock.Status))
+		return nil, nil
+	}
+
+	if flow.ID == 0 {
+		now := time.Now()
+		flow = model.AccountBalanceFlow{
+			MainID:     lock.MainID,
+			AssetID:    lock.AssetID,
+			TxHash:     e.TxHash,
+			Amount:     lock.RealAmount,
+			Op:         model.BalanceFlowOpRevoke,
+			Status:     model.BalanceFlowStatusPending,
+			ChainID:    fmt.Sprintf("%d", e.ChainID),
+			RefFlowID:  lock.ID,
+			LockID:     lock.LockID,
+			FromAddr:   e.User,
+			AddTime:    now,
+			UpdateTime: now,
+		}
+		if err := db.Create(&flow).Error; err != nil {
+			return nil, err
+		}
+	}
+	if err := UpdateAccountBalance(e.TopupTxInfo(lock.MainID, flow.ID)); err != nil {
+		return nil, err
+	}
+	return &flow, nil
+}
+
+// ListContractEvents pages through the recorded owner events, optionally of one status.
+func ListContractEvents(status string, page, limit int) ([]model.ContractEvent, int64, error) {
+	query := system.GetDb().Model(&model.ContractEvent{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var rows []model.ContractEvent
+	err := query.Order("id desc").Offset((page - 1) * limit).Limit(limit).Find(&rows).Error
+	return rows, total, err
+}
+
+// AckContractEvent marks an owner event as looked at by an operator.
+func AckContractEvent(operatorID, id uint64, comment string) (*model.ContractEvent, error) {
+	var event model.ContractEvent
+	err := system.GetDb().Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&event).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrContractEventNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if event.Status != model.ContractEventStatusOpen {
+			return ErrContractEventAcked
+		}
+		now := time.Now()
+		event.Status = model.ContractEventStatusAcked
+		event.AckOperator = operatorID
+		event.AckTime = &now
+		if err := tx.Save(&event).Error; err != nil {
+			return err
+		}
+		return RecordAudit(tx, operatorID, AuditContractEventAck, model.TB_CONTRACT_EVENT, event.ID, map[string]string{"comment": comment})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"chaos/api/chain"
+	"chaos/api/log"
+	"chaos/api/model"
+	"chaos/api/system"
+	"chaos/api/tools"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// IndexDeposits polls the Deposited logs of every configured TopupLogic contract once and
+// credits them. Each chain resumes from its cursor, so a restart neither skips nor double counts.
+func IndexDeposits(ctx context.Context) {
+	for _, c := range chain.DepositChains() {
+		if err := indexChainDeposits(ctx, c); err != nil {
+			log.Errorf("[DepositIndexer] chain %s (%d): %v", c.Name, c.ChainID, err)
+		}
+	}
+}
+
+func indexChainDeposits(ctx context.Context, c *tools.EvmChain) error {
+	return followContract(ctx, c, model.CursorTopupDeposit, func(from, to uint64) error {
+		deposits, err := chain.FetchDepositLogs(ctx, c.ChainID, c.GetTopupContract(), from, to)
+		if err != nil {
+			return err
+		}
+		for _, d := range deposits {
+			if err := SettleDeposit(ctx, d); err != nil {
+				return fmt.Errorf("settle %s/%d: %w", d.TxHash, d.LogIndex, err)
+			}
+		}
+		if len(deposits) > 0 {
+			log.Infof("[DepositIndexer] chain %d blocks %d-%d: %d deposits", c.ChainID, from, to, len(deposits))
+		}
+		return nil
+	})
+}
+
+// walletOwner returns the user whose linked wallet is addr, 0 when nobody's is.
+func walletOwner(addr string) (uint64, error) {
+	var providers []model.UserProvider
+	err := system.GetDb().Model(&model.UserProvider{}).
+		Where("provider_type = ? AND provider_id = ?", "wallet", addr).
+		Limit(1).
+		Find(&providers).Error
+	if err != nil || len(providers) == 0 {
+		return 0, err
+	}
+	return providers[0].MainID, nil
+}
+
+// SettleDeposit credits one Deposited log to the user owning the depositing wallet. It creates the
+// recharge flow, in the asset of the contract's token, when the frontend never reported the tx,
+// and settles a reported one still pending. A log already settled for that user is skipped, so a
+// deposit is never credited twice; pending reports of the tx by other users are failed, since
+// their wallets did not deposit.
+func SettleDeposit(ctx context.Context, d chain.DepositLog) error {
+	db := system.GetDb()
+	mainID, err := walletOwner(d.User)
+	if err != nil {
+		return err
+	}
+	if mainID == 0 {
+		return recordUnmatchedDeposit(d, model.UnmatchedDepositReasonNoWallet)
+	}
+
+	var flows []model.AccountBalanceFlow
+	if err := db.Model(&model.AccountBalanceFlow{}).
+		Where("chain_id = ? AND tx_hash = ? AND op = ?", fmt.Sprintf("%d", d.ChainID), d.TxHash, model.BalanceFlowOpRecharge).
+		Order("id").
+		Find(&flows).Error; err != nil {
+		return err
+	}
+	var pending *model.AccountBalanceFlow
+	for i := range flows {
+		f := &flows[i]
+		// a reported flow carries no log index until it is settled
+		sameLog := f.LogIndex == int(d.LogIndex) || f.LogIndex == 0
+		switch {
+		case f.Status == model.BalanceFlowStatusSuccess && f.LogIndex == int(d.LogIndex):
+			if f.MainID != mainID {
+				Alert(model.ContractEventSeverityCritical, "deposit credited to a user who did not make it", map[string]interface{}{
+					"flow_id": f.ID, "main_id": f.MainID, "depositor": d.User, "depositor_main_id": mainID,
+					"tx_hash": d.TxHash, "log_index": d.LogIndex,
+				})
+			}
+			return nil
+		case f.Status != model.BalanceFlowStatusPending || !sameLog:
+			// settled, failed, or a flow of another log of the tx
+		case f.MainID == mainID:
+			if pending == nil {
+				pending = f
+			}
+		default:
+			if err := failForeignReport(f, d); err != nil {
+				return err
+			}
+		}
+	}
+
+	if pending == nil {
+		asset, err := TopupAsset(ctx, d.ChainID, d.Contract)
+		if errors.Is(err, ErrAssetNotFound) {
+			return recordUnmatchedDeposit(d, model.UnmatchedDepositReasonNoAsset)
+		}
+		if err != nil {
+			return err
+		}
+		amount, _, err := asset.FromChain(d.Amount)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		flow := model.AccountBalanceFlow{
+			MainID:     mainID,
+			AssetID:    asset.ID,
+			Op:         model.BalanceFlowOpRecharge,
+			Status:     model.BalanceFlowStatusPending,
+			Amount:     amount.Uint64(),
+			ChainID:    fmt.Sprintf("%d", d.ChainID),
+			TxHash:     d.TxHash,
+			LogIndex:   int(d.LogIndex),
+			FromAddr:   d.User,
+			ToAddr:     d.Contract,
+			AddTime:    now,
+			UpdateTime: now,
+		}
+		if err := db.Create(&flow).Error; err != nil {
+			return err
+		}
+		pending = &flow
+		log.Infof("[DepositIndexer] unreported deposit %s of user %d picked up from logs", d.TxHash, mainID)
+	}
+	info := d.TopupTxInfo(mainID)
+	info.RefFlowID = pending.ID
+	return UpdateAccountBalance(info)
+}
+
+// failForeignReport fails the pending recharge f that a user reported for a deposit made by
+// another user's wallet.
+func failForeignReport(f *model.AccountBalanceFlow, d chain.DepositLog) error {
+	res := system.GetDb().Model(&model.AccountBalanceFlow{}).
+		Where("id = ? AND status = ?", f.ID, model.BalanceFlowStatusPending).
+		Updates(map[string]interface{}{"status": model.BalanceFlowStatusFailed, "update_time": time.Now()})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected > 0 {
+		log.Warnf("[DepositIndexer] flow %d of user %d reported deposit %s/%d of %s, not its wallet",
+			f.ID, f.MainID, d.TxHash, d.LogIndex, d.User)
+	}
+	return nil
+}
+
+func recordUnmatchedDeposit(d chain.DepositLog, reason string) error {
+	err := system.GetDb().Create(&model.UnmatchedDeposit{
+		ChainID:     d.ChainID,
+		Contract:    d.Contract,
+		TxHash:      d.TxHash,
+		LogIndex:    d.LogIndex,
+		BlockNumber: d.BlockNumber,
+		Depositor:   d.User,
+		Amount:      decimal.NewFromBigInt(d.Amount, 0),
+		Status:      model.UnmatchedDepositStatusOpen,
+		Reason:      reason,
+		AddTime:     time.Now(),
+	}).Error
+	if err != nil && !IsDuplicateKey(err) {
+		return err
+	}
+	if err == nil {
+		log.Warnf("[DepositIndexer] deposit %s/%d from %s not credited: %s", d.TxHash, d.LogIndex, d.User, reason)
+	}
+	return nil
+}
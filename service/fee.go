@@ -0,0 +1,300 @@
+package service
+
+import (
+	"chaos/api/model"
+	"chaos/api/system"
+	"errors"
+	"fmt"
+	"math/bits"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrFeeScheduleInvalid = errors.New("invalid fee schedule")
+
+const AuditFeeScheduleCreate = "fee_schedule.create"
+
+// defaultFeeSchedule applies when no schedule row matches: the whole spend is platform fee,
+// which is what a spend did before fee schedules existed.
+var defaultFeeSchedule = model.FeeSchedule{PlatformBps: model.FeeBpsTotal}
+
+// FeeScheduleAt returns the schedule in force for a catalog of a game at t. The most specific
+// row wins: the game and catalog, then the whole game, then the platform default.
+func FeeScheduleAt(db *gorm.DB, gameID uint64, catalog string, at time.Time) (model.FeeSchedule, error) {
+	var rows []model.FeeSchedule
+	err := db.Model(&model.FeeSchedule{}).
+		Where("game_id IN ? AND catalog IN ? AND effective_from <= ?", []uint64{gameID, 0}, []string{catalog, ""}, at).
+		Order("game_id desc, catalog desc, effective_from desc, id desc").
+		Limit(1).
+		Find(&rows).Error
+	if err != nil {
+		return model.FeeSchedule{}, err
+	}
+	if len(rows) == 0 {
+		return defaultFeeSchedule, nil
+	}
+	return rows[0], nil
+}
+
+// SpendShares divides amount by the basis points of s. The developer and prize pool shares are
+// rounded down and the platform fee takes the remainder, so the shares always add up to amount.
+func SpendShares(s model.FeeSchedule, amount uint64) (platform, developer, prizePool uint64) {
+	share := func(bps int) uint64 {
+		hi, lo := bits.Mul64(amount, uint64(bps))
+		q, _ := bits.Div64(hi, lo, model.FeeBpsTotal)
+		return q
+	}
+	developer = share(s.DeveloperBps)
+	prizePool = share(s.PrizePoolBps)
+	return amount - developer - prizePool, developer, prizePool
+}
+
+// ValidateFeeSchedule checks the shares of a new schedule before it is stored.
+func ValidateFeeSchedule(s model.FeeSchedule) error {
+	if s.PlatformBps < 0 || s.DeveloperBps < 0 || s.PrizePoolBps < 0 {
+		return fmt.Errorf("%w: shares can not be negative", ErrFeeScheduleInvalid)
+	}
+	if s.PlatformBps+s.DeveloperBps+s.PrizePoolBps != model.FeeBpsTotal {
+		return fmt.Errorf("%w: shares must add up to %d bps", ErrFeeScheduleInvalid, model.FeeBpsTotal)
+	}
+	if s.GameID == 0 && s.Catalog != "" {
+		return fmt.Errorf("%w: the platform default can not name a catalog", ErrFeeScheduleInvalid)
+	}
+	return nil
+}
+
+// CreateFeeSchedule stores a new version of the schedule of a game (or of the platform default).
+// It takes effect at EffectiveFrom, now when zero; schedules can not be back-dated.
+func CreateFeeSchedule(operatorID uint64, s model.FeeSchedule) (*model.FeeSchedule, error) {
+	if err := ValidateFeeSchedule(s); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if s.EffectiveFrom.IsZero() {
+		s.EffectiveFrom = now
+	}
+	if s.EffectiveFrom.Before(now.Add(-time.Minute)) {
+		return nil, fmt.Errorf("%w: effective_from is in the past", ErrFeeScheduleInvalid)
+	}
+	s.ID = 0
+	s.OperatorID = operatorID
+	s.AddTime = now
+
+	db := system.GetDb()
+	if s.GameID != 0 {
+		var count int64
+		if err := db.Model(&model.GameInfo{}).Where("id = ?", s.GameID).Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			return nil, fmt.Errorf("%w: game %d not found", ErrFeeScheduleInvalid, s.GameID)
+		}
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&s).Error; err != nil {
+			return err
+		}
+		return RecordAudit(tx, operatorID, AuditFeeScheduleCreate, model.TB_FEE_SCHEDULE, s.ID, s)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// ListFeeSchedules returns the schedule history of a game, newest first.
+func ListFeeSchedules(gameID uint64) ([]model.FeeSchedule, error) {
+	var rows []model.FeeSchedule
+	err := system.GetDb().Model(&model.FeeSchedule{}).
+		Where("game_id = ?", gameID).
+		Order("effective_from desc, id desc").
+		Find(&rows).Error
+	return rows, err
+}
+
+// seasonOfGame returns the season the game was part of at t, 0 when none was running.
+func seasonOfGame(db *gorm.DB, gameID uint64, at time.Time) (uint64, error) {
+	var ids []uint64
+	err := db.Table(model.TB_SEASON_GAME+" sg").
+		Joins("JOIN "+model.TB_SEASON_INFO+" s ON s.id = sg.season_id").
+		Where("sg.game_id = ? AND s.start_time <= ? AND s.end_time > ? AND s.status <> ?", gameID, at, at, model.SeasonStatusDraft).
+		Order("s.start_time desc").
+		Limit(1).
+		Pluck("s.id", &ids).Error
+	if err != nil || len(ids) == 0 {
+		return 0, err
+	}
+	return ids[0], nil
+}
+
+// ComputeSpendSplit works out how a spend of a game catalog is divided, from the schedule and the
+// season in force when it happened. A share with nobody to receive it (no developer on the game,
+// no running season) goes to the platform fee.
+func ComputeSpendSplit(db *gorm.DB, spend *model.AccountFlow, catalog string) (model.SpendSplit, error) {
+	split := model.SpendSplit{
+		FlowID:  spend.ID,
+		MainID:  spend.MainID,
+		AssetID: spend.AssetID,
+		GameID:  spend.GameID,
+		Catalog: catalog,
+		Amount:  spend.Amount,
+	}
+	schedule, err := FeeScheduleAt(db, spend.GameID, catalog, spend.AddTime)
+	if err != nil {
+		return split, err
+	}
+	split.ScheduleID = schedule.ID
+	split.PlatformFee, split.Developer, split.PrizePool = SpendShares(schedule, spend.Amount)
+
+	if split.Developer > 0 {
+		var game model.GameInfo
+		if err := db.Model(&model.GameInfo{}).Where("id = ?", spend.GameID).Find(&game).Error; err != nil {
+			return split, err
+		}
+		split.DeveloperID = game.DevID
+		if split.DeveloperID == 0 {
+			split.PlatformFee += split.Developer
+			split.Developer = 0
+		}
+	}
+	if split.PrizePool > 0 {
+		if split.SeasonID, err = seasonOfGame(db, spend.GameID, spend.AddTime); err != nil {
+			return split, err
+		}
+		if split.SeasonID == 0 {
+			split.PlatformFee += split.PrizePool
+			split.PrizePool = 0
+		}
+	}
+	return split, nil
+}
+
+// PostSpend settles a spend flow out of the frozen bucket of bal: it records the split and posts
+// one journal entry per share. The caller holds the row lock on bal and owns the transaction.
+func PostSpend(tx *gorm.DB, bal *model.AccountBalance, spend *model.AccountFlow, catalog string) (*model.SpendSplit, error) {
+	split, err := ComputeSpendSplit(tx, spend, catalog)
+	if err != nil {
+		return nil, err
+	}
+	if bal.Frozen < split.Amount {
+		return nil, ErrInsufficientBalance
+	}
+	split.AddTime = time.Now()
+	if err := tx.Create(&split).Error; err != nil {
+		return nil, err
+	}
+
+	ref := LedgerRef{
+		Table:  model.LedgerRefAccountFlow,
+		ID:     spend.ID,
+		Remark: fmt.Sprintf("fee schedule %d", split.ScheduleID),
+	}
+	for _, part := range []struct {
+		bizType string
+		amount  uint64
+	}{
+		{model.LedgerBizSpend, split.PlatformFee},
+		{model.LedgerBizSpendDeveloper, split.Developer},
+		{model.LedgerBizSpendPrizePool, split.PrizePool},
+	} {
+		if err := PostBalanceChange(tx, bal, part.bizType, part.amount, ref); err != nil {
+			return nil, err
+		}
+	}
+	return &split, nil
+}
+
+// refundSpend gives a spend back share by share. Spends made before fee schedules have no split
+// row and were taken as platform fee in full.
+func refundSpend(tx *gorm.DB, bal *model.AccountBalance, spend *model.AccountFlow, ref LedgerRef) error {
+	var splits []model.SpendSplit
+	if err := tx.Model(&model.SpendSplit{}).Where("flow_id = ?", spend.ID).Limit(1).Find(&splits).Error; err != nil {
+		return err
+	}
+	if len(splits) == 0 {
+		return PostBalanceChange(tx, bal, model.LedgerBizRefund, spend.Amount, ref)
+	}
+	split := splits[0]
+	for _, part := range []struct {
+		bizType string
+		amount  uint64
+	}{
+		{model.LedgerBizRefund, split.PlatformFee},
+		{model.LedgerBizRefundDeveloper, split.Developer},
+		{model.LedgerBizRefundPrizePool, split.PrizePool},
+	} {
+		if err := PostBalanceChange(tx, bal, part.bizType, part.amount, ref); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SpendRecompute compares the recorded split of a spend with the split the schedules in force
+// at the time of the spend give today, e.g. after a schedule was corrected.
+type SpendRecompute struct {
+	Flow     model.AccountFlow  `json:"flow"`
+	Recorded *model.SpendSplit  `json:"recorded"`
+	Computed model.SpendSplit   `json:"computed"`
+	Schedule *model.FeeSchedule `json:"schedule"`
+	Differs  bool               `json:"differs"`
+}
+
+// RecomputeSpends recomputes the splits of the spends of a session, or of a single spend flow.
+func RecomputeSpends(sessionID string, flowID uint64) ([]SpendRecompute, error) {
+	db := system.GetDb()
+	query := db.Model(&model.AccountFlow{}).Where("biz_type = ?", model.FlowSpend)
+	if flowID != 0 {
+		query = query.Where("id = ?", flowID)
+	} else {
+		query = query.Where("session_id = ?", sessionID)
+	}
+	var spends []model.AccountFlow
+	if err := query.Order("id").Limit(500).Find(&spends).Error; err != nil {
+		return nil, err
+	}
+
+	results := make([]SpendRecompute, 0, len(spends))
+	for i := range spends {
+		spend := &spends[i]
+		r := SpendRecompute{Flow: *spend}
+
+		var recorded []model.SpendSplit
+		if err := db.Model(&model.SpendSplit{}).Where("flow_id = ?", spend.ID).Limit(1).Find(&recorded).Error; err != nil {
+			return nil, err
+		}
+		catalog := ""
+		if len(recorded) > 0 {
+			r.Recorded = &recorded[0]
+			catalog = recorded[0].Catalog
+		} else if spend.SessionID != "" {
+			var session model.GameSession
+			if err := db.Model(&model.GameSession{}).Where("session_id = ?", spend.SessionID).Find(&session).Error; err != nil {
+				return nil, err
+			}
+			catalog = session.Catalog
+		}
+
+		computed, err := ComputeSpendSplit(db, spend, catalog)
+		if err != nil {
+			return nil, err
+		}
+		r.Computed = computed
+		if computed.ScheduleID != 0 {
+			var schedule model.FeeSchedule
+			if err := db.Model(&model.FeeSchedule{}).Where("id = ?", computed.ScheduleID).First(&schedule).Error; err == nil {
+				r.Schedule = &schedule
+			}
+		}
+		if r.Recorded != nil {
+			r.Differs = r.Recorded.PlatformFee != computed.PlatformFee ||
+				r.Recorded.Developer != computed.Developer ||
+				r.Recorded.PrizePool != computed.PrizePool
+		} else {
+			r.Differs = computed.Developer > 0 || computed.PrizePool > 0
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
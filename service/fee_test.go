@@ -0,0 +1,47 @@
+package service
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"chaos/api/model"
+)
+
+func TestSpendShares(t *testing.T) {
+	s := model.FeeSchedule{PlatformBps: 2000, DeveloperBps: 5000, PrizePoolBps: 3000}
+	platform, developer, prizePool := SpendShares(s, 1000001)
+	if developer != 500000 || prizePool != 300000 || platform != 200001 {
+		t.Fatalf("SpendShares = %d/%d/%d, want 200001/500000/300000", platform, developer, prizePool)
+	}
+
+	platform, developer, prizePool = SpendShares(defaultFeeSchedule, 777)
+	if platform != 777 || developer != 0 || prizePool != 0 {
+		t.Fatalf("default schedule = %d/%d/%d, want all platform", platform, developer, prizePool)
+	}
+
+	// no overflow on the largest amounts
+	platform, developer, prizePool = SpendShares(s, math.MaxUint64)
+	if platform+developer+prizePool != math.MaxUint64 || developer != math.MaxUint64/2 {
+		t.Fatalf("max amount = %d/%d/%d", platform, developer, prizePool)
+	}
+}
+
+func TestValidateFeeSchedule(t *testing.T) {
+	cases := []struct {
+		s  model.FeeSchedule
+		ok bool
+	}{
+		{model.FeeSchedule{GameID: 1, PlatformBps: 2000, DeveloperBps: 7000, PrizePoolBps: 1000}, true},
+		{model.FeeSchedule{GameID: 1, Catalog: "pvp", PlatformBps: 10000}, true},
+		{model.FeeSchedule{GameID: 1, PlatformBps: 2000, DeveloperBps: 7000}, false},
+		{model.FeeSchedule{GameID: 1, PlatformBps: 11000, DeveloperBps: -1000}, false},
+		{model.FeeSchedule{Catalog: "pvp", PlatformBps: 10000}, false},
+	}
+	for _, c := range cases {
+		err := ValidateFeeSchedule(c.s)
+		if c.ok != (err == nil) || (err != nil && !errors.Is(err, ErrFeeScheduleInvalid)) {
+			t.Fatalf("ValidateFeeSchedule(%+v) = %v", c.s, err)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"chaos/api/model"
+	"chaos/api/system"
+	"errors"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+var (
+	ErrIdempotencyMismatch = errors.New("idempotency key reused with a different request")
+	ErrIdempotencyInFlight = errors.New("request with this idempotency key is still processing")
+)
+
+// IdempotencyKeyTTL is how long a key is remembered; a retry after that runs as a new request.
+const IdempotencyKeyTTL = 24 * time.Hour
+
+// IsDuplicateKey tells whether err is a MySQL unique key violation.
+func IsDuplicateKey(err error) bool {
+	var me *mysql.MySQLError
+	return errors.As(err, &me) && me.Number == 1062
+}
+
+// ClaimIdempotencyKey reserves key of scope for one request. When the key was already used for the
+// same request and has completed, the stored row is returned with replay set. Otherwise the caller
+// gets a fresh processing row and must complete or release it. The unique (scope, idem_key) index
+// makes the claim safe across api instances.
+func ClaimIdempotencyKey(scope, key, route, requestHash string) (row *model.IdempotencyKey, replay bool, err error) {
+	db := system.GetDb()
+	now := time.Now()
+	claim := model.IdempotencyKey{
+		Scope:       scope,
+		IdemKey:     key,
+		Route:       route,
+		RequestHash: requestHash,
+		Status:      model.IdempotencyStatusProcessing,
+		AddTime:     now,
+		UpdateTime:  now,
+	}
+	err = db.Create(&claim).Error
+	if err == nil {
+		return &claim, false, nil
+	}
+	if !IsDuplicateKey(err) {
+		return nil, false, err
+	}
+
+	var existing model.IdempotencyKey
+	if err := db.Where("scope = ? AND idem_key = ?", scope, key).First(&existing).Error; err != nil {
+		return nil, false, err
+	}
+	if existing.Route != route || existing.RequestHash != requestHash {
+		return nil, false, ErrIdempotencyMismatch
+	}
+	if existing.Status != model.IdempotencyStatusDone {
+		// the first request is still running, or died without an answer; never run it twice
+		return nil, false, ErrIdempotencyInFlight
+	}
+	return &existing, true, nil
+}
+
+// CompleteIdempotencyKey stores the response of a claimed key for later replays.
+func CompleteIdempotencyKey(id uint64, responseCode int, responseBody string) error {
+	return system.GetDb().Model(&model.IdempotencyKey{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":        model.IdempotencyStatusDone,
+		"response_code": responseCode,
+		"response_body": responseBody,
+		"update_time":   time.Now(),
+	}).Error
+}
+
+// ReleaseIdempotencyKey drops a claimed key whose request failed without moving money, so the
+// client can retry with the same key.
+func ReleaseIdempotencyKey(id uint64) error {
+	return system.GetDb().Where("id = ? AND status = ?", id, model.IdempotencyStatusProcessing).Delete(&model.IdempotencyKey{}).Error
+}
+
+// PurgeIdempotencyKeys deletes keys older than IdempotencyKeyTTL and returns how many were removed.
+func PurgeIdempotencyKeys() (int64, error) {
+	res := system.GetDb().Where("add_time < ?", time.Now().Add(-IdempotencyKeyTTL)).Delete(&model.IdempotencyKey{})
+	return res.RowsAffected, res.Error
+}
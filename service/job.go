@@ -0,0 +1,66 @@
+package service
+
+import (
+	"chaos/api/model"
+	"chaos/api/system"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrJobNotFound = errors.New("job not found")
+	ErrJobNotDead  = errors.New("only dead jobs can be retried")
+)
+
+const AuditJobRetry = "job.retry"
+
+// ListJobs pages through the durable queue jobs, optionally of one queue and status.
+func ListJobs(queue, status string, page, limit int) ([]model.Job, int64, error) {
+	query := system.GetDb().Model(&model.Job{})
+	if queue != "" {
+		query = query.Where("queue = ?", queue)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var rows []model.Job
+	err := query.Order("id desc").Offset((page - 1) * limit).Limit(limit).Find(&rows).Error
+	return rows, total, err
+}
+
+// RetryJob puts a dead-lettered job back in its queue with a fresh set of attempts.
+func RetryJob(operatorID, id uint64) (*model.Job, error) {
+	var job model.Job
+	err := system.GetDb().Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&job).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrJobNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if job.Status != model.JobStatusDead {
+			return ErrJobNotDead
+		}
+		detail := map[string]interface{}{"attempts": job.Attempts, "last_error": job.LastError}
+		job.Status = model.JobStatusPending
+		job.Attempts = 0
+		job.RunAt = time.Now()
+		job.UpdateTime = job.RunAt
+		if err := tx.Save(&job).Error; err != nil {
+			return err
+		}
+		return RecordAudit(tx, operatorID, AuditJobRetry, model.TB_JOB, job.ID, detail)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
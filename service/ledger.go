@@ -0,0 +1,139 @@
+package service
+
+import (
+	"chaos/api/model"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrInsufficientBalance = errors.New("insufficient balance")
+
+// movement is the debit/credit account pair of one kind of balance change.
+type movement struct {
+	Debit  string
+	Credit string
+}
+
+var movements = map[string]movement{
+	model.LedgerBizRecharge:        {Debit: model.LedgerAccountCustody, Credit: model.LedgerAccountAvailable},
+	model.LedgerBizRechargeReorg:   {Debit: model.LedgerAccountAvailable, Credit: model.LedgerAccountCustody},
+	model.LedgerBizFreeze:          {Debit: model.LedgerAccountAvailable, Credit: model.LedgerAccountFrozen},
+	model.LedgerBizUnfreeze:        {Debit: model.LedgerAccountFrozen, Credit: model.LedgerAccountAvailable},
+	model.LedgerBizSpend:           {Debit: model.LedgerAccountFrozen, Credit: model.LedgerAccountRevenue},
+	model.LedgerBizSpendDeveloper:  {Debit: model.LedgerAccountFrozen, Credit: model.LedgerAccountDeveloper},
+	model.LedgerBizSpendPrizePool:  {Debit: model.LedgerAccountFrozen, Credit: model.LedgerAccountPrizePool},
+	model.LedgerBizWithdrawLock:    {Debit: model.LedgerAccountAvailable, Credit: model.LedgerAccountWithdrawal},
+	model.LedgerBizWithdrawCancel:  {Debit: model.LedgerAccountWithdrawal, Credit: model.LedgerAccountAvailable},
+	model.LedgerBizWithdrawClaim:   {Debit: model.LedgerAccountWithdrawal, Credit: model.LedgerAccountCustody},
+	model.LedgerBizWithdrawRevoke:  {Debit: model.LedgerAccountWithdrawal, Credit: model.LedgerAccountAvailable},
+	model.LedgerBizWithdrawExpire:  {Debit: model.LedgerAccountWithdrawal, Credit: model.LedgerAccountAvailable},
+	model.LedgerBizRefund:          {Debit: model.LedgerAccountRevenue, Credit: model.LedgerAccountAvailable},
+	model.LedgerBizRefundFreeze:    {Debit: model.LedgerAccountFrozen, Credit: model.LedgerAccountAvailable},
+	model.LedgerBizRefundDeveloper: {Debit: model.LedgerAccountDeveloper, Credit: model.LedgerAccountAvailable},
+	model.LedgerBizRefundPrizePool: {Debit: model.LedgerAccountPrizePool, Credit: model.LedgerAccountAvailable},
+	model.LedgerBizAdjustCredit:    {Debit: model.LedgerAccountAdjust, Credit: model.LedgerAccountAvailable},
+	model.LedgerBizAdjustDebit:     {Debit: model.LedgerAccountAvailable, Credit: model.LedgerAccountAdjust},
+}
+
+// LedgerRef points a journal entry back to the flow row that caused it.
+type LedgerRef struct {
+	Table  string
+	ID     uint64
+	Remark string
+}
+
+// bucket returns the balance field backing a user ledger account.
+func bucket(bal *model.AccountBalance, account string) *uint64 {
+	switch account {
+	case model.LedgerAccountAvailable:
+		return &bal.Available
+	case model.LedgerAccountFrozen:
+		return &bal.Frozen
+	case model.LedgerAccountWithdrawal:
+		return &bal.Withdrawal
+	}
+	return nil
+}
+
+// applyMovement moves amount between the buckets of bal without touching the db.
+// Platform accounts have no bucket and only appear in the journal.
+func applyMovement(bal *model.AccountBalance, m movement, amount uint64) error {
+	if from := bucket(bal, m.Debit); from != nil {
+		if *from < amount {
+			return ErrInsufficientBalance
+		}
+		*from -= amount
+	}
+	if to := bucket(bal, m.Credit); to != nil {
+		*to += amount
+	}
+	return nil
+}
+
+// postingsFor builds the two balanced legs of a movement. User accounts carry the owner's
+// main_id, platform accounts are posted with main_id 0.
+func postingsFor(mainID, assetID uint64, m movement, amount uint64, now time.Time) []model.LedgerPosting {
+	owner := func(account string) uint64 {
+		if model.IsUserLedgerAccount(account) {
+			return mainID
+		}
+		return 0
+	}
+	return []model.LedgerPosting{
+		{MainID: owner(m.Debit), AssetID: assetID, Account: m.Debit, Debit: amount, AddTime: now},
+		{MainID: owner(m.Credit), AssetID: assetID, Account: m.Credit, Credit: amount, AddTime: now},
+	}
+}
+
+// PostBalanceChange applies one ledger movement to a balance row and journals it in tx.
+// The caller must already hold the row lock on bal (SELECT ... FOR UPDATE) and owns commit/rollback.
+func PostBalanceChange(tx *gorm.DB, bal *model.AccountBalance, bizType string, amount uint64, ref LedgerRef) error {
+	m, ok := movements[bizType]
+	if !ok {
+		return fmt.Errorf("unknown ledger biz type: %s", bizType)
+	}
+	if amount == 0 {
+		return nil
+	}
+	if err := applyMovement(bal, m, amount); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	bal.UpdateTime = now
+	if err := tx.Save(bal).Error; err != nil {
+		return err
+	}
+	return writeJournal(tx, model.LedgerEntry{
+		BizType:  bizType,
+		MainID:   bal.MainID,
+		AssetID:  bal.AssetID,
+		Amount:   amount,
+		RefTable: ref.Table,
+		RefID:    ref.ID,
+		Remark:   ref.Remark,
+		AddTime:  now,
+	}, postingsFor(bal.MainID, bal.AssetID, m, amount, now))
+}
+
+func writeJournal(tx *gorm.DB, entry model.LedgerEntry, legs []model.LedgerPosting) error {
+	var debit, credit uint64
+	for _, leg := range legs {
+		debit += leg.Debit
+		credit += leg.Credit
+	}
+	if debit != credit {
+		return fmt.Errorf("unbalanced journal entry %s: debit %d != credit %d", entry.BizType, debit, credit)
+	}
+
+	if err := tx.Create(&entry).Error; err != nil {
+		return err
+	}
+	for i := range legs {
+		legs[i].EntryID = entry.ID
+	}
+	return tx.Create(&legs).Error
+}
@@ -0,0 +1,195 @@
+package service
+
+import (
+	"chaos/api/log"
+	"chaos/api/model"
+	"chaos/api/system"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var userLedgerAccounts = []string{model.LedgerAccountAvailable, model.LedgerAccountFrozen, model.LedgerAccountWithdrawal}
+
+// LedgerReport is the outcome of rebuilding every balance from the journal.
+type LedgerReport struct {
+	CheckedAccounts   int                 `json:"checked_accounts"`
+	Drifts            []model.LedgerDrift `json:"drifts"`
+	UnbalancedEntries []uint64            `json:"unbalanced_entries"`
+}
+
+type journalNet struct {
+	MainID  uint64
+	AssetID uint64
+	Account string
+	Net     decimal.Decimal
+}
+
+type balanceKey struct {
+	MainID  uint64
+	AssetID uint64
+	Account string
+}
+
+// CheckLedger rebuilds every user balance bucket from n_ledger_posting, compares it with
+// n_account_balance and stores the result as a n_ledger_check row.
+func CheckLedger() (*model.LedgerCheck, *LedgerReport, error) {
+	db := system.GetDb()
+	report := &LedgerReport{Drifts: []model.LedgerDrift{}, UnbalancedEntries: []uint64{}}
+
+	// every read sees one snapshot: a balance changing between them would show up as drift
+	journal := map[balanceKey]decimal.Decimal{}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.LedgerPosting{}).
+			Select("entry_id").
+			Group("entry_id").
+			Having("SUM(debit) <> SUM(credit)").
+			Pluck("entry_id", &report.UnbalancedEntries).Error; err != nil {
+			return err
+		}
+
+		var nets []journalNet
+		if err := tx.Model(&model.LedgerPosting{}).
+			Select("main_id, asset_id, account, CAST(SUM(credit) AS DECIMAL(65,0)) - CAST(SUM(debit) AS DECIMAL(65,0)) AS net").
+			Where("account IN ?", userLedgerAccounts).
+			Group("main_id, asset_id, account").
+			Scan(&nets).Error; err != nil {
+			return err
+		}
+		for _, n := range nets {
+			journal[balanceKey{n.MainID, n.AssetID, n.Account}] = n.Net
+		}
+
+		var balances []model.AccountBalance
+		return tx.Model(&model.AccountBalance{}).FindInBatches(&balances, 500, func(_ *gorm.DB, _ int) error {
+			for i := range balances {
+				for _, account := range userLedgerAccounts {
+					key := balanceKey{balances[i].MainID, balances[i].AssetID, account}
+					stored := decimal.NewFromUint64(*bucket(&balances[i], account))
+					expected := journal[key]
+					delete(journal, key)
+					report.CheckedAccounts++
+					if !stored.Equal(expected) {
+						report.Drifts = append(report.Drifts, model.LedgerDrift{
+							MainID:   key.MainID,
+							AssetID:  key.AssetID,
+							Account:  account,
+							Stored:   stored,
+							Journal:  expected,
+							Variance: stored.Sub(expected),
+						})
+					}
+				}
+			}
+			return nil
+		}).Error
+	}, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// journal buckets that have no balance row at all
+	for key, expected := range journal {
+		report.CheckedAccounts++
+		if expected.IsZero() {
+			continue
+		}
+		report.Drifts = append(report.Drifts, model.LedgerDrift{
+			MainID:   key.MainID,
+			AssetID:  key.AssetID,
+			Account:  key.Account,
+			Stored:   decimal.Zero,
+			Journal:  expected,
+			Variance: expected.Neg(),
+		})
+	}
+
+	detail, _ := json.Marshal(report)
+	check := &model.LedgerCheck{
+		CheckedAccounts:   report.CheckedAccounts,
+		DriftCount:        len(report.Drifts),
+		UnbalancedEntries: len(report.UnbalancedEntries),
+		Detail:            string(detail),
+		AddTime:           time.Now(),
+	}
+	if err := db.Create(check).Error; err != nil {
+		return nil, nil, err
+	}
+
+	if check.DriftCount > 0 || check.UnbalancedEntries > 0 {
+		log.Errorf("[LedgerCheck] %d: drift found in %d buckets, %d unbalanced entries", check.ID, check.DriftCount, check.UnbalancedEntries)
+	} else {
+		log.Infof("[LedgerCheck] %d: %d buckets consistent", check.ID, check.CheckedAccounts)
+	}
+	return check, report, nil
+}
+
+// OpenLedgerBalances journals the current value of every balance row that has no postings yet,
+// so balances created before the journal existed can be checked too. It returns the rows opened.
+func OpenLedgerBalances() (int, error) {
+	db := system.GetDb()
+
+	var pending []model.AccountBalance
+	err := db.Model(&model.AccountBalance{}).
+		Where("NOT EXISTS (SELECT 1 FROM n_ledger_posting p WHERE p.main_id = n_account_balance.main_id AND p.asset_id = n_account_balance.asset_id AND p.account IN ?)", userLedgerAccounts).
+		Find(&pending).Error
+	if err != nil {
+		return 0, err
+	}
+
+	opened := 0
+	for _, row := range pending {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var bal model.AccountBalance
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", row.ID).First(&bal).Error; err != nil {
+				return err
+			}
+			var posted int64
+			if err := tx.Model(&model.LedgerPosting{}).
+				Where("main_id = ? AND asset_id = ? AND account IN ?", bal.MainID, bal.AssetID, userLedgerAccounts).
+				Count(&posted).Error; err != nil {
+				return err
+			}
+			if posted > 0 {
+				return nil
+			}
+
+			now := time.Now()
+			var total uint64
+			var legs []model.LedgerPosting
+			for _, account := range userLedgerAccounts {
+				v := *bucket(&bal, account)
+				if v == 0 {
+					continue
+				}
+				total += v
+				legs = append(legs, model.LedgerPosting{MainID: bal.MainID, AssetID: bal.AssetID, Account: account, Credit: v, AddTime: now})
+			}
+			if total == 0 {
+				return nil
+			}
+			legs = append(legs, model.LedgerPosting{AssetID: bal.AssetID, Account: model.LedgerAccountOpening, Debit: total, AddTime: now})
+
+			opened++
+			return writeJournal(tx, model.LedgerEntry{
+				BizType:  model.LedgerBizOpening,
+				MainID:   bal.MainID,
+				AssetID:  bal.AssetID,
+				Amount:   total,
+				RefTable: model.LedgerRefAccountBalance,
+				RefID:    bal.ID,
+				Remark:   fmt.Sprintf("opening balance at %s", now.Format(time.RFC3339)),
+				AddTime:  now,
+			}, legs)
+		})
+		if err != nil {
+			return opened, err
+		}
+	}
+	return opened, nil
+}
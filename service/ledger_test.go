@@ -0,0 +1,61 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"chaos/api/model"
+)
+
+func TestMovementsAreBalanced(t *testing.T) {
+	for biz, m := range movements {
+		legs := postingsFor(7, 0, m, 100, time.Now())
+		var debit, credit uint64
+		for _, leg := range legs {
+			debit += leg.Debit
+			credit += leg.Credit
+			if model.IsUserLedgerAccount(leg.Account) && leg.MainID != 7 {
+				t.Fatalf("%s: user account %s posted to main_id %d", biz, leg.Account, leg.MainID)
+			}
+			if !model.IsUserLedgerAccount(leg.Account) && leg.MainID != 0 {
+				t.Fatalf("%s: platform account %s posted to main_id %d", biz, leg.Account, leg.MainID)
+			}
+		}
+		if debit != credit {
+			t.Fatalf("%s: debit %d != credit %d", biz, debit, credit)
+		}
+	}
+}
+
+func TestApplyMovement(t *testing.T) {
+	bal := model.AccountBalance{Available: 100}
+
+	steps := []struct {
+		biz    string
+		amount uint64
+	}{
+		{model.LedgerBizRecharge, 50},
+		{model.LedgerBizFreeze, 30},
+		{model.LedgerBizSpend, 20},
+		{model.LedgerBizUnfreeze, 10},
+		{model.LedgerBizWithdrawLock, 40},
+		{model.LedgerBizWithdrawCancel, 15},
+		{model.LedgerBizWithdrawClaim, 25},
+	}
+	for _, s := range steps {
+		if err := applyMovement(&bal, movements[s.biz], s.amount); err != nil {
+			t.Fatalf("%s: %v", s.biz, err)
+		}
+	}
+	if bal.Available != 105 || bal.Frozen != 0 || bal.Withdrawal != 0 {
+		t.Fatalf("unexpected balance %+v", bal)
+	}
+
+	if err := applyMovement(&bal, movements[model.LedgerBizSpend], 1); !errors.Is(err, ErrInsufficientBalance) {
+		t.Fatalf("expected insufficient balance, got %v", err)
+	}
+	if bal.Frozen != 0 || bal.Available != 105 {
+		t.Fatalf("failed movement changed balance %+v", bal)
+	}
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"chaos/api/chain"
+	"chaos/api/log"
+	"chaos/api/model"
+	"chaos/api/system"
+	"chaos/api/tools"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrLockNotOpen = errors.New("withdraw lock is no longer open")
+
+// expiredLockBatch bounds the locks looked at per run; each may cost a few dozen rpc calls.
+const expiredLockBatch = 50
+
+// lockWatchCursor is the id of the last lock WatchExpiredLocks looked at. Each run resumes after it
+// and it wraps around at the end, so locks that keep failing cannot hold back the later ones.
+var lockWatchCursor atomic.Uint64
+
+// WatchExpiredLocks looks at the withdraw locks past their expiry that are still open in the
+// ledger. Once the newest confirmed block is past the expiry nobody can open the lock any more,
+// so a lock never opened on chain gives its amount back to Available. A lock that was opened is
+// marked lock_expired: its funds stay held until the user's cancelLock or a revokeLock settles it.
+func WatchExpiredLocks(ctx context.Context) {
+	var locks []model.AccountBalanceFlow
+	err := system.GetDb().Model(&model.AccountBalanceFlow{}).
+		Where("op = ? AND status IN ? AND lock_expiry < ? AND id > ?", model.BalanceFlowOpFreeze,
+			[]int{model.BalanceFlowStatusPending, model.BalanceFlowStatusPendingWithDraw}, time.Now(), lockWatchCursor.Load()).
+		Order("id").Limit(expiredLockBatch).Find(&locks).Error
+	if err != nil {
+		log.Error("[LockWatcher] query expired locks failed", err)
+		return
+	}
+	if len(locks) < expiredLockBatch {
+		// the end is reached: start from the first lock again next run
+		defer lockWatchCursor.Store(0)
+	}
+	for _, lock := range locks {
+		if ctx.Err() != nil {
+			return
+		}
+		lockWatchCursor.Store(lock.ID)
+		if err := handleExpiredLock(ctx, lock); err != nil {
+			log.Errorf("[LockWatcher] lock flow %d: %v", lock.ID, err)
+		}
+	}
+}
+
+func handleExpiredLock(ctx context.Context, lock model.AccountBalanceFlow) error {
+	if lock.LockID == "" || lock.LockAddr == "" || lock.LockExpiry == nil {
+		return errors.New("lock flow misses its lock id, address or expiry")
+	}
+
+	// locks signed before the flow kept its chain may live on any chain with a contract
+	chains := chain.DepositChains()
+	if id, err := strconv.ParseUint(lock.ChainID, 10, 64); err == nil && id > 0 {
+		c, err := tools.LookupChain(id)
+		if err != nil {
+			return err
+		}
+		chains = []*tools.EvmChain{c}
+	}
+
+	for _, c := range chains {
+		state, err := chain.ReadLockState(ctx, c.ChainID, c.GetTopupContract(), lock.LockAddr, lock.LockID, lock.AddTime.Add(-time.Minute))
+		if err != nil {
+			return fmt.Errorf("chain %d: %w", c.ChainID, err)
+		}
+		if !state.BlockTime.After(*lock.LockExpiry) {
+			// an openLock may still land: wait for a confirmed block past the expiry
+			return nil
+		}
+		if state.Opened {
+			return markLockExpired(lock, c.ChainID, state)
+		}
+	}
+	return restoreExpiredLock(lock.ID)
+}
+
+// restoreExpiredLock gives the amount of a lock that was never opened on chain back to Available.
+func restoreExpiredLock(lockFlowID uint64) error {
+	var lock model.AccountBalanceFlow
+	err := system.GetDb().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", lockFlowID).First(&lock).Error; err != nil {
+			return err
+		}
+		if lock.Op != model.BalanceFlowOpFreeze ||
+			(lock.Status != model.BalanceFlowStatusPending && lock.Status != model.BalanceFlowStatusPendingWithDraw) {
+			return ErrLockNotOpen
+		}
+
+		var bal model.AccountBalance
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("main_id = ? AND asset_id = ?", lock.MainID, lock.AssetID).
+			First(&bal).Error; err != nil {
+			return err
+		}
+		err := PostBalanceChange(tx, &bal, model.LedgerBizWithdrawExpire, lock.RealAmount, LedgerRef{
+			Table:  model.LedgerRefAccountBalanceFlow,
+			ID:     lock.ID,
+			Remark: fmt.Sprintf("lock %s expired unopened", lock.LockID),
+		})
+		if err != nil {
+			return err
+		}
+
+		lock.Status = model.BalanceFlowStatusCanceled
+		lock.UpdateTime = time.Now()
+		return tx.Save(&lock).Error
+	})
+	if errors.Is(err, ErrLockNotOpen) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	log.Infof("[LockWatcher] lock flow %d of user %d expired unopened, %d returned to available", lock.ID, lock.MainID, lock.RealAmount)
+	return nil
+}
+
+// markLockExpired records that a lock opened on chain has expired. Its funds stay in Withdrawal:
+// they are still locked in the contract until the lock is canceled or revoked there.
+func markLockExpired(lock model.AccountBalanceFlow, chainID uint64, state *chain.LockState) error {
+	res := system.GetDb().Model(&model.AccountBalanceFlow{}).
+		Where("id = ? AND status IN ?", lock.ID, []int{model.BalanceFlowStatusPending, model.BalanceFlowStatusPendingWithDraw}).
+		Updates(map[string]interface{}{
+			"status":      model.BalanceFlowStatusLockExpired,
+			"chain_id":    fmt.Sprintf("%d", chainID),
+			"update_time": time.Now(),
+		})
+	if res.Error != nil || res.RowsAffected == 0 {
+		return res.Error
+	}
+
+	detail := map[string]interface{}{
+		"flow_id": lock.ID, "main_id": lock.MainID, "chain_id": chainID, "lock_id": lock.LockID,
+		"amount": state.Amount.String(), "claimed": state.Claimed, "block": state.BlockNumber,
+	}
+	switch {
+	case state.Claimed:
+		// paid out on chain while the ledger still holds it: the claim report never came
+		Alert(model.ContractEventSeverityCritical, "expired withdraw lock was claimed but never settled", detail)
+	case state.Amount.Sign() == 0:
+		// canceled or revoked on chain; settled once its tx is reported or indexed
+		Alert(model.ContractEventSeverityWarning, "expired withdraw lock was closed on chain but not settled", detail)
+	default:
+		log.Infof("[LockWatcher] lock flow %d of user %d expired with %s still locked on chain %d", lock.ID, lock.MainID, state.Amount, chainID)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"chaos/api/model"
+	"chaos/api/system"
+	"errors"
+	"fmt"
+	"slices"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ErrOperatorNotFound = errors.New("operator not found")
+
+// minOperatorPassword is the shortest password an operator, who can move funds, may set.
+const minOperatorPassword = 12
+
+// CheckOperatorPassword tells whether password is the one whose bcrypt hash op stores. Rows still
+// holding a plaintext password never match and must be reset with SetOperatorPassword.
+func CheckOperatorPassword(op model.SysOperator, password string) bool {
+	return op.Password != "" && bcrypt.CompareHashAndPassword([]byte(op.Password), []byte(password)) == nil
+}
+
+// SetOperatorPassword stores the bcrypt hash of password for the operator with email, and its
+// role when role is not empty.
+func SetOperatorPassword(email, password, role string) error {
+	if len(password) < minOperatorPassword {
+		return fmt.Errorf("password must have at least %d characters", minOperatorPassword)
+	}
+	updates := map[string]interface{}{}
+	if role != "" {
+		if !slices.Contains([]string{model.OperatorRoleOperator, model.OperatorRoleFinance, model.OperatorRoleAdmin}, role) {
+			return fmt.Errorf("unknown role %q", role)
+		}
+		updates["role"] = role
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	updates["password"] = string(hash)
+	res := system.GetDb().Model(&model.SysOperator{}).Where("email = ?", email).Updates(updates)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("%w: %s", ErrOperatorNotFound, email)
+	}
+	return nil
+}
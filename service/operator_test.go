@@ -0,0 +1,27 @@
+package service
+
+import (
+	"testing"
+
+	"chaos/api/model"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestCheckOperatorPassword(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("correct horse battery"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	op := model.SysOperator{Password: string(hash)}
+	if !CheckOperatorPassword(op, "correct horse battery") {
+		t.Fatal("right password refused")
+	}
+	if CheckOperatorPassword(op, "wrong") {
+		t.Fatal("wrong password accepted")
+	}
+	// a plaintext row never matches, not even its own value
+	if CheckOperatorPassword(model.SysOperator{Password: "plain"}, "plain") || CheckOperatorPassword(model.SysOperator{}, "") {
+		t.Fatal("plaintext password accepted")
+	}
+}
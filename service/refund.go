@@ -0,0 +1,129 @@
+package service
+
+import (
+	"chaos/api/model"
+	"chaos/api/system"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrFlowNotFound      = errors.New("flow not found")
+	ErrFlowNotRefundable = errors.New("flow can not be refunded")
+	ErrRefundForbidden   = errors.New("flow belongs to another client")
+	ErrRefundExpired     = errors.New("refund window has passed")
+)
+
+// RefundRequest describes who asks to give a flow back and within which limits.
+// ClientID and MainID restrict the refund to flows of that game client / user; MaxAge
+// rejects flows older than the window. Zero values mean no restriction (operators).
+type RefundRequest struct {
+	FlowID   uint64
+	ClientID string
+	MainID   uint64
+	MaxAge   time.Duration
+	Remark   string
+}
+
+// refundMovement picks the ledger movement that reverses a flow: a finished spend is paid
+// back out of revenue, a freeze still pending is released back to available.
+func refundMovement(flow *model.AccountFlow) (string, error) {
+	switch {
+	case flow.BizType == model.FlowSpend && flow.Status == model.FlowStatusDone:
+		return model.LedgerBizRefund, nil
+	case flow.BizType == model.FlowFreeze && flow.Status == model.FlowStatusPending:
+		return model.LedgerBizRefundFreeze, nil
+	}
+	return "", ErrFlowNotRefundable
+}
+
+// RefundFlow reverses a spend or a stuck freeze: it marks the original flow reversed, writes a
+// FlowRefund row pointing back to it, restores the user's available balance and marks the game
+// session reversed. A flow can only be refunded once.
+func RefundFlow(req RefundRequest) (*model.AccountFlow, error) {
+	var refund model.AccountFlow
+	err := system.GetDb().Transaction(func(tx *gorm.DB) error {
+		var flow model.AccountFlow
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", req.FlowID).First(&flow).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrFlowNotFound
+			}
+			return err
+		}
+		if req.MainID != 0 && flow.MainID != req.MainID {
+			return ErrFlowNotFound
+		}
+		if req.ClientID != "" && flow.ClientID != req.ClientID {
+			return ErrRefundForbidden
+		}
+		if req.MaxAge > 0 && time.Since(flow.AddTime) > req.MaxAge {
+			return ErrRefundExpired
+		}
+		bizType, err := refundMovement(&flow)
+		if err != nil {
+			return err
+		}
+
+		var bal model.AccountBalance
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("main_id = ? AND asset_id = ?", flow.MainID, flow.AssetID).
+			First(&bal).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		refund = model.AccountFlow{
+			MainID:         flow.MainID,
+			AssetID:        flow.AssetID,
+			BizType:        model.FlowRefund,
+			Amount:         flow.Amount,
+			Direction:      model.DirectionIn,
+			ClientID:       flow.ClientID,
+			GameID:         flow.GameID,
+			ExternalID:     flow.ExternalID,
+			ExternalRemark: req.Remark,
+			RefFlowID:      flow.ID,
+			Status:         model.FlowStatusDone,
+			AddTime:        now,
+			UpdateTime:     now,
+			SessionID:      flow.SessionID,
+		}
+		if err := tx.Create(&refund).Error; err != nil {
+			return err
+		}
+
+		flow.Status = model.FlowStatusReversed
+		flow.UpdateTime = now
+		if err := tx.Save(&flow).Error; err != nil {
+			return err
+		}
+
+		ref := LedgerRef{
+			Table:  model.LedgerRefAccountFlow,
+			ID:     refund.ID,
+			Remark: req.Remark,
+		}
+		if bizType == model.LedgerBizRefund {
+			err = refundSpend(tx, &bal, &flow, ref)
+		} else {
+			err = PostBalanceChange(tx, &bal, bizType, flow.Amount, ref)
+		}
+		if err != nil {
+			return err
+		}
+
+		if flow.SessionID == "" {
+			return nil
+		}
+		return tx.Model(&model.GameSession{}).
+			Where("session_id = ?", flow.SessionID).
+			Updates(map[string]interface{}{"status": model.GameSessionStatusReversed, "end_time": now}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &refund, nil
+}
@@ -0,0 +1,39 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"chaos/api/model"
+)
+
+func TestRefundMovement(t *testing.T) {
+	cases := []struct {
+		flow model.AccountFlow
+		want string
+		err  error
+	}{
+		{model.AccountFlow{BizType: model.FlowSpend, Status: model.FlowStatusDone}, model.LedgerBizRefund, nil},
+		{model.AccountFlow{BizType: model.FlowFreeze, Status: model.FlowStatusPending}, model.LedgerBizRefundFreeze, nil},
+		{model.AccountFlow{BizType: model.FlowSpend, Status: model.FlowStatusReversed}, "", ErrFlowNotRefundable},
+		{model.AccountFlow{BizType: model.FlowFreeze, Status: model.FlowStatusDone}, "", ErrFlowNotRefundable},
+		{model.AccountFlow{BizType: model.FlowRefund, Status: model.FlowStatusDone}, "", ErrFlowNotRefundable},
+	}
+	for _, c := range cases {
+		got, err := refundMovement(&c.flow)
+		if got != c.want || !errors.Is(err, c.err) {
+			t.Fatalf("refundMovement(%d/%d) = %q, %v; want %q, %v", c.flow.BizType, c.flow.Status, got, err, c.want, c.err)
+		}
+	}
+
+	bal := model.AccountBalance{Available: 10, Frozen: 5}
+	if err := applyMovement(&bal, movements[model.LedgerBizRefundFreeze], 5); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyMovement(&bal, movements[model.LedgerBizRefund], 7); err != nil {
+		t.Fatal(err)
+	}
+	if bal.Available != 22 || bal.Frozen != 0 {
+		t.Fatalf("unexpected balance %+v", bal)
+	}
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"chaos/api/chain"
+	"chaos/api/log"
+	"chaos/api/model"
+	"chaos/api/system"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrNotReorged = errors.New("flow is no longer a credited recharge of that block")
+
+// CheckReorgs re-reads the receipts of the recharges credited within the reorg window of every
+// EVM chain, and reverses the credit of those a reorg dropped or moved to another block.
+func CheckReorgs(ctx context.Context) {
+	for _, c := range chain.EvmChains() {
+		if ctx.Err() != nil {
+			return
+		}
+		head, err := chain.LatestBlock(ctx, c.ChainID)
+		if err != nil {
+			log.Errorf("[ReorgCheck] chain %s (%d): %v", c.Name, c.ChainID, err)
+			continue
+		}
+		from := uint64(0)
+		if window := c.GetReorgWindow(); head > window {
+			from = head - window
+		}
+
+		var flows []model.AccountBalanceFlow
+		if err := system.GetDb().Model(&model.AccountBalanceFlow{}).
+			Where("chain_id = ? AND op = ? AND status = ? AND block_height >= ? AND block_hash <> ''",
+				fmt.Sprintf("%d", c.ChainID), model.BalanceFlowOpRecharge, model.BalanceFlowStatusSuccess, from).
+			Find(&flows).Error; err != nil {
+			log.Errorf("[ReorgCheck] chain %d: %v", c.ChainID, err)
+			continue
+		}
+		for _, f := range flows {
+			inc, err := chain.LookupTxInclusion(ctx, c.ChainID, f.TxHash)
+			if err != nil {
+				log.Errorf("[ReorgCheck] tx %s: %v", f.TxHash, err)
+				continue
+			}
+			if inc.Found && inc.Success && strings.EqualFold(inc.BlockHash, f.BlockHash) {
+				continue
+			}
+			if err := ReverseReorgedCredit(f.ID, f.BlockHash); err != nil {
+				Alert(model.ContractEventSeverityCritical, "reorged recharge could not be reversed", map[string]interface{}{
+					"flow_id": f.ID, "main_id": f.MainID, "tx_hash": f.TxHash, "error": err.Error(),
+				})
+				continue
+			}
+			Alert(model.ContractEventSeverityWarning, "recharge reversed after a reorg", map[string]interface{}{
+				"flow_id": f.ID, "main_id": f.MainID, "tx_hash": f.TxHash, "old_block": f.BlockHash, "new_block": inc.BlockHash,
+			})
+			// settle it again once the tx is confirmed in its new block, if it ever is
+			if chain.IsNativeAsset(c.ChainID, f.AssetID) {
+				chain.AppendNativeTopupTx(c.ChainID, f.TxHash, f.MainID, f.ID)
+			} else {
+				chain.AppendTopupTx(c.ChainID, f.TxHash, f.MainID, f.ID, model.BalanceFlowOpRecharge)
+			}
+		}
+	}
+}
+
+// ReverseReorgedCredit takes back the credit of a recharge that was confirmed in blockHash and
+// puts the flow back to pending, so it is credited again only when its tx is confirmed anew.
+func ReverseReorgedCredit(flowID uint64, blockHash string) error {
+	return system.GetDb().Transaction(func(tx *gorm.DB) error {
+		var flow model.AccountBalanceFlow
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", flowID).First(&flow).Error; err != nil {
+			return err
+		}
+		if flow.Op != model.BalanceFlowOpRecharge || flow.Status != model.BalanceFlowStatusSuccess || !strings.EqualFold(flow.BlockHash, blockHash) {
+			return ErrNotReorged
+		}
+
+		var bal model.AccountBalance
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("main_id = ? AND asset_id = ?", flow.MainID, flow.AssetID).
+			First(&bal).Error; err != nil {
+			return err
+		}
+		err := PostBalanceChange(tx, &bal, model.LedgerBizRechargeReorg, flow.RealAmount, LedgerRef{
+			Table:  model.LedgerRefAccountBalanceFlow,
+			ID:     flow.ID,
+			Remark: fmt.Sprintf("block %s reorged", blockHash),
+		})
+		if err != nil {
+			return err
+		}
+
+		flow.Status = model.BalanceFlowStatusPending
+		flow.RealAmount = 0
+		flow.BlockHash = ""
+		flow.BlockHeight = 0
+		flow.UpdateTime = time.Now()
+		return tx.Save(&flow).Error
+	})
+}
@@ -0,0 +1,147 @@
+package service
+
+import (
+	"chaos/api/config"
+	"chaos/api/log"
+	"chaos/api/model"
+	"chaos/api/system"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultSessionTimeout = 30 * time.Minute
+	sweepBatchSize        = 200
+)
+
+// SessionTimeout is how long a session of game may stay open before its freeze is released.
+func SessionTimeout(game model.GameInfo) time.Duration {
+	if game.SessionTimeout > 0 {
+		return time.Duration(game.SessionTimeout) * time.Second
+	}
+	if t := config.GetConfig().Session.Timeout; t > 0 {
+		return time.Duration(t) * time.Second
+	}
+	return defaultSessionTimeout
+}
+
+// SweepAbandonedSessions releases the freezes of game sessions that were started but never
+// ended within their game's timeout. It returns the number of sessions released.
+func SweepAbandonedSessions() (int, error) {
+	db := system.GetDb()
+
+	// nothing can be older than the shortest allowed timeout
+	cutoff := time.Now().Add(-time.Duration(model.MinSessionTimeout) * time.Second)
+	games := map[uint64]model.GameInfo{}
+	released := 0
+	// page by id: freezes of long-timeout games stay behind and must not hide the later ones
+	for lastID := uint64(0); ; {
+		var freezes []model.AccountFlow
+		if err := db.Model(&model.AccountFlow{}).
+			Where("biz_type = ? AND status = ? AND session_id <> '' AND add_time < ? AND id > ?",
+				model.FlowFreeze, model.FlowStatusPending, cutoff, lastID).
+			Order("id").Limit(sweepBatchSize).
+			Find(&freezes).Error; err != nil {
+			return released, err
+		}
+
+		for _, freeze := range freezes {
+			lastID = freeze.ID
+			game, ok := games[freeze.GameID]
+			if !ok {
+				if err := db.Model(&model.GameInfo{}).Where("id = ?", freeze.GameID).First(&game).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+					return released, err
+				}
+				games[freeze.GameID] = game
+			}
+			if time.Since(freeze.AddTime) < SessionTimeout(game) {
+				continue
+			}
+
+			ok, err := releaseAbandonedFreeze(freeze.ID)
+			if err != nil {
+				log.Error("[SessionSweep] release freeze failed", freeze.ID, err)
+				continue
+			}
+			if ok {
+				released++
+				log.Infof("[SessionSweep] released freeze %d of session %s (%d)", freeze.ID, freeze.SessionID, freeze.Amount)
+			}
+		}
+		if len(freezes) < sweepBatchSize {
+			break
+		}
+	}
+	return released, nil
+}
+
+// releaseAbandonedFreeze unfreezes one pending session freeze. It takes the same locks in the same
+// order as GameEndHandler (freeze flow, then balance), so whichever runs first wins and the other
+// finds the freeze no longer pending. It reports false when the session was ended meanwhile.
+func releaseAbandonedFreeze(freezeID uint64) (bool, error) {
+	released := false
+	err := system.GetDb().Transaction(func(tx *gorm.DB) error {
+		var freeze model.AccountFlow
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND biz_type = ? AND status = ?", freezeID, model.FlowFreeze, model.FlowStatusPending).
+			First(&freeze).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		var bal model.AccountBalance
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("main_id = ? AND asset_id = ?", freeze.MainID, freeze.AssetID).
+			First(&bal).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		unfreeze := model.AccountFlow{
+			MainID:         freeze.MainID,
+			AssetID:        freeze.AssetID,
+			BizType:        model.FlowUnfreeze,
+			Amount:         freeze.Amount,
+			Direction:      model.DirectionNone,
+			ClientID:       freeze.ClientID,
+			GameID:         freeze.GameID,
+			ExternalRemark: "session timeout",
+			RefFlowID:      freeze.ID,
+			Status:         model.FlowStatusDone,
+			AddTime:        now,
+			UpdateTime:     now,
+			SessionID:      freeze.SessionID,
+		}
+		if err := tx.Create(&unfreeze).Error; err != nil {
+			return err
+		}
+
+		freeze.Status = model.FlowStatusReversed
+		freeze.UpdateTime = now
+		if err := tx.Save(&freeze).Error; err != nil {
+			return err
+		}
+
+		if err := PostBalanceChange(tx, &bal, model.LedgerBizUnfreeze, freeze.Amount, LedgerRef{
+			Table:  model.LedgerRefAccountFlow,
+			ID:     unfreeze.ID,
+			Remark: "session timeout",
+		}); err != nil {
+			return err
+		}
+
+		if err := tx.Model(&model.GameSession{}).
+			Where("session_id = ? AND status = ?", freeze.SessionID, model.GameSessionStatusStart).
+			Updates(map[string]interface{}{"status": model.GameSessionStatusReversed, "end_time": now}).Error; err != nil {
+			return err
+		}
+		released = true
+		return nil
+	})
+	return released, err
+}
@@ -0,0 +1,23 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"chaos/api/config"
+	"chaos/api/model"
+)
+
+func TestSessionTimeout(t *testing.T) {
+	if got := SessionTimeout(model.GameInfo{SessionTimeout: 120}); got != 2*time.Minute {
+		t.Fatalf("per-game timeout = %s, want 2m", got)
+	}
+
+	want := defaultSessionTimeout
+	if s := config.GetConfig().Session.Timeout; s > 0 {
+		want = time.Duration(s) * time.Second
+	}
+	if got := SessionTimeout(model.GameInfo{}); got != want {
+		t.Fatalf("default timeout = %s, want %s", got, want)
+	}
+}
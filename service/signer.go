@@ -0,0 +1,312 @@
+package service
+
+import (
+	"chaos/api/chain"
+	"chaos/api/config"
+	"chaos/api/log"
+	"chaos/api/model"
+	"chaos/api/system"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrNoSigner    = errors.New("no withdrawal signer for chain")
+	ErrSignerLimit = errors.New("withdrawal signer limit reached")
+)
+
+// lockSigner is a configured withdrawal signer with its spending limits; nil limits are unlimited.
+type lockSigner struct {
+	chain.Signer
+	name      string
+	chainIDs  []uint64
+	maxPerSig *big.Int
+	maxPerDay *big.Int
+}
+
+var (
+	signersOnce sync.Once
+	signers     []*lockSigner
+	signersErr  error
+)
+
+// LoadSigners unlocks the configured withdrawal signers. It runs once; main calls it at startup
+// so a bad keystore or passphrase shows up before the first withdrawal.
+func LoadSigners() error {
+	signersOnce.Do(func() {
+		for _, cfg := range config.GetConfig().Signer {
+			s, err := newLockSigner(cfg)
+			if err != nil {
+				signersErr = errors.Join(signersErr, fmt.Errorf("signer %s: %w", cfg.Name, err))
+				continue
+			}
+			log.Infof("withdrawal signer %s (%s) uses %s", cfg.Name, cfg.Backend, s.Address().Hex())
+			signers = append(signers, s)
+		}
+	})
+	return signersErr
+}
+
+func newLockSigner(cfg config.SignerConfig) (*lockSigner, error) {
+	s := &lockSigner{name: cfg.Name, chainIDs: cfg.ChainIDs}
+	var err error
+	if s.maxPerSig, err = parseSignerLimit(cfg.MaxPerSignature); err != nil {
+		return nil, err
+	}
+	if s.maxPerDay, err = parseSignerLimit(cfg.MaxPerDay); err != nil {
+		return nil, err
+	}
+
+	if s.Signer, err = newChainSigner(cfg); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// newChainSigner opens the key of cfg with its backend.
+func newChainSigner(cfg config.SignerConfig) (chain.Signer, error) {
+	switch cfg.Backend {
+	case "keystore":
+		return chain.UnlockKeystore(cfg.Keystore, os.Getenv(cfg.PasswordEnv))
+	case "remote":
+		if !common.IsHexAddress(cfg.Address) {
+			return nil, fmt.Errorf("remote signer address %q", cfg.Address)
+		}
+		return chain.NewRemoteSigner(cfg.URL, os.Getenv(cfg.TokenEnv), common.HexToAddress(cfg.Address)), nil
+	case "memory":
+		return chain.NewKeySigner(os.Getenv(cfg.KeyEnv))
+	default:
+		return nil, fmt.Errorf("unknown backend %q", cfg.Backend)
+	}
+}
+
+// ContractAdminSigner opens the contractAdmin key that sends TopupLogic owner calls. It is
+// ErrNoSigner when none is configured: the calls are then proposed to the owning multisig.
+func ContractAdminSigner() (chain.Signer, error) {
+	cfg := config.GetConfig().ContractAdmin
+	if cfg.Backend == "" {
+		return nil, fmt.Errorf("%w: no contractAdmin key configured", ErrNoSigner)
+	}
+	s, err := newChainSigner(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("contractAdmin: %w", err)
+	}
+	return s, nil
+}
+
+func parseSignerLimit(v string) (*big.Int, error) {
+	if v == "" {
+		return nil, nil
+	}
+	n, ok := new(big.Int).SetString(v, 10)
+	if !ok || n.Sign() < 0 {
+		return nil, fmt.Errorf("invalid limit %q", v)
+	}
+	return n, nil
+}
+
+func signerFor(chainID uint64) (*lockSigner, error) {
+	if err := LoadSigners(); err != nil {
+		log.Error("load withdrawal signers failed", err)
+	}
+	for _, s := range signers {
+		if len(s.chainIDs) == 0 || slices.Contains(s.chainIDs, chainID) {
+			return s, nil
+		}
+	}
+	return nil, fmt.Errorf("%w %d", ErrNoSigner, chainID)
+}
+
+// LockSignerAddress is the address that signs LockAuth on chainID, i.e. the signer the contract must trust.
+func LockSignerAddress(chainID uint64) (string, error) {
+	s, err := signerFor(chainID)
+	if err != nil {
+		return "", err
+	}
+	return s.Address().Hex(), nil
+}
+
+// CheckLockAuthDomains verifies the LockAuth domain of every chain with a TopupLogic contract and
+// caches it, so withdrawals need no rpc for it. A chain left unverified is checked again on its
+// next withdrawal; one whose domain does not match refuses withdrawals until the config is fixed.
+func CheckLockAuthDomains(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	err := chain.VerifyLockAuthDomains(ctx)
+	if err == nil {
+		return
+	}
+	severity := model.ContractEventSeverityWarning
+	if errors.Is(err, chain.ErrDomainMismatch) {
+		severity = model.ContractEventSeverityCritical
+	}
+	Alert(severity, "withdraw lock eip712 domain check failed", err.Error())
+}
+
+// LockTicketCheck is what a withdraw lock ticket recovers to.
+type LockTicketCheck struct {
+	Digest         string `json:"digest"`
+	Signer         string `json:"signer"`          // recovered from the signature
+	ExpectedSigner string `json:"expected_signer"` // the signer this service uses on the chain
+	Valid          bool   `json:"valid"`
+	Expired        bool   `json:"expired"`
+}
+
+// CheckLockTicket recovers the signer of a LockAuth signature, for anyone to check a ticket
+// before sending openLock. Only tickets of a TopupLogic contract whose domain is verified are
+// checked, without any rpc call.
+func CheckLockTicket(req LockAuthRequest, sig []byte) (*LockTicketCheck, error) {
+	digest, err := chain.VerifiedLockAuthDigest(req.ChainID, req.Contract, req.User, req.LockID, req.Amount, req.Expiry, req.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	recovered, err := chain.RecoverLockAuthSigner(digest, sig)
+	if err != nil {
+		return nil, err
+	}
+	check := &LockTicketCheck{
+		Digest:  digest.Hex(),
+		Signer:  recovered.Hex(),
+		Expired: req.Expiry <= uint64(time.Now().Unix()),
+	}
+	if expected, err := LockSignerAddress(req.ChainID); err == nil {
+		check.ExpectedSigner = expected
+		check.Valid = expected == recovered.Hex()
+	}
+	return check, nil
+}
+
+// checkSignerLimits tells whether amount may be signed by a signer that already signed
+// signedToday on the same chain today.
+func checkSignerLimits(amount, signedToday, maxPerSig, maxPerDay *big.Int) error {
+	if maxPerSig != nil && amount.Cmp(maxPerSig) > 0 {
+		return fmt.Errorf("%w: %s above the %s per signature", ErrSignerLimit, amount, maxPerSig)
+	}
+	if maxPerDay != nil {
+		total := new(big.Int).Add(signedToday, amount)
+		if total.Cmp(maxPerDay) > 0 {
+			return fmt.Errorf("%w: %s signed today, %s more exceeds the %s per day", ErrSignerLimit, signedToday, amount, maxPerDay)
+		}
+	}
+	return nil
+}
+
+// LockAuthRequest is a withdraw lock to authorize. Amount is in base units of the token.
+type LockAuthRequest struct {
+	MainID   uint64
+	ChainID  uint64
+	Contract string
+	User     string
+	LockID   string
+	Amount   *big.Int
+	Expiry   uint64
+	Nonce    *big.Int
+}
+
+// SignLockAuth builds the LockAuth digest of req and has the chain's signer sign it, within its
+// spending limits. Every digest is audited in n_signer_audit, including the refused ones.
+func SignLockAuth(ctx context.Context, req LockAuthRequest) (common.Hash, []byte, error) {
+	s, err := signerFor(req.ChainID)
+	if err != nil {
+		return common.Hash{}, nil, err
+	}
+	digest, err := chain.BuildLockAuthDigest(ctx, req.ChainID, req.Contract, req.User, req.LockID, req.Amount, req.Expiry, req.Nonce)
+	if err != nil {
+		return common.Hash{}, nil, err
+	}
+
+	audit, err := reserveSignature(s, req, digest)
+	if err != nil {
+		return common.Hash{}, nil, err
+	}
+
+	sig, signErr := chain.SignLockAuthDigest(ctx, s, digest)
+	updates := map[string]interface{}{"update_time": time.Now()}
+	if signErr != nil {
+		updates["status"] = model.SignerAuditStatusFailed
+		updates["reason"] = truncate(signErr.Error(), 255)
+	} else {
+		updates["status"] = model.SignerAuditStatusSigned
+		updates["signature"] = hexutil.Encode(sig)
+	}
+	if err := system.GetDb().Model(&model.SignerAudit{}).Where("id = ?", audit.ID).Updates(updates).Error; err != nil {
+		log.Errorf("record signer audit %d failed: %v", audit.ID, err)
+	}
+	if signErr != nil {
+		return common.Hash{}, nil, signErr
+	}
+	log.Infof("signer %s signed digest %s for lock %s of %s on chain %d", s.name, digest.Hex(), req.LockID, req.Amount, req.ChainID)
+	return digest, sig, nil
+}
+
+// reserveSignature checks the limits and records the digest as reserved. Locking the signer's
+// rows of the day makes concurrent requests, on any instance, take their turn.
+func reserveSignature(s *lockSigner, req LockAuthRequest, digest common.Hash) (*model.SignerAudit, error) {
+	now := time.Now()
+	audit := model.SignerAudit{
+		Signer:     s.name,
+		Address:    s.Address().Hex(),
+		ChainID:    req.ChainID,
+		Contract:   req.Contract,
+		Digest:     digest.Hex(),
+		MainID:     req.MainID,
+		UserAddr:   req.User,
+		LockID:     req.LockID,
+		Amount:     req.Amount.String(),
+		Status:     model.SignerAuditStatusReserved,
+		AddTime:    now,
+		UpdateTime: now,
+	}
+	var limitErr error
+	err := system.GetDb().Transaction(func(tx *gorm.DB) error {
+		dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		var rows []model.SignerAudit
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "amount").
+			Where("signer = ? AND chain_id = ? AND add_time >= ? AND status IN ?", s.name, req.ChainID, dayStart,
+				[]string{model.SignerAuditStatusReserved, model.SignerAuditStatusSigned}).
+			Find(&rows).Error
+		if err != nil {
+			return err
+		}
+		signedToday := new(big.Int)
+		for _, r := range rows {
+			n, ok := new(big.Int).SetString(r.Amount, 10)
+			if !ok {
+				return fmt.Errorf("signer audit %d amount %q", r.ID, r.Amount)
+			}
+			signedToday.Add(signedToday, n)
+		}
+		if limitErr = checkSignerLimits(req.Amount, signedToday, s.maxPerSig, s.maxPerDay); limitErr != nil {
+			audit.Status = model.SignerAuditStatusRefused
+			audit.Reason = truncate(limitErr.Error(), 255)
+		}
+		return tx.Create(&audit).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	if limitErr != nil {
+		Alert(model.ContractEventSeverityWarning, fmt.Sprintf("withdrawal signer %s refused a lock on chain %d", s.name, req.ChainID), audit)
+		return nil, limitErr
+	}
+	return &audit, nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
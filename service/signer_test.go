@@ -0,0 +1,38 @@
+package service
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestCheckSignerLimits(t *testing.T) {
+	n := big.NewInt
+	cases := []struct {
+		amount, today, perSig, perDay *big.Int
+		ok                            bool
+	}{
+		{n(500), n(0), nil, nil, true},
+		{n(500), n(0), n(500), nil, true},
+		{n(501), n(0), n(500), nil, false},
+		{n(400), n(600), nil, n(1000), true},
+		{n(401), n(600), nil, n(1000), false},
+		{n(100), n(0), n(50), n(1000), false},
+	}
+	for i, c := range cases {
+		err := checkSignerLimits(c.amount, c.today, c.perSig, c.perDay)
+		if c.ok && err != nil {
+			t.Errorf("case %d: unexpected %v", i, err)
+		}
+		if !c.ok && !errors.Is(err, ErrSignerLimit) {
+			t.Errorf("case %d: got %v, want ErrSignerLimit", i, err)
+		}
+	}
+
+	if _, err := parseSignerLimit("-1"); err == nil {
+		t.Error("negative limit accepted")
+	}
+	if l, err := parseSignerLimit(""); err != nil || l != nil {
+		t.Errorf("empty limit = %v, %v; want unlimited", l, err)
+	}
+}
@@ -0,0 +1,268 @@
+package service
+
+import (
+	"chaos/api/log"
+	"chaos/api/model"
+	"chaos/api/system"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+// snapshotGrace leaves in-flight transactions of the day time to commit before the snapshot runs.
+const snapshotGrace = 5 * time.Minute
+
+// snapshotDay truncates t to its local calendar day.
+func snapshotDay(t time.Time) time.Time {
+	y, m, d := t.In(time.Local).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+}
+
+// snapshotCutoff is the moment the snapshot of day stands for: local midnight at its end.
+func snapshotCutoff(day time.Time) time.Time {
+	return snapshotDay(day).AddDate(0, 0, 1)
+}
+
+// NextSnapshotRun is when the snapshot of the day before should be taken next.
+func NextSnapshotRun(now time.Time) time.Time {
+	run := snapshotDay(now).Add(snapshotGrace)
+	if !now.Before(run) {
+		run = run.AddDate(0, 0, 1)
+	}
+	return run
+}
+
+// TakeBalanceSnapshot records every balance and the per-asset liabilities as of the end of day.
+// Balances are read from n_account_balance and rolled back by the journal postings made after
+// the cutoff, both in one consistent read, so running it late or twice gives the same result.
+func TakeBalanceSnapshot(day time.Time) (int, error) {
+	snapDate := snapshotDay(day)
+	cutoff := snapshotCutoff(snapDate)
+	if time.Now().Before(cutoff) {
+		return 0, fmt.Errorf("day %s has not ended yet", snapDate.Format(time.DateOnly))
+	}
+
+	count := 0
+	err := system.GetDb().Transaction(func(tx *gorm.DB) error {
+		var nets []journalNet
+		if err := tx.Model(&model.LedgerPosting{}).
+			Select("main_id, asset_id, account, CAST(SUM(credit) AS DECIMAL(65,0)) - CAST(SUM(debit) AS DECIMAL(65,0)) AS net").
+			Where("account IN ? AND add_time >= ?", userLedgerAccounts, cutoff).
+			Group("main_id, asset_id, account").
+			Scan(&nets).Error; err != nil {
+			return err
+		}
+		later := make(map[balanceKey]decimal.Decimal, len(nets))
+		for _, n := range nets {
+			later[balanceKey{n.MainID, n.AssetID, n.Account}] = n.Net
+		}
+
+		if err := tx.Where("snap_date = ?", snapDate).Delete(&model.BalanceSnapshot{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("snap_date = ?", snapDate).Delete(&model.LiabilitySnapshot{}).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		liabilities := map[uint64]*model.LiabilitySnapshot{}
+		var balances []model.AccountBalance
+		err := tx.Model(&model.AccountBalance{}).FindInBatches(&balances, 500, func(_ *gorm.DB, _ int) error {
+			snaps := make([]model.BalanceSnapshot, 0, len(balances))
+			for i := range balances {
+				snap := model.BalanceSnapshot{SnapDate: snapDate, MainID: balances[i].MainID, AssetID: balances[i].AssetID, AddTime: now}
+				for _, account := range userLedgerAccounts {
+					v := decimal.NewFromUint64(*bucket(&balances[i], account)).Sub(later[balanceKey{snap.MainID, snap.AssetID, account}])
+					if v.IsNegative() {
+						log.Errorf("[BalanceSnapshot] %s %d/%d %s rolls back below zero (%s), run the ledger check", snapDate.Format(time.DateOnly), snap.MainID, snap.AssetID, account, v)
+						v = decimal.Zero
+					}
+					*snapshotBucket(&snap, account) = v.BigInt().Uint64()
+				}
+				if snap.Available == 0 && snap.Frozen == 0 && snap.Withdrawal == 0 {
+					continue
+				}
+				snaps = append(snaps, snap)
+
+				l, ok := liabilities[snap.AssetID]
+				if !ok {
+					l = &model.LiabilitySnapshot{SnapDate: snapDate, AssetID: snap.AssetID, AddTime: now}
+					liabilities[snap.AssetID] = l
+				}
+				l.Accounts++
+				l.Available = l.Available.Add(decimal.NewFromUint64(snap.Available))
+				l.Frozen = l.Frozen.Add(decimal.NewFromUint64(snap.Frozen))
+				l.Withdrawal = l.Withdrawal.Add(decimal.NewFromUint64(snap.Withdrawal))
+			}
+			if len(snaps) == 0 {
+				return nil
+			}
+			count += len(snaps)
+			return tx.Create(&snaps).Error
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		for _, l := range liabilities {
+			l.Total = l.Available.Add(l.Frozen).Add(l.Withdrawal)
+			if err := tx.Create(l).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+	if err != nil {
+		return 0, err
+	}
+	log.Infof("[BalanceSnapshot] %s: %d balances", snapDate.Format(time.DateOnly), count)
+	return count, nil
+}
+
+func snapshotBucket(snap *model.BalanceSnapshot, account string) *uint64 {
+	switch account {
+	case model.LedgerAccountAvailable:
+		return &snap.Available
+	case model.LedgerAccountFrozen:
+		return &snap.Frozen
+	}
+	return &snap.Withdrawal
+}
+
+// BalanceAtQuery asks for balances at a past moment. MainID 0 pages through all users,
+// a nil AssetID returns every asset.
+type BalanceAtQuery struct {
+	MainID  uint64
+	AssetID *uint64
+	At      time.Time
+	Page    int
+	Limit   int
+}
+
+// PointBalance is a user balance of one asset at a past moment, in asset units.
+type PointBalance struct {
+	MainID     uint64          `json:"main_id"`
+	AssetID    uint64          `json:"asset_id"`
+	Available  decimal.Decimal `json:"available"`
+	Frozen     decimal.Decimal `json:"frozen"`
+	Withdrawal decimal.Decimal `json:"withdrawal"`
+}
+
+func (p *PointBalance) bucket(account string) *decimal.Decimal {
+	switch account {
+	case model.LedgerAccountAvailable:
+		return &p.Available
+	case model.LedgerAccountFrozen:
+		return &p.Frozen
+	}
+	return &p.Withdrawal
+}
+
+// BalancesAt answers "balance of user X (or all users) at time T": it starts from the nearest
+// snapshot taken before T and replays the journal postings between its cutoff and T.
+// It also returns the day of the snapshot it started from, nil when it replayed the whole journal.
+func BalancesAt(q BalanceAtQuery) ([]PointBalance, *time.Time, error) {
+	db := system.GetDb()
+
+	mainIDs := []uint64{q.MainID}
+	if q.MainID == 0 {
+		mainIDs = nil
+		if err := db.Model(&model.AccountBalance{}).
+			Distinct("main_id").Order("main_id").
+			Offset((q.Page-1)*q.Limit).Limit(q.Limit).
+			Pluck("main_id", &mainIDs).Error; err != nil {
+			return nil, nil, err
+		}
+		if len(mainIDs) == 0 {
+			return []PointBalance{}, nil, nil
+		}
+	}
+
+	var days []time.Time
+	if err := db.Model(&model.LiabilitySnapshot{}).
+		Where("snap_date <= ?", snapshotDay(q.At).AddDate(0, 0, -1)).
+		Order("snap_date desc").Limit(1).
+		Pluck("snap_date", &days).Error; err != nil {
+		return nil, nil, err
+	}
+
+	result := map[[2]uint64]*PointBalance{}
+	get := func(mainID, assetID uint64) *PointBalance {
+		key := [2]uint64{mainID, assetID}
+		p, ok := result[key]
+		if !ok {
+			p = &PointBalance{MainID: mainID, AssetID: assetID}
+			result[key] = p
+		}
+		return p
+	}
+
+	var base *time.Time
+	replayFrom := time.Time{}
+	if len(days) > 0 {
+		day := snapshotDay(days[0])
+		base = &day
+		replayFrom = snapshotCutoff(day)
+
+		query := db.Model(&model.BalanceSnapshot{}).Where("snap_date = ? AND main_id IN ?", day, mainIDs)
+		if q.AssetID != nil {
+			query = query.Where("asset_id = ?", *q.AssetID)
+		}
+		var snaps []model.BalanceSnapshot
+		if err := query.Find(&snaps).Error; err != nil {
+			return nil, nil, err
+		}
+		for _, s := range snaps {
+			p := get(s.MainID, s.AssetID)
+			p.Available = decimal.NewFromUint64(s.Available)
+			p.Frozen = decimal.NewFromUint64(s.Frozen)
+			p.Withdrawal = decimal.NewFromUint64(s.Withdrawal)
+		}
+	}
+
+	query := db.Model(&model.LedgerPosting{}).
+		Select("main_id, asset_id, account, CAST(SUM(credit) AS DECIMAL(65,0)) - CAST(SUM(debit) AS DECIMAL(65,0)) AS net").
+		Where("main_id IN ? AND account IN ? AND add_time >= ? AND add_time < ?", mainIDs, userLedgerAccounts, replayFrom, q.At)
+	if q.AssetID != nil {
+		query = query.Where("asset_id = ?", *q.AssetID)
+	}
+	var nets []journalNet
+	if err := query.Group("main_id, asset_id, account").Scan(&nets).Error; err != nil {
+		return nil, nil, err
+	}
+	for _, n := range nets {
+		b := get(n.MainID, n.AssetID).bucket(n.Account)
+		*b = b.Add(n.Net)
+	}
+
+	balances := make([]PointBalance, 0, len(result))
+	for _, p := range result {
+		if q.MainID == 0 && p.Available.IsZero() && p.Frozen.IsZero() && p.Withdrawal.IsZero() {
+			continue
+		}
+		balances = append(balances, *p)
+	}
+	sort.Slice(balances, func(i, j int) bool {
+		if balances[i].MainID != balances[j].MainID {
+			return balances[i].MainID < balances[j].MainID
+		}
+		return balances[i].AssetID < balances[j].AssetID
+	})
+	return balances, base, nil
+}
+
+// ListLiabilities returns the daily platform liabilities between two days, inclusive.
+func ListLiabilities(from, to time.Time, assetID *uint64) ([]model.LiabilitySnapshot, error) {
+	query := system.GetDb().Model(&model.LiabilitySnapshot{}).
+		Where("snap_date >= ? AND snap_date <= ?", snapshotDay(from), snapshotDay(to))
+	if assetID != nil {
+		query = query.Where("asset_id = ?", *assetID)
+	}
+	var rows []model.LiabilitySnapshot
+	err := query.Order("snap_date, asset_id").Find(&rows).Error
+	return rows, err
+}
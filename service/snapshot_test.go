@@ -0,0 +1,22 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSnapshotSchedule(t *testing.T) {
+	day := time.Date(2025, 3, 9, 17, 30, 0, 0, time.Local)
+	if got := snapshotCutoff(day); !got.Equal(time.Date(2025, 3, 10, 0, 0, 0, 0, time.Local)) {
+		t.Fatalf("cutoff = %s", got)
+	}
+
+	before := time.Date(2025, 3, 10, 0, 1, 0, 0, time.Local)
+	if got := NextSnapshotRun(before); !got.Equal(time.Date(2025, 3, 10, 0, 5, 0, 0, time.Local)) {
+		t.Fatalf("next run before grace = %s", got)
+	}
+	after := time.Date(2025, 3, 10, 0, 5, 0, 0, time.Local)
+	if got := NextSnapshotRun(after); !got.Equal(time.Date(2025, 3, 11, 0, 5, 0, 0, time.Local)) {
+		t.Fatalf("next run after grace = %s", got)
+	}
+}
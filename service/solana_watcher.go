@@ -0,0 +1,150 @@
+package service
+
+import (
+	"chaos/api/chain"
+	"chaos/api/config"
+	"chaos/api/log"
+	"chaos/api/model"
+	"chaos/api/system"
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// solanaWatcher is the watcher RunSolanaWatcher currently runs, nil before the first start.
+var solanaWatcher atomic.Pointer[chain.SolanaWatcher]
+
+// RunSolanaWatcher follows the Solana chain for the balance changes of the wallets users linked in
+// the configured mints until ctx is done. The watcher is rebuilt and restarted whenever it stops
+// on an error, e.g. when its progress cannot be loaded.
+func RunSolanaWatcher(ctx context.Context) {
+	for {
+		err := runSolanaWatcher(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		log.Errorf("[SolanaWatcher] stopped: %v, restarting", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(30 * time.Second):
+		}
+	}
+}
+
+func runSolanaWatcher(ctx context.Context) error {
+	cfg := config.GetConfig().Solana
+	var sinks chain.MultiSink
+	for _, name := range cfg.Watch.Sinks {
+		switch name {
+		case "db":
+			sinks = append(sinks, solanaTradeDBSink{})
+		case "csv":
+			csv, err := chain.NewCSVSink(cfg.Watch.CSVPath)
+			if err != nil {
+				return fmt.Errorf("csv sink: %w", err)
+			}
+			defer csv.Close()
+			sinks = append(sinks, csv)
+		default:
+			return fmt.Errorf("unknown sink %q", name)
+		}
+	}
+	if len(sinks) == 0 {
+		sinks = chain.MultiSink{solanaTradeDBSink{}}
+	}
+
+	w, err := chain.NewSolanaWatcher(chain.SolanaWatcherConfig{
+		RPCURLs:       chain.SolanaRpcs(),
+		Mints:         cfg.Watch.Mints,
+		Wallets:       linkedSolanaWallets,
+		Sink:          sinks,
+		Progress:      solanaCursor{chainID: cfg.ChainID},
+		StartSlot:     cfg.Watch.StartSlot,
+		WalletRefresh: time.Duration(cfg.Watch.WalletRefresh) * time.Second,
+	})
+	if err != nil {
+		return err
+	}
+	solanaWatcher.Store(w)
+	log.Infof("[SolanaWatcher] watching mints %v", cfg.Watch.Mints)
+	return w.Run(ctx)
+}
+
+// SolanaWatcherStatus is the progress of the Solana watcher; false when it never started.
+func SolanaWatcherStatus() (chain.SolanaWatcherStatus, bool) {
+	w := solanaWatcher.Load()
+	if w == nil {
+		return chain.SolanaWatcherStatus{}, false
+	}
+	return w.Status(), true
+}
+
+// linkedSolanaWallets returns the Solana wallets users linked; EVM ones are left out.
+func linkedSolanaWallets(ctx context.Context) ([]string, error) {
+	var ids []string
+	err := system.GetDb().WithContext(ctx).Model(&model.UserProvider{}).
+		Where("provider_type = ? AND provider_id NOT LIKE ?", "wallet", "0x%").
+		Distinct().Pluck("provider_id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+	wallets := ids[:0]
+	for _, id := range ids {
+		if chain.IsSolanaAddress(id) {
+			wallets = append(wallets, id)
+		}
+	}
+	return wallets, nil
+}
+
+// solanaTradeDBSink stores trades in n_solana_trade. A trade already stored is skipped, so a slot
+// scanned again after a failure is harmless.
+type solanaTradeDBSink struct{}
+
+func (solanaTradeDBSink) WriteTrades(ctx context.Context, trades []chain.SolanaTrade) error {
+	now := time.Now()
+	rows := make([]model.SolanaTrade, 0, len(trades))
+	for _, t := range trades {
+		rows = append(rows, model.SolanaTrade{
+			Slot:       t.Slot,
+			BlockTime:  t.BlockTime,
+			Signature:  t.Signature,
+			Owner:      t.Owner,
+			Mint:       t.Mint,
+			Side:       t.Side,
+			Amount:     t.Amount,
+			FeeLamport: t.FeeLamport,
+			Success:    t.Success,
+			AddTime:    now,
+		})
+	}
+	return system.GetDb().WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error
+}
+
+// solanaCursor keeps the watcher's last slot in n_chain_cursor.
+type solanaCursor struct {
+	chainID uint64
+}
+
+func (c solanaCursor) LoadSlot() (uint64, error) {
+	var cursor model.ChainCursor
+	err := system.GetDb().Where("chain_id = ? AND name = ?", c.chainID, model.CursorSolanaTrades).First(&cursor).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	return cursor.BlockNumber, err
+}
+
+func (c solanaCursor) SaveSlot(slot uint64) error {
+	cursor := model.ChainCursor{ChainID: c.chainID, Name: model.CursorSolanaTrades, BlockNumber: slot, UpdateTime: time.Now()}
+	return system.GetDb().Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "chain_id"}, {Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"block_number", "update_time"}),
+	}).Create(&cursor).Error
+}
@@ -0,0 +1,370 @@
+package service
+
+import (
+	"chaos/api/model"
+	"chaos/api/system"
+	"errors"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const statementPageSize = 500
+
+var ErrStatementRange = errors.New("invalid statement range")
+
+// StatementQuery selects one asset of one user over [From, To).
+type StatementQuery struct {
+	MainID  uint64
+	AssetID uint64
+	From    time.Time
+	To      time.Time
+}
+
+// StatementBalances are the user buckets of one asset, scaled by the asset decimals.
+type StatementBalances struct {
+	Available  decimal.Decimal `json:"available"`
+	Frozen     decimal.Decimal `json:"frozen"`
+	Withdrawal decimal.Decimal `json:"withdrawal"`
+	Total      decimal.Decimal `json:"total"`
+}
+
+func (b StatementBalances) add(d StatementBalances) StatementBalances {
+	b.Available = b.Available.Add(d.Available)
+	b.Frozen = b.Frozen.Add(d.Frozen)
+	b.Withdrawal = b.Withdrawal.Add(d.Withdrawal)
+	b.Total = b.Available.Add(b.Frozen).Add(b.Withdrawal)
+	return b
+}
+
+type StatementHeader struct {
+	MainID      uint64            `json:"main_id"`
+	AssetID     uint64            `json:"asset_id"`
+	Symbol      string            `json:"symbol"`
+	Decimals    int32             `json:"decimals"`
+	From        time.Time         `json:"from"`
+	To          time.Time         `json:"to"`
+	Opening     StatementBalances `json:"opening"`
+	GeneratedAt time.Time         `json:"generated_at"`
+}
+
+// StatementLine is one flow of the statement. Lines backed by a ledger entry carry the balance
+// change of that entry; flows that never moved balance (pending, failed) have a zero change.
+type StatementLine struct {
+	Time     time.Time         `json:"time"`
+	Source   string            `json:"source"` // n_account_flow, n_account_balance_flow or n_account_balance
+	FlowID   uint64            `json:"flow_id"`
+	EntryID  uint64            `json:"entry_id,omitempty"`
+	Type     string            `json:"type"`
+	Movement string            `json:"movement,omitempty"` // ledger biz type
+	Status   string            `json:"status"`
+	Amount   decimal.Decimal   `json:"amount"`
+	Change   StatementBalances `json:"change"`
+	Balance  StatementBalances `json:"balance"` // running balances after this line
+	TxHash   string            `json:"tx_hash,omitempty"`
+	Remark   string            `json:"remark,omitempty"`
+
+	seq uint64 // keyset position within its source
+}
+
+type StatementSummary struct {
+	Lines   int               `json:"lines"`
+	Closing StatementBalances `json:"closing"`
+}
+
+// StatementWriter renders a statement as it is read, so a statement is never held in memory.
+type StatementWriter interface {
+	Begin(h StatementHeader) error
+	Line(l StatementLine) error
+	End(s StatementSummary) error
+}
+
+// ParseStatementRange parses an inclusive YYYY-MM-DD day range into [from, to).
+func ParseStatementRange(from, to string) (time.Time, time.Time, error) {
+	start, err := time.ParseInLocation(time.DateOnly, from, time.Local)
+	if err != nil {
+		return time.Time{}, time.Time{}, ErrStatementRange
+	}
+	end, err := time.ParseInLocation(time.DateOnly, to, time.Local)
+	if err != nil || end.Before(start) {
+		return time.Time{}, time.Time{}, ErrStatementRange
+	}
+	return start, end.AddDate(0, 0, 1), nil
+}
+
+// WriteStatement streams the statement of q to w: the opening balance from the journal, every
+// AccountFlow and AccountBalanceFlow in range with running balances, and the closing balance.
+func WriteStatement(q StatementQuery, w StatementWriter) error {
+	asset, err := GetAsset(q.AssetID)
+	if err != nil {
+		return err
+	}
+	scale := func(units decimal.Decimal) decimal.Decimal {
+		return units.Shift(-asset.Decimals)
+	}
+
+	opening, err := statementOpening(q, scale)
+	if err != nil {
+		return err
+	}
+	if err := w.Begin(StatementHeader{
+		MainID:      q.MainID,
+		AssetID:     q.AssetID,
+		Symbol:      asset.Symbol,
+		Decimals:    asset.Decimals,
+		From:        q.From,
+		To:          q.To,
+		Opening:     opening,
+		GeneratedAt: time.Now(),
+	}); err != nil {
+		return err
+	}
+
+	cursors := []*statementCursor{
+		newStatementCursor(q, func(t time.Time, id uint64) ([]StatementLine, error) {
+			return statementEntries(q, t, id, scale)
+		}),
+		newStatementCursor(q, func(t time.Time, id uint64) ([]StatementLine, error) {
+			return statementAccountFlows(q, t, id, scale)
+		}),
+		newStatementCursor(q, func(t time.Time, id uint64) ([]StatementLine, error) {
+			return statementBalanceFlows(q, t, id, scale)
+		}),
+	}
+
+	running := opening
+	count := 0
+	for {
+		var next *statementCursor
+		var line *StatementLine
+		for _, c := range cursors {
+			l, err := c.peek()
+			if err != nil {
+				return err
+			}
+			if l != nil && (line == nil || l.Time.Before(line.Time)) {
+				next, line = c, l
+			}
+		}
+		if next == nil {
+			break
+		}
+		running = running.add(line.Change)
+		line.Balance = running
+		if err := w.Line(*line); err != nil {
+			return err
+		}
+		next.pop()
+		count++
+	}
+
+	return w.End(StatementSummary{Lines: count, Closing: running})
+}
+
+// statementCursor pages through one time-ordered source of statement lines.
+type statementCursor struct {
+	fetch     func(afterTime time.Time, afterID uint64) ([]StatementLine, error)
+	buf       []StatementLine
+	afterTime time.Time
+	afterID   uint64
+	done      bool
+}
+
+func newStatementCursor(q StatementQuery, fetch func(time.Time, uint64) ([]StatementLine, error)) *statementCursor {
+	return &statementCursor{fetch: fetch, afterTime: q.From}
+}
+
+func (c *statementCursor) peek() (*StatementLine, error) {
+	if len(c.buf) == 0 && !c.done {
+		lines, err := c.fetch(c.afterTime, c.afterID)
+		if err != nil {
+			return nil, err
+		}
+		c.buf = lines
+		c.done = len(lines) < statementPageSize
+	}
+	if len(c.buf) == 0 {
+		return nil, nil
+	}
+	return &c.buf[0], nil
+}
+
+func (c *statementCursor) pop() {
+	c.afterTime, c.afterID = c.buf[0].Time, c.buf[0].seq
+	c.buf = c.buf[1:]
+}
+
+func statementOpening(q StatementQuery, scale func(decimal.Decimal) decimal.Decimal) (StatementBalances, error) {
+	var nets []journalNet
+	if err := system.GetDb().Model(&model.LedgerPosting{}).
+		Select("account, CAST(SUM(credit) AS DECIMAL(65,0)) - CAST(SUM(debit) AS DECIMAL(65,0)) AS net").
+		Where("main_id = ? AND asset_id = ? AND account IN ? AND add_time < ?", q.MainID, q.AssetID, userLedgerAccounts, q.From).
+		Group("account").
+		Scan(&nets).Error; err != nil {
+		return StatementBalances{}, err
+	}
+	var units StatementBalances
+	for _, n := range nets {
+		switch n.Account {
+		case model.LedgerAccountAvailable:
+			units.Available = scale(n.Net)
+		case model.LedgerAccountFrozen:
+			units.Frozen = scale(n.Net)
+		case model.LedgerAccountWithdrawal:
+			units.Withdrawal = scale(n.Net)
+		}
+	}
+	return StatementBalances{}.add(units), nil
+}
+
+type statementEntry struct {
+	ID         uint64
+	BizType    string
+	Amount     uint64
+	RefTable   string
+	RefID      uint64
+	AddTime    time.Time
+	Available  decimal.Decimal
+	Frozen     decimal.Decimal
+	Withdrawal decimal.Decimal
+}
+
+// statementEntries reads the journal entries of the user in range with the net change of every bucket,
+// and describes each with the flow it points back to.
+func statementEntries(q StatementQuery, afterTime time.Time, afterID uint64, scale func(decimal.Decimal) decimal.Decimal) ([]StatementLine, error) {
+	const net = "SUM(CASE WHEN p.account = ? THEN CAST(p.credit AS DECIMAL(65,0)) - CAST(p.debit AS DECIMAL(65,0)) ELSE 0 END)"
+	db := system.GetDb()
+
+	var entries []statementEntry
+	if err := db.Table(model.TB_LEDGER_ENTRY+" e").
+		Select("e.id, e.biz_type, e.amount, e.ref_table, e.ref_id, e.add_time, "+
+			net+" AS available, "+net+" AS frozen, "+net+" AS withdrawal",
+			model.LedgerAccountAvailable, model.LedgerAccountFrozen, model.LedgerAccountWithdrawal).
+		Joins("JOIN "+model.TB_LEDGER_POSTING+" p ON p.entry_id = e.id AND p.main_id = e.main_id").
+		Where("e.main_id = ? AND e.asset_id = ? AND e.add_time < ?", q.MainID, q.AssetID, q.To).
+		Where("e.add_time > ? OR (e.add_time = ? AND e.id > ?)", afterTime, afterTime, afterID).
+		Group("e.id").
+		Order("e.add_time, e.id").
+		Limit(statementPageSize).
+		Scan(&entries).Error; err != nil {
+		return nil, err
+	}
+
+	var flowIDs, balanceFlowIDs []uint64
+	for _, e := range entries {
+		switch e.RefTable {
+		case model.LedgerRefAccountFlow:
+			flowIDs = append(flowIDs, e.RefID)
+		case model.LedgerRefAccountBalanceFlow:
+			balanceFlowIDs = append(balanceFlowIDs, e.RefID)
+		}
+	}
+	flows := map[uint64]model.AccountFlow{}
+	if len(flowIDs) > 0 {
+		var rows []model.AccountFlow
+		if err := db.Model(&model.AccountFlow{}).Where("id IN ?", flowIDs).Find(&rows).Error; err != nil {
+			return nil, err
+		}
+		for _, f := range rows {
+			flows[f.ID] = f
+		}
+	}
+	balanceFlows := map[uint64]model.AccountBalanceFlow{}
+	if len(balanceFlowIDs) > 0 {
+		var rows []model.AccountBalanceFlow
+		if err := db.Model(&model.AccountBalanceFlow{}).Where("id IN ?", balanceFlowIDs).Find(&rows).Error; err != nil {
+			return nil, err
+		}
+		for _, f := range rows {
+			balanceFlows[f.ID] = f
+		}
+	}
+
+	lines := make([]StatementLine, 0, len(entries))
+	for _, e := range entries {
+		line := StatementLine{
+			Time:     e.AddTime,
+			Source:   e.RefTable,
+			FlowID:   e.RefID,
+			EntryID:  e.ID,
+			Type:     e.BizType,
+			Movement: e.BizType,
+			Status:   "confirmed",
+			Change: StatementBalances{}.add(StatementBalances{
+				Available:  scale(e.Available),
+				Frozen:     scale(e.Frozen),
+				Withdrawal: scale(e.Withdrawal),
+			}),
+			seq: e.ID,
+		}
+		if f, ok := flows[e.RefID]; ok && e.RefTable == model.LedgerRefAccountFlow {
+			describeAccountFlow(&line, f, scale)
+		} else if f, ok := balanceFlows[e.RefID]; ok && e.RefTable == model.LedgerRefAccountBalanceFlow {
+			describeBalanceFlow(&line, f, scale)
+		}
+		// a spend posts one entry per fee share; each line shows its own share
+		line.Amount = scale(decimal.NewFromUint64(e.Amount))
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+// statementAccountFlows reads the AccountFlow rows in range that never posted to the journal.
+func statementAccountFlows(q StatementQuery, afterTime time.Time, afterID uint64, scale func(decimal.Decimal) decimal.Decimal) ([]StatementLine, error) {
+	var rows []model.AccountFlow
+	if err := system.GetDb().Model(&model.AccountFlow{}).
+		Where("main_id = ? AND asset_id = ? AND add_time < ?", q.MainID, q.AssetID, q.To).
+		Where("add_time > ? OR (add_time = ? AND id > ?)", afterTime, afterTime, afterID).
+		Where("NOT EXISTS (SELECT 1 FROM "+model.TB_LEDGER_ENTRY+" e WHERE e.ref_table = ? AND e.ref_id = n_account_flow.id)", model.LedgerRefAccountFlow).
+		Order("add_time, id").
+		Limit(statementPageSize).
+		Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	lines := make([]StatementLine, 0, len(rows))
+	for _, f := range rows {
+		line := StatementLine{Time: f.AddTime, Source: model.LedgerRefAccountFlow, FlowID: f.ID, seq: f.ID}
+		describeAccountFlow(&line, f, scale)
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+// statementBalanceFlows reads the AccountBalanceFlow rows in range that never posted to the journal.
+func statementBalanceFlows(q StatementQuery, afterTime time.Time, afterID uint64, scale func(decimal.Decimal) decimal.Decimal) ([]StatementLine, error) {
+	var rows []model.AccountBalanceFlow
+	if err := system.GetDb().Model(&model.AccountBalanceFlow{}).
+		Where("main_id = ? AND asset_id = ? AND add_time < ?", q.MainID, q.AssetID, q.To).
+		Where("add_time > ? OR (add_time = ? AND id > ?)", afterTime, afterTime, afterID).
+		Where("NOT EXISTS (SELECT 1 FROM "+model.TB_LEDGER_ENTRY+" e WHERE e.ref_table = ? AND e.ref_id = n_account_balance_flow.id)", model.LedgerRefAccountBalanceFlow).
+		Order("add_time, id").
+		Limit(statementPageSize).
+		Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	lines := make([]StatementLine, 0, len(rows))
+	for _, f := range rows {
+		line := StatementLine{Time: f.AddTime, Source: model.LedgerRefAccountBalanceFlow, FlowID: f.ID, seq: f.ID}
+		describeBalanceFlow(&line, f, scale)
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+func describeAccountFlow(line *StatementLine, f model.AccountFlow, scale func(decimal.Decimal) decimal.Decimal) {
+	line.Type = model.FlowTypeName(f.BizType)
+	line.Status = model.FlowStatusName(f.Status)
+	line.Amount = scale(decimal.NewFromUint64(f.Amount))
+	line.Remark = f.ExternalRemark
+}
+
+func describeBalanceFlow(line *StatementLine, f model.AccountBalanceFlow, scale func(decimal.Decimal) decimal.Decimal) {
+	amount := f.RealAmount
+	if amount == 0 {
+		amount = f.Amount
+	}
+	line.Type = model.BalanceFlowOpName(f.Op)
+	line.Status = model.BalanceFlowStatusName(f.Status)
+	line.Amount = scale(decimal.NewFromUint64(amount))
+	line.TxHash = f.TxHash
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func writeSampleStatement(t *testing.T, format string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	w, _, err := NewStatementWriter(format, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	opening := StatementBalances{}.add(StatementBalances{Available: decimal.RequireFromString("10")})
+	if err := w.Begin(StatementHeader{MainID: 7, Symbol: "N", Decimals: 6, From: from, To: from.AddDate(0, 1, 0), Opening: opening}); err != nil {
+		t.Fatal(err)
+	}
+	running := opening
+	for i, change := range []StatementBalances{
+		{Available: decimal.RequireFromString("-1.5"), Frozen: decimal.RequireFromString("1.5")},
+		{Frozen: decimal.RequireFromString("-1.5")},
+	} {
+		change = StatementBalances{}.add(change)
+		running = running.add(change)
+		line := StatementLine{Time: from.Add(time.Hour), FlowID: uint64(i + 1), Type: "freeze", Status: "confirmed", Amount: decimal.RequireFromString("1.5"), Change: change, Balance: running}
+		if err := w.Line(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.End(StatementSummary{Lines: 2, Closing: running}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestStatementJSON(t *testing.T) {
+	var out struct {
+		Statement StatementHeader  `json:"statement"`
+		Lines     []StatementLine  `json:"lines"`
+		Summary   StatementSummary `json:"summary"`
+	}
+	if err := json.Unmarshal([]byte(writeSampleStatement(t, "json")), &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Lines) != 2 || out.Summary.Lines != 2 {
+		t.Fatalf("unexpected lines %+v", out)
+	}
+	if !out.Summary.Closing.Total.Equal(decimal.RequireFromString("8.5")) {
+		t.Fatalf("closing total = %s, want 8.5", out.Summary.Closing.Total)
+	}
+}
+
+func TestStatementCSV(t *testing.T) {
+	rows, err := csv.NewReader(strings.NewReader(writeSampleStatement(t, "csv"))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// header, opening, 2 lines, closing
+	if len(rows) != 5 {
+		t.Fatalf("got %d rows", len(rows))
+	}
+	if rows[1][4] != "opening" || rows[4][4] != "closing" || rows[4][14] != "8.5" {
+		t.Fatalf("unexpected balance rows %v / %v", rows[1], rows[4])
+	}
+}
+
+func TestStatementHTML(t *testing.T) {
+	out := writeSampleStatement(t, "html")
+	if !strings.Contains(out, "Opening balance") || !strings.Contains(out, "Closing balance (2 entries)") || !strings.HasSuffix(strings.TrimSpace(out), "</html>") {
+		t.Fatalf("unexpected html output:\n%s", out)
+	}
+}
+
+func TestParseStatementRange(t *testing.T) {
+	from, to, err := ParseStatementRange("2025-01-01", "2025-01-31")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if to.Sub(from) != 31*24*time.Hour {
+		t.Fatalf("range %s - %s", from, to)
+	}
+	if _, _, err := ParseStatementRange("2025-02-01", "2025-01-31"); err == nil {
+		t.Fatal("expected reversed range to fail")
+	}
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"strconv"
+	"time"
+)
+
+var ErrStatementFormat = errors.New("unsupported statement format")
+
+var statementTemplatePaths = []string{
+	"templates/statement.html",
+	"../templates/statement.html",
+	"./templates/statement.html",
+	"../../templates/statement.html",
+}
+
+// NewStatementWriter returns the writer of a format (csv, json or html) and its content type.
+func NewStatementWriter(format string, w io.Writer) (StatementWriter, string, error) {
+	switch format {
+	case "csv":
+		return &csvStatementWriter{w: csv.NewWriter(w)}, "text/csv; charset=utf-8", nil
+	case "json":
+		return &jsonStatementWriter{w: w}, "application/json; charset=utf-8", nil
+	case "html":
+		var tmpl *template.Template
+		var err error
+		for _, path := range statementTemplatePaths {
+			tmpl, err = template.ParseFiles(path)
+			if err == nil {
+				break
+			}
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("load statement template: %w", err)
+		}
+		return &htmlStatementWriter{w: w, tmpl: tmpl}, "text/html; charset=utf-8", nil
+	}
+	return nil, "", ErrStatementFormat
+}
+
+type csvStatementWriter struct {
+	w  *csv.Writer
+	to time.Time
+}
+
+var statementCSVHeader = []string{
+	"time", "source", "flow_id", "entry_id", "type", "movement", "status", "amount",
+	"available_change", "frozen_change", "withdrawal_change",
+	"available", "frozen", "withdrawal", "balance", "tx_hash", "remark",
+}
+
+func (c *csvStatementWriter) Begin(h StatementHeader) error {
+	c.to = h.To
+	if err := c.w.Write(statementCSVHeader); err != nil {
+		return err
+	}
+	return c.balanceRow(h.From, "opening", h.Opening)
+}
+
+func (c *csvStatementWriter) Line(l StatementLine) error {
+	return c.w.Write([]string{
+		l.Time.Format(time.DateTime), l.Source, strconv.FormatUint(l.FlowID, 10), strconv.FormatUint(l.EntryID, 10),
+		l.Type, l.Movement, l.Status, l.Amount.String(),
+		l.Change.Available.String(), l.Change.Frozen.String(), l.Change.Withdrawal.String(),
+		l.Balance.Available.String(), l.Balance.Frozen.String(), l.Balance.Withdrawal.String(), l.Balance.Total.String(),
+		l.TxHash, l.Remark,
+	})
+}
+
+func (c *csvStatementWriter) End(s StatementSummary) error {
+	if err := c.balanceRow(c.to, "closing", s.Closing); err != nil {
+		return err
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvStatementWriter) balanceRow(t time.Time, kind string, b StatementBalances) error {
+	return c.w.Write([]string{
+		t.Format(time.DateTime), "", "", "", kind, "", "", "",
+		"", "", "",
+		b.Available.String(), b.Frozen.String(), b.Withdrawal.String(), b.Total.String(),
+		"", "",
+	})
+}
+
+// jsonStatementWriter writes {"statement": header, "lines": [...], "summary": summary} line by line.
+type jsonStatementWriter struct {
+	w     io.Writer
+	lines int
+}
+
+func (j *jsonStatementWriter) Begin(h StatementHeader) error {
+	b, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(j.w, `{"statement":%s,"lines":[`, b)
+	return err
+}
+
+func (j *jsonStatementWriter) Line(l StatementLine) error {
+	b, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+	if j.lines > 0 {
+		if _, err := io.WriteString(j.w, ","); err != nil {
+			return err
+		}
+	}
+	j.lines++
+	_, err = j.w.Write(b)
+	return err
+}
+
+func (j *jsonStatementWriter) End(s StatementSummary) error {
+	b, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(j.w, `],"summary":%s}`, b)
+	return err
+}
+
+// htmlStatementWriter renders the "header", "line" and "footer" blocks of templates/statement.html.
+type htmlStatementWriter struct {
+	w      io.Writer
+	tmpl   *template.Template
+	header StatementHeader
+}
+
+func (h *htmlStatementWriter) Begin(header StatementHeader) error {
+	h.header = header
+	return h.tmpl.ExecuteTemplate(h.w, "header", header)
+}
+
+func (h *htmlStatementWriter) Line(l StatementLine) error {
+	return h.tmpl.ExecuteTemplate(h.w, "line", l)
+}
+
+func (h *htmlStatementWriter) End(s StatementSummary) error {
+	return h.tmpl.ExecuteTemplate(h.w, "footer", struct {
+		StatementHeader
+		StatementSummary
+	}{h.header, s})
+}
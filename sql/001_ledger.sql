@@ -0,0 +1,52 @@
+-- double-entry journal behind n_account_balance
+
+CREATE TABLE `n_ledger_entry` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `biz_type` varchar(64) NOT NULL,
+  `main_id` bigint unsigned NOT NULL,
+  `asset_id` bigint unsigned NOT NULL,
+  `amount` bigint unsigned NOT NULL,
+  `ref_table` varchar(64) NOT NULL,
+  `ref_id` bigint unsigned NOT NULL,
+  `remark` varchar(255) NOT NULL DEFAULT '',
+  `add_time` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `idx_main_asset` (`main_id`,`asset_id`),
+  KEY `idx_ref` (`ref_table`,`ref_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE `n_ledger_posting` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `entry_id` bigint unsigned NOT NULL,
+  `main_id` bigint unsigned NOT NULL,
+  `asset_id` bigint unsigned NOT NULL,
+  `account` varchar(64) NOT NULL,
+  `debit` bigint unsigned NOT NULL DEFAULT '0',
+  `credit` bigint unsigned NOT NULL DEFAULT '0',
+  `add_time` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `idx_entry` (`entry_id`),
+  KEY `idx_account` (`main_id`,`asset_id`,`account`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE `n_ledger_check` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `checked_accounts` int NOT NULL,
+  `drift_count` int NOT NULL,
+  `unbalanced_entries` int NOT NULL,
+  `detail` longtext NOT NULL,
+  `add_time` datetime NOT NULL,
+  PRIMARY KEY (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE `sys_operator` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `name` varchar(255) NOT NULL,
+  `email` varchar(255) NOT NULL,
+  `password` varchar(255) NOT NULL,
+  `role` varchar(32) NOT NULL DEFAULT 'operator',
+  `status` varchar(8) NOT NULL DEFAULT '00',
+  `add_time` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uk_email` (`email`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
@@ -0,0 +1,15 @@
+-- multi-asset balances keyed by t_asset
+
+ALTER TABLE `t_asset` ADD COLUMN `decimals` int NOT NULL DEFAULT 18 AFTER `type`;
+
+-- entry fees are charged in the asset of the play setting; 0 is the legacy N balance
+ALTER TABLE `game_setting` ADD COLUMN `asset_id` bigint unsigned NOT NULL DEFAULT 0 AFTER `catalog`;
+
+-- balances created before the registry keep asset_id 0 (N, 6 decimals). Once N is registered
+-- in t_asset, point contract.nAssetId at it and move the legacy rows over:
+-- UPDATE n_account_balance SET asset_id = <n asset id> WHERE asset_id = 0;
+-- UPDATE n_account_flow SET asset_id = <n asset id> WHERE asset_id = 0;
+-- UPDATE n_account_balance_flow SET asset_id = <n asset id> WHERE asset_id = 0;
+-- UPDATE n_ledger_entry SET asset_id = <n asset id> WHERE asset_id = 0;
+-- UPDATE n_ledger_posting SET asset_id = <n asset id> WHERE asset_id = 0;
+-- UPDATE game_setting SET asset_id = <n asset id> WHERE asset_id = 0;
@@ -0,0 +1,5 @@
+-- per-game timeout for abandoned sessions, 0 falls back to session.timeout in the config
+
+ALTER TABLE `game_info` ADD COLUMN `session_timeout` int NOT NULL DEFAULT 0 AFTER `status`;
+
+ALTER TABLE `n_account_flow` ADD KEY `idx_biz_status` (`biz_type`,`status`,`add_time`);
@@ -0,0 +1,17 @@
+-- responses of money-moving requests sent with an Idempotency-Key header
+
+CREATE TABLE `n_idempotency_key` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `scope` varchar(128) NOT NULL,
+  `idem_key` varchar(128) NOT NULL,
+  `route` varchar(255) NOT NULL,
+  `request_hash` char(64) NOT NULL,
+  `status` int NOT NULL DEFAULT 0,
+  `response_code` int NOT NULL DEFAULT 0,
+  `response_body` mediumtext NOT NULL,
+  `add_time` datetime NOT NULL,
+  `update_time` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uk_scope_key` (`scope`,`idem_key`),
+  KEY `idx_add_time` (`add_time`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
@@ -0,0 +1,32 @@
+-- end-of-day balance snapshots and platform liabilities per asset
+
+CREATE TABLE `n_balance_snapshot` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `snap_date` date NOT NULL,
+  `main_id` bigint unsigned NOT NULL,
+  `asset_id` bigint unsigned NOT NULL,
+  `available` bigint unsigned NOT NULL DEFAULT 0,
+  `frozen` bigint unsigned NOT NULL DEFAULT 0,
+  `withdrawal` bigint unsigned NOT NULL DEFAULT 0,
+  `add_time` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uk_date_main_asset` (`snap_date`,`main_id`,`asset_id`),
+  KEY `idx_main_asset` (`main_id`,`asset_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE `n_liability_snapshot` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `snap_date` date NOT NULL,
+  `asset_id` bigint unsigned NOT NULL,
+  `accounts` int NOT NULL DEFAULT 0,
+  `available` decimal(65,0) NOT NULL DEFAULT 0,
+  `frozen` decimal(65,0) NOT NULL DEFAULT 0,
+  `withdrawal` decimal(65,0) NOT NULL DEFAULT 0,
+  `total` decimal(65,0) NOT NULL DEFAULT 0,
+  `add_time` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uk_date_asset` (`snap_date`,`asset_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+-- point-in-time queries replay the journal after the nearest snapshot
+ALTER TABLE `n_ledger_posting` ADD KEY `idx_add_time` (`add_time`);
@@ -0,0 +1,45 @@
+-- operator balance adjustments with maker-checker approval, and the admin audit trail
+
+CREATE TABLE `n_balance_adjustment` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `main_id` bigint unsigned NOT NULL,
+  `asset_id` bigint unsigned NOT NULL,
+  `direction` varchar(16) NOT NULL,
+  `amount` bigint unsigned NOT NULL,
+  `reason` varchar(512) NOT NULL,
+  `evidence_url` varchar(512) NOT NULL DEFAULT '',
+  `status` varchar(8) NOT NULL,
+  `required_approvals` int NOT NULL DEFAULT 1,
+  `approvals` int NOT NULL DEFAULT 0,
+  `maker_id` bigint unsigned NOT NULL,
+  `flow_id` bigint unsigned NOT NULL DEFAULT 0,
+  `add_time` datetime NOT NULL,
+  `update_time` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `idx_status` (`status`),
+  KEY `idx_main_asset` (`main_id`,`asset_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE `n_adjustment_review` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `adjustment_id` bigint unsigned NOT NULL,
+  `operator_id` bigint unsigned NOT NULL,
+  `decision` varchar(16) NOT NULL,
+  `comment` varchar(512) NOT NULL DEFAULT '',
+  `add_time` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uk_adjustment_operator` (`adjustment_id`,`operator_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE `n_admin_audit` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `operator_id` bigint unsigned NOT NULL,
+  `action` varchar(64) NOT NULL,
+  `target_table` varchar(64) NOT NULL,
+  `target_id` bigint unsigned NOT NULL,
+  `detail` text NOT NULL,
+  `add_time` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `idx_target` (`target_table`,`target_id`),
+  KEY `idx_operator` (`operator_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
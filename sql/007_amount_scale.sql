@@ -0,0 +1,46 @@
+-- asset scales: decimals is the ledger scale, chain_decimals the token contract scale and
+-- display_decimals how many decimals amounts are shown with (0 = all of decimals)
+
+ALTER TABLE `t_asset`
+  ADD COLUMN `chain_decimals` int NOT NULL DEFAULT 0 AFTER `decimals`,
+  ADD COLUMN `display_decimals` int NOT NULL DEFAULT 0 AFTER `chain_decimals`;
+
+-- until now decimals held the contract scale of every registered token
+UPDATE `t_asset` SET `chain_decimals` = `decimals` WHERE `chain_decimals` = 0;
+
+-- a uint64 column holds at most ~18.4 tokens at 18 decimals: move assets nobody holds yet to
+-- 6 ledger decimals. Assets with balances keep their scale and are reported below.
+UPDATE `t_asset` a SET a.`decimals` = 6
+WHERE a.`decimals` > 9
+  AND NOT EXISTS (SELECT 1 FROM `n_account_balance` b WHERE b.`asset_id` = a.`id`)
+  AND NOT EXISTS (SELECT 1 FROM `n_account_balance_flow` f WHERE f.`asset_id` = a.`id`);
+
+-- rows written before the conversion existed, for an operator to settle with balance adjustments
+CREATE TABLE `n_amount_scale_issue` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `kind` varchar(32) NOT NULL,
+  `target_table` varchar(64) NOT NULL,
+  `target_id` bigint unsigned NOT NULL,
+  `asset_id` bigint unsigned NOT NULL,
+  `main_id` bigint unsigned NOT NULL DEFAULT 0,
+  `ledger_amount` bigint unsigned NOT NULL DEFAULT 0,
+  `chain_amount` bigint unsigned NOT NULL DEFAULT 0,
+  `add_time` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uk_kind_target` (`kind`,`target_table`,`target_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+-- confirmed chain operations credited with the raw chain amount instead of ledger units
+INSERT IGNORE INTO `n_amount_scale_issue` (`kind`, `target_table`, `target_id`, `asset_id`, `main_id`, `ledger_amount`, `chain_amount`, `add_time`)
+SELECT 'raw_chain_amount', 'n_account_balance_flow', f.`id`, f.`asset_id`, f.`main_id`, f.`amount`, f.`real_amount`, NOW()
+FROM `n_account_balance_flow` f
+WHERE f.`op` IN (0, 2, 3) AND f.`status` = 1 AND f.`real_amount` <> f.`amount`;
+
+-- assets whose ledger scale is still too fine for a uint64 balance
+INSERT IGNORE INTO `n_amount_scale_issue` (`kind`, `target_table`, `target_id`, `asset_id`, `add_time`)
+SELECT 'ledger_scale', 't_asset', a.`id`, a.`id`, NOW()
+FROM `t_asset` a
+WHERE a.`decimals` > 9;
+
+-- review before going live:
+-- SELECT kind, asset_id, COUNT(*) FROM n_amount_scale_issue GROUP BY kind, asset_id;
@@ -0,0 +1,44 @@
+-- spend fee schedules and the split of every spend between platform, developer and prize pool
+
+CREATE TABLE `n_fee_schedule` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `game_id` bigint unsigned NOT NULL DEFAULT 0,
+  `catalog` varchar(64) NOT NULL DEFAULT '',
+  `platform_bps` int NOT NULL,
+  `developer_bps` int NOT NULL,
+  `prize_pool_bps` int NOT NULL,
+  `effective_from` datetime NOT NULL,
+  `operator_id` bigint unsigned NOT NULL DEFAULT 0,
+  `remark` varchar(255) NOT NULL DEFAULT '',
+  `add_time` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `idx_game_catalog_from` (`game_id`,`catalog`,`effective_from`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE `n_spend_split` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `flow_id` bigint unsigned NOT NULL,
+  `main_id` bigint unsigned NOT NULL,
+  `asset_id` bigint unsigned NOT NULL,
+  `game_id` bigint unsigned NOT NULL,
+  `catalog` varchar(64) NOT NULL DEFAULT '',
+  `schedule_id` bigint unsigned NOT NULL DEFAULT 0,
+  `developer_id` bigint unsigned NOT NULL DEFAULT 0,
+  `season_id` bigint unsigned NOT NULL DEFAULT 0,
+  `amount` bigint unsigned NOT NULL,
+  `platform_fee` bigint unsigned NOT NULL,
+  `developer` bigint unsigned NOT NULL,
+  `prize_pool` bigint unsigned NOT NULL,
+  `add_time` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uk_flow` (`flow_id`),
+  KEY `idx_season` (`season_id`),
+  KEY `idx_game` (`game_id`,`add_time`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+-- the catalog a session was started with, to find its schedule when it ends
+ALTER TABLE `game_session` ADD COLUMN `catalog` varchar(64) NOT NULL DEFAULT '';
+
+-- until a schedule is inserted every spend goes to the platform fee, as before
+INSERT INTO `n_fee_schedule` (`game_id`, `catalog`, `platform_bps`, `developer_bps`, `prize_pool_bps`, `effective_from`, `remark`, `add_time`)
+VALUES (0, '', 10000, 0, 0, '2000-01-01 00:00:00', 'platform default', NOW());
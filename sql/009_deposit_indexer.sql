@@ -0,0 +1,30 @@
+-- log-driven deposit indexer: progress per chain and deposits nobody could be matched to
+
+CREATE TABLE `n_chain_cursor` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `chain_id` bigint unsigned NOT NULL,
+  `name` varchar(64) NOT NULL,
+  `contract` varchar(64) NOT NULL DEFAULT '',
+  `block_number` bigint unsigned NOT NULL DEFAULT 0,
+  `update_time` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uk_chain_name` (`chain_id`,`name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE `n_unmatched_deposit` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `chain_id` bigint unsigned NOT NULL,
+  `contract` varchar(64) NOT NULL,
+  `tx_hash` varchar(255) NOT NULL,
+  `log_index` int NOT NULL,
+  `block_number` bigint unsigned NOT NULL,
+  `depositor` varchar(64) NOT NULL,
+  `amount` decimal(65,0) NOT NULL,
+  `status` varchar(8) NOT NULL DEFAULT '00',
+  `add_time` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uk_chain_tx_log` (`chain_id`,`tx_hash`,`log_index`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+-- the indexer looks flows up by tx hash
+ALTER TABLE `n_account_balance_flow` ADD INDEX `idx_tx_hash` (`tx_hash`);
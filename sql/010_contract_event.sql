@@ -0,0 +1,24 @@
+-- operations record of TopupLogic owner events: revoked locks, sweeps, signer and ttl changes
+
+CREATE TABLE `n_contract_event` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `chain_id` bigint unsigned NOT NULL,
+  `contract` varchar(64) NOT NULL,
+  `tx_hash` varchar(255) NOT NULL,
+  `log_index` int NOT NULL,
+  `block_number` bigint unsigned NOT NULL,
+  `block_time` datetime NOT NULL,
+  `event` varchar(64) NOT NULL,
+  `severity` varchar(16) NOT NULL,
+  `detail` text NOT NULL,
+  `status` varchar(8) NOT NULL DEFAULT '00',
+  `ack_operator` bigint unsigned NOT NULL DEFAULT 0,
+  `ack_time` datetime DEFAULT NULL,
+  `add_time` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uk_chain_tx_log` (`chain_id`,`tx_hash`,`log_index`),
+  KEY `idx_status` (`status`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+-- revocations find the withdraw lock by its on-chain id
+ALTER TABLE `n_account_balance_flow` ADD INDEX `idx_lock_id` (`lock_id`);
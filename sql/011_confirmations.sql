@@ -0,0 +1,4 @@
+-- the block a chain tx was confirmed in, re-checked by the reorg checker
+
+ALTER TABLE `n_account_balance_flow` ADD COLUMN `block_hash` varchar(80) NOT NULL DEFAULT '' AFTER `block_timestamp`;
+ALTER TABLE `n_account_balance_flow` ADD INDEX `idx_chain_block` (`chain_id`,`block_height`);
@@ -0,0 +1,20 @@
+-- durable background jobs: chain tx checks and other work that must survive a restart
+
+CREATE TABLE `n_job` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `queue` varchar(64) NOT NULL,
+  `dedup_key` varchar(191) NOT NULL,
+  `payload` text NOT NULL,
+  `status` varchar(8) NOT NULL DEFAULT '00',
+  `attempts` int NOT NULL DEFAULT 0,
+  `max_attempts` int NOT NULL,
+  `run_at` datetime NOT NULL,
+  `locked_by` varchar(128) NOT NULL DEFAULT '',
+  `locked_until` datetime DEFAULT NULL,
+  `last_error` text NOT NULL,
+  `add_time` datetime NOT NULL,
+  `update_time` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uk_queue_dedup` (`queue`,`dedup_key`),
+  KEY `idx_queue_status_run` (`queue`,`status`,`run_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
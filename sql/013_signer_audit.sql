@@ -0,0 +1,22 @@
+-- every LockAuth digest handed to a withdrawal signer; reserved and signed rows count against its limits
+
+CREATE TABLE `n_signer_audit` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `signer` varchar(64) NOT NULL,
+  `address` varchar(64) NOT NULL,
+  `chain_id` bigint unsigned NOT NULL,
+  `contract` varchar(64) NOT NULL,
+  `digest` varchar(80) NOT NULL,
+  `main_id` bigint unsigned NOT NULL,
+  `user_addr` varchar(64) NOT NULL,
+  `lock_id` varchar(80) NOT NULL,
+  `amount` decimal(65,0) NOT NULL,
+  `status` varchar(8) NOT NULL,
+  `reason` varchar(255) NOT NULL DEFAULT '',
+  `signature` varchar(140) NOT NULL DEFAULT '',
+  `add_time` datetime NOT NULL,
+  `update_time` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `idx_signer_chain_time` (`signer`,`chain_id`,`add_time`),
+  KEY `idx_lock_id` (`lock_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
@@ -0,0 +1,2 @@
+-- the lock watcher looks for open withdraw locks past their expiry
+ALTER TABLE `n_account_balance_flow` ADD INDEX `idx_op_status_expiry` (`op`,`status`,`lock_expiry`);
@@ -0,0 +1,18 @@
+-- balance changes of linked Solana wallets in the watched mints; the watcher may write a slot twice
+
+CREATE TABLE `n_solana_trade` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `slot` bigint unsigned NOT NULL,
+  `block_time` datetime DEFAULT NULL,
+  `signature` varchar(100) NOT NULL,
+  `owner` varchar(64) NOT NULL,
+  `mint` varchar(64) NOT NULL,
+  `side` varchar(8) NOT NULL,
+  `amount` decimal(65,18) NOT NULL,
+  `fee_lamport` bigint unsigned NOT NULL DEFAULT 0,
+  `success` tinyint(1) NOT NULL,
+  `add_time` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uk_signature_owner_mint` (`signature`,`owner`,`mint`),
+  KEY `idx_owner_slot` (`owner`,`slot`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
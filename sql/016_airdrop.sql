@@ -0,0 +1,36 @@
+-- merkle airdrop campaigns and the leaf, proof and claim of every wallet in them
+
+CREATE TABLE `n_airdrop_campaign` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `name` varchar(64) NOT NULL,
+  `chain_id` bigint unsigned NOT NULL,
+  `contract` varchar(64) NOT NULL DEFAULT '',
+  `start_block` bigint unsigned NOT NULL DEFAULT 0,
+  `decimals` int NOT NULL,
+  `merkle_root` varchar(80) NOT NULL,
+  `leaves` int NOT NULL,
+  `total` decimal(65,0) NOT NULL,
+  `source` varchar(32) NOT NULL,
+  `status` varchar(8) NOT NULL,
+  `operator_id` bigint unsigned NOT NULL,
+  `add_time` datetime NOT NULL,
+  `update_time` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uk_name` (`name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
+
+CREATE TABLE `n_airdrop_leaf` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `campaign_id` bigint unsigned NOT NULL,
+  `idx` int NOT NULL,
+  `wallet` varchar(64) NOT NULL,
+  `amount` decimal(65,0) NOT NULL,
+  `leaf` varchar(80) NOT NULL,
+  `proof` text NOT NULL,
+  `claim_tx` varchar(255) NOT NULL DEFAULT '',
+  `claim_block` bigint unsigned NOT NULL DEFAULT 0,
+  `claim_time` datetime DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `uk_campaign_wallet` (`campaign_id`,`wallet`),
+  KEY `idx_wallet` (`wallet`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci;
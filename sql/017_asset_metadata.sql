@@ -0,0 +1,7 @@
+-- when the token metadata of an asset was last read from its contract; null for hand-made rows
+
+ALTER TABLE `t_asset` ADD COLUMN `meta_time` datetime DEFAULT NULL AFTER `add_time`;
+
+-- discovery refuses a second row of the same token, enforced by the unique key of 021_asset_unique.sql;
+-- check for existing ones first:
+-- SELECT `chain`, LOWER(`ca`), COUNT(*) FROM `t_asset` WHERE `ca` <> '' GROUP BY 1, 2 HAVING COUNT(*) > 1;
@@ -0,0 +1,13 @@
+-- one deposit log credits one user: no two live recharge flows may hold the same chain, tx and log.
+-- Failed recharges leave the key, so a report refused for a wallet that did not deposit does not
+-- block the depositor's own flow.
+
+ALTER TABLE `n_account_balance_flow`
+  ADD COLUMN `deposit_tx_hash` varchar(255) GENERATED ALWAYS AS (
+    CASE WHEN `op` = 0 AND `status` <> 2 AND `tx_hash` <> '' THEN `tx_hash` END
+  ) STORED,
+  ADD UNIQUE KEY `uk_chain_tx_log_op` (`chain_id`,`deposit_tx_hash`,`log_index`,`op`);
+
+-- check for deposits already held twice first:
+-- SELECT `chain_id`, `tx_hash`, `log_index`, GROUP_CONCAT(`id`), GROUP_CONCAT(`main_id`) FROM `n_account_balance_flow`
+-- WHERE `op` = 0 AND `status` <> 2 AND `tx_hash` <> '' GROUP BY 1, 2, 3 HAVING COUNT(*) > 1;
@@ -0,0 +1,5 @@
+-- operator passwords are bcrypt hashes; plaintext ones are cleared and must be set again with
+-- go run ./cmd/operator -email <email> [-role finance|admin]
+-- roles: operator reads, finance also moves funds, admin also runs contract, airdrop, asset and job actions
+
+UPDATE `sys_operator` SET `password` = '' WHERE `password` NOT LIKE '$2_$%';
@@ -0,0 +1,4 @@
+-- deposits are credited in the asset of the token their contract holds; a deposit whose token is
+-- not registered on the chain is kept unmatched, like one from a wallet no user linked
+
+ALTER TABLE `n_unmatched_deposit` ADD COLUMN `reason` varchar(16) NOT NULL DEFAULT 'no_wallet' AFTER `status`;
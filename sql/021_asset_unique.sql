@@ -0,0 +1,9 @@
+-- one t_asset row per token: a unique key on the chain and the lowercased contract address, so two
+-- concurrent discoveries of a token cannot both register it. Native coins (empty ca) are left out.
+
+ALTER TABLE `t_asset`
+  ADD COLUMN `ca_key` varchar(64) GENERATED ALWAYS AS (CASE WHEN `ca` <> '' THEN LOWER(`ca`) END) STORED,
+  ADD UNIQUE KEY `uk_chain_ca` (`chain`, `ca_key`);
+
+-- check for tokens registered twice first:
+-- SELECT `chain`, LOWER(`ca`), GROUP_CONCAT(`id`) FROM `t_asset` WHERE `ca` <> '' GROUP BY 1, 2 HAVING COUNT(*) > 1;
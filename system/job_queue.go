@@ -0,0 +1,234 @@
+package system
+
+import (
+	"chaos/api/log"
+	"chaos/api/model"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	sqldriver "github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	defaultJobMaxAttempts = 30
+	defaultJobVisibility  = 2 * time.Minute
+	defaultJobBaseBackoff = 5 * time.Second
+	defaultJobMaxBackoff  = 5 * time.Minute
+	jobPollInterval       = time.Second
+	jobErrorLimit         = 1000 // characters of a handler error kept on the job
+)
+
+// JobHandler runs one claimed job. A nil error completes it; any error schedules another
+// attempt after the backoff, or dead-letters the job once it is out of attempts.
+type JobHandler func(ctx context.Context, job model.Job) error
+
+// JobQueue is a durable queue stored in n_job. Jobs survive restarts and any number of
+// instances may consume the same queue: claims use SKIP LOCKED and hold a job only for the
+// visibility timeout, after which an unfinished job is handed to the next worker.
+type JobQueue struct {
+	Name        string
+	MaxAttempts int
+	Visibility  time.Duration
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+
+	worker string
+	notify chan struct{}
+}
+
+func NewJobQueue(name string) *JobQueue {
+	host, _ := os.Hostname()
+	return &JobQueue{
+		Name:        name,
+		MaxAttempts: defaultJobMaxAttempts,
+		Visibility:  defaultJobVisibility,
+		BaseBackoff: defaultJobBaseBackoff,
+		MaxBackoff:  defaultJobMaxBackoff,
+		worker:      fmt.Sprintf("%s-%d", host, os.Getpid()),
+		notify:      make(chan struct{}, 1),
+	}
+}
+
+// JobBackoff is the wait before attempt+1 after attempt failed: base doubled per attempt, capped.
+func JobBackoff(attempt int, base, max time.Duration) time.Duration {
+	d := base
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	return min(d, max)
+}
+
+// Enqueue adds a job under key. A job already waiting or running under the same key is left
+// alone; a finished or dead one is revived with the new payload and a fresh set of attempts.
+func (q *JobQueue) Enqueue(key string, payload interface{}) error {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	job := model.Job{
+		Queue:       q.Name,
+		DedupKey:    key,
+		Payload:     string(b),
+		Status:      model.JobStatusPending,
+		MaxAttempts: q.MaxAttempts,
+		RunAt:       now,
+		AddTime:     now,
+		UpdateTime:  now,
+	}
+	err = GetDb().Create(&job).Error
+	if err != nil && !isDuplicateEntry(err) {
+		return err
+	}
+	if err != nil {
+		err = GetDb().Model(&model.Job{}).
+			Where("queue = ? AND dedup_key = ? AND status IN ?", q.Name, key, []string{model.JobStatusDone, model.JobStatusDead}).
+			Updates(map[string]interface{}{
+				"payload":      string(b),
+				"status":       model.JobStatusPending,
+				"attempts":     0,
+				"max_attempts": q.MaxAttempts,
+				"run_at":       now,
+				"locked_by":    "",
+				"locked_until": nil,
+				"last_error":   "",
+				"update_time":  now,
+			}).Error
+		if err != nil {
+			return err
+		}
+	}
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Consume claims and runs jobs, up to concurrency at a time, until ctx is done.
+func (q *JobQueue) Consume(ctx context.Context, concurrency int, handle JobHandler) {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	ticker := time.NewTicker(jobPollInterval)
+	defer ticker.Stop()
+
+	for {
+		jobs, err := q.claim(concurrency)
+		if err != nil {
+			log.Errorf("[JobQueue] %s claim failed: %v", q.Name, err)
+		}
+		var wg sync.WaitGroup
+		for _, job := range jobs {
+			wg.Add(1)
+			go func(job model.Job) {
+				defer wg.Done()
+				q.run(ctx, job, handle)
+			}(job)
+		}
+		wg.Wait()
+		if len(jobs) == concurrency && ctx.Err() == nil {
+			// there may be more ready
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-q.notify:
+		}
+	}
+}
+
+// claim takes up to n ready jobs, including running ones whose visibility timeout ran out.
+func (q *JobQueue) claim(n int) ([]model.Job, error) {
+	var jobs []model.Job
+	err := GetDb().Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("queue = ? AND ((status = ? AND run_at <= ?) OR (status = ? AND locked_until < ?))",
+				q.Name, model.JobStatusPending, now, model.JobStatusRunning, now).
+			Order("run_at").Limit(n).Find(&jobs).Error
+		if err != nil || len(jobs) == 0 {
+			return err
+		}
+		until := now.Add(q.Visibility)
+		ids := make([]uint64, 0, len(jobs))
+		for i := range jobs {
+			ids = append(ids, jobs[i].ID)
+			jobs[i].Status = model.JobStatusRunning
+			jobs[i].Attempts++
+			jobs[i].LockedBy = q.worker
+			jobs[i].LockedUntil = &until
+		}
+		return tx.Model(&model.Job{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+			"status":       model.JobStatusRunning,
+			"attempts":     gorm.Expr("attempts + 1"),
+			"locked_by":    q.worker,
+			"locked_until": until,
+			"update_time":  now,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+func (q *JobQueue) run(ctx context.Context, job model.Job, handle JobHandler) {
+	runCtx, cancel := context.WithTimeout(ctx, q.Visibility)
+	defer cancel()
+
+	var err error
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+		err = handle(runCtx, job)
+	}()
+
+	now := time.Now()
+	updates := map[string]interface{}{"locked_by": "", "locked_until": nil, "update_time": now}
+	if err == nil {
+		updates["status"] = model.JobStatusDone
+		updates["last_error"] = ""
+	} else {
+		msg := err.Error()
+		if len(msg) > jobErrorLimit {
+			msg = msg[:jobErrorLimit]
+		}
+		updates["last_error"] = msg
+		if job.Attempts >= job.MaxAttempts {
+			updates["status"] = model.JobStatusDead
+			log.Errorf("[JobQueue] %s job %d (%s) dead after %d attempts: %v", q.Name, job.ID, job.DedupKey, job.Attempts, err)
+		} else {
+			updates["status"] = model.JobStatusPending
+			updates["run_at"] = now.Add(JobBackoff(job.Attempts, q.BaseBackoff, q.MaxBackoff))
+			log.Warnf("[JobQueue] %s job %d (%s) attempt %d failed: %v", q.Name, job.ID, job.DedupKey, job.Attempts, err)
+		}
+	}
+	// a worker that overran its visibility timeout lost the job to another one: leave it be
+	res := GetDb().Model(&model.Job{}).
+		Where("id = ? AND status = ? AND locked_by = ? AND attempts = ?", job.ID, model.JobStatusRunning, q.worker, job.Attempts).
+		Updates(updates)
+	if res.Error != nil {
+		log.Errorf("[JobQueue] %s job %d: record outcome failed: %v", q.Name, job.ID, res.Error)
+	} else if res.RowsAffected == 0 {
+		log.Warnf("[JobQueue] %s job %d was reclaimed before it finished", q.Name, job.ID)
+	}
+}
+
+func isDuplicateEntry(err error) bool {
+	var me *sqldriver.MySQLError
+	return errors.As(err, &me) && me.Number == 1062
+}
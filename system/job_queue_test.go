@@ -0,0 +1,26 @@
+package system
+
+import (
+	"testing"
+	"time"
+)
+
+func TestJobBackoff(t *testing.T) {
+	base, max := 5*time.Second, 5*time.Minute
+	cases := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{0, 5 * time.Second},
+		{1, 5 * time.Second},
+		{2, 10 * time.Second},
+		{3, 20 * time.Second},
+		{7, 5 * time.Minute},
+		{30, 5 * time.Minute},
+	}
+	for _, c := range cases {
+		if got := JobBackoff(c.attempt, base, max); got != c.want {
+			t.Errorf("attempt %d: backoff %s, want %s", c.attempt, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package system
+
+import (
+	"context"
+	"fmt"
+)
+
+// scheduledLockPrefix namespaces the MySQL named locks of scheduled jobs.
+const scheduledLockPrefix = "chaos:job:"
+
+// RunExclusive runs fn only if no other instance is running the job name, by holding the MySQL
+// named lock of the job on one connection while fn runs. It reports false, without waiting,
+// when another instance holds the lock.
+func RunExclusive(ctx context.Context, name string, fn func()) (bool, error) {
+	sqlDB, err := GetDb().DB()
+	if err != nil {
+		return false, err
+	}
+	// GET_LOCK belongs to the session: take and release it on the same connection
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	key := scheduledLockPrefix + name
+	var got *int
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 0)", key).Scan(&got); err != nil {
+		return false, fmt.Errorf("get lock %s: %w", key, err)
+	}
+	if got == nil || *got != 1 {
+		return false, nil
+	}
+	defer func() {
+		// a background context: the lock must go even when ctx was canceled meanwhile
+		_, _ = conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", key)
+	}()
+	fn()
+	return true, nil
+}
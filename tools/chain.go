@@ -4,6 +4,7 @@ import (
 	"chaos/api/config"
 	"chaos/api/log"
 	"context"
+	"errors"
 	"fmt"
 	"math/big"
 	"strings"
@@ -55,43 +56,46 @@ func newNToken() (*ERC20Token, error) {
 		return nil, fmt.Errorf("N token address is empty")
 	}
 
-	chainConfig := config.GetRpcConfig("BSC")
-	if chainConfig == nil {
-		return nil, fmt.Errorf("BSC chain config is nil")
+	// N 代币部署在 BSC 上
+	chain, err := LookupChainByName("BSC")
+	if err != nil {
+		return nil, err
 	}
-
-	var rpcURL string
-	for _, chain := range chainConfig.GetRpc() {
-		rpcURL = chain
-		break // 只取第一个 RPC URL
+	if chain.TokenContract != "" {
+		tokenAddr = chain.TokenContract
 	}
-
-	if rpcURL == "" {
+	candidates := chain.candidates(time.Now())
+	if len(candidates) == 0 {
 		return nil, fmt.Errorf("no RPC URL found for BSC")
 	}
+	rpcURL := candidates[0].url
 
 	return NewERC20Token(rpcURL, tokenAddr)
 }
 
 // GetClient 获取或创建指定网络的客户端
 func (cm *ClientManager) GetClient(rpcURL string) (*ethclient.Client, error) {
+	client, err := cm.dial(rpcURL)
+	if err != nil {
+		return nil, err
+	}
+	// 检查连接是否健康，不健康则移除并重新创建
+	if cm.isClientHealthy(client) {
+		return client, nil
+	}
+	cm.drop(rpcURL, client)
+	return cm.dial(rpcURL)
+}
+
+// dial 获取缓存的客户端，没有则创建
+func (cm *ClientManager) dial(rpcURL string) (*ethclient.Client, error) {
 	cm.mutex.RLock()
 	client, exists := cm.clients[rpcURL]
 	cm.mutex.RUnlock()
-
 	if exists {
-		// 检查连接是否健康
-		if cm.isClientHealthy(client) {
-			return client, nil
-		}
-		// 连接不健康，移除并重新创建
-		cm.mutex.Lock()
-		delete(cm.clients, rpcURL)
-		client.Close()
-		cm.mutex.Unlock()
+		return client, nil
 	}
 
-	// 创建新客户端
 	cm.mutex.Lock()
 	defer cm.mutex.Unlock()
 
@@ -109,6 +113,16 @@ func (cm *ClientManager) GetClient(rpcURL string) (*ethclient.Client, error) {
 	return client, nil
 }
 
+// drop 关闭并移除缓存的客户端
+func (cm *ClientManager) drop(rpcURL string, client *ethclient.Client) {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+	if cm.clients[rpcURL] == client {
+		delete(cm.clients, rpcURL)
+		client.Close()
+	}
+}
+
 // isClientHealthy 检查客户端连接是否健康
 func (cm *ClientManager) isClientHealthy(client *ethclient.Client) bool {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -141,7 +155,9 @@ func GetGlobalClient() *ClientManager {
 const erc20ABI = `[
   {"constant":true,"inputs":[{"name":"account","type":"address"}],"name":"balanceOf","outputs":[{"name":"","type":"uint256"}],"stateMutability":"view","type":"function"},
   {"constant":true,"inputs":[],"name":"decimals","outputs":[{"name":"","type":"uint8"}],"stateMutability":"view","type":"function"},
-  {"constant":true,"inputs":[],"name":"symbol","outputs":[{"name":"","type":"string"}],"stateMutability":"view","type":"function"}
+  {"constant":true,"inputs":[],"name":"symbol","outputs":[{"name":"","type":"string"}],"stateMutability":"view","type":"function"},
+  {"constant":true,"inputs":[],"name":"name","outputs":[{"name":"","type":"string"}],"stateMutability":"view","type":"function"},
+  {"constant":true,"inputs":[],"name":"totalSupply","outputs":[{"name":"","type":"uint256"}],"stateMutability":"view","type":"function"}
 ]`
 
 type ERC20Token struct {
@@ -170,6 +186,19 @@ func NewERC20Token(rpcURL, tokenAddr string) (*ERC20Token, error) {
 	}, nil
 }
 
+// NewChainERC20Token 在注册表中链的首选 RPC 上创建代币实例
+func NewChainERC20Token(chainID uint64, tokenAddr string) (*ERC20Token, error) {
+	chain, err := LookupChain(chainID)
+	if err != nil {
+		return nil, err
+	}
+	candidates := chain.candidates(time.Now())
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no RPC URL found for chain %d", chainID)
+	}
+	return NewERC20Token(candidates[0].url, tokenAddr)
+}
+
 // 查询代币余额
 func (t *ERC20Token) BalanceOf(ctx context.Context, wallet string) (*big.Int, error) {
 	addr := common.HexToAddress(wallet)
@@ -240,6 +269,97 @@ func (t *ERC20Token) Symbol(ctx context.Context) (string, error) {
 	return symbol, err
 }
 
+// 查询代币名称
+func (t *ERC20Token) Name(ctx context.Context) (string, error) {
+	data, err := t.ABI.Pack("name")
+	if err != nil {
+		return "", fmt.Errorf("pack name data failed: %w", err)
+	}
+
+	result, err := t.Client.CallContract(ctx, ethereum.CallMsg{
+		To:   &t.Address,
+		Data: data,
+	}, nil)
+	if err != nil {
+		return "", fmt.Errorf("call name contract failed: %w", err)
+	}
+
+	var name string
+	err = t.ABI.UnpackIntoInterface(&name, "name", result)
+	if err != nil {
+		return "", fmt.Errorf("unpack name result failed: %w", err)
+	}
+	return name, err
+}
+
+// 查询代币总量
+func (t *ERC20Token) TotalSupply(ctx context.Context) (*big.Int, error) {
+	data, err := t.ABI.Pack("totalSupply")
+	if err != nil {
+		return nil, fmt.Errorf("pack totalSupply data failed: %w", err)
+	}
+
+	result, err := t.Client.CallContract(ctx, ethereum.CallMsg{
+		To:   &t.Address,
+		Data: data,
+	}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("call totalSupply contract failed: %w", err)
+	}
+
+	var supply *big.Int
+	err = t.ABI.UnpackIntoInterface(&supply, "totalSupply", result)
+	if err != nil {
+		return nil, fmt.Errorf("unpack totalSupply result failed: %w", err)
+	}
+	return supply, err
+}
+
+var ErrNotERC20 = errors.New("contract does not behave like an ERC20")
+
+// ERC20Metadata is what a token contract says about itself.
+type ERC20Metadata struct {
+	Address     string   `json:"address"`
+	Name        string   `json:"name"`
+	Symbol      string   `json:"symbol"`
+	Decimals    uint8    `json:"decimals"`
+	TotalSupply *big.Int `json:"total_supply"`
+}
+
+// Metadata 读取代币信息，并确认合约像 ERC20 一样响应：有代码，decimals、symbol、totalSupply、
+// balanceOf 都能调用。name 是可选的，缺失时取 symbol
+func (t *ERC20Token) Metadata(ctx context.Context) (ERC20Metadata, error) {
+	code, err := t.Client.CodeAt(ctx, t.Address, nil)
+	if err != nil {
+		return ERC20Metadata{}, fmt.Errorf("get code failed: %w", err)
+	}
+	if len(code) == 0 {
+		return ERC20Metadata{}, fmt.Errorf("%w: no contract at %s", ErrNotERC20, t.Address.Hex())
+	}
+
+	md := ERC20Metadata{Address: t.Address.Hex()}
+	if md.Decimals, err = t.Decimals(ctx); err != nil {
+		return ERC20Metadata{}, fmt.Errorf("%w: %v", ErrNotERC20, err)
+	}
+	if md.Symbol, err = t.Symbol(ctx); err != nil {
+		return ERC20Metadata{}, fmt.Errorf("%w: %v", ErrNotERC20, err)
+	}
+	if md.TotalSupply, err = t.TotalSupply(ctx); err != nil {
+		return ERC20Metadata{}, fmt.Errorf("%w: %v", ErrNotERC20, err)
+	}
+	if _, err = t.BalanceOf(ctx, common.Address{}.Hex()); err != nil {
+		return ERC20Metadata{}, fmt.Errorf("%w: %v", ErrNotERC20, err)
+	}
+	if md.Name, err = t.Name(ctx); err != nil || md.Name == "" {
+		md.Name = md.Symbol
+	}
+	md.Symbol = strings.TrimSpace(md.Symbol)
+	if md.Symbol == "" {
+		return ERC20Metadata{}, fmt.Errorf("%w: empty symbol", ErrNotERC20)
+	}
+	return md, nil
+}
+
 // 将原始余额转成可读格式
 func FormatBalance(raw *big.Int, decimals uint8) string {
 	if decimals == 0 {
@@ -0,0 +1,108 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// erc20Stub answers eth_getCode with code and eth_call with the outputs of the methods in calls,
+// reverting any other call.
+func erc20Stub(t *testing.T, code string, calls map[string][]interface{}) *ERC20Token {
+	t.Helper()
+	parsed, err := abi.JSON(strings.NewReader(erc20ABI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage   `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+			return
+		}
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+		switch req.Method {
+		case "eth_getCode":
+			resp["result"] = code
+		case "eth_call":
+			var msg struct {
+				Input string `json:"input"`
+				Data  string `json:"data"`
+			}
+			_ = json.Unmarshal(req.Params[0], &msg)
+			input := msg.Input
+			if input == "" {
+				input = msg.Data
+			}
+			resp["error"] = map[string]interface{}{"code": 3, "message": "execution reverted"}
+			if m, err := parsed.MethodById(hexutil.MustDecode(input)[:4]); err == nil {
+				if out, ok := calls[m.Name]; ok {
+					b, err := m.Outputs.Pack(out...)
+					if err != nil {
+						t.Error(err)
+					}
+					delete(resp, "error")
+					resp["result"] = hexutil.Encode(b)
+				}
+			}
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := ethclient.Dial(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &ERC20Token{Address: [20]byte{0xaa}, ABI: parsed, Client: client}
+}
+
+func TestERC20Metadata(t *testing.T) {
+	token := map[string][]interface{}{
+		"decimals":    {uint8(18)},
+		"symbol":      {"USDT"},
+		"name":        {"Tether USD"},
+		"totalSupply": {big.NewInt(1e18)},
+		"balanceOf":   {big.NewInt(0)},
+	}
+	md, err := erc20Stub(t, "0x6080", token).Metadata(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if md.Symbol != "USDT" || md.Name != "Tether USD" || md.Decimals != 18 || md.TotalSupply.Cmp(big.NewInt(1e18)) != 0 {
+		t.Fatalf("metadata %+v", md)
+	}
+
+	// name is optional
+	noName := map[string][]interface{}{}
+	for k, v := range token {
+		if k != "name" {
+			noName[k] = v
+		}
+	}
+	if md, err := erc20Stub(t, "0x6080", noName).Metadata(context.Background()); err != nil || md.Name != "USDT" {
+		t.Fatalf("without name: %v %+v", err, md)
+	}
+
+	// a wallet, and a contract without totalSupply
+	if _, err := erc20Stub(t, "0x", token).Metadata(context.Background()); !errors.Is(err, ErrNotERC20) {
+		t.Fatalf("no code: %v", err)
+	}
+	noSupply := map[string][]interface{}{"decimals": {uint8(6)}, "symbol": {"X"}, "balanceOf": {big.NewInt(0)}}
+	if _, err := erc20Stub(t, "0x6080", noSupply).Metadata(context.Background()); !errors.Is(err, ErrNotERC20) {
+		t.Fatalf("no totalSupply: %v", err)
+	}
+}
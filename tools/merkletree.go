@@ -224,6 +224,24 @@ func (m *MerkleTree) GetMerklePathHex(content TreeContent) ([]string, []int64, e
 	return nil, nil, nil
 }
 
+// LeafPathHex is GetMerklePathHex of the i-th leaf, without searching the leaves for its content.
+func (m *MerkleTree) LeafPathHex(i int) []string {
+	if i < 0 || i >= len(m.Leafs) {
+		return nil
+	}
+	current := m.Leafs[i]
+	var merklePath []string
+	for currentParent := current.Parent; currentParent != nil; currentParent = currentParent.Parent {
+		if bytes.Equal(currentParent.Left.Hash, current.Hash) {
+			merklePath = append(merklePath, hexutil.Encode(currentParent.Right.Hash))
+		} else {
+			merklePath = append(merklePath, hexutil.Encode(currentParent.Left.Hash))
+		}
+		current = currentParent
+	}
+	return merklePath
+}
+
 func buildWithContent(cs []TreeContent, t *MerkleTree) (*Node, []*Node, error) {
 	if len(cs) == 0 {
 		return nil, nil, errors.New("error: cannot construct tree with no content")
@@ -0,0 +1,266 @@
+package tools
+
+import (
+	"chaos/api/config"
+	"chaos/api/log"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+var ErrUnknownChain = errors.New("unknown chain id")
+
+const (
+	breakerThreshold   = 3                // consecutive failures that take an rpc out of rotation
+	breakerCooldown    = 30 * time.Second // first time out, doubled on every failed trial after it
+	breakerMaxCooldown = 5 * time.Minute
+	defaultRpcLatency  = 200 * time.Millisecond // assumed until an rpc has been probed
+	rpcProbeTimeout    = 5 * time.Second
+	revertErrorCode    = 3 // json-rpc error code of a reverted eth_call
+)
+
+// EvmChain is one entry of the chain registry: the chain section of the config with the
+// health of its rpcs.
+type EvmChain struct {
+	config.ChainConfig
+	endpoints []*rpcEndpoint
+}
+
+// rpcEndpoint tracks one rpc of a chain. Its circuit opens after breakerThreshold failed calls
+// in a row; once the cooldown is over it is probed again, a success closes it and a failure
+// reopens it for twice as long.
+type rpcEndpoint struct {
+	url    string
+	weight int // RpcMapper.Quote, at least 1
+
+	mu        sync.Mutex
+	latency   time.Duration // moving average of successful probes and calls
+	failures  int
+	cooldown  time.Duration
+	openUntil time.Time
+}
+
+func (e *rpcEndpoint) available(now time.Time) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return !now.Before(e.openUntil)
+}
+
+// tripped tells whether the circuit of e has opened: it takes a successful probe before calls
+// go through e again.
+func (e *rpcEndpoint) tripped() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.failures >= breakerThreshold
+}
+
+// score favours heavy and fast rpcs.
+func (e *rpcEndpoint) score() float64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	latency := e.latency
+	if latency <= 0 {
+		latency = defaultRpcLatency
+	}
+	return float64(e.weight) / latency.Seconds()
+}
+
+func (e *rpcEndpoint) succeeded(latency time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.latency <= 0 {
+		e.latency = latency
+	} else {
+		e.latency = (e.latency*7 + latency*3) / 10
+	}
+	e.failures = 0
+	e.cooldown = 0
+	e.openUntil = time.Time{}
+}
+
+func (e *rpcEndpoint) failed(now time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.failures++
+	if e.failures < breakerThreshold {
+		return
+	}
+	if e.cooldown == 0 {
+		e.cooldown = breakerCooldown
+	} else {
+		e.cooldown = min(e.cooldown*2, breakerMaxCooldown)
+	}
+	e.openUntil = now.Add(e.cooldown)
+	log.Warnf("rpc %s failed %d times in a row, out of rotation for %s", e.url, e.failures, e.cooldown)
+}
+
+// candidates orders the rpcs to try: the ones in rotation first, picked at random in proportion
+// to their score, then the ones whose circuit is open, soonest to close first.
+func (c *EvmChain) candidates(now time.Time) []*rpcEndpoint {
+	type keyed struct {
+		e   *rpcEndpoint
+		key float64
+	}
+	var up, down []keyed
+	for _, e := range c.endpoints {
+		if e.available(now) {
+			// weighted random order (Efraimidis-Spirakis): key = u^(1/score)
+			up = append(up, keyed{e, math.Pow(rand.Float64(), 1/e.score())})
+		} else {
+			e.mu.Lock()
+			down = append(down, keyed{e, -float64(e.openUntil.UnixNano())})
+			e.mu.Unlock()
+		}
+	}
+	sort.Slice(up, func(i, j int) bool { return up[i].key > up[j].key })
+	sort.Slice(down, func(i, j int) bool { return down[i].key > down[j].key })
+
+	ordered := make([]*rpcEndpoint, 0, len(c.endpoints))
+	for _, k := range append(up, down...) {
+		ordered = append(ordered, k.e)
+	}
+	return ordered
+}
+
+var (
+	registryOnce   sync.Once
+	registryByID   map[uint64]*EvmChain
+	registryChains []*EvmChain
+)
+
+func loadRegistry() {
+	registryByID = map[uint64]*EvmChain{}
+	for _, cfg := range config.GetConfig().Chain {
+		if cfg.ChainID == 0 {
+			continue
+		}
+		if _, ok := registryByID[cfg.ChainID]; ok {
+			log.Errorf("chain id %d is configured twice, ignoring %s", cfg.ChainID, cfg.Name)
+			continue
+		}
+		c := &EvmChain{ChainConfig: cfg}
+		for _, r := range cfg.GetRpcMapper() {
+			c.endpoints = append(c.endpoints, &rpcEndpoint{url: r.Rpc, weight: max(r.Quote, 1)})
+		}
+		registryByID[cfg.ChainID] = c
+		registryChains = append(registryChains, c)
+	}
+}
+
+// LookupChain returns the registry entry of an EVM chain id, ErrUnknownChain when none is configured.
+func LookupChain(chainID uint64) (*EvmChain, error) {
+	registryOnce.Do(loadRegistry)
+	c, ok := registryByID[chainID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownChain, chainID)
+	}
+	return c, nil
+}
+
+// LookupChainByName returns the registry entry of the chain configured under name.
+func LookupChainByName(name string) (*EvmChain, error) {
+	registryOnce.Do(loadRegistry)
+	for _, c := range registryChains {
+		if c.Name == name {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownChain, name)
+}
+
+// Chains lists the registered EVM chains in config order.
+func Chains() []*EvmChain {
+	registryOnce.Do(loadRegistry)
+	return registryChains
+}
+
+// GetChainClient returns a client of a healthy rpc of chainID, in the order of candidates. Only
+// an rpc whose circuit has opened is probed first; callers report how their calls through the
+// client ended with ReportChainCall.
+func (cm *ClientManager) GetChainClient(chainID uint64) (*ethclient.Client, error) {
+	c, err := LookupChain(chainID)
+	if err != nil {
+		return nil, err
+	}
+	var lastErr error
+	for _, e := range c.candidates(time.Now()) {
+		client, err := cm.dial(e.url)
+		if err == nil {
+			if !e.tripped() {
+				return client, nil
+			}
+			start := time.Now()
+			ctx, cancel := context.WithTimeout(context.Background(), rpcProbeTimeout)
+			_, err = client.BlockNumber(ctx)
+			cancel()
+			if err == nil {
+				e.succeeded(time.Since(start))
+				return client, nil
+			}
+			cm.drop(e.url, client)
+		}
+		e.failed(time.Now())
+		lastErr = err
+		log.Warnf("rpc %s of chain %d failed: %v", e.url, chainID, err)
+	}
+	if lastErr == nil {
+		lastErr = errors.New("no rpc configured")
+	}
+	return nil, fmt.Errorf("no healthy rpc for chain %d: %w", chainID, lastErr)
+}
+
+// ReportChainCall tells the circuit breaker of the rpc behind client, as handed out by
+// GetChainClient, how a call through it ended: a failure that is the rpc's fault counts towards
+// taking it out of rotation, a success closes its circuit and its latency feeds the rpc's score.
+func (cm *ClientManager) ReportChainCall(chainID uint64, client *ethclient.Client, latency time.Duration, err error) {
+	c, lerr := LookupChain(chainID)
+	if lerr != nil {
+		return
+	}
+	e := cm.endpointOf(c, client)
+	if e == nil {
+		// dropped meanwhile, or not one of the chain's rpcs
+		return
+	}
+	switch {
+	case err == nil:
+		e.succeeded(latency)
+	case rpcFault(err):
+		e.failed(time.Now())
+		log.Warnf("rpc %s of chain %d failed: %v", e.url, chainID, err)
+	}
+}
+
+func (cm *ClientManager) endpointOf(c *EvmChain, client *ethclient.Client) *rpcEndpoint {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+	for _, e := range c.endpoints {
+		if cm.clients[e.url] == client {
+			return e
+		}
+	}
+	return nil
+}
+
+// rpcFault tells whether err, from a call through an rpc, is the rpc's fault. Not found and a
+// revert are answers of a working rpc, a canceled context is the caller's doing.
+func rpcFault(err error) bool {
+	if errors.Is(err, ethereum.NotFound) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var de rpc.DataError
+	if errors.As(err, &de) && de.ErrorData() != nil {
+		return false
+	}
+	var re rpc.Error
+	return !errors.As(err, &re) || re.ErrorCode() != revertErrorCode
+}
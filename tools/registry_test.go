@@ -0,0 +1,110 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+)
+
+func TestRpcCircuitBreaker(t *testing.T) {
+	now := time.Now()
+	e := &rpcEndpoint{url: "a", weight: 1}
+	for i := 0; i < breakerThreshold-1; i++ {
+		e.failed(now)
+	}
+	if !e.available(now) {
+		t.Fatal("opened before the threshold")
+	}
+	e.failed(now)
+	if e.available(now) || !e.available(now.Add(breakerCooldown)) {
+		t.Fatal("breaker did not open for one cooldown")
+	}
+	// a failed trial after the cooldown reopens it for twice as long
+	e.failed(now.Add(breakerCooldown))
+	if e.available(now.Add(2*breakerCooldown)) || !e.available(now.Add(3*breakerCooldown)) {
+		t.Fatal("breaker did not back off")
+	}
+	e.succeeded(50 * time.Millisecond)
+	if !e.available(now) {
+		t.Fatal("success did not close the breaker")
+	}
+}
+
+func TestRpcCandidates(t *testing.T) {
+	now := time.Now()
+	down := &rpcEndpoint{url: "down", weight: 100}
+	for i := 0; i < breakerThreshold; i++ {
+		down.failed(now)
+	}
+	fast := &rpcEndpoint{url: "fast", weight: 1}
+	fast.succeeded(10 * time.Millisecond)
+	slow := &rpcEndpoint{url: "slow", weight: 1}
+	slow.succeeded(2 * time.Second)
+	c := &EvmChain{endpoints: []*rpcEndpoint{down, slow, fast}}
+
+	firstFast := 0
+	for i := 0; i < 200; i++ {
+		got := c.candidates(now)
+		if len(got) != 3 || got[2] != down {
+			t.Fatalf("open rpc not tried last: %v", got)
+		}
+		if got[0] == fast {
+			firstFast++
+		}
+	}
+	if firstFast < 150 {
+		t.Fatalf("fast rpc first only %d/200 times", firstFast)
+	}
+}
+
+type rpcTestError struct {
+	code int
+	data interface{}
+}
+
+func (e rpcTestError) Error() string          { return fmt.Sprintf("rpc error %d", e.code) }
+func (e rpcTestError) ErrorCode() int         { return e.code }
+func (e rpcTestError) ErrorData() interface{} { return e.data }
+
+func TestRpcFault(t *testing.T) {
+	for _, tc := range []struct {
+		err   error
+		fault bool
+	}{
+		{ethereum.NotFound, false},
+		{fmt.Errorf("get receipt: %w", ethereum.NotFound), false},
+		{context.Canceled, false},
+		{rpcTestError{code: revertErrorCode}, false},
+		{rpcTestError{code: -32000, data: "0x08c379a0"}, false},
+		{rpcTestError{code: -32005}, true}, // e.g. eth_getLogs over the range limit
+		{context.DeadlineExceeded, true},
+		{errors.New("connection refused"), true},
+	} {
+		if got := rpcFault(tc.err); got != tc.fault {
+			t.Errorf("rpcFault(%v) = %v, want %v", tc.err, got, tc.fault)
+		}
+	}
+}
+
+func TestRpcTripped(t *testing.T) {
+	now := time.Now()
+	e := &rpcEndpoint{url: "a", weight: 1}
+	for i := 0; i < breakerThreshold-1; i++ {
+		e.failed(now)
+	}
+	if e.tripped() {
+		t.Fatal("probed before the threshold")
+	}
+	e.failed(now)
+	if !e.tripped() {
+		t.Fatal("not probed once the breaker opened")
+	}
+	e.succeeded(80 * time.Millisecond)
+	if e.tripped() || !e.available(now) || e.latency != 80*time.Millisecond {
+		t.Fatal("a successful call did not close the breaker and time the rpc")
+	}
+}